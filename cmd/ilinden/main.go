@@ -17,6 +17,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/ilijajolevski/ilinden/internal/api"
 	"github.com/ilijajolevski/ilinden/internal/cache"
@@ -91,11 +92,19 @@ func main() {
 	var cacheImpl cache.Cache
 	if cfg.Cache.Enabled {
 		cacheOpts := cache.MemoryOptions{
-			MaxSize:   cfg.Cache.MaxSize,
-			ShardSize: cfg.Cache.ShardCount,
+			MaxSize:    cfg.Cache.MaxSize,
+			MaxBytes:   cfg.Cache.MaxBytes,
+			ShardSize:  cfg.Cache.ShardCount,
+			AutoShards: cfg.Cache.AutoShards,
 		}
-		cacheImpl = cache.NewMemoryWithOptions(cacheOpts)
-		logger.Info("Initialized memory cache", "maxSize", cfg.Cache.MaxSize, "shards", cfg.Cache.ShardCount)
+		memoryCache := cache.NewMemoryWithOptions(cacheOpts)
+		shards, perShard := memoryCache.Layout()
+		cacheImpl = memoryCache
+		logger.Info("Initialized memory cache",
+			"maxSize", strconv.Itoa(cfg.Cache.MaxSize),
+			"shards", strconv.Itoa(shards),
+			"itemsPerShard", strconv.Itoa(perShard),
+			"autoShards", strconv.FormatBool(cfg.Cache.AutoShards))
 	} else {
 		logger.Info("Cache disabled")
 	}
@@ -104,6 +113,8 @@ func main() {
 	var redisTracker *redis.Tracker
 	if cfg.Redis.Enabled {
 		// In a real implementation, this would initialize the Redis client
+		redisTracker = redis.NewTracker(&cfg.Redis, logger)
+		redisTracker.StartCleanupWorker()
 		logger.Info("Redis tracking enabled")
 	} else {
 		logger.Info("Redis tracking disabled")
@@ -122,41 +133,186 @@ func main() {
 	})
 
 	// Setup middleware chain
-	chain := middleware.NewChain(
-		middleware.Recovery(logger),
-		middleware.Logging(logger),
-		middleware.Metrics(metrics),
-	)
+	ipFilter, err := middleware.IPFilter(middleware.IPFilterOptions{
+		Allow:          cfg.Server.AllowedCIDRs,
+		Deny:           cfg.Server.DeniedCIDRs,
+		TrustedProxies: cfg.Server.TrustedProxies,
+	})
+	if err != nil {
+		log.Fatalf("Invalid IP filter configuration: %v", err)
+	}
+	adminAllow, adminDeny := cfg.AdminCIDRs()
+	adminIPFilter, err := middleware.IPFilter(middleware.IPFilterOptions{
+		Allow:          adminAllow,
+		Deny:           adminDeny,
+		TrustedProxies: cfg.Server.TrustedProxies,
+	})
+	if err != nil {
+		log.Fatalf("Invalid admin IP filter configuration: %v", err)
+	}
+
+	// Every route group shares recovery; each adds the middleware it needs
+	base := middleware.NewChain().
+		Require(middleware.DefaultOrder...).
+		AppendNamed(middleware.NameRecovery, middleware.Recovery(logger))
+	proxyChain := middleware.NewChain().
+		AppendNamed(middleware.NameLogging, middleware.Logging(logger, cfg.TokenParams()...)).
+		AppendNamed(middleware.NameMetrics, middleware.Metrics(metrics)).
+		AppendNamed(middleware.NameIPFilter, ipFilter).
+		AppendNamed(middleware.NameHeaders, middleware.ResponseHeaders(cfg.Server.ResponseHeaders))
+	if cfg.Server.EnableCompression {
+		proxyChain = proxyChain.AppendNamed(middleware.NameCompression, middleware.Compression(middleware.CompressionOptions{
+			MinSize:      cfg.Server.CompressMinBytes,
+			ContentTypes: cfg.Server.CompressTypes,
+			Brotli:       cfg.Server.EnableBrotli,
+		}))
+	}
+	adminChain := middleware.NewChain().
+		AppendNamed(middleware.NameLogging, middleware.Logging(logger, cfg.TokenParams()...)).
+		AppendNamed(middleware.NameIPFilter, adminIPFilter).
+		AppendNamed(middleware.NameAuth, middleware.AdminAuth(cfg.Admin.Token))
+	groups := middleware.NewGroups(base).
+		Extend(middleware.GroupProxy, proxyChain).
+		Extend(middleware.GroupAdmin, adminChain)
+	route := func(group string, handler http.Handler) http.Handler {
+		built, err := groups.Build(group, handler)
+		if err != nil {
+			log.Fatalf("Invalid middleware chain: %v", err)
+		}
+		return built
+	}
 
 	// Register routes
-	mux.Handle("/", chain.Then(proxyHandler))
+	mux.Handle("/", route(middleware.GroupProxy, proxyHandler))
 
 	// Register health check endpoint
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/health", route(middleware.GroupHealth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		api.WriteResponse(w, http.StatusOK, api.NewResponse(true, "OK", nil))
-	})
+	})))
+
+	// Register readiness endpoint, optionally gated on origin reachability
+	readyChecks := proxyHandler.ReadinessChecks()
+	var originHealth *proxy.OriginHealth
+	if cfg.Origin.HealthCheck.Enabled {
+		originHealth, err = proxy.NewOriginHealth(cfg.Origin.HealthCheck, cfg.Origin.BaseURL, logger)
+		if err != nil {
+			log.Fatalf("Invalid origin health check configuration: %v", err)
+		}
+		originHealth.Start()
+		readyChecks["origin"] = originHealth.Ready
+	}
+	mux.Handle("/readyz", route(middleware.GroupHealth, api.ReadyHandler(readyChecks)))
+
+	// Publish cache statistics alongside the other metrics
+	var cacheStats *cache.StatsReporter
+	if cfg.Metrics.Enabled && cacheImpl != nil {
+		cacheStats = cache.NewStatsReporter(cacheImpl, metrics, cfg.Metrics.CacheStatsInterval)
+		cacheStats.Start()
+	}
+
+	// Sample runtime and process metrics in the background
+	var systemStats *telemetry.SystemCollector
+	if cfg.Metrics.Enabled && cfg.Metrics.CollectSystem {
+		systemStats = telemetry.NewSystemCollector(metrics, cfg.Metrics.SystemInterval)
+		systemStats.Start()
+	}
+
+	// Limit request bodies on every route of both listeners
+	bodyLimit := middleware.MaxBodySize(int64(cfg.Server.MaxRequestBodyMB) << 20)
+
+	// Internal listener for metrics and debugging, kept off the public port
+	var internalMux *http.ServeMux
+	var internalSrv *server.Server
+	if cfg.Metrics.Enabled && cfg.Metrics.Address != "" {
+		internalMux = http.NewServeMux()
+		internalSrv = server.New(server.NewInternalOptions(cfg, cfg.Metrics.Address), bodyLimit(internalMux))
+	}
 
 	// Register metrics endpoint if enabled
 	if cfg.Metrics.Enabled {
-		mux.HandleFunc(cfg.Metrics.Path, func(w http.ResponseWriter, r *http.Request) {
+		metricsHandler := func(w http.ResponseWriter, r *http.Request) {
 			// This would typically expose Prometheus metrics
 			// For our simple implementation, we'll just return some basic stats
+			// Refresh runtime gauges on every scrape
+			if cfg.Metrics.CollectSystem {
+				telemetry.RecordRuntime(metrics)
+			}
 			if m, ok := metrics.(*telemetry.SimpleMetrics); ok {
 				api.WriteJSON(w, http.StatusOK, m.DumpMetrics())
 			} else {
 				api.WriteResponse(w, http.StatusOK, api.NewResponse(true, "Metrics not available", nil))
 			}
-		})
+		}
+		
+		if internalMux != nil {
+			internalMux.Handle(cfg.Metrics.Path, route(middleware.GroupMetrics, http.HandlerFunc(metricsHandler)))
+		} else {
+			mux.Handle(cfg.Metrics.Path, route(middleware.GroupMetrics, http.HandlerFunc(metricsHandler)))
+		}
+	}
+
+	// Register admin endpoints on the internal listener only
+	adminRoute := func(handler http.Handler) http.Handler {
+		return route(middleware.GroupAdmin, handler)
+	}
+	if internalMux != nil {
+		introspector := proxyHandler.TokenValidator()
+		internalMux.Handle("/admin/token", adminRoute(api.TokenIntrospectHandler(func(token, path string) interface{} {
+			return introspector.Introspect(token, path)
+		})))
+		internalMux.Handle("/admin/cache/keys", adminRoute(api.CacheKeysHandler(func(limit int) (interface{}, bool) {
+			if cacheImpl == nil {
+				return nil, false
+			}
+			return cache.Sample(cacheImpl, limit, cfg.TokenParams())
+		})))
+		internalMux.Handle("/admin/status", adminRoute(api.StatusReportHandler(statusSections(cacheImpl, redisTracker, proxyHandler, originHealth))))
+	}
+
+	// Register profiling endpoints on the internal listener only
+	if cfg.Debug.Pprof {
+		if internalMux != nil {
+			internalMux.Handle("/debug/pprof/", adminRoute(api.PprofHandler()))
+			logger.Info("Profiling endpoints enabled", "address", cfg.Metrics.Address)
+		} else {
+			logger.Warn("Profiling requires a separate metrics listener, not enabling")
+		}
 	}
 
 	// Create and configure the server
 	srv := server.New(
 		server.NewOptionsFromConfig(cfg),
-		mux,
+		bodyLimit(mux),
 	)
 
-	// Setup graceful shutdown
-	shutdown := server.NewGracefulShutdown(srv, cfg.Server.ShutdownTimeout)
+	// Setup graceful shutdown; once the servers drain, components close in
+	// phase order: flush tracking, close pools and caches, stop workers
+	lifecycle := server.NewLifecycle()
+	if redisTracker != nil {
+		lifecycle.Add(server.PhaseFlush, "player tracker", closeFunc(redisTracker.Stop))
+	}
+	lifecycle.Add(server.PhasePools, "origin connections", proxyHandler.Close)
+	if cacheImpl != nil {
+		lifecycle.Add(server.PhasePools, "cache", func() error {
+			logger.Info("Cleaning up cache")
+			cacheImpl.Clear()
+			return nil
+		})
+	}
+	if originHealth != nil {
+		lifecycle.Add(server.PhaseWorkers, "origin health checks", closeFunc(originHealth.Stop))
+	}
+	if cacheStats != nil {
+		lifecycle.Add(server.PhaseWorkers, "cache stats", closeFunc(cacheStats.Stop))
+	}
+	if systemStats != nil {
+		lifecycle.Add(server.PhaseWorkers, "system stats", closeFunc(systemStats.Stop))
+	}
+
+	shutdown := server.NewGracefulShutdown(srv, cfg.Server.ShutdownTimeout).WithLifecycle(lifecycle)
+	if internalSrv != nil {
+		shutdown.WithServers(internalSrv)
+	}
 
 	// Start the server
 	logger.Info("Starting server", "address", cfg.GetAddress())
@@ -164,15 +320,60 @@ func main() {
 		logger.Error("Failed to start server", "error", err.Error())
 		os.Exit(1)
 	}
+	if internalSrv != nil {
+		logger.Info("Starting internal server", "address", cfg.Metrics.Address)
+		if err := internalSrv.Start(); err != nil {
+			logger.Error("Failed to start internal server", "error", err.Error())
+			os.Exit(1)
+		}
+	}
 
 	// Wait for shutdown signal
 	shutdown.WaitForShutdown()
 
-	// Perform any cleanup
+	logger.Info("Server shutdown complete")
+}
+
+// statusSections builds the sections of the /admin/status document from the
+// components that are enabled
+func statusSections(cacheImpl cache.Cache, tracker *redis.Tracker, handler *proxy.Handler, originHealth *proxy.OriginHealth) map[string]func() interface{} {
+	sections := map[string]func() interface{}{
+		"breakers": func() interface{} { return handler.UpstreamStatus() },
+	}
 	if cacheImpl != nil {
-		logger.Info("Cleaning up cache")
-		cacheImpl.Clear()
+		sections["cache"] = func() interface{} {
+			stats := cacheImpl.Stats()
+			return map[string]interface{}{
+				"hits":        stats.Hits,
+				"misses":      stats.Misses,
+				"hitRatio":    stats.HitRatio(),
+				"size":        stats.Size,
+				"evictions":   stats.Evictions,
+				"expirations": stats.Expirations,
+				"deletes":     stats.Deletes,
+			}
+		}
 	}
+	if tracker != nil {
+		sections["players"] = func() interface{} {
+			stats := tracker.Stats()
+			return map[string]interface{}{
+				"active":   stats.ActiveSessions,
+				"requests": stats.Requests,
+				"activity": stats.Activity,
+			}
+		}
+	}
+	if originHealth != nil {
+		sections["originHealth"] = func() interface{} { return originHealth.Status() }
+	}
+	return sections
+}
 
-	logger.Info("Server shutdown complete")
+// closeFunc adapts a Stop method without an error to a lifecycle close
+func closeFunc(stop func()) func() error {
+	return func() error {
+		stop()
+		return nil
+	}
 }
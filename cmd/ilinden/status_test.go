@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/middleware"
+	"github.com/ilijajolevski/ilinden/internal/proxy"
+	"github.com/ilijajolevski/ilinden/internal/redis"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+func TestStatusReport(t *testing.T) {
+	cfg := &config.Config{}
+	config.SetDefaults(cfg)
+	cfg.Origin.BaseURL = "https://origin.example.com"
+	logger := telemetry.NewLogger("error", "", "stdout")
+
+	cacheImpl := cache.NewMemory()
+	tracker := redis.NewTracker(&cfg.Redis, logger)
+	handler := proxy.NewHandler(proxy.HandlerOptions{Config: cfg, Cache: cacheImpl, Logger: logger, Metrics: telemetry.NewMetrics()})
+	originHealth, err := proxy.NewOriginHealth(cfg.Origin.HealthCheck, cfg.Origin.BaseURL, logger)
+	if err != nil {
+		t.Fatalf("NewOriginHealth: %v", err)
+	}
+
+	report := middleware.AdminAuth("secret")(api.StatusReportHandler(statusSections(cacheImpl, tracker, handler, originHealth)))
+	fetch := func(authorization string) (int, map[string]json.RawMessage) {
+		req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rec := httptest.NewRecorder()
+		report.ServeHTTP(rec, req)
+		var doc map[string]json.RawMessage
+		json.Unmarshal(rec.Body.Bytes(), &doc)
+		return rec.Code, doc
+	}
+
+	if code, _ := fetch(""); code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated status %d, want 401", code)
+	}
+
+	_, before := fetch("Bearer secret")
+	tracker.TrackPlayer("player-1", "/live/index.m3u8", "test")
+	cacheImpl.Set("k", []byte("v"), time.Minute)
+	cacheImpl.Get("k")
+	code, after := fetch("Bearer secret")
+	if code != http.StatusOK {
+		t.Fatalf("status %d, want 200", code)
+	}
+
+	for _, section := range []string{"status", "uptime", "cache", "players", "breakers", "originHealth"} {
+		if _, ok := after[section]; !ok {
+			t.Errorf("document lacks %s", section)
+		}
+	}
+
+	// Values are read on every request
+	var players struct{ Active, Requests, Activity int }
+	var stats struct{ Hits, Size int64 }
+	json.Unmarshal(after["players"], &players)
+	json.Unmarshal(after["cache"], &stats)
+	if players.Active != 1 || players.Requests != 1 || players.Activity != 1 {
+		t.Errorf("players %s, want one active player, request and activity (before: %s)", after["players"], before["players"])
+	}
+	if stats.Hits != 1 || stats.Size != 1 {
+		t.Errorf("cache stats %s, want one hit and one entry (before: %s)", after["cache"], before["cache"])
+	}
+
+	var breakers []proxy.UpstreamStatus
+	json.Unmarshal(after["breakers"], &breakers)
+	if len(breakers) != 1 || breakers[0].Breaker == "" {
+		t.Errorf("breakers %s, want the default origin", after["breakers"])
+	}
+}
+
+func TestStatusSectionsOmitDisabledComponents(t *testing.T) {
+	cfg := &config.Config{}
+	config.SetDefaults(cfg)
+	cfg.Origin.BaseURL = "https://origin.example.com"
+	handler := proxy.NewHandler(proxy.HandlerOptions{Config: cfg, Cache: cache.NewMemory(), Logger: telemetry.NewLogger("error", "", "stdout"), Metrics: telemetry.NewMetrics()})
+
+	sections := statusSections(nil, nil, handler, nil)
+	if len(sections) != 1 || sections["breakers"] == nil {
+		t.Errorf("sections %v, want breakers only", sections)
+	}
+}
@@ -2,4 +2,7 @@ module github.com/ilijajolevski/ilinden
 
 go 1.21.0
 
-require gopkg.in/yaml.v3 v3.0.1
+require (
+	github.com/andybalholm/brotli v1.1.1
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -6,13 +6,16 @@
 // - Configuration reporting
 // - Health checks
 // - Player statistics
+// - Token introspection
+// - Cache key sampling
+// - Combined status reports for dashboards
 
 package api
 
 import (
-	"encoding/json"
 	"net/http"
 	"runtime"
+	"strconv"
 	"time"
 )
 
@@ -31,6 +34,25 @@ func StatusHandler() http.HandlerFunc {
 	}
 }
 
+// StatusReportHandler returns a handler for the /admin/status endpoint,
+// combining the process status with the named sections in one document.
+// Sections are evaluated on every request so values are live.
+func StatusReportHandler(sections map[string]func() interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := map[string]interface{}{
+			"status":     "ok",
+			"timestamp":  time.Now().Unix(),
+			"uptime":     time.Since(startTime).String(),
+			"goroutines": runtime.NumGoroutine(),
+		}
+		for name, section := range sections {
+			report[name] = section()
+		}
+		
+		WriteJSON(w, http.StatusOK, report)
+	}
+}
+
 // HealthHandler returns a handler for the /health endpoint
 func HealthHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -42,6 +64,33 @@ func HealthHandler() http.HandlerFunc {
 	}
 }
 
+// ReadyHandler returns a handler for the /readyz endpoint. Each check is
+// reported by name; the endpoint returns 503 if any check is not ready.
+func ReadyHandler(checks map[string]func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		results := make(map[string]string, len(checks))
+		for name, ready := range checks {
+			if ready() {
+				results[name] = "ok"
+			} else {
+				results[name] = "failing"
+				status = http.StatusServiceUnavailable
+			}
+		}
+		
+		readiness := map[string]interface{}{
+			"status": "ready",
+			"checks": results,
+		}
+		if status != http.StatusOK {
+			readiness["status"] = "not_ready"
+		}
+		
+		WriteJSON(w, status, readiness)
+	}
+}
+
 // ConfigHandler returns a handler for the /config endpoint
 func ConfigHandler(configGetter func() interface{}) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -76,6 +125,60 @@ func CacheClearHandler(clearFunc func() error) http.HandlerFunc {
 	}
 }
 
+// maxCacheKeys bounds the number of entries listed by /admin/cache/keys
+const maxCacheKeys = 1000
+
+// CacheKeysHandler returns a handler for the /admin/cache/keys endpoint,
+// listing a sample of cached entries. The ?limit= parameter defaults to 100
+// and is capped at maxCacheKeys.
+func CacheKeysHandler(sample func(limit int) (interface{}, bool)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := 100
+		if raw := r.URL.Query().Get("limit"); raw != "" {
+			parsed, err := strconv.Atoi(raw)
+			if err != nil || parsed < 0 {
+				WriteError(w, NewError("Invalid limit", "invalid_limit", http.StatusBadRequest))
+				return
+			}
+			limit = parsed
+		}
+		if limit > maxCacheKeys {
+			limit = maxCacheKeys
+		}
+		
+		keys, ok := sample(limit)
+		if !ok {
+			WriteError(w, NewError("Cache cannot list its keys", "not_supported", http.StatusNotImplemented))
+			return
+		}
+		
+		WriteJSON(w, http.StatusOK, map[string]interface{}{
+			"limit": limit,
+			"keys":  keys,
+		})
+	}
+}
+
+// TokenIntrospectHandler returns a handler for the /admin/token endpoint.
+// The token and optional stream path are posted as form values so that
+// tokens stay out of URLs and access logs.
+func TokenIntrospectHandler(introspect func(token, path string) interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			WriteError(w, NewError("Method not allowed", "method_not_allowed", http.StatusMethodNotAllowed))
+			return
+		}
+		
+		token := r.PostFormValue("token")
+		if token == "" {
+			WriteError(w, NewError("Missing token", "missing_token", http.StatusBadRequest))
+			return
+		}
+		
+		WriteJSON(w, http.StatusOK, introspect(token, r.PostFormValue("path")))
+	}
+}
+
 // PlayersHandler returns a handler for the /players endpoint
 func PlayersHandler(playersGetter func() interface{}) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,25 @@
+// Profiling endpoints
+//
+// Exposes net/http/pprof for production diagnostics:
+// - Index, cmdline, profile, symbol and trace handlers
+// - Named runtime profiles (heap, goroutine, ...)
+// - Meant for internal listeners behind admin auth only
+
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// PprofHandler returns a handler serving the pprof endpoints under
+// /debug/pprof/
+func PprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
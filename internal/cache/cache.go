@@ -2,6 +2,7 @@
 //
 // Generic cache interface:
 // - Get/Set/Delete operations
+// - Batch operations
 // - TTL support
 // - Stats collection
 // - Key value store abstraction
@@ -20,6 +21,12 @@ type Cache interface {
 	// Set stores a value in the cache with an optional TTL
 	Set(key Key, value interface{}, ttl time.Duration)
 	
+	// GetMulti retrieves several values at once, returning only the keys found
+	GetMulti(keys []Key) map[Key]interface{}
+	
+	// SetMulti stores several values at once, each with its own TTL
+	SetMulti(items map[Key]ValueTTL)
+	
 	// Delete removes a value from the cache
 	Delete(key Key)
 	
@@ -33,13 +40,31 @@ type Cache interface {
 	Stats() Stats
 }
 
+// ValueTTL is a value to store with its TTL in a batch operation
+type ValueTTL struct {
+	Value interface{}
+	TTL   time.Duration
+}
+
 // Stats represents cache performance statistics
 type Stats struct {
-	Hits        uint64
-	Misses      uint64
-	Size        int
-	Evictions   uint64
-	Expirations uint64
+	Hits          uint64
+	Misses        uint64
+	Size          int
+	Evictions     uint64 // Removed by LRU to stay within MaxSize
+	ByteEvictions uint64 // Removed by LRU to stay within MaxBytes
+	Expirations   uint64 // Removed after their TTL elapsed
+	Deletes       uint64 // Removed by an explicit Delete
+}
+
+// HitRatio returns the fraction of lookups that were hits, or 0 before
+// any lookups
+func (s Stats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(total)
 }
 
 // Factory defines a function that creates a new cache
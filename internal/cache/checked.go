@@ -0,0 +1,51 @@
+// Error-reporting cache access
+//
+// Optional error reporting for cache backends:
+// - Distinguishes a miss from a backend failure
+// - Lets callers degrade to the origin when the backend is down
+// - Fallback to the context interface for caches that cannot fail
+
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrBackendUnavailable is returned by cache backends that cannot reach
+// their store
+var ErrBackendUnavailable = errors.New("cache backend unavailable")
+
+// CheckedCache is implemented by caches whose operations can fail, such as
+// remote backends, so that failures are not mistaken for misses
+type CheckedCache interface {
+	Cache
+
+	// GetChecked retrieves a value, reporting backend failures separately
+	// from misses
+	GetChecked(ctx context.Context, key Key) (interface{}, bool, error)
+
+	// SetChecked stores a value, reporting backend failures
+	SetChecked(ctx context.Context, key Key, value interface{}, ttl time.Duration) error
+}
+
+// GetChecked retrieves a value and reports whether the backend failed. Caches
+// that cannot fail are read with GetContext and never return an error.
+func GetChecked(ctx context.Context, c Cache, key Key) (interface{}, bool, error) {
+	if cc, ok := c.(CheckedCache); ok {
+		return cc.GetChecked(ctx, key)
+	}
+	value, found := GetContext(ctx, c, key)
+	return value, found, nil
+}
+
+// SetChecked stores a value and reports whether the backend failed. Caches
+// that cannot fail are written with SetContext and never return an error.
+func SetChecked(ctx context.Context, c Cache, key Key, value interface{}, ttl time.Duration) error {
+	if cc, ok := c.(CheckedCache); ok {
+		return cc.SetChecked(ctx, key, value, ttl)
+	}
+	SetContext(ctx, c, key, value, ttl)
+	return nil
+}
@@ -0,0 +1,80 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCheckedOperations(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name      string
+		cache     func() Cache
+		ctx       context.Context
+		wantErr   error
+		wantFound bool
+	}{
+		{name: "memory", cache: func() Cache { return NewMemory() }, ctx: context.Background(), wantFound: true},
+		{name: "memory canceled", cache: func() Cache { return NewMemory() }, ctx: canceled},
+		{name: "healthy backend", cache: func() Cache { return NewFake() }, ctx: context.Background(), wantFound: true},
+		{
+			name: "failing backend",
+			cache: func() Cache {
+				c := NewFake()
+				c.FailWith(ErrBackendUnavailable)
+				return c
+			},
+			ctx:     context.Background(),
+			wantErr: ErrBackendUnavailable,
+		},
+		{name: "canceled backend", cache: func() Cache { return NewFake() }, ctx: canceled, wantErr: context.Canceled},
+		{
+			name: "failing namespaced backend",
+			cache: func() Cache {
+				c := NewFake()
+				c.FailWith(ErrBackendUnavailable)
+				return WithNamespace(c, "v1")
+			},
+			ctx:     context.Background(),
+			wantErr: ErrBackendUnavailable,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.cache()
+			c.Set("stored", "v", time.Minute)
+
+			if err := SetChecked(tt.ctx, c, "new", "v", time.Minute); !errors.Is(err, tt.wantErr) {
+				t.Errorf("SetChecked: got %v, want %v", err, tt.wantErr)
+			}
+			value, found, err := GetChecked(tt.ctx, c, "stored")
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("GetChecked: got %v, want %v", err, tt.wantErr)
+			}
+			if found != tt.wantFound || (found && value != "v") {
+				t.Errorf("GetChecked found %v (%v), want %v", found, value, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestFakeCacheRecovers(t *testing.T) {
+	c := NewFake()
+	c.FailWith(ErrBackendUnavailable)
+	if err := c.SetChecked(context.Background(), "k", "v", 0); err == nil {
+		t.Fatal("failing backend stored a value")
+	}
+
+	c.FailWith(nil)
+	if err := c.SetChecked(context.Background(), "k", "v", 0); err != nil {
+		t.Fatalf("SetChecked after recovery: %v", err)
+	}
+	if _, found, err := c.GetChecked(context.Background(), "k"); err != nil || !found {
+		t.Fatalf("GetChecked after recovery: found %v, err %v", found, err)
+	}
+}
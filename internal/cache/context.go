@@ -0,0 +1,66 @@
+// Context-aware cache access
+//
+// Optional context support for cache backends:
+// - Cancellation and deadlines for remote backends
+// - Trace propagation
+// - Fallback to the plain interface for local caches
+
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// ContextCache is implemented by caches that honor request contexts, such
+// as remote backends where an operation can be canceled or traced
+type ContextCache interface {
+	Cache
+
+	// GetCtx retrieves a value, treating a canceled context as a miss
+	GetCtx(ctx context.Context, key Key) (interface{}, bool)
+
+	// SetCtx stores a value unless the context is canceled
+	SetCtx(ctx context.Context, key Key, value interface{}, ttl time.Duration)
+
+	// DeleteCtx removes a value unless the context is canceled
+	DeleteCtx(ctx context.Context, key Key)
+}
+
+// GetContext retrieves a value using the context if the cache supports it.
+// For other caches a canceled context is treated as a miss.
+func GetContext(ctx context.Context, c Cache, key Key) (interface{}, bool) {
+	if cc, ok := c.(ContextCache); ok {
+		return cc.GetCtx(ctx, key)
+	}
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	return c.Get(key)
+}
+
+// SetContext stores a value using the context if the cache supports it.
+// For other caches nothing is stored once the context is canceled.
+func SetContext(ctx context.Context, c Cache, key Key, value interface{}, ttl time.Duration) {
+	if cc, ok := c.(ContextCache); ok {
+		cc.SetCtx(ctx, key, value, ttl)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	c.Set(key, value, ttl)
+}
+
+// DeleteContext removes a value using the context if the cache supports it.
+// For other caches nothing is removed once the context is canceled.
+func DeleteContext(ctx context.Context, c Cache, key Key) {
+	if cc, ok := c.(ContextCache); ok {
+		cc.DeleteCtx(ctx, key)
+		return
+	}
+	if ctx.Err() != nil {
+		return
+	}
+	c.Delete(key)
+}
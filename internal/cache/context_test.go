@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestContextOperations(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	caches := map[string]func() Cache{
+		"memory":          func() Cache { return NewMemory() },
+		"context backend": func() Cache { return NewFake() },
+		"namespace":       func() Cache { return WithNamespace(NewFake(), "v1") },
+	}
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		wantFound bool
+	}{
+		{"live context", context.Background(), true},
+		{"canceled context", canceled, false},
+	}
+
+	for kind, newCache := range caches {
+		for _, tt := range tests {
+			t.Run(kind+"/"+tt.name, func(t *testing.T) {
+				c := newCache()
+
+				// A canceled set stores nothing
+				SetContext(tt.ctx, c, "set", "v", time.Minute)
+				if _, found := c.Get("set"); found != tt.wantFound {
+					t.Errorf("SetContext stored = %v, want %v", found, tt.wantFound)
+				}
+
+				// A canceled get is a miss, even for a stored key
+				c.Set("get", "v", time.Minute)
+				if _, found := GetContext(tt.ctx, c, "get"); found != tt.wantFound {
+					t.Errorf("GetContext found = %v, want %v", found, tt.wantFound)
+				}
+
+				// A canceled delete leaves the entry in place
+				DeleteContext(tt.ctx, c, "get")
+				if _, found := c.Get("get"); found == tt.wantFound {
+					t.Errorf("DeleteContext removed = %v, want %v", !found, tt.wantFound)
+				}
+			})
+		}
+	}
+}
+
+func TestContextCacheIsUsedWhenImplemented(t *testing.T) {
+	var c Cache = NewFake()
+	if _, ok := c.(ContextCache); !ok {
+		t.Fatal("fake cache does not implement ContextCache")
+	}
+	if _, ok := WithNamespace(c, "v1").(ContextCache); !ok {
+		t.Fatal("namespaced cache does not implement ContextCache")
+	}
+}
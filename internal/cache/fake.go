@@ -0,0 +1,246 @@
+// Fake cache for tests
+//
+// Deterministic in-memory cache for consumers' tests:
+// - Unbounded, no eviction
+// - Entries expire only when the fake clock is advanced
+// - Injectable backend failures, honoring canceled contexts like a remote
+//   backend would
+// - Implements Cache, Inspector, ContextCache and CheckedCache
+
+package cache
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// FakeCache is a Cache test double driven by a fake clock. Entries with a
+// TTL expire once Advance has moved the clock past their lifetime; nothing
+// expires in real time and nothing is evicted.
+type FakeCache struct {
+	mu      sync.Mutex
+	now     time.Time
+	entries map[Key]fakeEntry
+	stats   Stats
+	err     error // Returned by checked operations while set
+}
+
+// fakeEntry is a value stored in a FakeCache
+type fakeEntry struct {
+	value  interface{}
+	expiry time.Time // Zero if the entry never expires
+}
+
+// NewFake creates an empty fake cache
+func NewFake() *FakeCache {
+	return &FakeCache{
+		now:     time.Unix(0, 0),
+		entries: make(map[Key]fakeEntry),
+	}
+}
+
+// Advance moves the fake clock forward, expiring entries whose TTL has
+// elapsed
+func (c *FakeCache) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+	for key, entry := range c.entries {
+		if c.expired(entry) {
+			delete(c.entries, key)
+			c.stats.Expirations++
+		}
+	}
+}
+
+// FailWith makes checked operations fail with err, as a remote backend
+// that lost its store would; nil restores normal operation. The plain and
+// context methods keep working so tests can inspect the contents.
+func (c *FakeCache) FailWith(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.err = err
+}
+
+// GetCtx retrieves a value, treating a canceled context as a miss
+func (c *FakeCache) GetCtx(ctx context.Context, key Key) (interface{}, bool) {
+	if ctx.Err() != nil {
+		return nil, false
+	}
+	return c.Get(key)
+}
+
+// SetCtx stores a value unless the context is canceled
+func (c *FakeCache) SetCtx(ctx context.Context, key Key, value interface{}, ttl time.Duration) {
+	if ctx.Err() != nil {
+		return
+	}
+	c.Set(key, value, ttl)
+}
+
+// DeleteCtx removes a value unless the context is canceled
+func (c *FakeCache) DeleteCtx(ctx context.Context, key Key) {
+	if ctx.Err() != nil {
+		return
+	}
+	c.Delete(key)
+}
+
+// GetChecked retrieves a value, failing with the injected error or the
+// context's error
+func (c *FakeCache) GetChecked(ctx context.Context, key Key) (interface{}, bool, error) {
+	if err := c.check(ctx); err != nil {
+		return nil, false, err
+	}
+	value, found := c.Get(key)
+	return value, found, nil
+}
+
+// SetChecked stores a value, failing with the injected error or the
+// context's error
+func (c *FakeCache) SetChecked(ctx context.Context, key Key, value interface{}, ttl time.Duration) error {
+	if err := c.check(ctx); err != nil {
+		return err
+	}
+	c.Set(key, value, ttl)
+	return nil
+}
+
+// check returns the error a checked operation fails with, if any
+func (c *FakeCache) check(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.err
+}
+
+// Get retrieves a value from the cache
+func (c *FakeCache) Get(key Key) (interface{}, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.get(key)
+}
+
+// Set stores a value in the cache; a TTL of zero or less never expires
+func (c *FakeCache) Set(key Key, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.set(key, value, ttl)
+}
+
+// GetMulti retrieves several values at once, returning only the keys found
+func (c *FakeCache) GetMulti(keys []Key) map[Key]interface{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	found := make(map[Key]interface{}, len(keys))
+	for _, key := range keys {
+		if value, ok := c.get(key); ok {
+			found[key] = value
+		}
+	}
+	return found
+}
+
+// SetMulti stores several values at once, each with its own TTL
+func (c *FakeCache) SetMulti(items map[Key]ValueTTL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, item := range items {
+		c.set(key, item.Value, item.TTL)
+	}
+}
+
+// Delete removes a value from the cache
+func (c *FakeCache) Delete(key Key) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; ok {
+		delete(c.entries, key)
+		c.stats.Deletes++
+	}
+}
+
+// Clear removes all values from the cache
+func (c *FakeCache) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[Key]fakeEntry)
+}
+
+// Size returns the number of items in the cache
+func (c *FakeCache) Size() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.entries)
+}
+
+// Stats returns cache statistics
+func (c *FakeCache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Size = len(c.entries)
+	return stats
+}
+
+// Range calls fn for each entry in key order until fn returns false, with
+// TTLs measured on the fake clock
+func (c *FakeCache) Range(fn func(KeyInfo) bool) {
+	c.mu.Lock()
+	infos := make([]KeyInfo, 0, len(c.entries))
+	for key, entry := range c.entries {
+		info := KeyInfo{Key: key, Size: valueSize(entry.value)}
+		if !entry.expiry.IsZero() {
+			info.TTL = entry.expiry.Sub(c.now)
+		}
+		infos = append(infos, info)
+	}
+	c.mu.Unlock()
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Key < infos[j].Key })
+	for _, info := range infos {
+		if !fn(info) {
+			return
+		}
+	}
+}
+
+// get looks up a live entry; the caller holds the lock
+func (c *FakeCache) get(key Key) (interface{}, bool) {
+	entry, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	c.stats.Hits++
+	return entry.value, true
+}
+
+// set stores an entry; the caller holds the lock
+func (c *FakeCache) set(key Key, value interface{}, ttl time.Duration) {
+	entry := fakeEntry{value: value}
+	if ttl > 0 {
+		entry.expiry = c.now.Add(ttl)
+	}
+	c.entries[key] = entry
+}
+
+// expired reports whether an entry's TTL has elapsed on the fake clock
+func (c *FakeCache) expired(entry fakeEntry) bool {
+	return !entry.expiry.IsZero() && !c.now.Before(entry.expiry)
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name      string
+		ttl       time.Duration
+		advance   []time.Duration
+		wantFound bool
+	}{
+		{"no expiry", 0, []time.Duration{24 * time.Hour}, true},
+		{"within TTL", time.Minute, []time.Duration{59 * time.Second}, true},
+		{"at TTL", time.Minute, []time.Duration{time.Minute}, false},
+		{"past TTL in steps", time.Minute, []time.Duration{30 * time.Second, 31 * time.Second}, false},
+		{"not advanced", time.Nanosecond, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewFake()
+			c.Set("k", "v", tt.ttl)
+			for _, d := range tt.advance {
+				c.Advance(d)
+			}
+
+			value, found := c.Get("k")
+			if found != tt.wantFound {
+				t.Fatalf("found = %v, want %v", found, tt.wantFound)
+			}
+			if found && value != "v" {
+				t.Errorf("value %v, want v", value)
+			}
+			// Expired entries are removed when the clock moves
+			if expired := c.Stats().Expirations == 1; expired == tt.wantFound {
+				t.Errorf("expirations %d, found %v", c.Stats().Expirations, found)
+			}
+		})
+	}
+}
+
+func TestFakeCacheOperations(t *testing.T) {
+	c := NewFake()
+	c.Set("a", 1, time.Minute)
+	c.Set("b", 2, 0)
+	c.Set("a", 3, 0) // Overwrite drops the TTL
+	c.Advance(time.Hour)
+
+	if value, ok := c.Get("a"); !ok || value != 3 {
+		t.Errorf("Get(a) = %v, %v, want 3", value, ok)
+	}
+	c.Delete("b")
+	if _, ok := c.Get("b"); ok {
+		t.Error("deleted entry still present")
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Deletes != 1 || stats.Size != 1 {
+		t.Errorf("stats %+v, want 1 hit, 1 miss, 1 delete, size 1", stats)
+	}
+
+	c.Clear()
+	if c.Size() != 0 {
+		t.Errorf("size %d after Clear", c.Size())
+	}
+}
@@ -0,0 +1,106 @@
+// Cache inspection
+//
+// Bounded listing of cached entries for diagnostics:
+// - Key, value size and remaining TTL per entry
+// - Optional interface for caches that can enumerate entries
+// - Namespace-aware through the namespaced wrapper
+// - Viewer tokens masked in listed keys
+
+package cache
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// tokenKeyPrefix starts the keys of validated token entries, which are keyed
+// by the full token
+const tokenKeyPrefix = "jwt:token:"
+
+// KeyInfo describes a cached entry
+type KeyInfo struct {
+	Key  Key
+	Size int           // Value size in bytes, or -1 if unknown
+	TTL  time.Duration // Remaining lifetime, or 0 if the entry never expires
+}
+
+// MarshalJSON renders the TTL in Go duration notation
+func (k KeyInfo) MarshalJSON() ([]byte, error) {
+	ttl := ""
+	if k.TTL > 0 {
+		ttl = k.TTL.Round(time.Millisecond).String()
+	}
+	return json.Marshal(struct {
+		Key  Key    `json:"key"`
+		Size int    `json:"size"`
+		TTL  string `json:"ttl,omitempty"`
+	}{k.Key, k.Size, ttl})
+}
+
+// Sizer is implemented by cached values that know their size in bytes
+type Sizer interface {
+	Size() int
+}
+
+// Inspector is implemented by caches that can enumerate their entries
+type Inspector interface {
+	// Range calls fn for each live entry until fn returns false. The order
+	// is unspecified, and fn must not call back into the cache.
+	Range(fn func(KeyInfo) bool)
+}
+
+// Sample returns up to limit entries of the cache, and false if the cache
+// cannot enumerate its entries. Keys are masked with RedactKey, so a listing
+// never hands out viewer tokens.
+func Sample(c Cache, limit int, tokenParams []string) ([]KeyInfo, bool) {
+	inspector, ok := c.(Inspector)
+	if !ok {
+		return nil, false
+	}
+
+	entries := make([]KeyInfo, 0)
+	if limit <= 0 {
+		return entries, true
+	}
+	inspector.Range(func(info KeyInfo) bool {
+		info.Key = RedactKey(info.Key, tokenParams)
+		entries = append(entries, info)
+		return len(entries) < limit
+	})
+	return entries, true
+}
+
+// RedactKey masks the viewer tokens in a cache key: the token of a validated
+// token entry, the named token query parameters of a keyed URL, and any JWT,
+// such as the token that scopes playlist and segment entries
+func RedactKey(key Key, tokenParams []string) Key {
+	s := string(key)
+	if i := strings.Index(s, tokenKeyPrefix); i >= 0 {
+		return Key(s[:i+len(tokenKeyPrefix)] + "[REDACTED]")
+	}
+
+	// URL keys end in ":<token>[|variant]"; masking the URL and the suffix
+	// apart keeps a masked query value from swallowing the suffix
+	i := strings.LastIndex(s, ":")
+	if i < 0 {
+		return Key(utils.RedactText(s, tokenParams))
+	}
+	return Key(utils.RedactText(s[:i], tokenParams) + utils.RedactText(s[i:], tokenParams))
+}
+
+// valueSize returns the size of a cached value in bytes, or -1 if unknown
+func valueSize(value interface{}) int {
+	switch v := value.(type) {
+	case Sizer:
+		return v.Size()
+	case []byte:
+		return len(v)
+	case string:
+		return len(v)
+	default:
+		return -1
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"crypto/sha256"
 	"encoding/hex"
 	"net/http"
+	"net/url"
 	"sort"
 	"strings"
 )
@@ -24,9 +25,42 @@ func FromString(s string) Key {
 	return Key(s)
 }
 
-// FromURL creates a cache key from a URL
-func FromURL(url string) Key {
-	return Key("url:" + url)
+// FromURL creates a cache key from a URL. Query parameters are sorted
+// unless normalization is disabled, so reordered queries share a key;
+// WithPrefix replaces the default "url:" prefix.
+func FromURL(rawURL string, opts ...KeyOption) Key {
+	options := defaultKeyOptions()
+	options.prefix = "url:"
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	key := rawURL
+	if options.ignoreQuery {
+		if i := strings.IndexByte(key, '?'); i >= 0 {
+			key = key[:i]
+		}
+	} else if options.normalizeQuery {
+		key = NormalizeURL(key)
+	}
+
+	key = options.prefix + key
+	if options.hash {
+		return hashKey(key)
+	}
+	return Key(key)
+}
+
+// NormalizeURL returns the URL with its query parameters sorted by name and
+// repeated values sorted, or the URL unchanged if it has no query or does
+// not parse
+func NormalizeURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.RawQuery == "" {
+		return rawURL
+	}
+	u.RawQuery = normalizeQuery(u.Query())
+	return u.String()
 }
 
 // FromRequest creates a cache key from an HTTP request
@@ -144,7 +178,8 @@ func DisableQueryNormalization() KeyOption {
 	}
 }
 
-// normalizeQuery normalizes query parameters for consistent keys
+// normalizeQuery normalizes query parameters for consistent keys. Names and
+// values are re-escaped so an encoded '&' or '=' cannot pass for a separator.
 func normalizeQuery(q map[string][]string) string {
 	if len(q) == 0 {
 		return ""
@@ -163,7 +198,7 @@ func normalizeQuery(q map[string][]string) string {
 		values := q[k]
 		sort.Strings(values) // Sort values for consistent ordering
 		for _, v := range values {
-			parts = append(parts, k+"="+v)
+			parts = append(parts, url.QueryEscape(k)+"="+url.QueryEscape(v))
 		}
 	}
 
@@ -0,0 +1,59 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFromURL(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		opts  []KeyOption
+		equal bool
+	}{
+		{"reordered parameters", "https://origin/a.ts?x=1&y=2", "https://origin/a.ts?y=2&x=1", nil, true},
+		{"reordered repeated values", "https://origin/a.ts?x=2&x=1", "https://origin/a.ts?x=1&x=2", nil, true},
+		{"different values", "https://origin/a.ts?x=1", "https://origin/a.ts?x=2", nil, false},
+		{"encoded separator", "https://origin/a.ts?x=1%26y%3D2", "https://origin/a.ts?x=1&y=2", nil, false},
+		{"normalization disabled", "https://origin/a.ts?x=1&y=2", "https://origin/a.ts?y=2&x=1", []KeyOption{DisableQueryNormalization()}, false},
+		{"query ignored", "https://origin/a.ts?x=1", "https://origin/a.ts?x=2", []KeyOption{IgnoreQuery()}, true},
+		{"hashed", "https://origin/a.ts?x=1&y=2", "https://origin/a.ts?y=2&x=1", []KeyOption{WithHash()}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := FromURL(tt.a, tt.opts...), FromURL(tt.b, tt.opts...)
+			if (a == b) != tt.equal {
+				t.Errorf("keys %q and %q: equal = %v, want %v", a, b, a == b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestFromURLPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []KeyOption
+		want Key
+	}{
+		{"default prefix", nil, "url:https://origin/a.ts?x=1&y=2"},
+		{"custom prefix", []KeyOption{WithPrefix("segment:")}, "segment:https://origin/a.ts?x=1&y=2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FromURL("https://origin/a.ts?y=2&x=1", tt.opts...); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromRequestNormalizesQuery(t *testing.T) {
+	a := FromRequest(httptest.NewRequest("GET", "/a.ts?y=2&x=1", nil))
+	b := FromRequest(httptest.NewRequest("GET", "/a.ts?x=1&y=2", nil))
+	if a != b {
+		t.Errorf("keys %q and %q differ", a, b)
+	}
+}
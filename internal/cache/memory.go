@@ -4,45 +4,70 @@
 // - Concurrent access support
 // - Size-based eviction
 // - TTL-based expiration
-// - Memory usage limiting
+// - Memory usage limiting by byte budget
 
 package cache
 
 import (
 	"container/list"
+	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // MemoryCache implements an in-memory cache
 type MemoryCache struct {
-	shards    []*memoryShard
-	shardMask uint32
-	stats     Stats
+	shards        []*memoryShard
+	shardMask     uint32
+	itemsPerShard int
+	clock         utils.Clock
+	stats         Stats
 }
 
 // MemoryOptions configures a memory cache
 type MemoryOptions struct {
-	MaxSize   int
-	ShardSize int
+	MaxSize    int
+	ShardSize  int
+	MaxBytes   int64       // Byte budget for sized values, split across shards (0: unlimited)
+	AutoShards bool        // Derive the shard count from GOMAXPROCS and MaxSize
+	Clock      utils.Clock // Time source for expiry (default: the real clock)
 }
 
+// minItemsPerShard is the smallest shard auto-tuning will create, so that
+// LRU eviction within a shard stays meaningful
+const minItemsPerShard = 64
+
 // memoryShard represents a single shard of the cache
 type memoryShard struct {
 	items     map[Key]*list.Element
 	lruList   *list.List
 	maxSize   int
+	maxBytes  int64 // Zero when there is no byte budget
 	mu        sync.RWMutex
 	itemCount int
+	bytes     int64 // Total size of the shard's sized values
 }
 
+// evictReason records why an item left the cache
+type evictReason int
+
+const (
+	evictCapacity evictReason = iota // LRU eviction over the item limit
+	evictBytes                       // LRU eviction over the byte budget
+	evictExpired                     // TTL expiry
+	evictDeleted                     // explicit Delete
+)
+
 // cacheItem represents a cached item with TTL
 type cacheItem struct {
 	key       Key
 	value     interface{}
 	expiry    time.Time
 	hasExpiry bool
+	size      int64 // Bytes charged to the shard's budget
 }
 
 // NewMemoryWithOptions creates a new memory cache with options
@@ -56,29 +81,30 @@ func NewMemoryWithOptions(opts MemoryOptions) *MemoryCache {
 		opts.ShardSize = 16
 	}
 	
-	// Ensure ShardSize is a power of 2
-	shardSize := nextPowerOfTwo(uint32(opts.ShardSize))
+	shardSize, itemsPerShard := ShardLayout(opts.MaxSize, opts.ShardSize, opts.AutoShards)
 	shardMask := shardSize - 1
 	
-	// Calculate items per shard
-	itemsPerShard := opts.MaxSize / int(shardSize)
-	if itemsPerShard <= 0 {
-		itemsPerShard = 100
+	var bytesPerShard int64
+	if opts.MaxBytes > 0 {
+		bytesPerShard = (opts.MaxBytes + int64(shardSize) - 1) / int64(shardSize)
 	}
 	
 	// Create shards
 	shards := make([]*memoryShard, shardSize)
 	for i := uint32(0); i < shardSize; i++ {
 		shards[i] = &memoryShard{
-			items:   make(map[Key]*list.Element),
-			lruList: list.New(),
-			maxSize: itemsPerShard,
+			items:    make(map[Key]*list.Element),
+			lruList:  list.New(),
+			maxSize:  itemsPerShard,
+			maxBytes: bytesPerShard,
 		}
 	}
 	
 	cache := &MemoryCache{
-		shards:    shards,
-		shardMask: shardMask,
+		shards:        shards,
+		shardMask:     shardMask,
+		itemsPerShard: itemsPerShard,
+		clock:         utils.ClockOrReal(opts.Clock),
 	}
 	
 	// Start cleanup worker
@@ -87,6 +113,86 @@ func NewMemoryWithOptions(opts MemoryOptions) *MemoryCache {
 	return cache
 }
 
+// ShardLayout returns the shard count and per-shard item limit for a cache
+// of maxSize items. The shard count is a power of two: the requested count
+// rounded up, or with auto-tuning four shards per GOMAXPROCS. Shards are then
+// halved until each holds at least one item (minItemsPerShard when
+// auto-tuning), and the per-shard limit is rounded up so the total capacity
+// is never below maxSize.
+func ShardLayout(maxSize, shardCount int, auto bool) (uint32, int) {
+	if maxSize <= 0 {
+		maxSize = 1
+	}
+	
+	minPerShard := 1
+	if auto {
+		shardCount = runtime.GOMAXPROCS(0) * 4
+		minPerShard = minItemsPerShard
+	}
+	if shardCount <= 0 {
+		shardCount = 1
+	}
+	
+	shards := nextPowerOfTwo(uint32(shardCount))
+	for shards > 1 && maxSize/int(shards) < minPerShard {
+		shards /= 2
+	}
+	
+	perShard := (maxSize + int(shards) - 1) / int(shards)
+	return shards, perShard
+}
+
+// Layout returns the effective shard count and per-shard item limit
+func (c *MemoryCache) Layout() (int, int) {
+	return len(c.shards), c.itemsPerShard
+}
+
+// Keys returns up to limit keys of live entries
+func (c *MemoryCache) Keys(limit int) []Key {
+	keys := make([]Key, 0)
+	if limit <= 0 {
+		return keys
+	}
+	c.Range(func(info KeyInfo) bool {
+		keys = append(keys, info.Key)
+		return len(keys) < limit
+	})
+	return keys
+}
+
+// Range calls fn for each live entry, shard by shard and most recently
+// used first within a shard, until fn returns false. Each shard is read
+// locked while it is visited, so fn must not call back into the cache.
+func (c *MemoryCache) Range(fn func(KeyInfo) bool) {
+	now := c.clock.Now()
+	for _, shard := range c.shards {
+		if !c.rangeShard(shard, now, fn) {
+			return
+		}
+	}
+}
+
+// rangeShard visits the live entries of a shard, reporting whether to go on
+func (c *MemoryCache) rangeShard(shard *memoryShard, now time.Time, fn func(KeyInfo) bool) bool {
+	shard.mu.RLock()
+	defer shard.mu.RUnlock()
+	
+	for el := shard.lruList.Front(); el != nil; el = el.Next() {
+		item := el.Value.(*cacheItem)
+		info := KeyInfo{Key: item.key, Size: valueSize(item.value)}
+		if item.hasExpiry {
+			if !now.Before(item.expiry) {
+				continue
+			}
+			info.TTL = item.expiry.Sub(now)
+		}
+		if !fn(info) {
+			return false
+		}
+	}
+	return true
+}
+
 // Get retrieves a value from the cache
 func (c *MemoryCache) Get(key Key) (interface{}, bool) {
 	shard := c.getShard(key)
@@ -102,12 +208,11 @@ func (c *MemoryCache) Get(key Key) (interface{}, bool) {
 	item := element.Value.(*cacheItem)
 	
 	// Check if expired
-	if item.hasExpiry && time.Now().After(item.expiry) {
+	if item.hasExpiry && c.clock.Now().After(item.expiry) {
 		shard.mu.RUnlock()
 		// Delete in a separate goroutine to avoid deadlock
-		go c.Delete(key)
+		go c.removeExpired(key)
 		atomic.AddUint64(&c.stats.Misses, 1)
-		atomic.AddUint64(&c.stats.Expirations, 1)
 		return nil, false
 	}
 	
@@ -129,34 +234,75 @@ func (c *MemoryCache) Set(key Key, value interface{}, ttl time.Duration) {
 	defer shard.mu.Unlock()
 	
 	// Create cache item
-	item := &cacheItem{
-		key:   key,
-		value: value,
-	}
+	item := newCacheItem(key, value)
 	
 	// Set expiry if TTL provided
 	if ttl > 0 {
 		item.hasExpiry = true
-		item.expiry = time.Now().Add(ttl)
+		item.expiry = c.clock.Now().Add(ttl)
 	}
 	
-	// Check if key already exists
-	if element, found := shard.items[key]; found {
-		// Update existing item
-		element.Value = item
-		shard.lruList.MoveToFront(element)
-		return
-	}
-	
-	// Add new item
-	element := shard.lruList.PushFront(item)
-	shard.items[key] = element
-	shard.itemCount++
+	c.store(shard, item)
 	
 	// Evict if needed
 	c.evictIfNeeded(shard)
 }
 
+// GetMulti retrieves several values, locking each shard once
+func (c *MemoryCache) GetMulti(keys []Key) map[Key]interface{} {
+	result := make(map[Key]interface{}, len(keys))
+	now := c.clock.Now()
+	
+	for shard, shardKeys := range c.groupByShard(keys) {
+		shard.mu.Lock()
+		for _, key := range shardKeys {
+			element, found := shard.items[key]
+			if !found {
+				atomic.AddUint64(&c.stats.Misses, 1)
+				continue
+			}
+			
+			item := element.Value.(*cacheItem)
+			if item.hasExpiry && now.After(item.expiry) {
+				c.removeElement(shard, element, evictExpired)
+				atomic.AddUint64(&c.stats.Misses, 1)
+				continue
+			}
+			
+			shard.lruList.MoveToFront(element)
+			atomic.AddUint64(&c.stats.Hits, 1)
+			result[key] = item.value
+		}
+		shard.mu.Unlock()
+	}
+	
+	return result
+}
+
+// SetMulti stores several values, locking each shard once
+func (c *MemoryCache) SetMulti(items map[Key]ValueTTL) {
+	keys := make([]Key, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	now := c.clock.Now()
+	
+	for shard, shardKeys := range c.groupByShard(keys) {
+		shard.mu.Lock()
+		for _, key := range shardKeys {
+			entry := items[key]
+			item := newCacheItem(key, entry.Value)
+			if entry.TTL > 0 {
+				item.hasExpiry = true
+				item.expiry = now.Add(entry.TTL)
+			}
+			c.store(shard, item)
+		}
+		c.evictIfNeeded(shard)
+		shard.mu.Unlock()
+	}
+}
+
 // Delete removes a value from the cache
 func (c *MemoryCache) Delete(key Key) {
 	shard := c.getShard(key)
@@ -164,7 +310,22 @@ func (c *MemoryCache) Delete(key Key) {
 	defer shard.mu.Unlock()
 	
 	if element, found := shard.items[key]; found {
-		c.removeElement(shard, element)
+		c.removeElement(shard, element, evictDeleted)
+	}
+}
+
+// removeExpired removes a key if it is still expired, leaving a value
+// stored since the expired read in place
+func (c *MemoryCache) removeExpired(key Key) {
+	shard := c.getShard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+	
+	if element, found := shard.items[key]; found {
+		item := element.Value.(*cacheItem)
+		if item.hasExpiry && c.clock.Now().After(item.expiry) {
+			c.removeElement(shard, element, evictExpired)
+		}
 	}
 }
 
@@ -175,6 +336,7 @@ func (c *MemoryCache) Clear() {
 		shard.items = make(map[Key]*list.Element)
 		shard.lruList.Init()
 		shard.itemCount = 0
+		shard.bytes = 0
 		shard.mu.Unlock()
 	}
 	
@@ -196,11 +358,13 @@ func (c *MemoryCache) Size() int {
 // Stats returns cache statistics
 func (c *MemoryCache) Stats() Stats {
 	stats := Stats{
-		Hits:        atomic.LoadUint64(&c.stats.Hits),
-		Misses:      atomic.LoadUint64(&c.stats.Misses),
-		Evictions:   atomic.LoadUint64(&c.stats.Evictions),
-		Expirations: atomic.LoadUint64(&c.stats.Expirations),
-		Size:        c.Size(),
+		Hits:          atomic.LoadUint64(&c.stats.Hits),
+		Misses:        atomic.LoadUint64(&c.stats.Misses),
+		Evictions:     atomic.LoadUint64(&c.stats.Evictions),
+		ByteEvictions: atomic.LoadUint64(&c.stats.ByteEvictions),
+		Expirations:   atomic.LoadUint64(&c.stats.Expirations),
+		Deletes:       atomic.LoadUint64(&c.stats.Deletes),
+		Size:          c.Size(),
 	}
 	return stats
 }
@@ -212,24 +376,82 @@ func (c *MemoryCache) getShard(key Key) *memoryShard {
 	return c.shards[hash&c.shardMask]
 }
 
-// evictIfNeeded evicts items if the shard is over capacity
+// groupByShard groups keys by the shard that owns them
+func (c *MemoryCache) groupByShard(keys []Key) map[*memoryShard][]Key {
+	groups := make(map[*memoryShard][]Key)
+	for _, key := range keys {
+		shard := c.getShard(key)
+		groups[shard] = append(groups[shard], key)
+	}
+	return groups
+}
+
+// newCacheItem creates an item charged with the size of its value; values
+// of unknown size are not charged
+func newCacheItem(key Key, value interface{}) *cacheItem {
+	item := &cacheItem{
+		key:   key,
+		value: value,
+	}
+	if size := valueSize(value); size > 0 {
+		item.size = int64(size)
+	}
+	return item
+}
+
+// store adds or replaces an item in a shard; the caller holds the lock
+func (c *MemoryCache) store(shard *memoryShard, item *cacheItem) {
+	if element, found := shard.items[item.key]; found {
+		shard.bytes += item.size - element.Value.(*cacheItem).size
+		element.Value = item
+		shard.lruList.MoveToFront(element)
+		return
+	}
+	
+	shard.items[item.key] = shard.lruList.PushFront(item)
+	shard.itemCount++
+	shard.bytes += item.size
+}
+
+// evictIfNeeded evicts least recently used items while the shard is over
+// its item limit or byte budget. An item larger than the whole budget is
+// evicted as well, so it is never cached.
 func (c *MemoryCache) evictIfNeeded(shard *memoryShard) {
 	for shard.itemCount > shard.maxSize {
 		back := shard.lruList.Back()
 		if back == nil {
 			break
 		}
-		c.removeElement(shard, back)
-		atomic.AddUint64(&c.stats.Evictions, 1)
+		c.removeElement(shard, back, evictCapacity)
+	}
+	
+	for shard.maxBytes > 0 && shard.bytes > shard.maxBytes {
+		back := shard.lruList.Back()
+		if back == nil {
+			break
+		}
+		c.removeElement(shard, back, evictBytes)
 	}
 }
 
-// removeElement removes an element from the cache
-func (c *MemoryCache) removeElement(shard *memoryShard, element *list.Element) {
+// removeElement removes an element from the cache, counting it under reason
+func (c *MemoryCache) removeElement(shard *memoryShard, element *list.Element, reason evictReason) {
 	item := element.Value.(*cacheItem)
 	delete(shard.items, item.key)
 	shard.lruList.Remove(element)
 	shard.itemCount--
+	shard.bytes -= item.size
+	
+	switch reason {
+	case evictCapacity:
+		atomic.AddUint64(&c.stats.Evictions, 1)
+	case evictBytes:
+		atomic.AddUint64(&c.stats.ByteEvictions, 1)
+	case evictExpired:
+		atomic.AddUint64(&c.stats.Expirations, 1)
+	case evictDeleted:
+		atomic.AddUint64(&c.stats.Deletes, 1)
+	}
 }
 
 // cleanupWorker periodically removes expired items
@@ -246,7 +468,7 @@ func (c *MemoryCache) cleanupWorker() {
 
 // cleanupExpired removes expired items from a shard
 func (c *MemoryCache) cleanupExpired(shard *memoryShard) {
-	now := time.Now()
+	now := c.clock.Now()
 	shard.mu.Lock()
 	defer shard.mu.Unlock()
 	
@@ -265,8 +487,7 @@ func (c *MemoryCache) cleanupExpired(shard *memoryShard) {
 	
 	// Remove expired items
 	for _, element := range expiredItems {
-		c.removeElement(shard, element)
-		atomic.AddUint64(&c.stats.Expirations, 1)
+		c.removeElement(shard, element, evictExpired)
 	}
 }
 
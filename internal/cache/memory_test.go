@@ -0,0 +1,157 @@
+package cache
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// batchCaches returns fresh caches of every kind implementing the batch
+// operations, each with a function advancing its expiry clock
+func batchCaches() map[string]struct {
+	cache   Cache
+	advance func(time.Duration)
+} {
+	clock := utils.NewFakeClock(time.Unix(1700000000, 0))
+	memory := NewMemoryWithOptions(MemoryOptions{MaxSize: 1024, ShardSize: 4, Clock: clock})
+	fake := NewFake()
+	return map[string]struct {
+		cache   Cache
+		advance func(time.Duration)
+	}{
+		"memory":    {memory, clock.Advance},
+		"namespace": {WithNamespace(NewMemoryWithOptions(MemoryOptions{MaxSize: 1024, ShardSize: 4, Clock: clock}), "v1"), clock.Advance},
+		"fake":      {fake, fake.Advance},
+	}
+}
+
+func TestGetMultiSetMulti(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  map[Key]ValueTTL
+		advance time.Duration
+		lookup  []Key
+		want    map[Key]interface{}
+	}{
+		{
+			name:   "all hits",
+			stored: map[Key]ValueTTL{"a": {"1", time.Minute}, "b": {"2", time.Minute}},
+			lookup: []Key{"a", "b"},
+			want:   map[Key]interface{}{"a": "1", "b": "2"},
+		},
+		{
+			name:   "partial hits",
+			stored: map[Key]ValueTTL{"a": {"1", time.Minute}, "c": {"3", 0}},
+			lookup: []Key{"a", "b", "c", "d"},
+			want:   map[Key]interface{}{"a": "1", "c": "3"},
+		},
+		{
+			name:    "expired entries missing",
+			stored:  map[Key]ValueTTL{"short": {"1", time.Second}, "long": {"2", time.Hour}, "forever": {"3", 0}},
+			advance: time.Minute,
+			lookup:  []Key{"short", "long", "forever"},
+			want:    map[Key]interface{}{"long": "2", "forever": "3"},
+		},
+		{
+			name:   "no keys",
+			stored: map[Key]ValueTTL{"a": {"1", 0}},
+			want:   map[Key]interface{}{},
+		},
+	}
+
+	for _, tt := range tests {
+		for kind, c := range batchCaches() {
+			t.Run(tt.name+"/"+kind, func(t *testing.T) {
+				c.cache.SetMulti(tt.stored)
+				c.advance(tt.advance)
+
+				got := c.cache.GetMulti(tt.lookup)
+				if len(got) != len(tt.want) {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+				for key, want := range tt.want {
+					if got[key] != want {
+						t.Errorf("%s = %v, want %v", key, got[key], want)
+					}
+					// Batch and single-key access see the same entries
+					if value, ok := c.cache.Get(key); !ok || value != want {
+						t.Errorf("Get(%s) = %v, %v after SetMulti", key, value, ok)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestMemoryGetMultiCountsHitsAndMisses(t *testing.T) {
+	c := NewMemoryWithOptions(MemoryOptions{MaxSize: 1024, ShardSize: 4})
+	c.SetMulti(map[Key]ValueTTL{"a": {"1", 0}, "b": {"2", 0}})
+	c.GetMulti([]Key{"a", "b", "x"})
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 1 {
+		t.Errorf("hits %d misses %d, want 2 and 1", stats.Hits, stats.Misses)
+	}
+}
+
+func TestMemorySetMultiEvictsBeyondMaxSize(t *testing.T) {
+	c := NewMemoryWithOptions(MemoryOptions{MaxSize: 4, ShardSize: 1})
+	items := make(map[Key]ValueTTL)
+	for _, key := range []Key{"a", "b", "c", "d", "e", "f"} {
+		items[key] = ValueTTL{Value: string(key)}
+	}
+	c.SetMulti(items)
+
+	if c.Size() > 4 {
+		t.Errorf("size %d exceeds MaxSize 4", c.Size())
+	}
+}
+
+func TestShardLayout(t *testing.T) {
+	procs := runtime.GOMAXPROCS(0)
+	autoShards := nextPowerOfTwo(uint32(procs * 4))
+	for autoShards > 1 && 1<<20/int(autoShards) < minItemsPerShard {
+		autoShards /= 2
+	}
+
+	tests := []struct {
+		name         string
+		maxSize      int
+		shardCount   int
+		auto         bool
+		wantShards   uint32
+		wantPerShard int
+	}{
+		{"even split", 10000, 16, false, 16, 625},
+		{"rounded up to a power of two", 1000, 12, false, 16, 63},
+		{"fewer items than shards", 5, 16, false, 4, 2},
+		{"single item", 1, 16, false, 1, 1},
+		{"no shard count", 100, 0, false, 1, 100},
+		{"auto-tuned", 1 << 20, 3, true, autoShards, (1<<20 + int(autoShards) - 1) / int(autoShards)},
+		{"auto-tuned small cache", 100, 0, true, 1, 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards, perShard := ShardLayout(tt.maxSize, tt.shardCount, tt.auto)
+			if shards != tt.wantShards || perShard != tt.wantPerShard {
+				t.Errorf("ShardLayout = %d x %d, want %d x %d", shards, perShard, tt.wantShards, tt.wantPerShard)
+			}
+			if int(shards)*perShard < tt.maxSize {
+				t.Errorf("capacity %d below maxSize %d", int(shards)*perShard, tt.maxSize)
+			}
+			if tt.auto && shards > 1 && perShard < minItemsPerShard {
+				t.Errorf("auto-tuned shards hold %d items, below %d", perShard, minItemsPerShard)
+			}
+		})
+	}
+}
+
+func TestMemoryLayout(t *testing.T) {
+	c := NewMemoryWithOptions(MemoryOptions{MaxSize: 1000, ShardSize: 12})
+	if shards, perShard := c.Layout(); shards != 16 || perShard != 63 {
+		t.Errorf("Layout = %d x %d, want 16 x 63", shards, perShard)
+	}
+}
@@ -0,0 +1,120 @@
+// Cache key namespacing
+//
+// Prefixes every key with a configurable namespace:
+// - Invalidation by bumping the namespace
+// - Transparent to cache users
+// - No-op when the namespace is empty
+
+package cache
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// namespacedCache prefixes all keys of an underlying cache
+type namespacedCache struct {
+	Cache
+	prefix string
+}
+
+// WithNamespace returns a cache that prefixes every key with the namespace.
+// Changing the namespace makes previously cached entries unreachable, which
+// invalidates them without a flush. An empty namespace or nil cache is
+// returned unchanged.
+func WithNamespace(c Cache, namespace string) Cache {
+	if c == nil || namespace == "" {
+		return c
+	}
+	return &namespacedCache{
+		Cache:  c,
+		prefix: namespace + ":",
+	}
+}
+
+// Get retrieves a value from the namespace
+func (n *namespacedCache) Get(key Key) (interface{}, bool) {
+	return n.Cache.Get(n.key(key))
+}
+
+// Set stores a value in the namespace
+func (n *namespacedCache) Set(key Key, value interface{}, ttl time.Duration) {
+	n.Cache.Set(n.key(key), value, ttl)
+}
+
+// GetMulti retrieves several values from the namespace
+func (n *namespacedCache) GetMulti(keys []Key) map[Key]interface{} {
+	nsKeys := make([]Key, len(keys))
+	for i, key := range keys {
+		nsKeys[i] = n.key(key)
+	}
+
+	found := n.Cache.GetMulti(nsKeys)
+	result := make(map[Key]interface{}, len(found))
+	for i, key := range keys {
+		if value, ok := found[nsKeys[i]]; ok {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// SetMulti stores several values in the namespace
+func (n *namespacedCache) SetMulti(items map[Key]ValueTTL) {
+	nsItems := make(map[Key]ValueTTL, len(items))
+	for key, item := range items {
+		nsItems[n.key(key)] = item
+	}
+	n.Cache.SetMulti(nsItems)
+}
+
+// Delete removes a value from the namespace
+func (n *namespacedCache) Delete(key Key) {
+	n.Cache.Delete(n.key(key))
+}
+
+// GetCtx retrieves a value from the namespace with a context
+func (n *namespacedCache) GetCtx(ctx context.Context, key Key) (interface{}, bool) {
+	return GetContext(ctx, n.Cache, n.key(key))
+}
+
+// SetCtx stores a value in the namespace with a context
+func (n *namespacedCache) SetCtx(ctx context.Context, key Key, value interface{}, ttl time.Duration) {
+	SetContext(ctx, n.Cache, n.key(key), value, ttl)
+}
+
+// DeleteCtx removes a value from the namespace with a context
+func (n *namespacedCache) DeleteCtx(ctx context.Context, key Key) {
+	DeleteContext(ctx, n.Cache, n.key(key))
+}
+
+// GetChecked retrieves a value from the namespace, reporting backend failures
+func (n *namespacedCache) GetChecked(ctx context.Context, key Key) (interface{}, bool, error) {
+	return GetChecked(ctx, n.Cache, n.key(key))
+}
+
+// SetChecked stores a value in the namespace, reporting backend failures
+func (n *namespacedCache) SetChecked(ctx context.Context, key Key, value interface{}, ttl time.Duration) error {
+	return SetChecked(ctx, n.Cache, n.key(key), value, ttl)
+}
+
+// Range visits the entries of the namespace with the prefix removed
+func (n *namespacedCache) Range(fn func(KeyInfo) bool) {
+	inspector, ok := n.Cache.(Inspector)
+	if !ok {
+		return
+	}
+	inspector.Range(func(info KeyInfo) bool {
+		if !strings.HasPrefix(string(info.Key), n.prefix) {
+			return true
+		}
+		info.Key = info.Key[len(n.prefix):]
+		return fn(info)
+	})
+}
+
+// key returns the namespaced key
+func (n *namespacedCache) key(key Key) Key {
+	return Key(n.prefix) + key
+}
@@ -0,0 +1,66 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWithNamespace(t *testing.T) {
+	tests := []struct {
+		name       string
+		writeNS    string
+		readNS     string
+		wantHit    bool
+		wantRawKey Key
+	}{
+		{"same namespace", "v1", "v1", true, "v1:a"},
+		{"bumped namespace", "v1", "v2", false, "v1:a"},
+		{"namespace added", "", "v1", false, "a"},
+		{"no namespace", "", "", true, "a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewMemory()
+			WithNamespace(backend, tt.writeNS).Set("a", "value", time.Minute)
+
+			if _, hit := WithNamespace(backend, tt.readNS).Get("a"); hit != tt.wantHit {
+				t.Errorf("hit = %v, want %v", hit, tt.wantHit)
+			}
+			if _, ok := backend.Get(tt.wantRawKey); !ok {
+				t.Errorf("backend has no key %q", tt.wantRawKey)
+			}
+		})
+	}
+}
+
+func TestWithNamespaceUnchanged(t *testing.T) {
+	backend := NewMemory()
+	if got := WithNamespace(backend, ""); got != backend {
+		t.Error("empty namespace wrapped the cache")
+	}
+	if got := WithNamespace(nil, "v1"); got != nil {
+		t.Error("nil cache wrapped")
+	}
+}
+
+func TestNamespacedCacheRangeAndDelete(t *testing.T) {
+	backend := NewMemory()
+	backend.Set("other:a", "x", time.Minute)
+	ns := WithNamespace(backend, "v1")
+	ns.Set("a", "1", time.Minute)
+	ns.Set("b", "2", time.Minute)
+	ns.Delete("b")
+
+	var keys []Key
+	ns.(Inspector).Range(func(info KeyInfo) bool {
+		keys = append(keys, info.Key)
+		return true
+	})
+	if len(keys) != 1 || keys[0] != "a" {
+		t.Errorf("Range visited %v, want [a]", keys)
+	}
+	if _, ok := backend.Get("other:a"); !ok {
+		t.Error("delete in the namespace removed another namespace's key")
+	}
+}
@@ -0,0 +1,98 @@
+// Cache statistics reporting
+//
+// Publishes cache statistics as metrics:
+// - Eviction counters broken down by cause
+// - Rolling hit ratio over the reporting interval
+// - Current cache size
+
+package cache
+
+import (
+	"sync"
+	"time"
+)
+
+// StatsSink receives published cache statistics
+type StatsSink interface {
+	IncCounterBy(name string, value int)
+	SetGauge(name string, value float64)
+}
+
+// StatsReporter periodically publishes the statistics of a cache
+type StatsReporter struct {
+	cache    Cache
+	sink     StatsSink
+	interval time.Duration
+	last     Stats
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewStatsReporter creates a reporter publishing every interval
+func NewStatsReporter(c Cache, sink StatsSink, interval time.Duration) *StatsReporter {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	return &StatsReporter{
+		cache:    c,
+		sink:     sink,
+		interval: interval,
+		last:     c.Stats(),
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start begins publishing in the background
+func (r *StatsReporter) Start() {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				r.Report()
+			case <-r.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends background publishing
+func (r *StatsReporter) Stop() {
+	r.once.Do(func() { close(r.stop) })
+}
+
+// Report publishes the change in statistics since the previous report.
+// The hit ratio covers only that window so it tracks current behaviour.
+func (r *StatsReporter) Report() {
+	current := r.cache.Stats()
+	window := Stats{
+		Hits:          delta(current.Hits, r.last.Hits),
+		Misses:        delta(current.Misses, r.last.Misses),
+		Evictions:     delta(current.Evictions, r.last.Evictions),
+		ByteEvictions: delta(current.ByteEvictions, r.last.ByteEvictions),
+		Expirations:   delta(current.Expirations, r.last.Expirations),
+		Deletes:       delta(current.Deletes, r.last.Deletes),
+	}
+	r.last = current
+
+	r.sink.IncCounterBy("cache.evictions.capacity", int(window.Evictions))
+	r.sink.IncCounterBy("cache.evictions.bytes", int(window.ByteEvictions))
+	r.sink.IncCounterBy("cache.evictions.expired", int(window.Expirations))
+	r.sink.IncCounterBy("cache.evictions.deleted", int(window.Deletes))
+	r.sink.SetGauge("cache.size", float64(current.Size))
+	if window.Hits+window.Misses > 0 {
+		r.sink.SetGauge("cache.hit_ratio", window.HitRatio())
+	}
+}
+
+// delta returns the growth of a counter, treating a reset as a restart
+func delta(current, previous uint64) uint64 {
+	if current < previous {
+		return current
+	}
+	return current - previous
+}
@@ -0,0 +1,164 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// recordingSink collects published statistics
+type recordingSink struct {
+	counters map[string]int
+	gauges   map[string]float64
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{counters: make(map[string]int), gauges: make(map[string]float64)}
+}
+
+func (s *recordingSink) IncCounterBy(name string, value int) { s.counters[name] += value }
+func (s *recordingSink) SetGauge(name string, value float64) { s.gauges[name] = value }
+
+func TestMemoryEvictionCauses(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  MemoryOptions
+		apply func(c *MemoryCache, clock *utils.FakeClock)
+		want  Stats
+	}{
+		{
+			name: "capacity",
+			opts: MemoryOptions{MaxSize: 2, ShardSize: 1},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", "1", 0)
+				c.Set("b", "2", 0)
+				c.Set("c", "3", 0)
+			},
+			want: Stats{Evictions: 1, Size: 2},
+		},
+		{
+			name: "byte budget",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1, MaxBytes: 10},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", "aaaa", 0)
+				c.Set("b", "bbbb", 0)
+				c.Set("c", "cccc", 0)
+			},
+			want: Stats{ByteEvictions: 1, Size: 2},
+		},
+		{
+			name: "value over the whole budget",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1, MaxBytes: 10},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", "aaaa", 0)
+				c.Set("huge", []byte("0123456789abcdef"), 0)
+			},
+			want: Stats{ByteEvictions: 2},
+		},
+		{
+			name: "replacing a value recharges the budget",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1, MaxBytes: 10},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", "aaaaaaaa", 0)
+				c.Set("a", "a", 0)
+				c.Set("b", "bbbbbbbb", 0)
+			},
+			want: Stats{Size: 2},
+		},
+		{
+			name: "unsized values are not charged",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1, MaxBytes: 1},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", 1, 0)
+				c.Set("b", struct{}{}, 0)
+			},
+			want: Stats{Size: 2},
+		},
+		{
+			name: "batch over the byte budget",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1, MaxBytes: 8},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.SetMulti(map[Key]ValueTTL{"a": {"aaaa", 0}, "b": {"bbbb", 0}, "c": {"cccc", 0}})
+			},
+			want: Stats{ByteEvictions: 1, Size: 2},
+		},
+		{
+			name: "expired",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", "1", time.Second)
+				clock.Advance(time.Minute)
+				c.GetMulti([]Key{"a"})
+			},
+			want: Stats{Expirations: 1, Misses: 1},
+		},
+		{
+			name: "deleted",
+			opts: MemoryOptions{MaxSize: 100, ShardSize: 1},
+			apply: func(c *MemoryCache, clock *utils.FakeClock) {
+				c.Set("a", "1", 0)
+				c.Delete("a")
+				c.Delete("missing")
+			},
+			want: Stats{Deletes: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := utils.NewFakeClock(time.Unix(1700000000, 0))
+			tt.opts.Clock = clock
+			c := NewMemoryWithOptions(tt.opts)
+			tt.apply(c, clock)
+
+			if got := c.Stats(); got != tt.want {
+				t.Errorf("stats %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatsReporterPublishesWindow(t *testing.T) {
+	c := NewMemoryWithOptions(MemoryOptions{MaxSize: 100, ShardSize: 1, MaxBytes: 8})
+	sink := newRecordingSink()
+	reporter := NewStatsReporter(c, sink, time.Minute)
+
+	c.Set("a", "aaaa", 0)
+	c.Set("b", "bbbb", 0)
+	c.Set("c", "cccc", 0) // evicts a over the byte budget
+	c.Delete("b")
+	c.Get("c")
+	c.Get("a")
+	c.Get("c")
+	c.Get("x")
+	reporter.Report()
+
+	wantCounters := map[string]int{
+		"cache.evictions.capacity": 0,
+		"cache.evictions.bytes":    1,
+		"cache.evictions.expired":  0,
+		"cache.evictions.deleted":  1,
+	}
+	for name, want := range wantCounters {
+		if got := sink.counters[name]; got != want {
+			t.Errorf("%s = %d, want %d", name, got, want)
+		}
+	}
+	if got := sink.gauges["cache.hit_ratio"]; got != 0.5 {
+		t.Errorf("cache.hit_ratio = %v, want 0.5", got)
+	}
+	if got := sink.gauges["cache.size"]; got != 1 {
+		t.Errorf("cache.size = %v, want 1", got)
+	}
+
+	// The next window starts from the previous report
+	c.Get("c")
+	reporter.Report()
+	if got := sink.counters["cache.evictions.bytes"]; got != 1 {
+		t.Errorf("cache.evictions.bytes = %d after an idle window, want 1", got)
+	}
+	if got := sink.gauges["cache.hit_ratio"]; got != 1 {
+		t.Errorf("cache.hit_ratio = %v for an all-hit window, want 1", got)
+	}
+}
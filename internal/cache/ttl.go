@@ -1,10 +1,11 @@
 // TTL management strategies
 //
 // Dynamic TTL calculation:
-// - Content-type based TTL
+// - Content-type based TTL, configurable per media type
 // - Playlist type detection
 // - Adaptive TTL for changing content
 // - Jitter to prevent stampedes
+// - Global TTL ceiling
 
 package cache
 
@@ -25,7 +26,9 @@ type TTLOptions struct {
 	MasterTTL   time.Duration
 	MediaTTL    time.Duration
 	ApplyJitter bool
-	JitterPct   float64 // Percentage of jitter (0-1)
+	JitterPct   float64       // Percentage of jitter (0-1)
+	MaxTTL      time.Duration // Ceiling for any computed TTL (0 = none)
+	TypeTTLs    map[string]time.Duration // TTLs for non-playlist content by media type
 }
 
 // DefaultTTLOptions returns sensible default TTL options
@@ -58,6 +61,10 @@ func NewHLSTTLStrategy(opts TTLOptions) TTLStrategy {
 			} else {
 				ttl = opts.MediaTTL
 			}
+		default:
+			if typeTTL, ok := ContentTypeTTL(opts.TypeTTLs, contentType); ok {
+				ttl = typeTTL
+			}
 		}
 		
 		// Apply jitter if enabled
@@ -65,8 +72,42 @@ func NewHLSTTLStrategy(opts TTLOptions) TTLStrategy {
 			ttl = applyJitter(ttl, opts.JitterPct)
 		}
 		
-		return ttl
+		return ClampTTL(ttl, opts.MaxTTL)
+	}
+}
+
+// ContentTypeTTL looks up the TTL configured for a Content-Type value. An
+// exact media type wins over a "type/*" wildcard, which wins over "*".
+func ContentTypeTTL(ttls map[string]time.Duration, contentType string) (time.Duration, bool) {
+	if len(ttls) == 0 {
+		return 0, false
+	}
+
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	candidates := []string{"*"}
+	if mediaType != "" {
+		major, _, _ := strings.Cut(mediaType, "/")
+		candidates = []string{mediaType, major + "/*", "*"}
+	}
+
+	for _, candidate := range candidates {
+		for key, ttl := range ttls {
+			if strings.EqualFold(key, candidate) {
+				return ttl, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// ClampTTL limits ttl to max. A TTL of zero never expires, so it is
+// clamped as well. A max of zero disables the ceiling.
+func ClampTTL(ttl, max time.Duration) time.Duration {
+	if max > 0 && (ttl <= 0 || ttl > max) {
+		return max
 	}
+	return ttl
 }
 
 // isMasterPlaylist attempts to determine if a response is a master playlist
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClampTTL(t *testing.T) {
+	tests := []struct {
+		name     string
+		ttl, max time.Duration
+		want     time.Duration
+	}{
+		{"no ceiling", time.Hour, 0, time.Hour},
+		{"below ceiling", time.Minute, time.Hour, time.Minute},
+		{"above ceiling", 2 * time.Hour, time.Hour, time.Hour},
+		{"never-expiring clamped", 0, time.Hour, time.Hour},
+		{"never-expiring without ceiling", 0, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClampTTL(tt.ttl, tt.max); got != tt.want {
+				t.Errorf("ClampTTL(%v, %v) = %v, want %v", tt.ttl, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHLSTTLStrategyCeiling(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		maxTTL      time.Duration
+		want        time.Duration
+	}{
+		{"master under ceiling", "/master.m3u8", "application/vnd.apple.mpegurl", time.Minute, 30 * time.Second},
+		{"master over ceiling", "/master.m3u8", "application/vnd.apple.mpegurl", 20 * time.Second, 20 * time.Second},
+		{"media playlist", "/chunklist.m3u8", "application/x-mpegurl", 0, 5 * time.Second},
+		{"segment over ceiling", "/seg.ts", "video/mp2t", time.Second, time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultTTLOptions()
+			opts.ApplyJitter = false
+			opts.MaxTTL = tt.maxTTL
+			resp := &http.Response{Header: http.Header{"Content-Type": {tt.contentType}}}
+
+			got := NewHLSTTLStrategy(opts)(httptest.NewRequest(http.MethodGet, tt.path, nil), resp)
+			if got != tt.want {
+				t.Errorf("TTL = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentTypeTTL(t *testing.T) {
+	ttls := map[string]time.Duration{
+		"video/mp2t": 30 * time.Second,
+		"Video/*":    time.Minute,
+		"text/vtt":   10 * time.Second,
+		"*":          5 * time.Minute,
+	}
+
+	tests := []struct {
+		name        string
+		ttls        map[string]time.Duration
+		contentType string
+		want        time.Duration
+		wantOK      bool
+	}{
+		{"exact type", ttls, "video/mp2t", 30 * time.Second, true},
+		{"parameters and case ignored", ttls, "VIDEO/MP2T; charset=binary", 30 * time.Second, true},
+		{"wildcard subtype", ttls, "video/mp4", time.Minute, true},
+		{"catch-all", ttls, "application/octet-stream", 5 * time.Minute, true},
+		{"missing type uses catch-all", ttls, "", 5 * time.Minute, true},
+		{"no match", map[string]time.Duration{"video/mp2t": time.Second}, "text/vtt", 0, false},
+		{"nothing configured", nil, "video/mp2t", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ContentTypeTTL(tt.ttls, tt.contentType)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ContentTypeTTL(%q) = %v, %v, want %v, %v", tt.contentType, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHLSTTLStrategyContentTypes(t *testing.T) {
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		want        time.Duration
+	}{
+		{"configured segment type", "/seg.ts", "video/mp2t", time.Minute},
+		{"unconfigured type uses default", "/sub.vtt", "text/vtt", DefaultTTLOptions().DefaultTTL},
+		{"playlists ignore type TTLs", "/chunklist.m3u8", "application/vnd.apple.mpegurl", 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultTTLOptions()
+			opts.ApplyJitter = false
+			opts.TypeTTLs = map[string]time.Duration{"video/mp2t": time.Minute, "application/*": time.Hour}
+			resp := &http.Response{Header: http.Header{"Content-Type": {tt.contentType}}}
+
+			got := NewHLSTTLStrategy(opts)(httptest.NewRequest(http.MethodGet, tt.path, nil), resp)
+			if got != tt.want {
+				t.Errorf("TTL = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -11,6 +11,8 @@
 // - RedisConfig: Optional Redis connection
 // - LogConfig: Logging parameters
 // - MetricsConfig: Telemetry settings
+// - AdminConfig: Admin endpoint access
+// - DebugConfig: Diagnostics endpoints
 
 package config
 
@@ -28,6 +30,8 @@ type Config struct {
 	Log      LogConfig      `yaml:"log" json:"log"`
 	Metrics  MetricsConfig  `yaml:"metrics" json:"metrics"`
 	Tracing  TracingConfig  `yaml:"tracing" json:"tracing"`
+	Admin    AdminConfig    `yaml:"admin" json:"admin"`
+	Debug    DebugConfig    `yaml:"debug" json:"debug"`
 }
 
 // ServerConfig contains HTTP server settings
@@ -41,38 +45,186 @@ type ServerConfig struct {
 	MaxHeaderBytes    int           `yaml:"maxHeaderBytes" json:"maxHeaderBytes" default:"1048576"` // 1MB
 	MaxRequestBodyMB  int           `yaml:"maxRequestBodyMB" json:"maxRequestBodyMB" default:"10"`
 	EnableCompression bool          `yaml:"enableCompression" json:"enableCompression" default:"true"`
+	EnableBrotli      bool          `yaml:"enableBrotli" json:"enableBrotli" default:"true"`
+	CompressMinBytes  int           `yaml:"compressMinBytes" json:"compressMinBytes" default:"1024"`
+	CompressTypes     []string      `yaml:"compressTypes" json:"compressTypes" default:"[\"application/vnd.apple.mpegurl\", \"application/x-mpegurl\", \"audio/mpegurl\", \"application/json\", \"text/\"]"`
 	TrustedProxies    []string      `yaml:"trustedProxies" json:"trustedProxies"`
+	AllowedCIDRs      []string      `yaml:"allowedCIDRs" json:"allowedCIDRs"`
+	DeniedCIDRs       []string      `yaml:"deniedCIDRs" json:"deniedCIDRs"`
+	ServerTiming      bool          `yaml:"serverTiming" json:"serverTiming" default:"false"`
+	ResponseHeaders   map[string]string `yaml:"responseHeaders" json:"responseHeaders"`
 }
 
 // OriginConfig contains settings for communicating with origin servers
 type OriginConfig struct {
 	Timeout               time.Duration `yaml:"timeout" json:"timeout" default:"5s"`
+	BodyTimeout           time.Duration `yaml:"bodyTimeout" json:"bodyTimeout" default:"10s"`
 	MaxIdleConns          int           `yaml:"maxIdleConns" json:"maxIdleConns" default:"100"`
 	MaxIdleConnsPerHost   int           `yaml:"maxIdleConnsPerHost" json:"maxIdleConnsPerHost" default:"10"`
 	MaxConnsPerHost       int           `yaml:"maxConnsPerHost" json:"maxConnsPerHost" default:"100"`
+	MaxFetchesPerHost     int           `yaml:"maxFetchesPerHost" json:"maxFetchesPerHost" default:"0"`
 	IdleConnTimeout       time.Duration `yaml:"idleConnTimeout" json:"idleConnTimeout" default:"90s"`
 	TLSHandshakeTimeout   time.Duration `yaml:"tlsHandshakeTimeout" json:"tlsHandshakeTimeout" default:"10s"`
 	ExpectContinueTimeout time.Duration `yaml:"expectContinueTimeout" json:"expectContinueTimeout" default:"1s"`
 	DefaultScheme         string        `yaml:"defaultScheme" json:"defaultScheme" default:"https"`
 	BaseURL               string        `yaml:"baseURL" json:"baseURL"`
+	UserAgent             string        `yaml:"userAgent" json:"userAgent" default:"Ilinden-HLS-Proxy"`
+	RequestHeaders        map[string]string `yaml:"requestHeaders" json:"requestHeaders"`
+	SensitiveHeaders      []string      `yaml:"sensitiveHeaders" json:"sensitiveHeaders" default:"[\"Authorization\", \"Cookie\", \"Proxy-Authorization\", \"X-Api-Key\"]"`
+	ForwardHeaders        []string      `yaml:"forwardHeaders" json:"forwardHeaders"`
+	DropHeaders           []string      `yaml:"dropHeaders" json:"dropHeaders"`
+	ResponseForwardHeaders []string     `yaml:"responseForwardHeaders" json:"responseForwardHeaders"`
+	ResponseDropHeaders   []string      `yaml:"responseDropHeaders" json:"responseDropHeaders" default:"[\"Server\", \"X-Powered-By\", \"X-AspNet-Version\", \"X-AspNetMvc-Version\", \"X-Runtime\", \"X-Backend-Server\"]"`
+	MaxForwardedHeaderBytes int         `yaml:"maxForwardedHeaderBytes" json:"maxForwardedHeaderBytes" default:"0"`
+	ForwardedHeaders      bool          `yaml:"forwardedHeaders" json:"forwardedHeaders" default:"false"`
+	Via                   string        `yaml:"via" json:"via"`
 	RetryCount            int           `yaml:"retryCount" json:"retryCount" default:"3"`
 	RetryWaitMin          time.Duration `yaml:"retryWaitMin" json:"retryWaitMin" default:"100ms"`
 	RetryWaitMax          time.Duration `yaml:"retryWaitMax" json:"retryWaitMax" default:"2s"`
 	CircuitBreaker        bool          `yaml:"circuitBreaker" json:"circuitBreaker" default:"true"`
+	BreakerThreshold      int           `yaml:"breakerThreshold" json:"breakerThreshold" default:"5"`
+	BreakerCooldown       time.Duration `yaml:"breakerCooldown" json:"breakerCooldown" default:"30s"`
+	RetryAfterMax         time.Duration `yaml:"retryAfterMax" json:"retryAfterMax" default:"1m"`
+	Backups               []string      `yaml:"backups" json:"backups"`
+	AllowedHosts          []string      `yaml:"allowedHosts" json:"allowedHosts"`
+	RewriteAbsoluteURLs   bool          `yaml:"rewriteAbsoluteURLs" json:"rewriteAbsoluteURLs" default:"false"`
+	ProxyHosts            []string      `yaml:"proxyHosts" json:"proxyHosts"`
+	MaxURLLength          int           `yaml:"maxURLLength" json:"maxURLLength" default:"0"`
+	SegmentTitles         string        `yaml:"segmentTitles" json:"segmentTitles" default:"keep"`
+	PlaylistPatterns      []string      `yaml:"playlistPatterns" json:"playlistPatterns"`
+	SegmentPatterns       []string      `yaml:"segmentPatterns" json:"segmentPatterns"`
+	PassthroughPatterns   []string      `yaml:"passthroughPatterns" json:"passthroughPatterns"`
+	PlaylistValidation    string        `yaml:"playlistValidation" json:"playlistValidation" default:"warn"`
+	MissingBandwidth      string        `yaml:"missingBandwidth" json:"missingBandwidth" default:"reject"`
+	DefaultBandwidth      int64         `yaml:"defaultBandwidth" json:"defaultBandwidth" default:"0"`
+	MaxConcurrentParses   int           `yaml:"maxConcurrentParses" json:"maxConcurrentParses" default:"0"`
+	ParseQueueTimeout     time.Duration `yaml:"parseQueueTimeout" json:"parseQueueTimeout" default:"1s"`
+	MaxDecodedBytes       int64         `yaml:"maxDecodedBytes" json:"maxDecodedBytes" default:"10485760"` // 10MB
+	FallbackPlaylist      string        `yaml:"fallbackPlaylist" json:"fallbackPlaylist"`
+	FallbackTTL           time.Duration `yaml:"fallbackTTL" json:"fallbackTTL" default:"2s"`
+	Routes                []OriginRoute `yaml:"routes" json:"routes"`
+	TLS                   OriginTLSConfig `yaml:"tls" json:"tls"`
+	HealthCheck           OriginHealthConfig `yaml:"healthCheck" json:"healthCheck"`
+	VariantFilter         VariantFilterConfig `yaml:"variantFilter" json:"variantFilter"`
+	RenditionFilter       RenditionFilterConfig `yaml:"renditionFilter" json:"renditionFilter"`
+	LiveWindow            LiveWindowConfig `yaml:"liveWindow" json:"liveWindow"`
+	DNS                   OriginDNSConfig `yaml:"dns" json:"dns"`
+}
+
+// VariantFilterConfig limits the variants listed in rewritten master
+// playlists; I-frame streams of removed variants are removed with them.
+// Zero values impose no limit.
+type VariantFilterConfig struct {
+	MinBandwidth int64 `yaml:"minBandwidth" json:"minBandwidth" default:"0"`
+	MaxBandwidth int64 `yaml:"maxBandwidth" json:"maxBandwidth" default:"0"`
+	MaxWidth     int   `yaml:"maxWidth" json:"maxWidth" default:"0"`
+	MaxHeight    int   `yaml:"maxHeight" json:"maxHeight" default:"0"`
+}
+
+// RenditionFilterConfig removes renditions from rewritten master playlists
+// by capability; a rendition group is never left empty. With QueryControl,
+// clients may narrow the filter per request with the excludeCharacteristics
+// (comma-separated) and maxChannels query parameters.
+type RenditionFilterConfig struct {
+	ExcludeCharacteristics []string `yaml:"excludeCharacteristics" json:"excludeCharacteristics"`
+	MaxChannels            int      `yaml:"maxChannels" json:"maxChannels" default:"0"`
+	QueryControl           bool     `yaml:"queryControl" json:"queryControl" default:"false"`
+}
+
+// LiveWindowConfig trims live media playlists to their most recent
+// segments, advancing MEDIA-SEQUENCE accordingly. VOD and EVENT playlists
+// are never trimmed, and live playlists keep at least three target
+// durations whatever the limits. Zero values impose no limit.
+type LiveWindowConfig struct {
+	MaxSegments int           `yaml:"maxSegments" json:"maxSegments" default:"0"`
+	MaxDuration time.Duration `yaml:"maxDuration" json:"maxDuration" default:"0s"`
+}
+
+// OriginDNSConfig controls how origin host names are resolved. Answers
+// are cached for cacheTTL regardless of the record TTL, so keep it short
+// where origins fail over through DNS; zero disables caching. Resolver is
+// the host:port of a DNS server used instead of the system resolver.
+type OriginDNSConfig struct {
+	CacheTTL        time.Duration `yaml:"cacheTTL" json:"cacheTTL" default:"0s"`
+	Resolver        string        `yaml:"resolver" json:"resolver"`
+	ResolverTimeout time.Duration `yaml:"resolverTimeout" json:"resolverTimeout" default:"2s"`
+}
+
+// OriginHealthConfig controls the periodic origin reachability probe
+// that feeds the readiness endpoint
+type OriginHealthConfig struct {
+	Enabled          bool          `yaml:"enabled" json:"enabled" default:"false"`
+	Path             string        `yaml:"path" json:"path" default:"/"`
+	Interval         time.Duration `yaml:"interval" json:"interval" default:"10s"`
+	Timeout          time.Duration `yaml:"timeout" json:"timeout" default:"2s"`
+	FailureThreshold int           `yaml:"failureThreshold" json:"failureThreshold" default:"3"`
+	SuccessThreshold int           `yaml:"successThreshold" json:"successThreshold" default:"2"`
+}
+
+// OriginRoute maps requests matching a host and/or path prefix to a
+// dedicated origin. Routes are matched before falling back to BaseURL.
+// Backups are tried in order when the primary origin is failing.
+type OriginRoute struct {
+	Name         string        `yaml:"name" json:"name"`
+	Host         string        `yaml:"host" json:"host"`
+	PathPrefix   string        `yaml:"pathPrefix" json:"pathPrefix"`
+	BaseURL      string        `yaml:"baseURL" json:"baseURL"`
+	Backups      []string      `yaml:"backups" json:"backups"`
+	StripPrefix  bool          `yaml:"stripPrefix" json:"stripPrefix"`
+	Timeout      time.Duration `yaml:"timeout" json:"timeout"`
+	AllowedHosts []string      `yaml:"allowedHosts" json:"allowedHosts"`
+	TokenParam   string        `yaml:"tokenParam" json:"tokenParam"`
+	FallbackPlaylist string    `yaml:"fallbackPlaylist" json:"fallbackPlaylist"`
+	TLS          *OriginTLSConfig `yaml:"tls" json:"tls"`
+}
+
+// OriginTLSConfig holds the TLS settings for origin connections: a client
+// certificate for origins requiring mutual TLS, extra trusted CAs and, for
+// test origins only, disabled certificate verification
+type OriginTLSConfig struct {
+	CertFile           string `yaml:"certFile" json:"certFile"`
+	KeyFile            string `yaml:"keyFile" json:"keyFile"`
+	CAFile             string `yaml:"caFile" json:"caFile"`
+	InsecureSkipVerify bool   `yaml:"insecureSkipVerify" json:"insecureSkipVerify" default:"false"`
 }
 
 // JWTConfig contains JWT validation parameters
 type JWTConfig struct {
-	Enabled         bool     `yaml:"enabled" json:"enabled" default:"true"`
-	ParamName       string   `yaml:"paramName" json:"paramName" default:"token"`
-	HeaderName      string   `yaml:"headerName" json:"headerName" default:"Authorization"`
-	Secret          string   `yaml:"secret" json:"secret"`
-	KeysURL         string   `yaml:"keysUrl" json:"keysUrl"`
-	RequiredClaims  []string `yaml:"requiredClaims" json:"requiredClaims"`
-	ClaimsNamespace string   `yaml:"claimsNamespace" json:"claimsNamespace"`
-	Issuer          string   `yaml:"issuer" json:"issuer"`
-	Audience        string   `yaml:"audience" json:"audience"`
-	AllowedAlgs     []string `yaml:"allowedAlgs" json:"allowedAlgs" default:"[\"HS256\", \"RS256\"]"`
+	Enabled              bool          `yaml:"enabled" json:"enabled" default:"true"`
+	Optional             bool          `yaml:"optional" json:"optional" default:"false"`
+	PublicPaths          []string      `yaml:"publicPaths" json:"publicPaths"`
+	ParamName            string        `yaml:"paramName" json:"paramName" default:"token"`
+	HeaderName           string        `yaml:"headerName" json:"headerName" default:"Authorization"`
+	TokenPrecedence      string        `yaml:"tokenPrecedence" json:"tokenPrecedence" default:"header"`
+	Secret               string        `yaml:"secret" json:"secret"`
+	KeysURL              string        `yaml:"keysUrl" json:"keysUrl"`
+	KeysRefresh          time.Duration `yaml:"keysRefresh" json:"keysRefresh" default:"10m"`
+	KeysMinInterval      time.Duration `yaml:"keysMinInterval" json:"keysMinInterval" default:"30s"`
+	KeysTimeout          time.Duration `yaml:"keysTimeout" json:"keysTimeout" default:"5s"`
+	KeysMaxBytes         int64         `yaml:"keysMaxBytes" json:"keysMaxBytes" default:"1048576"`
+	KeysBreakerThreshold int           `yaml:"keysBreakerThreshold" json:"keysBreakerThreshold" default:"3"`
+	KeysBreakerCooldown  time.Duration `yaml:"keysBreakerCooldown" json:"keysBreakerCooldown" default:"1m"`
+	RequiredClaims       []string      `yaml:"requiredClaims" json:"requiredClaims"`
+	ClaimsNamespace      string        `yaml:"claimsNamespace" json:"claimsNamespace"`
+	PlayerIDClaim        string        `yaml:"playerIdClaim" json:"playerIdClaim"`
+	RequiredRoles        []string      `yaml:"requiredRoles" json:"requiredRoles"`
+	RequiredScopes       []string      `yaml:"requiredScopes" json:"requiredScopes"`
+	StreamClaim          string        `yaml:"streamClaim" json:"streamClaim"`
+	StreamMatch          string        `yaml:"streamMatch" json:"streamMatch" default:"prefix"`
+	AccessStartClaim     string        `yaml:"accessStartClaim" json:"accessStartClaim"`
+	AccessEndClaim       string        `yaml:"accessEndClaim" json:"accessEndClaim"`
+	BindIP               bool          `yaml:"bindIP" json:"bindIP" default:"false"`
+	BindIPClaim          string        `yaml:"bindIPClaim" json:"bindIPClaim" default:"ip"`
+	BindIPv4Prefix       int           `yaml:"bindIPv4Prefix" json:"bindIPv4Prefix" default:"32"`
+	BindIPv6Prefix       int           `yaml:"bindIPv6Prefix" json:"bindIPv6Prefix" default:"64"`
+	BindUserAgent        bool          `yaml:"bindUserAgent" json:"bindUserAgent" default:"false"`
+	BindUAClaim          string        `yaml:"bindUAClaim" json:"bindUAClaim" default:"ua"`
+	Issuer               string        `yaml:"issuer" json:"issuer"`
+	Audience             string        `yaml:"audience" json:"audience"`
+	Issuers              []string      `yaml:"issuers" json:"issuers"`
+	Audiences            []string      `yaml:"audiences" json:"audiences"`
+	RequiredType         string        `yaml:"requiredType" json:"requiredType"`
+	AllowedAlgs          []string      `yaml:"allowedAlgs" json:"allowedAlgs" default:"[\"HS256\", \"RS256\"]"`
 }
 
 // CacheConfig contains caching behavior settings
@@ -80,10 +232,45 @@ type CacheConfig struct {
 	Enabled            bool          `yaml:"enabled" json:"enabled" default:"true"`
 	TTLMaster          time.Duration `yaml:"ttlMaster" json:"ttlMaster" default:"10s"`
 	TTLMedia           time.Duration `yaml:"ttlMedia" json:"ttlMedia" default:"2s"`
+	TTLVOD             time.Duration `yaml:"ttlVod" json:"ttlVod" default:"5m"`
+	TTLEvent           time.Duration `yaml:"ttlEvent" json:"ttlEvent" default:"2s"`
+	TTLByContentType   map[string]time.Duration `yaml:"ttlByContentType" json:"ttlByContentType"`
+	MaxTTL             time.Duration `yaml:"maxTTL" json:"maxTTL" default:"0s"`
+	ClampTTLToToken    bool          `yaml:"clampTTLToToken" json:"clampTTLToToken" default:"false"`
+	CacheAgeHeader     bool          `yaml:"cacheAgeHeader" json:"cacheAgeHeader" default:"false"`
 	MaxSize            int           `yaml:"maxSize" json:"maxSize" default:"10000"`
+	MaxBytes           int64         `yaml:"maxBytes" json:"maxBytes" default:"0"`
+	StreamThresholdBytes int64       `yaml:"streamThresholdBytes" json:"streamThresholdBytes" default:"0"`
+	StreamBufferBytes  int           `yaml:"streamBufferBytes" json:"streamBufferBytes" default:"32768"`
+	UnknownLength      string        `yaml:"unknownLength" json:"unknownLength" default:"auto"`
+	ParsedPlaylistBytes int64        `yaml:"parsedPlaylistBytes" json:"parsedPlaylistBytes" default:"0"`
+	PrecompressPlaylists bool        `yaml:"precompressPlaylists" json:"precompressPlaylists" default:"false"`
+	CompressStorage    bool          `yaml:"compressStorage" json:"compressStorage" default:"false"`
+	NormalizeQuery     bool          `yaml:"normalizeQuery" json:"normalizeQuery" default:"true"`
 	ShardCount         int           `yaml:"shardCount" json:"shardCount" default:"16"`
+	AutoShards         bool          `yaml:"autoShards" json:"autoShards" default:"false"`
 	StaleWhileRevalidate bool         `yaml:"staleWhileRevalidate" json:"staleWhileRevalidate" default:"true"`
+	StampedeWait       time.Duration `yaml:"stampedeWait" json:"stampedeWait" default:"0s"`
+	StaleTTL           time.Duration `yaml:"staleTTL" json:"staleTTL" default:"0s"`
 	UseRedis           bool          `yaml:"useRedis" json:"useRedis" default:"false"`
+	Namespace          string        `yaml:"namespace" json:"namespace"`
+	TTLNegative        time.Duration `yaml:"ttlNegative" json:"ttlNegative" default:"5s"`
+	NegativeStatuses   []int         `yaml:"negativeStatuses" json:"negativeStatuses" default:"[404, 410]"`
+	Prefetch           bool          `yaml:"prefetch" json:"prefetch" default:"false"`
+	PrefetchVariants   int           `yaml:"prefetchVariants" json:"prefetchVariants" default:"3"`
+	PrefetchConcurrency int          `yaml:"prefetchConcurrency" json:"prefetchConcurrency" default:"4"`
+	Bypass             CacheBypassConfig `yaml:"bypass" json:"bypass"`
+}
+
+// CacheBypassConfig lets trusted clients skip the cache read for a request
+// with "<header>: bypass" or the param query flag. The origin response
+// still refreshes the cache. Clients are trusted when they connect from
+// allowedCIDRs or send the admin token in X-Ilinden-Admin-Token.
+type CacheBypassConfig struct {
+	Enabled      bool     `yaml:"enabled" json:"enabled" default:"false"`
+	Header       string   `yaml:"header" json:"header" default:"X-Ilinden-Cache"`
+	Param        string   `yaml:"param" json:"param"`
+	AllowedCIDRs []string `yaml:"allowedCIDRs" json:"allowedCIDRs"`
 }
 
 // RedisConfig contains optional Redis connection details
@@ -102,6 +289,8 @@ type RedisConfig struct {
 	MaxConnAge     time.Duration `yaml:"maxConnAge" json:"maxConnAge" default:"30m"`
 	TrackingPrefix string        `yaml:"trackingPrefix" json:"trackingPrefix" default:"ilinden:player:"`
 	TrackingExpiry time.Duration `yaml:"trackingExpiry" json:"trackingExpiry" default:"5m"`
+	TrackingDebounce time.Duration `yaml:"trackingDebounce" json:"trackingDebounce" default:"0s"`
+	BeaconPaths    []string      `yaml:"beaconPaths" json:"beaconPaths"`
 }
 
 // LogConfig contains logging parameters
@@ -115,10 +304,12 @@ type LogConfig struct {
 
 // MetricsConfig contains telemetry settings
 type MetricsConfig struct {
-	Enabled       bool   `yaml:"enabled" json:"enabled" default:"true"`
-	Address       string `yaml:"address" json:"address" default:":9090"`
-	Path          string `yaml:"path" json:"path" default:"/metrics"`
-	CollectSystem bool   `yaml:"collectSystem" json:"collectSystem" default:"true"`
+	Enabled            bool          `yaml:"enabled" json:"enabled" default:"true"`
+	Address            string        `yaml:"address" json:"address" default:":9090"`
+	Path               string        `yaml:"path" json:"path" default:"/metrics"`
+	CollectSystem      bool          `yaml:"collectSystem" json:"collectSystem" default:"true"`
+	SystemInterval     time.Duration `yaml:"systemInterval" json:"systemInterval" default:"15s"`
+	CacheStatsInterval time.Duration `yaml:"cacheStatsInterval" json:"cacheStatsInterval" default:"15s"`
 }
 
 // TracingConfig contains distributed tracing settings
@@ -127,4 +318,20 @@ type TracingConfig struct {
 	ServiceName string  `yaml:"serviceName" json:"serviceName" default:"ilinden"`
 	Endpoint    string  `yaml:"endpoint" json:"endpoint" default:"localhost:4317"`
 	SampleRate  float64 `yaml:"sampleRate" json:"sampleRate" default:"0.1"`
-}
\ No newline at end of file
+}
+
+// AdminConfig controls access to administrative endpoints. The CIDR lists
+// replace the server's for admin routes when either is set.
+type AdminConfig struct {
+	Token        string   `yaml:"token" json:"-"`
+	AllowedCIDRs []string `yaml:"allowedCIDRs" json:"allowedCIDRs"`
+	DeniedCIDRs  []string `yaml:"deniedCIDRs" json:"deniedCIDRs"`
+}
+
+// DebugConfig enables diagnostics endpoints on the internal listener and
+// sampled logging of rewritten playlists
+type DebugConfig struct {
+	Pprof bool `yaml:"pprof" json:"pprof" default:"false"`
+	CaptureSampleRate float64 `yaml:"captureSampleRate" json:"captureSampleRate" default:"0"`
+	CaptureMaxBytes   int     `yaml:"captureMaxBytes" json:"captureMaxBytes" default:"4096"`
+}
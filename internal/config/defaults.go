@@ -79,7 +79,7 @@ func setDefaultsForStruct(val reflect.Value) {
 			}
 		case reflect.Slice:
 			if field.Len() == 0 {
-				// Process array default value in format [\"value1\", \"value2\"]
+				// Process array default value in format [\"value1\", \"value2\"] or [1, 2]
 				if field.Type().Elem().Kind() == reflect.String {
 					trimmed := strings.Trim(defaultValue, "[]")
 					if trimmed != "" {
@@ -91,6 +91,19 @@ func setDefaultsForStruct(val reflect.Value) {
 						}
 						field.Set(slice)
 					}
+				} else if field.Type().Elem().Kind() == reflect.Int {
+					trimmed := strings.Trim(defaultValue, "[]")
+					if trimmed != "" {
+						items := strings.Split(trimmed, ",")
+						slice := reflect.MakeSlice(field.Type(), 0, len(items))
+						for _, item := range items {
+							intVal, err := strconv.Atoi(strings.TrimSpace(item))
+							if err == nil {
+								slice = reflect.Append(slice, reflect.ValueOf(intVal))
+							}
+						}
+						field.Set(slice)
+					}
 				}
 			}
 		case reflect.Struct:
@@ -11,16 +11,21 @@
 package config
 
 import (
+	"crypto/tls"
 	"fmt"
 	"io/ioutil"
+	"net"
+	"net/url"
 	"os"
-	"path/filepath"
 	"reflect"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // LoadConfig loads configuration from the specified file path and
@@ -185,6 +190,18 @@ func setFieldValue(field reflect.Value, value string) error {
 				slice.Index(i).SetString(strings.TrimSpace(item))
 			}
 			field.Set(slice)
+		} else if field.Type().Elem().Kind() == reflect.Int {
+			// Parse comma-separated list of integers
+			items := strings.Split(value, ",")
+			slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+			for i, item := range items {
+				intVal, err := strconv.Atoi(strings.TrimSpace(item))
+				if err != nil {
+					return fmt.Errorf("invalid integer value: %s", item)
+				}
+				slice.Index(i).SetInt(int64(intVal))
+			}
+			field.Set(slice)
 		} else {
 			return fmt.Errorf("unsupported slice type: %s", field.Type().Elem().Kind())
 		}
@@ -203,7 +220,211 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid server port: %d", c.Server.Port)
 	}
 	
+	// Network access lists
+	for _, cidrs := range [][]string{c.Server.TrustedProxies, c.Server.AllowedCIDRs, c.Server.DeniedCIDRs, c.Cache.Bypass.AllowedCIDRs, c.Admin.AllowedCIDRs, c.Admin.DeniedCIDRs} {
+		if _, err := utils.ParseCIDRs(cidrs); err != nil {
+			return err
+		}
+	}
+	
+	// Origin route validation
+	for i, route := range c.Origin.Routes {
+		if route.Host == "" && route.PathPrefix == "" {
+			return fmt.Errorf("origin route %d must define a host or pathPrefix", i)
+		}
+		if route.BaseURL == "" {
+			return fmt.Errorf("origin route %d has no baseURL", i)
+		}
+		if u, err := url.Parse(route.BaseURL); err != nil || u.Host == "" {
+			return fmt.Errorf("origin route %d has an invalid baseURL: %s", i, route.BaseURL)
+		}
+		for _, backup := range route.Backups {
+			if u, err := url.Parse(backup); err != nil || u.Host == "" {
+				return fmt.Errorf("origin route %d has an invalid backup: %s", i, backup)
+			}
+		}
+		if route.TLS != nil {
+			if _, err := route.TLS.ClientConfig(); err != nil {
+				return fmt.Errorf("origin route %d TLS: %w", i, err)
+			}
+		}
+		if err := checkFallbackPlaylist(route.FallbackPlaylist); err != nil {
+			return fmt.Errorf("origin route %d: %w", i, err)
+		}
+	}
+	
+	// Origin TLS files must load
+	if _, err := c.Origin.TLS.ClientConfig(); err != nil {
+		return fmt.Errorf("origin TLS: %w", err)
+	}
+	
+	// Backup origin validation
+	if len(c.Origin.Backups) > 0 && c.Origin.BaseURL == "" {
+		return fmt.Errorf("origin backups require a baseURL")
+	}
+	for _, backup := range c.Origin.Backups {
+		if u, err := url.Parse(backup); err != nil || u.Host == "" {
+			return fmt.Errorf("invalid origin backup: %s", backup)
+		}
+	}
+	
+	// Fallback playlists are read at startup and must be usable
+	if err := checkFallbackPlaylist(c.Origin.FallbackPlaylist); err != nil {
+		return err
+	}
+	
+	// Request classification patterns
+	patterns := append(append([]string(nil), c.Origin.PlaylistPatterns...), c.Origin.SegmentPatterns...)
+	for _, pattern := range append(patterns, c.Origin.PassthroughPatterns...) {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid origin URL pattern %q: %w", pattern, err)
+		}
+	}
+	
+	// Playlist compliance mode
+	switch c.Origin.PlaylistValidation {
+	case "", "off", "warn", "strict":
+	default:
+		return fmt.Errorf("invalid origin playlistValidation: %s", c.Origin.PlaylistValidation)
+	}
+	switch c.Origin.MissingBandwidth {
+	case "", "reject", "default":
+	default:
+		return fmt.Errorf("invalid origin missingBandwidth: %s", c.Origin.MissingBandwidth)
+	}
+	if c.Origin.DefaultBandwidth < 0 {
+		return fmt.Errorf("invalid origin defaultBandwidth: %d", c.Origin.DefaultBandwidth)
+	}
+	
+	// Variant filter limits
+	vf := c.Origin.VariantFilter
+	if vf.MinBandwidth < 0 || vf.MaxBandwidth < 0 || vf.MaxWidth < 0 || vf.MaxHeight < 0 {
+		return fmt.Errorf("invalid origin variantFilter: limits must not be negative")
+	}
+	if vf.MaxBandwidth > 0 && vf.MinBandwidth > vf.MaxBandwidth {
+		return fmt.Errorf("origin variantFilter minBandwidth %d exceeds maxBandwidth %d", vf.MinBandwidth, vf.MaxBandwidth)
+	}
+	
+	// Rendition filter
+	if c.Origin.RenditionFilter.MaxChannels < 0 {
+		return fmt.Errorf("invalid origin renditionFilter maxChannels: %d", c.Origin.RenditionFilter.MaxChannels)
+	}
+	
+	// Live window trimming
+	if c.Origin.LiveWindow.MaxSegments < 0 || c.Origin.LiveWindow.MaxDuration < 0 {
+		return fmt.Errorf("invalid origin liveWindow: limits must not be negative")
+	}
+	
+	// Origin DNS resolution
+	if c.Origin.DNS.CacheTTL < 0 {
+		return fmt.Errorf("invalid origin dns cacheTTL: %s", c.Origin.DNS.CacheTTL)
+	}
+	if c.Origin.DNS.ResolverTimeout < 0 {
+		return fmt.Errorf("invalid origin dns resolverTimeout: %s", c.Origin.DNS.ResolverTimeout)
+	}
+	if c.Origin.DNS.Resolver != "" {
+		if _, _, err := net.SplitHostPort(c.Origin.DNS.Resolver); err != nil {
+			return fmt.Errorf("invalid origin dns resolver %q: %w", c.Origin.DNS.Resolver, err)
+		}
+	}
+	
+	// EXTINF title handling
+	switch c.Origin.SegmentTitles {
+	case "", "keep", "strip":
+	default:
+		return fmt.Errorf("invalid origin segmentTitles: %s", c.Origin.SegmentTitles)
+	}
+	
+	// URL length limit
+	if c.Origin.MaxURLLength < 0 {
+		return fmt.Errorf("invalid origin maxURLLength: %d", c.Origin.MaxURLLength)
+	}
+	if c.Origin.MaxDecodedBytes < 0 {
+		return fmt.Errorf("invalid origin maxDecodedBytes: %d", c.Origin.MaxDecodedBytes)
+	}
+	
+	// Origin health check validation if enabled
+	if c.Origin.HealthCheck.Enabled {
+		if c.Origin.BaseURL == "" {
+			return fmt.Errorf("origin health check is enabled but no baseURL is provided")
+		}
+		if c.Origin.HealthCheck.Interval <= 0 {
+			return fmt.Errorf("invalid origin health check interval: %s", c.Origin.HealthCheck.Interval)
+		}
+	}
+	
+	// Memory cache sizing; every shard must be able to hold an item
+	if c.Cache.MaxSize < 0 {
+		return fmt.Errorf("invalid cache maxSize: %d", c.Cache.MaxSize)
+	}
+	if c.Cache.MaxBytes < 0 {
+		return fmt.Errorf("invalid cache maxBytes: %d", c.Cache.MaxBytes)
+	}
+	if c.Cache.ShardCount < 0 {
+		return fmt.Errorf("invalid cache shardCount: %d", c.Cache.ShardCount)
+	}
+	if !c.Cache.AutoShards && c.Cache.MaxSize > 0 && c.Cache.ShardCount > c.Cache.MaxSize {
+		return fmt.Errorf("cache shardCount %d exceeds maxSize %d", c.Cache.ShardCount, c.Cache.MaxSize)
+	}
+	
+	// Content-type TTLs
+	for contentType, ttl := range c.Cache.TTLByContentType {
+		if ttl < 0 {
+			return fmt.Errorf("invalid cache TTL for content type %q: %s", contentType, ttl)
+		}
+	}
+	
+	// Streaming
+	if c.Cache.StreamBufferBytes < 0 {
+		return fmt.Errorf("invalid cache streamBufferBytes: %d", c.Cache.StreamBufferBytes)
+	}
+	switch c.Cache.UnknownLength {
+	case "", "auto", "stream", "buffer":
+	default:
+		return fmt.Errorf("invalid cache unknownLength mode: %s", c.Cache.UnknownLength)
+	}
+	
+	// Stampede protection
+	if c.Cache.StampedeWait < 0 {
+		return fmt.Errorf("invalid cache stampedeWait: %s", c.Cache.StampedeWait)
+	}
+	if c.Cache.StaleTTL < 0 {
+		return fmt.Errorf("invalid cache staleTTL: %s", c.Cache.StaleTTL)
+	}
+	
+	// Forced cache bypass
+	if c.Cache.Bypass.Enabled {
+		if c.Cache.Bypass.Header == "" && c.Cache.Bypass.Param == "" {
+			return fmt.Errorf("cache bypass is enabled without a header or param")
+		}
+		if len(c.Cache.Bypass.AllowedCIDRs) == 0 && c.Admin.Token == "" {
+			return fmt.Errorf("cache bypass is enabled but neither allowedCIDRs nor an admin token allow it")
+		}
+	}
+	
 	// JWT validation if enabled
+	switch c.JWT.StreamMatch {
+	case "", "prefix", "glob", "exact":
+	default:
+		return fmt.Errorf("invalid JWT stream match mode: %s", c.JWT.StreamMatch)
+	}
+	switch c.JWT.TokenPrecedence {
+	case "", "header", "query", "nonExpired":
+	default:
+		return fmt.Errorf("invalid JWT token precedence: %s", c.JWT.TokenPrecedence)
+	}
+	if c.JWT.BindIPv4Prefix < 0 || c.JWT.BindIPv4Prefix > 32 {
+		return fmt.Errorf("invalid JWT IPv4 binding prefix: %d", c.JWT.BindIPv4Prefix)
+	}
+	if c.JWT.BindIPv6Prefix < 0 || c.JWT.BindIPv6Prefix > 128 {
+		return fmt.Errorf("invalid JWT IPv6 binding prefix: %d", c.JWT.BindIPv6Prefix)
+	}
+	if c.JWT.KeysTimeout < 0 {
+		return fmt.Errorf("invalid JWT keysTimeout: %s", c.JWT.KeysTimeout)
+	}
+	if c.JWT.KeysMaxBytes < 0 {
+		return fmt.Errorf("invalid JWT keysMaxBytes: %d", c.JWT.KeysMaxBytes)
+	}
 	if c.JWT.Enabled {
 		if c.JWT.Secret == "" && c.JWT.KeysURL == "" {
 			return fmt.Errorf("JWT is enabled but neither Secret nor KeysURL is provided")
@@ -214,6 +435,9 @@ func (c *Config) Validate() error {
 	if c.Redis.Enabled && len(c.Redis.Addresses) == 0 {
 		return fmt.Errorf("Redis is enabled but no addresses are provided")
 	}
+	if c.Redis.TrackingDebounce < 0 {
+		return fmt.Errorf("invalid Redis trackingDebounce: %s", c.Redis.TrackingDebounce)
+	}
 	
 	return nil
 }
@@ -221,4 +445,64 @@ func (c *Config) Validate() error {
 // GetAddress returns the full server address with host and port
 func (c *Config) GetAddress() string {
 	return fmt.Sprintf("%s:%d", c.Server.Host, c.Server.Port)
+}
+
+// ClientConfig loads the client TLS configuration, nil when nothing is set
+func (t *OriginTLSConfig) ClientConfig() (*tls.Config, error) {
+	cfg, err := utils.ClientTLSConfig(t.CertFile, t.KeyFile, t.CAFile)
+	if err != nil || !t.InsecureSkipVerify {
+		return cfg, err
+	}
+	
+	if cfg == nil {
+		cfg = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	cfg.InsecureSkipVerify = true
+	return cfg, nil
+}
+
+// checkFallbackPlaylist verifies that a configured fallback playlist file
+// can be read and is an M3U8 playlist
+func checkFallbackPlaylist(path string) error {
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("fallback playlist: %w", err)
+	}
+	if !strings.HasPrefix(strings.TrimSpace(string(data)), "#EXTM3U") {
+		return fmt.Errorf("fallback playlist %s is not an M3U8 playlist", path)
+	}
+	return nil
+}
+
+// TokenParams returns the query parameters that may carry a token: the JWT
+// parameter and any per-route origin token parameters
+func (c *Config) TokenParams() []string {
+	var params []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		key := strings.ToLower(name)
+		if name == "" || seen[key] {
+			return
+		}
+		seen[key] = true
+		params = append(params, name)
+	}
+
+	add(c.JWT.ParamName)
+	for _, route := range c.Origin.Routes {
+		add(route.TokenParam)
+	}
+	return params
+}
+
+// AdminCIDRs returns the allow and deny lists for admin routes: the admin
+// lists when either is set, the server lists otherwise
+func (c *Config) AdminCIDRs() (allow, deny []string) {
+	if len(c.Admin.AllowedCIDRs) > 0 || len(c.Admin.DeniedCIDRs) > 0 {
+		return c.Admin.AllowedCIDRs, c.Admin.DeniedCIDRs
+	}
+	return c.Server.AllowedCIDRs, c.Server.DeniedCIDRs
 }
\ No newline at end of file
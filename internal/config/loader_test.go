@@ -0,0 +1,627 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig returns a default configuration that passes validation
+func validConfig() *Config {
+	cfg := &Config{}
+	SetDefaults(cfg)
+	cfg.JWT.Enabled = false
+	cfg.Origin.BaseURL = "https://origin.example.com"
+	return cfg
+}
+
+// writeFile writes content to a file in a temporary directory
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestValidateFallbackPlaylist(t *testing.T) {
+	slate := writeFile(t, "slate.m3u8", "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nslate.ts\n")
+	notPlaylist := writeFile(t, "slate.html", "<html>down</html>")
+	missing := filepath.Join(t.TempDir(), "missing.m3u8")
+
+	tests := []struct {
+		name    string
+		global  string
+		route   string
+		wantErr string
+	}{
+		{"none", "", "", ""},
+		{"global playlist", slate, "", ""},
+		{"route playlist", "", slate, ""},
+		{"global missing", missing, "", "fallback playlist"},
+		{"global not a playlist", notPlaylist, "", "not an M3U8 playlist"},
+		{"route missing", "", missing, "origin route 0"},
+		{"route not a playlist", "", notPlaylist, "origin route 0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.FallbackPlaylist = tt.global
+			cfg.Origin.Routes = []OriginRoute{{
+				PathPrefix:       "/live/",
+				BaseURL:          "https://live.example.com",
+				FallbackPlaylist: tt.route,
+			}}
+
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate: got %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAdminCIDRs(t *testing.T) {
+	tests := []struct {
+		name        string
+		server      [2][]string
+		admin       [2][]string
+		wantAllow   []string
+		wantDeny    []string
+		wantInvalid bool
+	}{
+		{name: "server lists apply", server: [2][]string{{"10.0.0.0/8"}, {"10.9.0.0/16"}}, wantAllow: []string{"10.0.0.0/8"}, wantDeny: []string{"10.9.0.0/16"}},
+		{name: "admin allow list replaces", server: [2][]string{{"0.0.0.0/0"}, {"10.9.0.0/16"}}, admin: [2][]string{{"192.168.0.0/16"}, nil}, wantAllow: []string{"192.168.0.0/16"}},
+		{name: "admin deny list replaces", server: [2][]string{{"0.0.0.0/0"}, nil}, admin: [2][]string{nil, {"203.0.113.0/24"}}, wantDeny: []string{"203.0.113.0/24"}},
+		{name: "invalid admin CIDR", admin: [2][]string{{"192.168.0.0/33"}, nil}, wantInvalid: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Server.AllowedCIDRs, cfg.Server.DeniedCIDRs = tt.server[0], tt.server[1]
+			cfg.Admin.AllowedCIDRs, cfg.Admin.DeniedCIDRs = tt.admin[0], tt.admin[1]
+
+			if err := cfg.Validate(); (err != nil) != tt.wantInvalid {
+				t.Fatalf("Validate: %v", err)
+			}
+			if tt.wantInvalid {
+				return
+			}
+			allow, deny := cfg.AdminCIDRs()
+			if strings.Join(allow, ",") != strings.Join(tt.wantAllow, ",") || strings.Join(deny, ",") != strings.Join(tt.wantDeny, ",") {
+				t.Errorf("got allow %v deny %v, want allow %v deny %v", allow, deny, tt.wantAllow, tt.wantDeny)
+			}
+		})
+	}
+}
+
+func TestIntSliceValues(t *testing.T) {
+	if got := validConfig().Cache.NegativeStatuses; len(got) != 2 || got[0] != 404 || got[1] != 410 {
+		t.Errorf("default negative statuses %v, want [404 410]", got)
+	}
+
+	tests := []struct {
+		value   string
+		want    []int
+		wantErr bool
+	}{
+		{"404", []int{404}, false},
+		{"404, 500 ,503", []int{404, 500, 503}, false},
+		{"404,x", nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			cfg := validConfig()
+			err := setConfigValue(cfg, []string{"cache", "negativeStatuses"}, tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			got := cfg.Cache.NegativeStatuses
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateStreamMatch(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{"prefix", false},
+		{"exact", false},
+		{"glob", false},
+		{"regex", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.JWT.StreamMatch = tt.mode
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateBindingPrefixes(t *testing.T) {
+	tests := []struct {
+		name    string
+		v4, v6  int
+		wantErr bool
+	}{
+		{"defaults", 32, 64, false},
+		{"widest", 0, 0, false},
+		{"IPv4 too long", 33, 64, true},
+		{"IPv6 too long", 32, 129, true},
+		{"negative", -1, 64, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.JWT.BindIPv4Prefix = tt.v4
+			cfg.JWT.BindIPv6Prefix = tt.v6
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateURLPatterns(t *testing.T) {
+	tests := []struct {
+		name        string
+		playlists   []string
+		segments    []string
+		passthrough []string
+		wantErr     bool
+	}{
+		{"none", nil, nil, nil, false},
+		{"valid", []string{`/manifest$`}, []string{`\.ts$`}, []string{`^/beacon/`}, false},
+		{"invalid playlist pattern", []string{`(`}, nil, nil, true},
+		{"invalid segment pattern", nil, []string{`[a-`}, nil, true},
+		{"invalid passthrough pattern", nil, nil, []string{`*`}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.PlaylistPatterns = tt.playlists
+			cfg.Origin.SegmentPatterns = tt.segments
+			cfg.Origin.PassthroughPatterns = tt.passthrough
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateContentTypeTTLs(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttls    map[string]time.Duration
+		wantErr bool
+	}{
+		{"none", nil, false},
+		{"positive", map[string]time.Duration{"video/mp2t": 30 * time.Second}, false},
+		{"zero", map[string]time.Duration{"video/mp4": 0}, false},
+		{"negative", map[string]time.Duration{"text/vtt": -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Cache.TTLByContentType = tt.ttls
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCacheSharding(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxSize    int
+		shardCount int
+		auto       bool
+		wantErr    bool
+	}{
+		{"defaults", 10000, 16, false, false},
+		{"one item per shard", 16, 16, false, false},
+		{"more shards than items", 8, 16, false, true},
+		{"more shards than items when auto-tuned", 8, 16, true, false},
+		{"negative maxSize", -1, 16, false, true},
+		{"negative shardCount", 10000, -1, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Cache.MaxSize = tt.maxSize
+			cfg.Cache.ShardCount = tt.shardCount
+			cfg.Cache.AutoShards = tt.auto
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTokenParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		param  string
+		routes []OriginRoute
+		want   []string
+	}{
+		{"JWT parameter", "token", nil, []string{"token"}},
+		{"route parameters", "token", []OriginRoute{{TokenParam: "sig"}, {}, {TokenParam: "hdnts"}}, []string{"token", "sig", "hdnts"}},
+		{"duplicates folded", "token", []OriginRoute{{TokenParam: "Token"}, {TokenParam: "sig"}, {TokenParam: "sig"}}, []string{"token", "sig"}},
+		{"no JWT parameter", "", []OriginRoute{{TokenParam: "sig"}}, []string{"sig"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.JWT.ParamName = tt.param
+			cfg.Origin.Routes = tt.routes
+			if got := cfg.TokenParams(); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("TokenParams() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidatePlaylistValidation(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{"off", false},
+		{"warn", false},
+		{"strict", false},
+		{"lenient", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.PlaylistValidation = tt.mode
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateSegmentTitles(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{"keep", false},
+		{"strip", false},
+		{"drop", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.SegmentTitles = tt.mode
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTokenPrecedence(t *testing.T) {
+	tests := []struct {
+		precedence string
+		wantErr    bool
+	}{
+		{"", false},
+		{"header", false},
+		{"query", false},
+		{"nonExpired", false},
+		{"newest", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.precedence, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.JWT.TokenPrecedence = tt.precedence
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateJWKSLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		maxBytes int64
+		wantErr  bool
+	}{
+		{"defaults", 5 * time.Second, 1 << 20, false},
+		{"unset", 0, 0, false},
+		{"negative timeout", -time.Second, 1 << 20, true},
+		{"negative size", 5 * time.Second, -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.JWT.KeysTimeout = tt.timeout
+			cfg.JWT.KeysMaxBytes = tt.maxBytes
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStampede(t *testing.T) {
+	tests := []struct {
+		name     string
+		wait     time.Duration
+		staleTTL time.Duration
+		wantErr  bool
+	}{
+		{"disabled", 0, 0, false},
+		{"wait with stale copies", time.Second, time.Minute, false},
+		{"negative wait", -time.Second, 0, true},
+		{"negative stale TTL", time.Second, -time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Cache.StampedeWait = tt.wait
+			cfg.Cache.StaleTTL = tt.staleTTL
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateStreamBufferBytes(t *testing.T) {
+	tests := []struct {
+		size    int
+		wantErr bool
+	}{
+		{32768, false},
+		{0, false},
+		{-1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.size), func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Cache.StreamBufferBytes = tt.size
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMissingBandwidth(t *testing.T) {
+	tests := []struct {
+		name      string
+		mode      string
+		bandwidth int64
+		wantErr   bool
+	}{
+		{"unset", "", 0, false},
+		{"reject", "reject", 0, false},
+		{"default", "default", 500000, false},
+		{"unknown mode", "ignore", 0, true},
+		{"negative default", "default", -1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.MissingBandwidth = tt.mode
+			cfg.Origin.DefaultBandwidth = tt.bandwidth
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateVariantFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  VariantFilterConfig
+		wantErr bool
+	}{
+		{"no limits", VariantFilterConfig{}, false},
+		{"bandwidth window", VariantFilterConfig{MinBandwidth: 500000, MaxBandwidth: 2000000}, false},
+		{"min without max", VariantFilterConfig{MinBandwidth: 500000}, false},
+		{"min above max", VariantFilterConfig{MinBandwidth: 3000000, MaxBandwidth: 2000000}, true},
+		{"negative height", VariantFilterConfig{MaxHeight: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.VariantFilter = tt.filter
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateRenditionFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  RenditionFilterConfig
+		wantErr bool
+	}{
+		{"no limits", RenditionFilterConfig{}, false},
+		{"stereo only", RenditionFilterConfig{MaxChannels: 2}, false},
+		{"characteristics", RenditionFilterConfig{ExcludeCharacteristics: []string{"public.accessibility.describes-video"}}, false},
+		{"negative channels", RenditionFilterConfig{MaxChannels: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.RenditionFilter = tt.filter
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOriginDNS(t *testing.T) {
+	tests := []struct {
+		name    string
+		dns     OriginDNSConfig
+		wantErr bool
+	}{
+		{"disabled", OriginDNSConfig{}, false},
+		{"cached with resolver", OriginDNSConfig{CacheTTL: 5 * time.Second, Resolver: "10.0.0.53:53", ResolverTimeout: 2 * time.Second}, false},
+		{"negative ttl", OriginDNSConfig{CacheTTL: -time.Second}, true},
+		{"negative resolver timeout", OriginDNSConfig{ResolverTimeout: -time.Second}, true},
+		{"resolver without port", OriginDNSConfig{Resolver: "10.0.0.53"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.DNS = tt.dns
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateCacheBypass(t *testing.T) {
+	tests := []struct {
+		name       string
+		bypass     CacheBypassConfig
+		adminToken string
+		wantErr    bool
+	}{
+		{"disabled", CacheBypassConfig{}, "", false},
+		{"allowed networks", CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache", AllowedCIDRs: []string{"10.0.0.0/8"}}, "", false},
+		{"admin token", CacheBypassConfig{Enabled: true, Param: "refresh"}, "s3cret", false},
+		{"nobody allowed", CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache"}, "", true},
+		{"no header or param", CacheBypassConfig{Enabled: true, AllowedCIDRs: []string{"10.0.0.0/8"}}, "", true},
+		{"invalid network", CacheBypassConfig{AllowedCIDRs: []string{"10.0.0.0/33"}}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Cache.Bypass = tt.bypass
+			cfg.Admin.Token = tt.adminToken
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownLength(t *testing.T) {
+	tests := []struct {
+		mode    string
+		wantErr bool
+	}{
+		{"", false},
+		{"auto", false},
+		{"stream", false},
+		{"buffer", false},
+		{"chunked", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Cache.UnknownLength = tt.mode
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMaxURLLength(t *testing.T) {
+	tests := []struct {
+		length  int
+		wantErr bool
+	}{
+		{0, false},
+		{2048, false},
+		{-1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strconv.Itoa(tt.length), func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Origin.MaxURLLength = tt.length
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateTrackingDebounce(t *testing.T) {
+	tests := []struct {
+		debounce time.Duration
+		wantErr  bool
+	}{
+		{0, false},
+		{10 * time.Second, false},
+		{-time.Second, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.debounce.String(), func(t *testing.T) {
+			cfg := validConfig()
+			cfg.Redis.TrackingDebounce = tt.debounce
+			if err := cfg.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
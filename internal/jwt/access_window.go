@@ -0,0 +1,78 @@
+// Content access windows
+//
+// Limits access to a window carried in token claims, independent of the
+// token's own validity:
+// - Absolute bounds as Unix times or RFC 3339 timestamps
+// - Relative bounds as durations, e.g. a 48h rental
+// - Invalid bounds deny access
+
+package jwt
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AccessWindowAuthorizer allows access only between the times held in the
+// start and end claims. A claim is a Unix time, an RFC 3339 timestamp or a
+// duration: a relative start counts from the token's issue time, and a
+// relative end from the start, or the issue time when there is no start.
+// Empty claim names or claims missing from the token leave that side of
+// the window open.
+func AccessWindowAuthorizer(startClaim, endClaim string, now func() time.Time) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, _ *http.Request) Decision {
+		issued := time.Unix(claims.IssuedAt, 0)
+
+		start, hasStart, ok := claimTime(claims, startClaim, issued)
+		if !ok {
+			return Deny("token has an invalid access window")
+		}
+		from := issued
+		if hasStart {
+			from = start
+		}
+		end, hasEnd, ok := claimTime(claims, endClaim, from)
+		if !ok {
+			return Deny("token has an invalid access window")
+		}
+
+		t := now()
+		if hasStart && t.Before(start) {
+			return Deny("content is not available yet")
+		}
+		if hasEnd && !t.Before(end) {
+			return Deny("content is no longer available")
+		}
+		return Allow()
+	})
+}
+
+// claimTime resolves a window bound claim to a time, resolving durations
+// from base. It reports whether the claim is present and whether it is
+// valid; durations need a known base.
+func claimTime(claims *Claims, name string, base time.Time) (t time.Time, present, ok bool) {
+	if name == "" {
+		return time.Time{}, false, true
+	}
+	val, found := claims.GetClaimPath(name)
+	if !found {
+		return time.Time{}, false, true
+	}
+
+	switch v := val.(type) {
+	case float64:
+		return time.Unix(int64(v), 0), true, true
+	case string:
+		if secs, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return time.Unix(secs, 0), true, true
+		}
+		if ts, err := time.Parse(time.RFC3339, v); err == nil {
+			return ts, true, true
+		}
+		if d, err := time.ParseDuration(v); err == nil && base.Unix() > 0 {
+			return base.Add(d), true, true
+		}
+	}
+	return time.Time{}, true, false
+}
@@ -0,0 +1,75 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAccessWindowAuthorizer(t *testing.T) {
+	// testClaims are issued at 1700000000
+	issued := time.Unix(1700000000, 0)
+	broadcast := map[string]interface{}{
+		"start": "2023-11-14T19:00:00Z",
+		"end":   "2023-11-14T22:00:00Z",
+	}
+	at := func(s string) time.Time {
+		ts, _ := time.Parse(time.RFC3339, s)
+		return ts
+	}
+
+	tests := []struct {
+		name       string
+		startClaim string
+		endClaim   string
+		claims     map[string]interface{}
+		now        time.Time
+		want       bool
+		wantReason string
+	}{
+		{"no window configured", "", "", broadcast, at("2023-11-15T00:00:00Z"), true, ""},
+		{"claims missing", "start", "end", nil, issued, true, ""},
+		{"before timestamp window", "start", "end", broadcast, at("2023-11-14T18:59:59Z"), false, "not available yet"},
+		{"at window start", "start", "end", broadcast, at("2023-11-14T19:00:00Z"), true, ""},
+		{"within timestamp window", "start", "end", broadcast, at("2023-11-14T21:30:00Z"), true, ""},
+		{"at window end", "start", "end", broadcast, at("2023-11-14T22:00:00Z"), false, "no longer available"},
+		{"start only", "start", "", broadcast, at("2023-11-15T12:00:00Z"), true, ""},
+		{"end only", "", "end", broadcast, at("2023-11-14T23:00:00Z"), false, "no longer available"},
+		{"unix times", "start", "end", map[string]interface{}{"start": float64(1700000100), "end": "1700000200"}, time.Unix(1700000150, 0), true, ""},
+		{"before unix start", "start", "end", map[string]interface{}{"start": float64(1700000100), "end": "1700000200"}, time.Unix(1700000050, 0), false, "not available yet"},
+		{"rental within 48h of issue", "", "end", map[string]interface{}{"end": "48h"}, issued.Add(47 * time.Hour), true, ""},
+		{"rental after 48h of issue", "", "end", map[string]interface{}{"end": "48h"}, issued.Add(48 * time.Hour), false, "no longer available"},
+		{"relative start from issue", "start", "", map[string]interface{}{"start": "1h"}, issued.Add(30 * time.Minute), false, "not available yet"},
+		{"relative end from start", "start", "end", map[string]interface{}{"start": "2023-11-14T19:00:00Z", "end": "3h"}, at("2023-11-14T21:59:59Z"), true, ""},
+		{"relative end from start passed", "start", "end", map[string]interface{}{"start": "2023-11-14T19:00:00Z", "end": "3h"}, at("2023-11-14T22:00:00Z"), false, "no longer available"},
+		{"nested claim", "", "rental.until", map[string]interface{}{"rental": map[string]interface{}{"until": float64(1700000200)}}, time.Unix(1700000300, 0), false, "no longer available"},
+		{"invalid value", "", "end", map[string]interface{}{"end": "tomorrow"}, issued, false, "invalid access window"},
+		{"invalid type", "start", "", map[string]interface{}{"start": true}, issued, false, "invalid access window"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := tt.now
+			a := AccessWindowAuthorizer(tt.startClaim, tt.endClaim, func() time.Time { return now })
+			d := a.Authorize(testClaims(tt.claims), httptest.NewRequest(http.MethodGet, "/", nil))
+			if d.Allowed != tt.want {
+				t.Fatalf("allowed = %v (%s), want %v", d.Allowed, d.Reason, tt.want)
+			}
+			if !strings.Contains(d.Reason, tt.wantReason) {
+				t.Errorf("reason %q, want it to mention %q", d.Reason, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestAccessWindowRelativeNeedsIssueTime(t *testing.T) {
+	claims := testClaims(map[string]interface{}{"end": "48h"})
+	claims.IssuedAt = 0
+
+	a := AccessWindowAuthorizer("", "end", time.Now)
+	if d := a.Authorize(claims, nil); d.Allowed {
+		t.Fatal("relative window allowed without an issue time")
+	}
+}
@@ -0,0 +1,42 @@
+package jwt
+
+import "testing"
+
+func TestValidatorIssuersAndAudiences(t *testing.T) {
+	tests := []struct {
+		name      string
+		issuer    string
+		issuers   []string
+		audience  string
+		audiences []string
+		claims    map[string]interface{}
+		wantValid bool
+	}{
+		{"first issuer", "", []string{"idp-a", "idp-b"}, "", nil, map[string]interface{}{"iss": "idp-a"}, true},
+		{"second issuer", "", []string{"idp-a", "idp-b"}, "", nil, map[string]interface{}{"iss": "idp-b"}, true},
+		{"third issuer", "", []string{"idp-a", "idp-b"}, "", nil, map[string]interface{}{"iss": "idp-c"}, false},
+		{"single issuer", "idp-a", nil, "", nil, map[string]interface{}{"iss": "idp-a"}, true},
+		{"single issuer rejects others", "idp-a", nil, "", nil, map[string]interface{}{"iss": "idp-b"}, false},
+		{"single issuer joins the list", "idp-a", []string{"idp-b"}, "", nil, map[string]interface{}{"iss": "idp-a"}, true},
+		{"listed audience", "", nil, "", []string{"web", "tv"}, map[string]interface{}{"aud": "tv"}, true},
+		{"audience array", "", nil, "", []string{"web", "tv"}, map[string]interface{}{"aud": []string{"mobile", "web"}}, true},
+		{"unlisted audience", "", nil, "", []string{"web", "tv"}, map[string]interface{}{"aud": "mobile"}, false},
+		{"missing audience", "", nil, "", []string{"web"}, map[string]interface{}{}, false},
+		{"single audience", "", nil, "web", nil, map[string]interface{}{"aud": "web"}, true},
+		{"single audience rejects others", "", nil, "web", nil, map[string]interface{}{"aud": "tv"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.Issuer, cfg.Issuers = tt.issuer, tt.issuers
+			cfg.Audience, cfg.Audiences = tt.audience, tt.audiences
+			tt.claims["sub"] = "p1"
+
+			_, err := NewValidator(cfg, nil).ValidateToken(signToken(t, nil, tt.claims))
+			if (err == nil) != tt.wantValid {
+				t.Errorf("ValidateToken() = %v, want valid %v", err, tt.wantValid)
+			}
+		})
+	}
+}
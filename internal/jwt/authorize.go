@@ -0,0 +1,117 @@
+// JWT authorization checks
+//
+// Access decisions on validated tokens:
+// - Required roles (any of)
+// - Required scopes (all of)
+// - Per-stream access claims
+// - Content access windows
+// - Client IP and user-agent binding
+
+package jwt
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Authorize checks that validated claims grant access to the requested
+// stream path under the configured requirements. The token must hold at
+// least one of the required roles and every required scope, and when a
+// stream claim is configured one of its entries must match the path. The
+// current time must fall within any configured access window.
+func (v *Validator) Authorize(claims *Claims, streamPath string) error {
+	pathOf := func(*http.Request) string { return streamPath }
+	return v.Authorizer(pathOf).Authorize(claims, nil).Err()
+}
+
+// AllowsStream reports whether the stream claim permits the path. The claim
+// may be a string or an array of strings. Entries are matched as path
+// prefixes, exact paths, or globs where a trailing "/**" matches everything
+// below a directory and "*" does not cross "/".
+func (c *Claims) AllowsStream(claim, mode, streamPath string) bool {
+	val, ok := c.GetClaimPath(claim)
+	if !ok {
+		return false
+	}
+
+	var patterns []string
+	switch v := val.(type) {
+	case string:
+		patterns = []string{v}
+	case []interface{}:
+		for _, p := range v {
+			if s, ok := p.(string); ok {
+				patterns = append(patterns, s)
+			}
+		}
+	}
+
+	for _, pattern := range patterns {
+		if pattern != "" && matchStream(pattern, mode, streamPath) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchStream matches a single stream pattern against a path
+func matchStream(pattern, mode, streamPath string) bool {
+	switch mode {
+	case "exact":
+		return streamPath == pattern
+	case "glob":
+		if prefix, ok := strings.CutSuffix(pattern, "/**"); ok {
+			return strings.HasPrefix(streamPath, prefix+"/")
+		}
+		matched, err := path.Match(pattern, streamPath)
+		return err == nil && matched
+	default:
+		return strings.HasPrefix(streamPath, pattern)
+	}
+}
+
+// CheckBinding verifies that a token bound to a client is presented by
+// that client. Each binding is enabled independently; IP bindings compare
+// networks of the configured prefix length to tolerate NAT pools.
+func (v *Validator) CheckBinding(claims *Claims, clientIP net.IP, userAgent string) error {
+	v.mu.RLock()
+	config := v.config
+	v.mu.RUnlock()
+
+	if config.BindIP {
+		bound, ok := claims.GetStringClaim(config.BindIPClaim)
+		if !ok || !sameNetwork(net.ParseIP(bound), clientIP, config.BindIPv4Prefix, config.BindIPv6Prefix) {
+			return NewForbiddenError("token is not valid for this client address")
+		}
+	}
+
+	if config.BindUserAgent {
+		bound, ok := claims.GetStringClaim(config.BindUAClaim)
+		if !ok || bound != userAgent {
+			return NewForbiddenError("token is not valid for this client")
+		}
+	}
+
+	return nil
+}
+
+// sameNetwork reports whether two addresses share a network of the prefix
+// length for their address family
+func sameNetwork(a, b net.IP, v4Prefix, v6Prefix int) bool {
+	if a == nil || b == nil {
+		return false
+	}
+
+	if a4, b4 := a.To4(), b.To4(); a4 != nil || b4 != nil {
+		if a4 == nil || b4 == nil {
+			return false
+		}
+		mask := net.CIDRMask(v4Prefix, 32)
+		return a4.Mask(mask).Equal(b4.Mask(mask))
+	}
+
+	mask := net.CIDRMask(v6Prefix, 128)
+	return a.Mask(mask).Equal(b.Mask(mask))
+}
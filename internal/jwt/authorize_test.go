@@ -0,0 +1,152 @@
+package jwt
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
+)
+
+func TestClaimsRolesAndScopes(t *testing.T) {
+	tests := []struct {
+		name      string
+		namespace string
+		custom    map[string]interface{}
+		role      string
+		scope     string
+		wantRole  bool
+		wantScope bool
+	}{
+		{name: "role in array", custom: map[string]interface{}{"roles": []interface{}{"viewer", "admin"}}, role: "admin", wantRole: true},
+		{name: "role absent", custom: map[string]interface{}{"roles": []interface{}{"viewer"}}, role: "admin"},
+		{name: "roles not an array", custom: map[string]interface{}{"roles": "admin"}, role: "admin"},
+		{name: "namespaced roles", namespace: "https://example.com/", custom: map[string]interface{}{"https://example.com/roles": []interface{}{"admin"}}, role: "admin", wantRole: true},
+		{name: "scope string", custom: map[string]interface{}{"scope": "play download"}, scope: "download", wantScope: true},
+		{name: "scope prefix only", custom: map[string]interface{}{"scope": "playback"}, scope: "play"},
+		{name: "scp array", custom: map[string]interface{}{"scp": []interface{}{"play"}}, scope: "play", wantScope: true},
+		{name: "no scopes", scope: "play"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := NewClaims(&jwtheader.JWTClaims{Custom: tt.custom}, tt.namespace)
+			if tt.role != "" && claims.HasRole(tt.role) != tt.wantRole {
+				t.Errorf("HasRole(%q) = %v, want %v", tt.role, !tt.wantRole, tt.wantRole)
+			}
+			if tt.scope != "" && claims.HasScope(tt.scope) != tt.wantScope {
+				t.Errorf("HasScope(%q) = %v, want %v", tt.scope, !tt.wantScope, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestValidatorAuthorizeRolesAndScopes(t *testing.T) {
+	tests := []struct {
+		name   string
+		roles  []string
+		scopes []string
+		custom map[string]interface{}
+		want   bool
+	}{
+		{name: "no requirements", want: true},
+		{name: "any role suffices", roles: []string{"admin", "viewer"}, custom: map[string]interface{}{"roles": []interface{}{"viewer"}}, want: true},
+		{name: "no required role", roles: []string{"admin"}, custom: map[string]interface{}{"roles": []interface{}{"viewer"}}},
+		{name: "every scope held", scopes: []string{"play", "hd"}, custom: map[string]interface{}{"scope": "hd play"}, want: true},
+		{name: "one scope missing", scopes: []string{"play", "hd"}, custom: map[string]interface{}{"scp": []interface{}{"play"}}},
+		{name: "role and scope", roles: []string{"viewer"}, scopes: []string{"play"}, custom: map[string]interface{}{"roles": []interface{}{"viewer"}, "scope": "play"}, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.RequiredRoles = tt.roles
+			cfg.RequiredScopes = tt.scopes
+
+			err := NewValidator(cfg, nil).Authorize(testClaims(tt.custom), "/live/a.m3u8")
+			if (err == nil) != tt.want {
+				t.Fatalf("Authorize error = %v, want allowed %v", err, tt.want)
+			}
+			var tokenErr *TokenError
+			if err != nil && (!errors.As(err, &tokenErr) || tokenErr.StatusCode != http.StatusForbidden) {
+				t.Errorf("error %v is not a 403 token error", err)
+			}
+		})
+	}
+}
+
+func TestClaimsAllowsStream(t *testing.T) {
+	tests := []struct {
+		name  string
+		claim interface{}
+		mode  string
+		path  string
+		want  bool
+	}{
+		{"prefix match", "/live/", "prefix", "/live/a/index.m3u8", true},
+		{"prefix mismatch", "/live/", "prefix", "/vod/a.m3u8", false},
+		{"default mode is prefix", "/live/", "", "/live/a.m3u8", true},
+		{"array entry", []interface{}{"/vod/", "/live/"}, "prefix", "/live/a.m3u8", true},
+		{"non-string entries ignored", []interface{}{42, "/live/"}, "prefix", "/live/a.m3u8", true},
+		{"empty entry matches nothing", "", "prefix", "/live/a.m3u8", false},
+		{"exact match", "/live/a.m3u8", "exact", "/live/a.m3u8", true},
+		{"exact mismatch", "/live/a.m3u8", "exact", "/live/a.m3u8.bak", false},
+		{"glob star", "/live/*/index.m3u8", "glob", "/live/a/index.m3u8", true},
+		{"glob star stops at slash", "/live/*", "glob", "/live/a/index.m3u8", false},
+		{"glob double star", "/live/**", "glob", "/live/a/b/seg.ts", true},
+		{"glob double star needs directory", "/live/**", "glob", "/livestream/seg.ts", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := testClaims(map[string]interface{}{"streams": tt.claim})
+			if got := claims.AllowsStream("streams", tt.mode, tt.path); got != tt.want {
+				t.Errorf("AllowsStream(%v, %q, %q) = %v, want %v", tt.claim, tt.mode, tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if testClaims(nil).AllowsStream("streams", "prefix", "/live/a.m3u8") {
+		t.Error("stream allowed without a stream claim")
+	}
+}
+
+func TestValidatorCheckBinding(t *testing.T) {
+	tests := []struct {
+		name      string
+		bindIP    bool
+		bindUA    bool
+		v4Prefix  int
+		custom    map[string]interface{}
+		clientIP  string
+		userAgent string
+		want      bool
+	}{
+		{name: "no binding", clientIP: "198.51.100.7", want: true},
+		{name: "same address", bindIP: true, v4Prefix: 32, custom: map[string]interface{}{"ip": "198.51.100.7"}, clientIP: "198.51.100.7", want: true},
+		{name: "other address", bindIP: true, v4Prefix: 32, custom: map[string]interface{}{"ip": "198.51.100.7"}, clientIP: "198.51.100.8"},
+		{name: "same network", bindIP: true, v4Prefix: 24, custom: map[string]interface{}{"ip": "198.51.100.7"}, clientIP: "198.51.100.200", want: true},
+		{name: "other network", bindIP: true, v4Prefix: 24, custom: map[string]interface{}{"ip": "198.51.100.7"}, clientIP: "198.51.101.7"},
+		{name: "same IPv6 /64", bindIP: true, custom: map[string]interface{}{"ip": "2001:db8:0:1::1"}, clientIP: "2001:db8:0:1::ffff", want: true},
+		{name: "other IPv6 /64", bindIP: true, custom: map[string]interface{}{"ip": "2001:db8:0:1::1"}, clientIP: "2001:db8:0:2::1"},
+		{name: "family mismatch", bindIP: true, v4Prefix: 0, custom: map[string]interface{}{"ip": "198.51.100.7"}, clientIP: "2001:db8::1"},
+		{name: "IP claim missing", bindIP: true, v4Prefix: 32, clientIP: "198.51.100.7"},
+		{name: "same user agent", bindUA: true, custom: map[string]interface{}{"ua": "Player/1.0"}, userAgent: "Player/1.0", want: true},
+		{name: "other user agent", bindUA: true, custom: map[string]interface{}{"ua": "Player/1.0"}, userAgent: "Player/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.BindIP = tt.bindIP
+			cfg.BindUserAgent = tt.bindUA
+			cfg.BindIPv4Prefix = tt.v4Prefix
+
+			err := NewValidator(cfg, nil).CheckBinding(testClaims(tt.custom), net.ParseIP(tt.clientIP), tt.userAgent)
+			if (err == nil) != tt.want {
+				t.Errorf("CheckBinding error = %v, want allowed %v", err, tt.want)
+			}
+		})
+	}
+}
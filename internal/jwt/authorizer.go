@@ -0,0 +1,140 @@
+// Pluggable access decisions
+//
+// Authorizers decide whether validated claims grant a request:
+// - Allow or deny with a reason
+// - Role, scope and stream authorizers
+// - The configured checks as one authorizer
+// - Composition where the first denial wins
+// - Function adapter for custom policies (geo, entitlements, time windows)
+
+package jwt
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+// Decision is the outcome of an access check. A denial carries the reason
+// reported to the client.
+type Decision struct {
+	Allowed bool
+	Reason  string
+}
+
+// Allow returns a decision granting access
+func Allow() Decision {
+	return Decision{Allowed: true}
+}
+
+// Deny returns a decision refusing access for the given reason
+func Deny(reason string) Decision {
+	return Decision{Reason: reason}
+}
+
+// Err returns nil for an allowed decision, otherwise a forbidden error
+// with the reason
+func (d Decision) Err() error {
+	if d.Allowed {
+		return nil
+	}
+	return NewForbiddenError(d.Reason)
+}
+
+// Authorizer decides whether validated claims grant access to a request.
+// It is only consulted for requests that carry a valid token.
+type Authorizer interface {
+	Authorize(claims *Claims, r *http.Request) Decision
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface
+type AuthorizerFunc func(claims *Claims, r *http.Request) Decision
+
+// Authorize calls f(claims, r)
+func (f AuthorizerFunc) Authorize(claims *Claims, r *http.Request) Decision {
+	return f(claims, r)
+}
+
+// All composes authorizers: access is granted only if every one of them
+// allows it, and the first denial is returned. Nil authorizers are skipped.
+func All(authorizers ...Authorizer) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, r *http.Request) Decision {
+		for _, a := range authorizers {
+			if a == nil {
+				continue
+			}
+			if d := a.Authorize(claims, r); !d.Allowed {
+				return d
+			}
+		}
+		return Allow()
+	})
+}
+
+// RoleAuthorizer requires at least one of the roles. No roles allow all.
+func RoleAuthorizer(roles []string) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, _ *http.Request) Decision {
+		if len(roles) == 0 {
+			return Allow()
+		}
+		for _, role := range roles {
+			if claims.HasRole(role) {
+				return Allow()
+			}
+		}
+		return Deny("token does not have a required role")
+	})
+}
+
+// ScopeAuthorizer requires every one of the scopes
+func ScopeAuthorizer(scopes []string) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, _ *http.Request) Decision {
+		for _, scope := range scopes {
+			if !claims.HasScope(scope) {
+				return Deny(fmt.Sprintf("token is missing required scope %q", scope))
+			}
+		}
+		return Allow()
+	})
+}
+
+// StreamAuthorizer requires the stream claim to permit the stream path of
+// the request, found with pathOf and matched in the given mode. An empty
+// claim allows all.
+func StreamAuthorizer(claim, mode string, pathOf func(*http.Request) string) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, r *http.Request) Decision {
+		if claim == "" || claims.AllowsStream(claim, mode, pathOf(r)) {
+			return Allow()
+		}
+		return Deny("token does not grant access to this stream")
+	})
+}
+
+// Authorizer returns the authorizer for the validator's configured roles,
+// scopes, stream claim and access window. The configuration is read on
+// every decision, so it follows configuration updates.
+func (v *Validator) Authorizer(pathOf func(*http.Request) string) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, r *http.Request) Decision {
+		v.mu.RLock()
+		config := v.config
+		clock := v.clock
+		v.mu.RUnlock()
+
+		return ConfigAuthorizer(config, pathOf, clock.Now).Authorize(claims, r)
+	})
+}
+
+// ConfigAuthorizer returns the authorizer for the roles, scopes, stream
+// claim and access window of a JWT configuration, read on every decision
+func ConfigAuthorizer(cfg *config.JWTConfig, pathOf func(*http.Request) string, now func() time.Time) Authorizer {
+	return AuthorizerFunc(func(claims *Claims, r *http.Request) Decision {
+		return All(
+			RoleAuthorizer(cfg.RequiredRoles),
+			ScopeAuthorizer(cfg.RequiredScopes),
+			StreamAuthorizer(cfg.StreamClaim, cfg.StreamMatch, pathOf),
+			AccessWindowAuthorizer(cfg.AccessStartClaim, cfg.AccessEndClaim, now),
+		).Authorize(claims, r)
+	})
+}
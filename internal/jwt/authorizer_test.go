@@ -0,0 +1,93 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
+)
+
+// testClaims returns claims with the given custom claims
+func testClaims(custom map[string]interface{}) *Claims {
+	return NewClaims(&jwtheader.JWTClaims{IssuedAt: 1700000000, Custom: custom}, "")
+}
+
+func TestConfigAuthorizer(t *testing.T) {
+	pathOf := func(r *http.Request) string { return r.URL.Path }
+	now := func() time.Time { return time.Unix(1700000100, 0) }
+
+	tests := []struct {
+		name   string
+		cfg    config.JWTConfig
+		claims map[string]interface{}
+		path   string
+		want   bool
+	}{
+		{name: "no requirements", path: "/live/a.m3u8", want: true},
+		{name: "role held", cfg: config.JWTConfig{RequiredRoles: []string{"viewer", "admin"}}, claims: map[string]interface{}{"roles": []interface{}{"viewer"}}, want: true},
+		{name: "role missing", cfg: config.JWTConfig{RequiredRoles: []string{"admin"}}, claims: map[string]interface{}{"roles": []interface{}{"viewer"}}},
+		{name: "scopes held", cfg: config.JWTConfig{RequiredScopes: []string{"play", "hd"}}, claims: map[string]interface{}{"scope": "play hd"}, want: true},
+		{name: "scope missing", cfg: config.JWTConfig{RequiredScopes: []string{"play", "hd"}}, claims: map[string]interface{}{"scope": "play"}},
+		{name: "stream granted", cfg: config.JWTConfig{StreamClaim: "streams", StreamMatch: "prefix"}, claims: map[string]interface{}{"streams": "/live/"}, path: "/live/a.m3u8", want: true},
+		{name: "stream refused", cfg: config.JWTConfig{StreamClaim: "streams", StreamMatch: "prefix"}, claims: map[string]interface{}{"streams": "/vod/"}, path: "/live/a.m3u8"},
+		{name: "within access window", cfg: config.JWTConfig{AccessEndClaim: "end"}, claims: map[string]interface{}{"end": float64(1700000200)}, want: true},
+		{name: "after access window", cfg: config.JWTConfig{AccessEndClaim: "end"}, claims: map[string]interface{}{"end": float64(1700000050)}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			if tt.path == "" {
+				tt.path = "/"
+			}
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			d := ConfigAuthorizer(&cfg, pathOf, now).Authorize(testClaims(tt.claims), r)
+			if d.Allowed != tt.want {
+				t.Errorf("allowed = %v (%s), want %v", d.Allowed, d.Reason, tt.want)
+			}
+		})
+	}
+}
+
+func TestConfigAuthorizerFollowsUpdates(t *testing.T) {
+	cfg := config.JWTConfig{}
+	a := ConfigAuthorizer(&cfg, func(*http.Request) string { return "/" }, time.Now)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if !a.Authorize(testClaims(nil), r).Allowed {
+		t.Fatal("denied without requirements")
+	}
+	cfg.RequiredRoles = []string{"admin"}
+	if a.Authorize(testClaims(nil), r).Allowed {
+		t.Fatal("allowed after a role became required")
+	}
+}
+
+func TestAll(t *testing.T) {
+	allow := AuthorizerFunc(func(*Claims, *http.Request) Decision { return Allow() })
+	deny := func(reason string) Authorizer {
+		return AuthorizerFunc(func(*Claims, *http.Request) Decision { return Deny(reason) })
+	}
+
+	tests := []struct {
+		name        string
+		authorizers []Authorizer
+		wantReason  string
+		wantAllowed bool
+	}{
+		{name: "none", wantAllowed: true},
+		{name: "all allow", authorizers: []Authorizer{allow, nil, allow}, wantAllowed: true},
+		{name: "first denial wins", authorizers: []Authorizer{allow, deny("geo"), deny("plan")}, wantReason: "geo"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := All(tt.authorizers...).Authorize(testClaims(nil), nil)
+			if d.Allowed != tt.wantAllowed || d.Reason != tt.wantReason {
+				t.Errorf("got %+v, want allowed %v reason %q", d, tt.wantAllowed, tt.wantReason)
+			}
+		})
+	}
+}
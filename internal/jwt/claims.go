@@ -11,6 +11,8 @@ package jwt
 import (
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
@@ -19,7 +21,8 @@ import (
 // Claims wraps the standard JWT claims and adds application-specific functionality
 type Claims struct {
 	*jwtheader.JWTClaims
-	namespace string
+	namespace     string
+	playerIDClaim string // Claim path holding the player ID; empty for the default lookup
 }
 
 // NewClaims creates a new Claims instance from JWTClaims
@@ -30,8 +33,22 @@ func NewClaims(claims *jwtheader.JWTClaims, namespace string) *Claims {
 	}
 }
 
-// GetPlayerID extracts the player ID from the claims
+// GetPlayerID extracts the player ID from the claims. A configured claim
+// path is used exclusively; otherwise the subject, the namespaced playerId
+// and the playerId claims are tried in order.
 func (c *Claims) GetPlayerID() (string, error) {
+	if c.playerIDClaim != "" {
+		val, ok := c.GetClaimPath(c.playerIDClaim)
+		if !ok {
+			return "", fmt.Errorf("player ID claim %q not found in token", c.playerIDClaim)
+		}
+		id := claimString(val)
+		if id == "" {
+			return "", fmt.Errorf("player ID claim %q is empty or not a scalar", c.playerIDClaim)
+		}
+		return id, nil
+	}
+	
 	// Try to get from subject claim first
 	if c.Subject != "" {
 		return c.Subject, nil
@@ -72,6 +89,55 @@ func (c *Claims) GetCustomClaim(name string) (interface{}, bool) {
 	return val, ok
 }
 
+// GetClaimPath retrieves a claim by a dot-separated path into nested
+// objects, e.g. "user.id". The first segment may be a registered claim or
+// a custom claim, considering the namespace.
+func (c *Claims) GetClaimPath(path string) (interface{}, bool) {
+	parts := strings.Split(path, ".")
+	
+	var val interface{}
+	switch parts[0] {
+	case "sub":
+		val = c.Subject
+	case "iss":
+		val = c.Issuer
+	case "jti":
+		val = c.JWTID
+	default:
+		v, ok := c.GetCustomClaim(parts[0])
+		if !ok {
+			return nil, false
+		}
+		val = v
+	}
+	
+	for _, part := range parts[1:] {
+		obj, ok := val.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if val, ok = obj[part]; !ok {
+			return nil, false
+		}
+	}
+	
+	return val, true
+}
+
+// claimString converts a scalar claim value to a string
+func claimString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
 // GetStringClaim retrieves a string custom claim
 func (c *Claims) GetStringClaim(name string) (string, bool) {
 	val, ok := c.GetCustomClaim(name)
@@ -103,24 +169,56 @@ func (c *Claims) HasRole(role string) bool {
 	return false
 }
 
+// HasScope checks if the token grants a scope, read from the space-delimited
+// "scope" claim or the "scp" array claim
+func (c *Claims) HasScope(scope string) bool {
+	if scopes, ok := c.GetStringClaim("scope"); ok {
+		for _, s := range strings.Fields(scopes) {
+			if s == scope {
+				return true
+			}
+		}
+	}
+	
+	if scp, ok := c.GetCustomClaim("scp"); ok {
+		if scpArr, ok := scp.([]interface{}); ok {
+			for _, s := range scpArr {
+				if sStr, ok := s.(string); ok && sStr == scope {
+					return true
+				}
+			}
+		}
+	}
+	
+	return false
+}
+
 // IsExpired checks if the token is expired
 func (c *Claims) IsExpired() bool {
+	return c.IsExpiredAt(time.Now())
+}
+
+// IsExpiredAt checks if the token is expired at the given time
+func (c *Claims) IsExpiredAt(t time.Time) bool {
 	if c.ExpirationTime == 0 {
 		return false // No expiration time means token doesn't expire
 	}
 	
-	now := time.Now().Unix()
-	return now > c.ExpirationTime
+	return t.Unix() > c.ExpirationTime
 }
 
 // RemainingValidity returns the remaining validity time of the token in seconds
 func (c *Claims) RemainingValidity() int64 {
+	return c.RemainingValidityAt(time.Now())
+}
+
+// RemainingValidityAt returns the validity left at the given time in seconds
+func (c *Claims) RemainingValidityAt(t time.Time) int64 {
 	if c.ExpirationTime == 0 {
 		return 0 // No expiration time
 	}
 	
-	now := time.Now().Unix()
-	remaining := c.ExpirationTime - now
+	remaining := c.ExpirationTime - t.Unix()
 	
 	if remaining < 0 {
 		return 0 // Token already expired
@@ -0,0 +1,47 @@
+package jwt
+
+import (
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
+)
+
+func TestGetPlayerID(t *testing.T) {
+	tests := []struct {
+		name      string
+		claim     string
+		namespace string
+		subject   string
+		custom    map[string]interface{}
+		want      string
+		wantErr   bool
+	}{
+		{name: "subject by default", subject: "sub-1", custom: map[string]interface{}{"playerId": "p-1"}, want: "sub-1"},
+		{name: "namespaced playerId", namespace: "https://example.com/", custom: map[string]interface{}{"https://example.com/playerId": "ns-1", "playerId": "p-1"}, want: "ns-1"},
+		{name: "plain playerId", custom: map[string]interface{}{"playerId": "p-1"}, want: "p-1"},
+		{name: "nothing found", wantErr: true},
+		{name: "configured claim", claim: "device", subject: "sub-1", custom: map[string]interface{}{"device": "d-1"}, want: "d-1"},
+		{name: "configured registered claim", claim: "sub", subject: "sub-1", want: "sub-1"},
+		{name: "configured nested path", claim: "user.id", custom: map[string]interface{}{"user": map[string]interface{}{"id": "u-1"}}, want: "u-1"},
+		{name: "configured numeric claim", claim: "uid", custom: map[string]interface{}{"uid": float64(42)}, want: "42"},
+		{name: "configured namespaced claim", claim: "device", namespace: "https://example.com/", custom: map[string]interface{}{"https://example.com/device": "d-2"}, want: "d-2"},
+		{name: "configured claim missing", claim: "device", subject: "sub-1", wantErr: true},
+		{name: "configured claim not scalar", claim: "user", custom: map[string]interface{}{"user": map[string]interface{}{"id": "u-1"}}, wantErr: true},
+		{name: "configured path too deep", claim: "user.id.x", custom: map[string]interface{}{"user": map[string]interface{}{"id": "u-1"}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := NewClaims(&jwtheader.JWTClaims{Subject: tt.subject, Custom: tt.custom}, tt.namespace)
+			claims.playerIDClaim = tt.claim
+
+			got, err := claims.GetPlayerID()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("player ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
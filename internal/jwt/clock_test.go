@@ -0,0 +1,57 @@
+package jwt
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+func TestValidatorExpiryFollowsClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	token := signToken(t, nil, map[string]interface{}{
+		"sub": "p1",
+		"exp": start.Add(time.Minute).Unix(),
+	})
+
+	tests := []struct {
+		name    string
+		at      time.Duration
+		wantErr error
+	}{
+		{"issued", 0, nil},
+		{"last second", 59 * time.Second, nil},
+		{"expired", time.Minute + time.Second, ErrTokenExpired},
+	}
+
+	for _, cached := range []bool{false, true} {
+		for _, tt := range tests {
+			name := tt.name
+			if cached {
+				name += "/cached"
+			}
+			t.Run(name, func(t *testing.T) {
+				var c cache.Cache
+				if cached {
+					c = cache.NewMemory()
+				}
+				clock := utils.NewFakeClock(start.Add(30 * time.Second))
+				v := NewValidator(testJWTConfig(), c)
+				v.SetClock(clock)
+
+				// Validate once while valid, filling the cache when enabled
+				if _, err := v.ValidateToken(token); err != nil {
+					t.Fatalf("ValidateToken while valid: %v", err)
+				}
+
+				clock.Set(start.Add(tt.at))
+				_, err := v.ValidateToken(token)
+				if (tt.wantErr == nil && err != nil) || !errors.Is(err, tt.wantErr) {
+					t.Errorf("ValidateToken at +%v: %v, want %v", tt.at, err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
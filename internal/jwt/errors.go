@@ -16,6 +16,7 @@ var (
 	ErrPlayerIDMissing    = errors.New("player ID is missing in the token")
 	ErrExtraction         = errors.New("failed to extract JWT token")
 	ErrValidation         = errors.New("JWT token validation failed")
+	ErrForbidden          = errors.New("JWT token does not grant access")
 )
 
 // TokenError represents a JWT token error with an HTTP status code
@@ -47,6 +48,28 @@ func (e *TokenError) Unwrap() error {
 	return e.Err
 }
 
+// ErrorCode returns a stable machine-readable code for the error
+func (e *TokenError) ErrorCode() string {
+	switch {
+	case errors.Is(e.Err, ErrTokenRequired):
+		return "token_required"
+	case errors.Is(e.Err, ErrTokenExpired):
+		return "token_expired"
+	case errors.Is(e.Err, ErrTokenUnsupported):
+		return "token_unsupported"
+	case errors.Is(e.Err, ErrPlayerIDMissing):
+		return "player_id_missing"
+	case errors.Is(e.Err, ErrExtraction):
+		return "token_extraction_failed"
+	case errors.Is(e.Err, ErrValidation):
+		return "token_validation_failed"
+	case errors.Is(e.Err, ErrForbidden):
+		return "token_forbidden"
+	default:
+		return "token_invalid"
+	}
+}
+
 // Common token errors
 func NewTokenRequiredError() *TokenError {
 	return NewTokenError(
@@ -86,4 +109,12 @@ func NewValidationError(err error) *TokenError {
 		http.StatusUnauthorized,
 		"authentication token validation failed",
 	)
-}
\ No newline at end of file
+}
+
+func NewForbiddenError(message string) *TokenError {
+	return NewTokenError(
+		ErrForbidden,
+		http.StatusForbidden,
+		message,
+	)
+}
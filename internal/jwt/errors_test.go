@@ -0,0 +1,33 @@
+package jwt
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestTokenErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  *TokenError
+		want string
+	}{
+		{"required", NewTokenRequiredError(), "token_required"},
+		{"invalid", NewTokenInvalidError(), "token_invalid"},
+		{"expired", NewTokenExpiredError(), "token_expired"},
+		{"extraction", NewExtractionError(errors.New("bad header")), "token_extraction_failed"},
+		{"validation", NewValidationError(errors.New("bad issuer")), "token_validation_failed"},
+		{"forbidden", NewForbiddenError("stream not granted"), "token_forbidden"},
+		{"unsupported", NewTokenError(ErrTokenUnsupported, http.StatusUnauthorized, "unsupported"), "token_unsupported"},
+		{"player ID", NewTokenError(ErrPlayerIDMissing, http.StatusUnauthorized, "no player"), "player_id_missing"},
+		{"unknown cause", NewTokenError(errors.New("other"), http.StatusUnauthorized, "other"), "token_invalid"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.err.ErrorCode(); got != tt.want {
+				t.Errorf("ErrorCode() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -29,6 +29,7 @@ func NewExtractor(config *config.JWTConfig) *Extractor {
 		opts: jwtheader.ExtractOptions{
 			HeaderName: config.HeaderName,
 			ParamName:  config.ParamName,
+			Precedence: jwtheader.Precedence(config.TokenPrecedence),
 		},
 		config: config,
 	}
@@ -62,6 +63,7 @@ func (e *Extractor) UpdateConfig(config *config.JWTConfig) {
 
 	e.opts.HeaderName = config.HeaderName
 	e.opts.ParamName = config.ParamName
+	e.opts.Precedence = jwtheader.Precedence(config.TokenPrecedence)
 	e.config = config
 }
 
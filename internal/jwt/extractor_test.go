@@ -0,0 +1,45 @@
+package jwt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestExtractorTokenPrecedence(t *testing.T) {
+	stale := signToken(t, nil, map[string]interface{}{"sub": "old", "exp": time.Now().Add(-time.Hour).Unix()})
+	fresh := signToken(t, nil, map[string]interface{}{"sub": "new", "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		precedence string
+		want       string
+	}{
+		{"header", stale},
+		{"query", fresh},
+		{"nonExpired", fresh},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.precedence, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.TokenPrecedence = tt.precedence
+			r := httptest.NewRequest(http.MethodGet, "/live/index.m3u8?"+cfg.ParamName+"="+fresh, nil)
+			r.Header.Set(cfg.HeaderName, "Bearer "+stale)
+
+			got, err := NewExtractor(cfg).Extract(r)
+			if err != nil {
+				t.Fatalf("Extract: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("extracted the %s token", map[string]string{stale: "header", fresh: "query"}[got])
+			}
+		})
+	}
+
+	// The default configuration keeps the header first
+	cfg := testJWTConfig()
+	if cfg.TokenPrecedence != "header" {
+		t.Errorf("default precedence %q, want header", cfg.TokenPrecedence)
+	}
+}
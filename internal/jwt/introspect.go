@@ -0,0 +1,95 @@
+// JWT token introspection
+//
+// Explains how the proxy sees a token, for debugging:
+// - Decoded header and claims, without signature checks
+// - Validation against the current configuration
+// - The specific failure reason and resulting status
+// - Never includes secrets or keys
+
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
+)
+
+// Introspection is the result of examining a token
+type Introspection struct {
+	Valid     bool                   `json:"valid"`
+	Status    int                    `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	Reason    string                 `json:"reason,omitempty"`
+	Header    map[string]interface{} `json:"header,omitempty"`
+	Claims    map[string]interface{} `json:"claims,omitempty"`
+	PlayerID  string                 `json:"playerId,omitempty"`
+	ExpiresIn int64                  `json:"expiresIn,omitempty"`
+	Path      string                 `json:"path,omitempty"`
+}
+
+// Introspect decodes and validates a token the way the proxy would for a
+// request to streamPath. The cache is bypassed so the result reflects the
+// current configuration. An empty streamPath skips authorization checks.
+func (v *Validator) Introspect(token, streamPath string) *Introspection {
+	v.mu.RLock()
+	config := v.config
+	v.mu.RUnlock()
+
+	result := &Introspection{Status: http.StatusOK, Path: streamPath}
+
+	if !jwtheader.IsValidJWT(token) {
+		return result.fail(NewTokenInvalidError(), jwtheader.ErrInvalidToken)
+	}
+
+	// Decode what can be decoded, even if validation fails below
+	parts := strings.Split(token, ".")
+	result.Header = decodeSegment(parts[0])
+	result.Claims = decodeSegment(parts[1])
+
+	claims, err := v.verify(token, config)
+	if err != nil {
+		return result.fail(mapValidationError(err), err)
+	}
+
+	result.PlayerID, _ = claims.GetPlayerID()
+	result.ExpiresIn = claims.RemainingValidity()
+
+	if streamPath != "" {
+		if err := v.Authorize(claims, streamPath); err != nil {
+			return result.fail(err, err)
+		}
+	}
+
+	result.Valid = true
+	return result
+}
+
+// fail records a failed check on the introspection result
+func (i *Introspection) fail(err error, reason error) *Introspection {
+	i.Valid = false
+	i.Status = http.StatusUnauthorized
+	if tokenErr, ok := err.(*TokenError); ok {
+		i.Status = tokenErr.StatusCode
+	}
+	i.Error = err.Error()
+	i.Reason = reason.Error()
+	return i
+}
+
+// decodeSegment decodes a base64url JSON token segment, returning nil if
+// it is malformed
+func decodeSegment(segment string) map[string]interface{} {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return nil
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
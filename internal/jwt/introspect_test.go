@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidatorIntrospect(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	valid := signToken(t, nil, map[string]interface{}{"sub": "p-1", "exp": exp, "stream": "/live/"})
+	expired := signToken(t, nil, map[string]interface{}{"sub": "p-1", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	tests := []struct {
+		name        string
+		token       string
+		path        string
+		audience    string
+		wantValid   bool
+		wantStatus  int
+		wantClaims  bool
+		wantPlayer  string
+		wantExpires bool
+	}{
+		{name: "valid", token: valid, wantValid: true, wantStatus: http.StatusOK, wantClaims: true, wantPlayer: "p-1", wantExpires: true},
+		{name: "allowed stream", token: valid, path: "/live/index.m3u8", wantValid: true, wantStatus: http.StatusOK, wantClaims: true, wantPlayer: "p-1", wantExpires: true},
+		{name: "forbidden stream", token: valid, path: "/vod/index.m3u8", wantStatus: http.StatusForbidden, wantClaims: true, wantPlayer: "p-1", wantExpires: true},
+		{name: "expired", token: expired, wantStatus: http.StatusUnauthorized, wantClaims: true},
+		{name: "wrong audience", token: valid, audience: "other", wantStatus: http.StatusUnauthorized, wantClaims: true},
+		{name: "malformed", token: "not-a-token", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.StreamClaim = "stream"
+			cfg.Audience = tt.audience
+			result := NewValidator(cfg, nil).Introspect(tt.token, tt.path)
+
+			if result.Valid != tt.wantValid || result.Status != tt.wantStatus {
+				t.Fatalf("valid %v status %d, want %v %d (%s)", result.Valid, result.Status, tt.wantValid, tt.wantStatus, result.Reason)
+			}
+			if !tt.wantValid && (result.Error == "" || result.Reason == "") {
+				t.Errorf("failure without error and reason: %+v", result)
+			}
+			if (result.Claims != nil) != tt.wantClaims {
+				t.Errorf("claims decoded = %v, want %v", result.Claims != nil, tt.wantClaims)
+			}
+			if result.PlayerID != tt.wantPlayer {
+				t.Errorf("player %q, want %q", result.PlayerID, tt.wantPlayer)
+			}
+			if (result.ExpiresIn > 0) != tt.wantExpires {
+				t.Errorf("expires in %d", result.ExpiresIn)
+			}
+			if result.Path != tt.path {
+				t.Errorf("path %q, want %q", result.Path, tt.path)
+			}
+		})
+	}
+}
+
+func TestValidatorIntrospectBypassesCache(t *testing.T) {
+	cfg := testJWTConfig()
+	v := NewValidator(cfg, nil)
+	token := signToken(t, nil, map[string]interface{}{"sub": "p-1", "exp": time.Now().Add(time.Hour).Unix()})
+	if _, err := v.ValidateToken(token); err != nil {
+		t.Fatalf("ValidateToken: %v", err)
+	}
+
+	rotated := *cfg
+	rotated.Audience = "other"
+	v.UpdateConfig(&rotated)
+	if result := v.Introspect(token, ""); result.Valid {
+		t.Error("introspection reused claims verified under the old configuration")
+	}
+}
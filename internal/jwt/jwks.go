@@ -0,0 +1,191 @@
+// JWKS key management
+//
+// Fetches and caches the RSA keys used to verify tokens:
+// - Periodic refresh with a minimum interval between fetches
+// - Circuit breaking when the JWKS endpoint keeps failing
+// - Stale keys kept and preferred over failing fetches, including fetches
+//   that yield no usable keys
+// - Fetch health exposed through metrics and readiness
+// - Bounded fetch time and response size
+
+package jwt
+
+import (
+	"crypto/rsa"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
+)
+
+// KeySet holds the keys of a JWKS endpoint
+type KeySet struct {
+	url         string
+	client      *http.Client
+	refresh     time.Duration
+	minInterval time.Duration
+	maxBytes    int64
+	breaker     *utils.CircuitBreaker
+	metrics     telemetry.Metrics
+
+	mu          sync.RWMutex
+	keys        map[string]*rsa.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	fetchMu     sync.Mutex
+}
+
+// NewKeySet creates a key set for the configured JWKS URL. Keys are fetched
+// lazily on first use. Metrics may be nil.
+func NewKeySet(cfg *config.JWTConfig, metrics telemetry.Metrics) *KeySet {
+	return &KeySet{
+		url:         cfg.KeysURL,
+		client:      &http.Client{Timeout: keysTimeout(cfg.KeysTimeout)},
+		refresh:     cfg.KeysRefresh,
+		minInterval: cfg.KeysMinInterval,
+		maxBytes:    cfg.KeysMaxBytes,
+		breaker:     utils.NewCircuitBreaker(cfg.KeysBreakerThreshold, cfg.KeysBreakerCooldown),
+		metrics:     metrics,
+		keys:        make(map[string]*rsa.PublicKey),
+	}
+}
+
+// keysTimeout returns the JWKS fetch timeout, 5s when unset; a fetch is
+// never left unbounded
+func keysTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return 5 * time.Second
+	}
+	return timeout
+}
+
+// Key returns the key with the given ID. The set is refreshed when it is
+// due or the ID is unknown, which happens on key rotation. If a refresh
+// fails, previously fetched keys are still used.
+func (k *KeySet) Key(kid string) (*rsa.PublicKey, error) {
+	key, found, due := k.lookup(kid)
+	if !found || due {
+		k.fetch()
+		key, found, _ = k.lookup(kid)
+	}
+	if !found {
+		return nil, jwtheader.ErrKeyUnavailable
+	}
+	return key, nil
+}
+
+// Ready reports whether keys are available for verification, attempting
+// a fetch if none have been loaded yet
+func (k *KeySet) Ready() bool {
+	if _, _, due := k.lookup(""); due {
+		k.fetch()
+	}
+
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return len(k.keys) > 0
+}
+
+// Close waits for an in-flight refresh and releases the connections to the
+// JWKS endpoint. Later lookups still work but open new connections.
+func (k *KeySet) Close() {
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+
+	k.client.CloseIdleConnections()
+}
+
+// lookup finds a key and reports whether the set is due for refresh. A
+// token without a key ID matches a set holding a single key.
+func (k *KeySet) lookup(kid string) (*rsa.PublicKey, bool, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	due := k.fetchedAt.IsZero() || (k.refresh > 0 && time.Since(k.fetchedAt) > k.refresh)
+
+	key, found := k.keys[kid]
+	if !found && kid == "" && len(k.keys) == 1 {
+		for _, only := range k.keys {
+			key, found = only, true
+		}
+	}
+	return key, found, due
+}
+
+// fetch refreshes the keys unless a fetch ran recently or the breaker is
+// open. Concurrent callers wait for a single fetch.
+func (k *KeySet) fetch() {
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+
+	k.mu.RLock()
+	recent := !k.lastAttempt.IsZero() && time.Since(k.lastAttempt) < k.minInterval
+	k.mu.RUnlock()
+	if recent {
+		return
+	}
+
+	if !k.breaker.Allow() {
+		k.incCounter("jwks.fetch.skipped")
+		return
+	}
+
+	k.mu.Lock()
+	k.lastAttempt = time.Now()
+	k.mu.Unlock()
+
+	maxBytes := k.maxBytes
+	if maxBytes <= 0 {
+		maxBytes = jwtheader.DefaultMaxJWKSBytes
+	}
+	set, err := jwtheader.FetchJWKSLimit(k.client, k.url, maxBytes)
+	if err != nil {
+		k.breaker.Failure()
+		k.incCounter("jwks.fetch.error")
+		k.setGauge("jwks.healthy", 0)
+		return
+	}
+
+	keys := make(map[string]*rsa.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+
+	// A set without usable keys would fail every token; keep the old keys
+	if len(keys) == 0 {
+		k.breaker.Failure()
+		k.incCounter("jwks.fetch.error")
+		k.incCounter("jwks.fetch.empty")
+		k.setGauge("jwks.healthy", 0)
+		return
+	}
+
+	k.breaker.Success()
+	k.incCounter("jwks.fetch.success")
+	k.setGauge("jwks.healthy", 1)
+
+	k.mu.Lock()
+	k.keys = keys
+	k.fetchedAt = time.Now()
+	k.mu.Unlock()
+}
+
+// incCounter increments a metric counter if metrics are configured
+func (k *KeySet) incCounter(name string) {
+	if k.metrics != nil {
+		k.metrics.IncCounter(name)
+	}
+}
+
+// setGauge sets a metric gauge if metrics are configured
+func (k *KeySet) setGauge(name string, value float64) {
+	if k.metrics != nil {
+		k.metrics.SetGauge(name, value)
+	}
+}
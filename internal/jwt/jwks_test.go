@@ -0,0 +1,356 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+var (
+	rsaKeysOnce sync.Once
+	rsaKeys     [2]*rsa.PrivateKey
+)
+
+// testRSAKeys returns two RSA keys shared by the JWKS tests
+func testRSAKeys(t *testing.T) [2]*rsa.PrivateKey {
+	t.Helper()
+	rsaKeysOnce.Do(func() {
+		for i := range rsaKeys {
+			key, err := rsa.GenerateKey(rand.Reader, 2048)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rsaKeys[i] = key
+		}
+	})
+	return rsaKeys
+}
+
+// signRS256 builds an RS256 token over claims with the given key ID
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	fields := map[string]string{"alg": "RS256", "typ": "JWT"}
+	if kid != "" {
+		fields["kid"] = kid
+	}
+	h, _ := json.Marshal(fields)
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// jwksServer serves the public keys as a JWKS, failing while fail is set.
+// fetches counts the requests it receives.
+type jwksServer struct {
+	*httptest.Server
+	fetches int32
+	fail    atomic.Bool
+}
+
+func newJWKSServer(t *testing.T, keys map[string]*rsa.PublicKey) *jwksServer {
+	t.Helper()
+	type jwk struct {
+		Kty string `json:"kty"`
+		Kid string `json:"kid,omitempty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, key := range keys {
+		set.Keys = append(set.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		})
+	}
+
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&s.fetches, 1)
+		if s.fail.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// jwksConfig returns a JWT configuration using the JWKS at url
+func jwksConfig(url string) *config.JWTConfig {
+	cfg := testJWTConfig()
+	cfg.KeysURL = url
+	return cfg
+}
+
+func TestValidatorVerifiesJWKSTokens(t *testing.T) {
+	keys := testRSAKeys(t)
+	claims := map[string]interface{}{"sub": "p-1", "exp": time.Now().Add(time.Hour).Unix()}
+
+	tests := []struct {
+		name    string
+		served  map[string]*rsa.PublicKey
+		signer  *rsa.PrivateKey
+		kid     string
+		wantErr bool
+	}{
+		{"matching key ID", map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey}, keys[0], "k1", false},
+		{"rotated key", map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey, "k2": &keys[1].PublicKey}, keys[1], "k2", false},
+		{"single key without ID", map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey}, keys[0], "", false},
+		{"wrong signer", map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey}, keys[1], "k1", true},
+		{"unknown key ID", map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey}, keys[0], "k9", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newJWKSServer(t, tt.served)
+			cfg := jwksConfig(server.URL)
+			v := NewValidator(cfg, nil)
+			v.SetKeySet(NewKeySet(cfg, nil))
+
+			_, err := v.ValidateToken(signRS256(t, tt.signer, tt.kid, claims))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateToken error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeySetRefetchLimits(t *testing.T) {
+	keys := testRSAKeys(t)
+	server := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey})
+
+	tests := []struct {
+		name        string
+		minInterval time.Duration
+		wantFetches int32
+	}{
+		{"unknown IDs refetch", 0, 3},
+		{"minimum interval holds refetches", time.Hour, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&server.fetches, 0)
+			cfg := jwksConfig(server.URL)
+			cfg.KeysMinInterval = tt.minInterval
+			set := NewKeySet(cfg, nil)
+
+			if _, err := set.Key("k1"); err != nil {
+				t.Fatalf("Key: %v", err)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err := set.Key("unknown"); err == nil {
+					t.Fatal("unknown key ID found")
+				}
+			}
+			if got := atomic.LoadInt32(&server.fetches); got != tt.wantFetches {
+				t.Errorf("fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+func TestKeySetKeepsStaleKeysWhileBreakerOpen(t *testing.T) {
+	keys := testRSAKeys(t)
+	server := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey})
+
+	cfg := jwksConfig(server.URL)
+	cfg.KeysRefresh = time.Nanosecond
+	cfg.KeysMinInterval = 0
+	cfg.KeysBreakerThreshold = 2
+	cfg.KeysBreakerCooldown = time.Hour
+	metrics := telemetry.NewMetrics()
+	set := NewKeySet(cfg, metrics)
+
+	if _, err := set.Key("k1"); err != nil {
+		t.Fatalf("Key: %v", err)
+	}
+
+	// Every lookup is due for refresh; failures keep the old key
+	server.fail.Store(true)
+	for i := 0; i < 4; i++ {
+		time.Sleep(time.Millisecond)
+		if _, err := set.Key("k1"); err != nil {
+			t.Fatalf("lookup %d: stale key not used: %v", i, err)
+		}
+	}
+	if !set.Ready() {
+		t.Error("not ready with stale keys")
+	}
+
+	// Two failed fetches open the breaker; later lookups skip the endpoint
+	if got := atomic.LoadInt32(&server.fetches); got != 3 {
+		t.Errorf("fetched %d times, want 3", got)
+	}
+	dump := metrics.(*telemetry.SimpleMetrics).DumpMetrics()
+	if dump["counter_jwks.fetch.error"] != 2 || dump["counter_jwks.fetch.skipped"] == nil {
+		t.Errorf("fetch metrics: %v", dump)
+	}
+	if dump["gauge_jwks.healthy"] != float64(0) {
+		t.Errorf("jwks.healthy = %v, want 0", dump["gauge_jwks.healthy"])
+	}
+}
+
+func TestKeySetKeepsKeysWhenFetchYieldsNone(t *testing.T) {
+	keys := testRSAKeys(t)
+	good := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey})
+
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"empty key array", `{"keys":[]}`},
+		{"no key array", `{}`},
+		{"unsupported key type", `{"keys":[{"kty":"EC","kid":"k1","crv":"P-256","x":"AA","y":"AA"}]}`},
+		{"malformed RSA key", `{"keys":[{"kty":"RSA","kid":"k1","n":"!!","e":"AQAB"}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var broken atomic.Bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if broken.Load() {
+					w.Write([]byte(tt.body))
+					return
+				}
+				good.Config.Handler.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+
+			cfg := jwksConfig(server.URL)
+			cfg.KeysRefresh = time.Nanosecond
+			cfg.KeysMinInterval = 0
+			cfg.KeysBreakerThreshold = 2
+			cfg.KeysBreakerCooldown = time.Hour
+			metrics := telemetry.NewMetrics()
+			set := NewKeySet(cfg, metrics)
+
+			if _, err := set.Key("k1"); err != nil {
+				t.Fatalf("Key: %v", err)
+			}
+
+			// The refreshed set has no usable key; the old one stays
+			broken.Store(true)
+			for i := 0; i < 3; i++ {
+				time.Sleep(time.Millisecond)
+				if _, err := set.Key("k1"); err != nil {
+					t.Fatalf("lookup %d: previous key dropped: %v", i, err)
+				}
+			}
+
+			dump := metrics.(*telemetry.SimpleMetrics).DumpMetrics()
+			if dump["counter_jwks.fetch.empty"] != 2 || dump["counter_jwks.fetch.error"] != 2 {
+				t.Errorf("empty fetches not counted as errors: %v", dump)
+			}
+			if dump["counter_jwks.fetch.skipped"] == nil {
+				t.Errorf("breaker did not open on empty fetches: %v", dump)
+			}
+			if dump["gauge_jwks.healthy"] != float64(0) {
+				t.Errorf("jwks.healthy = %v, want 0", dump["gauge_jwks.healthy"])
+			}
+		})
+	}
+
+	t.Run("no keys yet", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"keys":[]}`))
+		}))
+		defer server.Close()
+
+		if NewKeySet(jwksConfig(server.URL), nil).Ready() {
+			t.Error("ready without usable keys")
+		}
+	})
+}
+
+func TestKeySetFetchLimits(t *testing.T) {
+	keys := testRSAKeys(t)
+	served := newJWKSServer(t, map[string]*rsa.PublicKey{"k1": &keys[0].PublicKey})
+
+	tests := []struct {
+		name      string
+		handler   http.HandlerFunc
+		timeout   time.Duration
+		maxBytes  int64
+		wantReady bool
+	}{
+		{
+			name: "within limits",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				served.Config.Handler.ServeHTTP(w, r)
+			},
+			wantReady: true,
+		},
+		{
+			name:    "slow server times out",
+			timeout: 50 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(time.Second):
+				case <-r.Context().Done():
+				}
+			},
+		},
+		{
+			name:     "oversized response rejected",
+			maxBytes: 64,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				served.Config.Handler.ServeHTTP(w, r)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(tt.handler)
+			defer server.Close()
+
+			cfg := jwksConfig(server.URL)
+			cfg.KeysTimeout = tt.timeout
+			cfg.KeysMaxBytes = tt.maxBytes
+			metrics := telemetry.NewMetrics()
+			set := NewKeySet(cfg, metrics)
+
+			start := time.Now()
+			if got := set.Ready(); got != tt.wantReady {
+				t.Errorf("Ready() = %v, want %v", got, tt.wantReady)
+			}
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("fetch took %s", elapsed)
+			}
+
+			dump := metrics.(*telemetry.SimpleMetrics).DumpMetrics()
+			if !tt.wantReady && dump["counter_jwks.fetch.error"] != 1 {
+				t.Errorf("fetch errors %v, want 1", dump["counter_jwks.fetch.error"])
+			}
+		})
+	}
+
+	// Unset limits fall back to bounded defaults
+	if got := keysTimeout(0); got != 5*time.Second {
+		t.Errorf("default timeout %s, want 5s", got)
+	}
+}
@@ -0,0 +1,33 @@
+package jwt
+
+import "testing"
+
+func TestValidatorRequiredType(t *testing.T) {
+	tests := []struct {
+		name      string
+		required  string
+		typ       string
+		wantValid bool
+	}{
+		{"matching", "JWT", "JWT", true},
+		{"matching any case", "at+jwt", "AT+JWT", true},
+		{"media type prefix", "at+jwt", "application/at+jwt", true},
+		{"mismatching", "at+jwt", "JWT", false},
+		{"absent", "JWT", "", false},
+		{"not required", "", "", true},
+		{"not required, any type", "", "dpop+jwt", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.RequiredType = tt.required
+			token := signToken(t, map[string]string{"typ": tt.typ}, map[string]interface{}{"sub": "p1"})
+
+			_, err := NewValidator(cfg, nil).ValidateToken(token)
+			if (err == nil) != tt.wantValid {
+				t.Errorf("ValidateToken() = %v, want valid %v", err, tt.wantValid)
+			}
+		})
+	}
+}
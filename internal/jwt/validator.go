@@ -15,6 +15,7 @@ import (
 
 	"github.com/ilijajolevski/ilinden/internal/cache"
 	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/utils"
 	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
 )
 
@@ -22,8 +23,10 @@ import (
 type Validator struct {
 	config     *config.JWTConfig
 	cache      cache.Cache
+	keys       *KeySet
 	cacheTTL   time.Duration
 	validCache bool
+	clock      utils.Clock
 	mu         sync.RWMutex
 }
 
@@ -33,6 +36,7 @@ func NewValidator(config *config.JWTConfig, optionalCache cache.Cache) *Validato
 		config:     config,
 		cacheTTL:   5 * time.Minute,
 		validCache: optionalCache != nil,
+		clock:      utils.RealClock{},
 	}
 
 	if optionalCache != nil {
@@ -47,6 +51,7 @@ func (v *Validator) ValidateToken(token string) (*Claims, error) {
 	v.mu.RLock()
 	config := v.config
 	useCache := v.validCache
+	clock := v.clock
 	v.mu.RUnlock()
 
 	// Check cache first if available
@@ -54,7 +59,7 @@ func (v *Validator) ValidateToken(token string) (*Claims, error) {
 		cachedClaims, found := v.getFromCache(token)
 		if found {
 			// Check if token has expired since being cached
-			if cachedClaims.IsExpired() {
+			if cachedClaims.IsExpiredAt(clock.Now()) {
 				v.removeFromCache(token)
 				return nil, NewTokenExpiredError()
 			}
@@ -62,6 +67,22 @@ func (v *Validator) ValidateToken(token string) (*Claims, error) {
 		}
 	}
 
+	claims, err := v.verify(token, config)
+	if err != nil {
+		return nil, mapValidationError(err)
+	}
+
+	// Cache valid claims if caching is enabled
+	if useCache {
+		v.addToCache(token, claims)
+	}
+
+	return claims, nil
+}
+
+// verify parses and verifies a token against config, bypassing the cache.
+// Errors are returned as reported by the parser.
+func (v *Validator) verify(token string, config *config.JWTConfig) (*Claims, error) {
 	// Prepare validation options
 	opts := jwtheader.ValidationOptions{
 		Secret:          config.Secret,
@@ -69,33 +90,61 @@ func (v *Validator) ValidateToken(token string) (*Claims, error) {
 		RequiredClaims:  config.RequiredClaims,
 		Issuer:          config.Issuer,
 		Audience:        config.Audience,
+		Issuers:         config.Issuers,
+		Audiences:       config.Audiences,
 		ClaimsNamespace: config.ClaimsNamespace,
 		AllowedAlgs:     config.AllowedAlgs,
+		RequiredType:    config.RequiredType,
+	}
+
+	v.mu.RLock()
+	keys := v.keys
+	opts.Now = v.clock.Now
+	v.mu.RUnlock()
+	if keys != nil {
+		opts.KeyFunc = keys.Key
 	}
 
 	// Validate token
 	jwtClaims, err := jwtheader.ParseAndVerify(token, opts)
 	if err != nil {
-		// Map specific error types
-		switch err {
-		case jwtheader.ErrTokenExpired:
-			return nil, NewTokenExpiredError()
-		case jwtheader.ErrInvalidToken, jwtheader.ErrInvalidSignature:
-			return nil, NewTokenInvalidError()
-		default:
-			return nil, NewValidationError(err)
-		}
+		return nil, err
 	}
 
 	// Create our claims wrapper
 	claims := NewClaims(jwtClaims, config.ClaimsNamespace)
+	claims.playerIDClaim = config.PlayerIDClaim
 
-	// Cache valid claims if caching is enabled
-	if useCache {
-		v.addToCache(token, claims)
+	return claims, nil
+}
+
+// mapValidationError converts a parser error to a token error
+func mapValidationError(err error) *TokenError {
+	switch err {
+	case jwtheader.ErrTokenExpired:
+		return NewTokenExpiredError()
+	case jwtheader.ErrInvalidToken, jwtheader.ErrInvalidSignature:
+		return NewTokenInvalidError()
+	default:
+		return NewValidationError(err)
 	}
+}
 
-	return claims, nil
+// SetKeySet sets the JWKS keys used to verify RSA-signed tokens
+func (v *Validator) SetKeySet(keys *KeySet) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.keys = keys
+}
+
+// SetClock replaces the clock used for expiry checks, e.g. with a fake one
+// in tests
+func (v *Validator) SetClock(clock utils.Clock) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.clock = utils.ClockOrReal(clock)
 }
 
 // UpdateConfig updates the validator configuration
@@ -134,7 +183,9 @@ func (v *Validator) addToCache(token string, claims *Claims) {
 	// If token has an expiration, use that as TTL instead
 	// (minus a small buffer to ensure we don't serve nearly-expired tokens)
 	if claims.ExpirationTime > 0 {
-		remaining := claims.RemainingValidity()
+		v.mu.RLock()
+		remaining := claims.RemainingValidityAt(v.clock.Now())
+		v.mu.RUnlock()
 		if remaining > 0 {
 			// Use the lower of the two values
 			expTTL := time.Duration(remaining-30) * time.Second
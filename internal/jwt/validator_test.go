@@ -0,0 +1,69 @@
+package jwt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+const testSecret = "test-secret"
+
+// signToken builds an HS256 token over claims with the given header fields
+func signToken(t *testing.T, header map[string]string, claims map[string]interface{}) string {
+	t.Helper()
+	fields := map[string]string{"alg": "HS256", "typ": "JWT"}
+	for k, v := range header {
+		fields[k] = v
+	}
+	h, _ := json.Marshal(fields)
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(testSecret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// testJWTConfig returns a JWT configuration verifying tokens signed by
+// signToken
+func testJWTConfig() *config.JWTConfig {
+	cfg := &config.Config{}
+	config.SetDefaults(cfg)
+	cfg.JWT.Secret = testSecret
+	return &cfg.JWT
+}
+
+func TestValidatorPlayerIDClaim(t *testing.T) {
+	token := signToken(t, nil, map[string]interface{}{
+		"sub":    "sub-1",
+		"device": map[string]interface{}{"id": "d-1"},
+	})
+
+	tests := []struct {
+		claim string
+		want  string
+	}{
+		{"", "sub-1"},
+		{"device.id", "d-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.claim, func(t *testing.T) {
+			cfg := testJWTConfig()
+			cfg.PlayerIDClaim = tt.claim
+			claims, err := NewValidator(cfg, nil).ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if got, _ := claims.GetPlayerID(); got != tt.want {
+				t.Errorf("player ID = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+// Admin authentication middleware
+//
+// Protects administrative and debugging endpoints:
+// - Bearer token authentication
+// - Constant-time token comparison
+// - Denies all requests when no token is configured
+
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+)
+
+// AdminAuth returns a middleware requiring "Authorization: Bearer <token>".
+// With an empty token every request is rejected, so admin endpoints are
+// never exposed unauthenticated by accident.
+func AdminAuth(token string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				api.WriteError(w, api.NewError("Admin access is not configured", "admin_disabled", http.StatusForbidden))
+				return
+			}
+
+			presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+				api.WriteError(w, api.NewError("Unauthorized", "admin_unauthorized", http.StatusUnauthorized))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
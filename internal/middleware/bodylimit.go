@@ -0,0 +1,39 @@
+// Request body size limiting middleware
+//
+// Protects handlers from oversized request bodies:
+// - Declared Content-Length checking
+// - Streaming body limit enforcement
+// - 413 responses on overflow
+
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+)
+
+// MaxBodySize returns a middleware that limits request bodies to maxBytes.
+// Requests declaring a larger Content-Length are rejected with 413; bodies
+// without a declared length fail on read once the limit is exceeded.
+func MaxBodySize(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		if maxBytes <= 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				apiErr := api.NewError("Request body too large", "body_too_large", http.StatusRequestEntityTooLarge)
+				api.WriteError(w, apiErr)
+				return
+			}
+
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
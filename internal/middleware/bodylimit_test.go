@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMaxBodySize(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int64
+		body          string
+		unknownLength bool
+		wantStatus    int
+	}{
+		{name: "within limit", limit: 8, body: "12345678", wantStatus: http.StatusOK},
+		{name: "declared too large", limit: 8, body: "123456789", wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "streamed too large", limit: 8, body: "123456789", unknownLength: true, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "no body", limit: 8, wantStatus: http.StatusOK},
+		{name: "disabled", limit: 0, body: strings.Repeat("x", 1024), wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := MaxBodySize(tt.limit)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, err := io.ReadAll(r.Body); err != nil {
+					w.WriteHeader(http.StatusRequestEntityTooLarge)
+					return
+				}
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/token", strings.NewReader(tt.body))
+			if tt.unknownLength {
+				req.ContentLength = -1
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
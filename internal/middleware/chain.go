@@ -5,25 +5,63 @@
 // - Order management
 // - Context propagation
 // - Chain building helpers
+// - Ordering constraints between named middleware
 
 package middleware
 
 import (
+	"fmt"
 	"net/http"
 )
 
 // Middleware is a function that wraps an http.Handler
 type Middleware func(http.Handler) http.Handler
 
+// Names of the standard middleware, for ordering constraints
+const (
+	NameRecovery    = "recovery"
+	NameLogging     = "logging"
+	NameMetrics     = "metrics"
+	NameIPFilter    = "ipfilter"
+	NameHeaders     = "headers"
+	NameCompression = "compression"
+	NameAuth        = "auth"
+	NameRateLimit   = "ratelimit"
+)
+
+// AnyMiddleware stands for every other middleware in an Order
+const AnyMiddleware = "*"
+
+// Order requires the Outer middleware to wrap the Inner one, i.e. to come
+// earlier in the chain. Constraints naming absent middleware are ignored.
+type Order struct {
+	Outer string
+	Inner string
+}
+
+// DefaultOrder holds the invariants of the standard middleware: recovery
+// outermost, requests observed before they can be rejected, and
+// authentication before rate limiting
+var DefaultOrder = []Order{
+	{Outer: NameRecovery, Inner: AnyMiddleware},
+	{Outer: NameLogging, Inner: NameAuth},
+	{Outer: NameMetrics, Inner: NameAuth},
+	{Outer: NameMetrics, Inner: NameIPFilter},
+	{Outer: NameAuth, Inner: NameRateLimit},
+}
+
 // Chain represents a chain of middleware
 type Chain struct {
 	middlewares []Middleware
+	names       []string // Parallel to middlewares; empty for unnamed ones
+	order       []Order
 }
 
 // NewChain creates a new middleware chain
 func NewChain(middlewares ...Middleware) Chain {
 	return Chain{
 		middlewares: append([]Middleware{}, middlewares...),
+		names:       make([]string, len(middlewares)),
 	}
 }
 
@@ -42,16 +80,75 @@ func (c Chain) ThenFunc(fn func(http.ResponseWriter, *http.Request)) http.Handle
 
 // Append adds middleware to the chain
 func (c Chain) Append(middlewares ...Middleware) Chain {
-	newMiddlewares := make([]Middleware, len(c.middlewares)+len(middlewares))
-	copy(newMiddlewares, c.middlewares)
-	copy(newMiddlewares[len(c.middlewares):], middlewares)
-	
-	return Chain{
-		middlewares: newMiddlewares,
-	}
+	return c.extend(middlewares, make([]string, len(middlewares)), nil)
+}
+
+// AppendNamed adds a named middleware to the chain, so that ordering
+// constraints can refer to it
+func (c Chain) AppendNamed(name string, middleware Middleware) Chain {
+	return c.extend([]Middleware{middleware}, []string{name}, nil)
 }
 
 // Extend extends the chain with another chain
 func (c Chain) Extend(chain Chain) Chain {
-	return c.Append(chain.middlewares...)
+	return c.extend(chain.middlewares, chain.names, chain.order)
+}
+
+// Require adds ordering constraints checked by Build
+func (c Chain) Require(order ...Order) Chain {
+	return c.extend(nil, nil, order)
+}
+
+// extend returns a copy of the chain with middleware and constraints added
+func (c Chain) extend(middlewares []Middleware, names []string, order []Order) Chain {
+	next := Chain{
+		middlewares: make([]Middleware, 0, len(c.middlewares)+len(middlewares)),
+		names:       make([]string, 0, len(c.middlewares)+len(middlewares)),
+		order:       make([]Order, 0, len(c.order)+len(order)),
+	}
+	next.middlewares = append(append(next.middlewares, c.middlewares...), middlewares...)
+	next.names = append(append(next.names, c.names...), names...)
+	next.order = append(append(next.order, c.order...), order...)
+	return next
+}
+
+// Validate checks the chain against its ordering constraints
+func (c Chain) Validate() error {
+	position := make(map[string]int, len(c.names))
+	for i, name := range c.names {
+		if name == "" {
+			continue
+		}
+		if _, dup := position[name]; dup {
+			return fmt.Errorf("middleware %q is registered twice", name)
+		}
+		position[name] = i
+	}
+
+	for _, o := range c.order {
+		outer, ok := position[o.Outer]
+		if !ok {
+			continue
+		}
+		if o.Inner == AnyMiddleware {
+			if outer != 0 {
+				return fmt.Errorf("middleware %q must be outermost, found at position %d", o.Outer, outer)
+			}
+			continue
+		}
+		inner, ok := position[o.Inner]
+		if ok && outer > inner {
+			return fmt.Errorf("middleware %q must wrap %q", o.Outer, o.Inner)
+		}
+	}
+	return nil
+}
+
+// Build validates the ordering constraints and applies the chain to a
+// handler, returning an error instead of a misordered handler
+func (c Chain) Build(h http.Handler) (http.Handler, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c.Then(h), nil
 }
\ No newline at end of file
@@ -0,0 +1,115 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tag returns a middleware appending name to the X-Order response header
+func tag(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Order", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// named builds a chain of tagged middleware registered under names, with
+// "-" for an unnamed one
+func named(names ...string) Chain {
+	c := NewChain()
+	for _, name := range names {
+		if name == "-" {
+			c = c.Append(tag(name))
+			continue
+		}
+		c = c.AppendNamed(name, tag(name))
+	}
+	return c
+}
+
+func TestChainBuildValidatesOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		chain   Chain
+		wantErr string
+	}{
+		{
+			name:  "standard order",
+			chain: named(NameRecovery, NameLogging, NameMetrics, NameIPFilter, NameAuth, NameRateLimit).Require(DefaultOrder...),
+		},
+		{
+			name:  "absent middleware ignored",
+			chain: named(NameRecovery, "-", NameRateLimit).Require(DefaultOrder...),
+		},
+		{
+			name:    "recovery not outermost",
+			chain:   named("-", NameRecovery).Require(DefaultOrder...),
+			wantErr: "outermost",
+		},
+		{
+			name:    "metrics inside auth",
+			chain:   named(NameRecovery, NameAuth, NameMetrics).Require(DefaultOrder...),
+			wantErr: `"metrics" must wrap "auth"`,
+		},
+		{
+			name:    "rate limit before auth",
+			chain:   named(NameRateLimit, NameAuth).Require(DefaultOrder...),
+			wantErr: `"auth" must wrap "ratelimit"`,
+		},
+		{
+			name:    "registered twice",
+			chain:   named(NameLogging, NameLogging),
+			wantErr: "registered twice",
+		},
+		{
+			name:    "constraints carried by Extend",
+			chain:   named(NameAuth).Extend(named(NameLogging).Require(DefaultOrder...)),
+			wantErr: `"logging" must wrap "auth"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := tt.chain.Build(http.NotFoundHandler())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Build() error = %v, want %q", err, tt.wantErr)
+				}
+				if h != nil {
+					t.Error("handler returned for an invalid chain")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Build() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestChainAppliesInOrder(t *testing.T) {
+	// Chains sharing a prefix do not overwrite each other's entries
+	shared := named("a")
+	first := shared.Extend(named("b", "-"))
+	second := shared.AppendNamed("c", tag("c"))
+
+	tests := []struct {
+		chain Chain
+		want  string
+	}{
+		{shared, "a"},
+		{first, "a,b,-"},
+		{second, "a,c"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		tt.chain.ThenFunc(func(http.ResponseWriter, *http.Request) {}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := strings.Join(rec.Header().Values("X-Order"), ","); got != tt.want {
+			t.Errorf("applied %s, want %s", got, tt.want)
+		}
+	}
+}
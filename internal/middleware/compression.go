@@ -0,0 +1,304 @@
+// Response compression middleware
+//
+// Negotiated compression of text responses:
+// - Brotli (preferred) and gzip encodings
+// - Size threshold before compressing
+// - Content-type gating
+// - Vary header management
+
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// Supported content codings
+const (
+	EncodingBrotli = "br"
+	EncodingGzip   = "gzip"
+)
+
+// CompressionOptions configures response compression
+type CompressionOptions struct {
+	MinSize      int      // Minimum body size in bytes before compressing
+	ContentTypes []string // Compressible media types (entries ending in "/" match a prefix)
+	Brotli       bool     // Whether to offer Brotli when the client advertises it
+}
+
+// DefaultCompressionOptions returns sensible default compression options
+func DefaultCompressionOptions() CompressionOptions {
+	return CompressionOptions{
+		MinSize: 1024,
+		ContentTypes: []string{
+			"application/vnd.apple.mpegurl",
+			"application/x-mpegurl",
+			"audio/mpegurl",
+			"application/json",
+			"text/",
+		},
+		Brotli: true,
+	}
+}
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() interface{} { return gzip.NewWriter(io.Discard) },
+	}
+	brotliWriterPool = sync.Pool{
+		New: func() interface{} { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) },
+	}
+)
+
+// Compression returns a middleware that compresses eligible responses using
+// the best encoding advertised by the client
+func Compression(opts CompressionOptions) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressWriter{
+				ResponseWriter: w,
+				opts:           &opts,
+				encoding:       NegotiateEncoding(r.Header.Get("Accept-Encoding"), opts.Brotli),
+			}
+			defer cw.Close()
+
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// NegotiateEncoding picks the response encoding for an Accept-Encoding
+// header, preferring Brotli when allowed. It returns "" for identity.
+func NegotiateEncoding(acceptEncoding string, allowBrotli bool) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	if allowBrotli && utils.AcceptsEncoding(acceptEncoding, EncodingBrotli) {
+		return EncodingBrotli
+	}
+	if utils.AcceptsEncoding(acceptEncoding, EncodingGzip) {
+		return EncodingGzip
+	}
+	return ""
+}
+
+// compressWriter buffers the start of a response until it can decide
+// whether to compress, then either compresses or passes through
+type compressWriter struct {
+	http.ResponseWriter
+	opts        *CompressionOptions
+	encoding    string
+	status      int
+	buf         []byte
+	enc         io.WriteCloser
+	decided     bool
+	compressing bool
+	wroteHeader bool
+}
+
+// WriteHeader records the status code; it is sent once compression is decided
+func (cw *compressWriter) WriteHeader(code int) {
+	if cw.wroteHeader || cw.status != 0 {
+		return
+	}
+	cw.status = code
+
+	// Bodiless responses are never compressed
+	if code == http.StatusNoContent || code == http.StatusNotModified || code < http.StatusOK {
+		cw.decided = true
+		cw.writeHeader()
+	}
+}
+
+// Write buffers or compresses the response body
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.decided {
+		cw.decide(b)
+	}
+
+	if cw.compressing {
+		return cw.enc.Write(b)
+	}
+
+	if !cw.decided {
+		// Still below the threshold with unknown length
+		cw.buf = append(cw.buf, b...)
+		if len(cw.buf) >= cw.opts.MinSize {
+			cw.startCompression()
+			buffered := cw.buf
+			cw.buf = nil
+			if _, err := cw.enc.Write(buffered); err != nil {
+				return 0, err
+			}
+		}
+		return len(b), nil
+	}
+
+	cw.writeHeader()
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush sends any compressed data written so far to the client
+func (cw *compressWriter) Flush() {
+	if !cw.decided && len(cw.buf) > 0 {
+		// Flushing commits the response, so give up on compressing it
+		cw.passthrough()
+	}
+	if cw.compressing {
+		if f, ok := cw.enc.(interface{ Flush() error }); ok {
+			f.Flush()
+		}
+	} else {
+		cw.writeHeader()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController, so
+// deadlines and hijacking reach the connection
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the response, writing any buffered body and releasing the encoder
+func (cw *compressWriter) Close() error {
+	if cw.compressing {
+		err := cw.enc.Close()
+		cw.releaseEncoder()
+		return err
+	}
+
+	if !cw.decided && (cw.buf != nil || cw.status != 0) {
+		cw.passthrough()
+	}
+	return nil
+}
+
+// decide determines from the response headers whether the body may be compressed
+func (cw *compressWriter) decide(first []byte) {
+	h := cw.Header()
+
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(first))
+	}
+
+	if h.Get("Content-Encoding") != "" || !cw.compressible(h.Get("Content-Type")) {
+		cw.decided = true
+		return
+	}
+
+	// Compressible representation: caches must key on the request encoding
+	addVary(h, "Accept-Encoding")
+
+	if cw.encoding == "" {
+		cw.decided = true
+		return
+	}
+
+	if cl := h.Get("Content-Length"); cl != "" {
+		if n, err := strconv.Atoi(cl); err == nil && n < cw.opts.MinSize {
+			cw.decided = true
+			return
+		}
+		cw.startCompression()
+	}
+}
+
+// compressible reports whether the content type is eligible for compression
+func (cw *compressWriter) compressible(contentType string) bool {
+	mediaType := utils.MediaType(contentType)
+	for _, ct := range cw.opts.ContentTypes {
+		ct = strings.ToLower(ct)
+		if strings.HasSuffix(ct, "/") {
+			if strings.HasPrefix(mediaType, ct) {
+				return true
+			}
+		} else if mediaType == ct {
+			return true
+		}
+	}
+	return false
+}
+
+// startCompression switches the writer into compressing mode
+func (cw *compressWriter) startCompression() {
+	h := cw.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", cw.encoding)
+
+	cw.decided = true
+	cw.compressing = true
+	cw.writeHeader()
+
+	switch cw.encoding {
+	case EncodingBrotli:
+		bw := brotliWriterPool.Get().(*brotli.Writer)
+		bw.Reset(cw.ResponseWriter)
+		cw.enc = bw
+	default:
+		gw := gzipWriterPool.Get().(*gzip.Writer)
+		gw.Reset(cw.ResponseWriter)
+		cw.enc = gw
+	}
+}
+
+// passthrough writes the buffered body uncompressed
+func (cw *compressWriter) passthrough() {
+	cw.decided = true
+	if cw.buf != nil && cw.Header().Get("Content-Length") == "" {
+		cw.Header().Set("Content-Length", strconv.Itoa(len(cw.buf)))
+	}
+	cw.writeHeader()
+	if len(cw.buf) > 0 {
+		cw.ResponseWriter.Write(cw.buf)
+	}
+	cw.buf = nil
+}
+
+// writeHeader sends the status code once
+func (cw *compressWriter) writeHeader() {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+	if cw.status == 0 {
+		cw.status = http.StatusOK
+	}
+	cw.ResponseWriter.WriteHeader(cw.status)
+}
+
+// releaseEncoder returns the encoder to its pool
+func (cw *compressWriter) releaseEncoder() {
+	switch enc := cw.enc.(type) {
+	case *gzip.Writer:
+		gzipWriterPool.Put(enc)
+	case *brotli.Writer:
+		brotliWriterPool.Put(enc)
+	}
+	cw.enc = nil
+}
+
+// addVary adds a value to the Vary header if not already present
+func addVary(h http.Header, value string) {
+	for _, v := range h.Values("Vary") {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), value) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", value)
+}
@@ -0,0 +1,108 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		accept      string
+		allowBrotli bool
+		want        string
+	}{
+		{"", true, ""},
+		{"gzip", true, EncodingGzip},
+		{"br", true, EncodingBrotli},
+		{"gzip, br", true, EncodingBrotli},
+		{"gzip, br", false, EncodingGzip},
+		{"br;q=0, gzip", true, EncodingGzip},
+		{"gzip;q=0", true, ""},
+		{"*", true, EncodingBrotli},
+		{"identity", true, ""},
+	}
+
+	for _, tt := range tests {
+		if got := NegotiateEncoding(tt.accept, tt.allowBrotli); got != tt.want {
+			t.Errorf("NegotiateEncoding(%q, %v) = %q, want %q", tt.accept, tt.allowBrotli, got, tt.want)
+		}
+	}
+}
+
+// decompress decodes body according to its content coding
+func decompress(t *testing.T, encoding string, body io.Reader) string {
+	t.Helper()
+	switch encoding {
+	case EncodingGzip:
+		zr, err := gzip.NewReader(body)
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		body = zr
+	case EncodingBrotli:
+		body = brotli.NewReader(body)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read %s body: %v", encoding, err)
+	}
+	return string(data)
+}
+
+func TestCompression(t *testing.T) {
+	large := "#EXTM3U\n" + strings.Repeat("#EXTINF:6,\nsegment.ts\n", 100)
+	small := "#EXTM3U\n"
+
+	tests := []struct {
+		name         string
+		accept       string
+		brotli       bool
+		contentType  string
+		body         string
+		wantEncoding string
+		wantVary     bool
+	}{
+		{"brotli preferred", "gzip, br", true, "application/vnd.apple.mpegurl", large, EncodingBrotli, true},
+		{"gzip when brotli disabled", "gzip, br", false, "application/vnd.apple.mpegurl", large, EncodingGzip, true},
+		{"gzip only client", "gzip", true, "application/vnd.apple.mpegurl", large, EncodingGzip, true},
+		{"text prefix", "gzip", true, "text/plain; charset=utf-8", large, EncodingGzip, true},
+		{"identity client", "", true, "application/vnd.apple.mpegurl", large, "", true},
+		{"below threshold", "gzip", true, "application/vnd.apple.mpegurl", small, "", true},
+		{"segments untouched", "gzip, br", true, "video/mp2t", large, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultCompressionOptions()
+			opts.Brotli = tt.brotli
+			handler := Compression(opts)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", tt.contentType)
+				io.WriteString(w, tt.body)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/live/a.m3u8", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			encoding := rec.Header().Get("Content-Encoding")
+			if encoding != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("Vary") == "Accept-Encoding"; got != tt.wantVary {
+				t.Errorf("Vary = %q, want Accept-Encoding: %v", rec.Header().Get("Vary"), tt.wantVary)
+			}
+			if got := decompress(t, encoding, rec.Body); got != tt.body {
+				t.Errorf("body changed by compression")
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Route group middleware
+//
+// Distinct middleware chains per group of routes:
+// - A base chain shared by every group
+// - Per-group extensions, e.g. admin authentication
+// - Ordering constraints checked per group
+
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Standard route groups
+const (
+	GroupProxy   = "proxy"   // Proxied playlists and segments
+	GroupAdmin   = "admin"   // Administrative endpoints
+	GroupHealth  = "health"  // Liveness and readiness probes
+	GroupMetrics = "metrics" // Metrics and profiling
+)
+
+// Groups holds a middleware chain per route group, each extending a shared
+// base chain
+type Groups struct {
+	base   Chain
+	groups map[string]Chain
+}
+
+// NewGroups creates route groups sharing the base chain
+func NewGroups(base Chain) *Groups {
+	return &Groups{
+		base:   base,
+		groups: make(map[string]Chain),
+	}
+}
+
+// Extend adds a chain to a group, inside the base chain and any chain
+// added to the group before
+func (g *Groups) Extend(group string, chain Chain) *Groups {
+	g.groups[group] = g.groups[group].Extend(chain)
+	return g
+}
+
+// Chain returns a group's full chain; groups that were never extended get
+// the base chain
+func (g *Groups) Chain(group string) Chain {
+	return g.base.Extend(g.groups[group])
+}
+
+// Build validates a group's chain and applies it to a handler
+func (g *Groups) Build(group string, h http.Handler) (http.Handler, error) {
+	handler, err := g.Chain(group).Build(h)
+	if err != nil {
+		return nil, fmt.Errorf("%s routes: %w", group, err)
+	}
+	return handler, nil
+}
@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGroupsApplyPerGroupChains(t *testing.T) {
+	base := named(NameRecovery, NameLogging).Require(DefaultOrder...)
+	groups := NewGroups(base).
+		Extend(GroupProxy, named(NameMetrics, NameRateLimit)).
+		Extend(GroupAdmin, named(NameIPFilter).AppendNamed(NameAuth, AdminAuth("secret"))).
+		Extend(GroupAdmin, named("admin-extra"))
+
+	tests := []struct {
+		group         string
+		authorization string
+		wantStatus    int
+		wantOrder     string
+	}{
+		{GroupProxy, "", http.StatusOK, "recovery,logging,metrics,ratelimit"},
+		{GroupAdmin, "", http.StatusUnauthorized, "recovery,logging,ipfilter"},
+		{GroupAdmin, "Bearer secret", http.StatusOK, "recovery,logging,ipfilter,admin-extra"},
+		{GroupHealth, "", http.StatusOK, "recovery,logging"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.group+" "+tt.authorization, func(t *testing.T) {
+			handler, err := groups.Build(tt.group, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			if err != nil {
+				t.Fatalf("Build: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := strings.Join(rec.Header()["X-Order"], ","); got != tt.wantOrder {
+				t.Errorf("middleware %s, want %s", got, tt.wantOrder)
+			}
+		})
+	}
+}
+
+func TestGroupsBuildValidatesEachGroup(t *testing.T) {
+	base := named(NameRecovery).Require(DefaultOrder...)
+	groups := NewGroups(base).
+		Extend(GroupProxy, named(NameMetrics)).
+		Extend(GroupAdmin, named(NameAuth, NameMetrics))
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if _, err := groups.Build(GroupProxy, ok); err != nil {
+		t.Errorf("proxy group: %v", err)
+	}
+	_, err := groups.Build(GroupAdmin, ok)
+	if err == nil || !strings.HasPrefix(err.Error(), "admin routes:") {
+		t.Errorf("admin group error %v, want an ordering error for admin routes", err)
+	}
+}
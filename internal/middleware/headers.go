@@ -0,0 +1,72 @@
+// Static response headers middleware
+//
+// Adds operator-configured headers to responses:
+// - Applied when the response header is written
+// - Headers set by the handler take precedence
+// - Streaming-safe flush passthrough
+// - Unwrap for http.ResponseController
+
+package middleware
+
+import (
+	"net/http"
+)
+
+// ResponseHeaders returns a middleware that adds the configured headers to
+// every response. A header already set by the handler is left untouched.
+func ResponseHeaders(headers map[string]string) Middleware {
+	return func(next http.Handler) http.Handler {
+		if len(headers) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(&headerWriter{ResponseWriter: w, headers: headers}, r)
+		})
+	}
+}
+
+// headerWriter adds static headers just before the response header is sent
+type headerWriter struct {
+	http.ResponseWriter
+	headers map[string]string
+	applied bool
+}
+
+// WriteHeader adds the static headers and sends the status code
+func (hw *headerWriter) WriteHeader(code int) {
+	hw.apply()
+	hw.ResponseWriter.WriteHeader(code)
+}
+
+// Write adds the static headers before the first body write
+func (hw *headerWriter) Write(b []byte) (int, error) {
+	hw.apply()
+	return hw.ResponseWriter.Write(b)
+}
+
+// Flush adds the static headers and flushes the underlying writer
+func (hw *headerWriter) Flush() {
+	hw.apply()
+	http.NewResponseController(hw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (hw *headerWriter) Unwrap() http.ResponseWriter {
+	return hw.ResponseWriter
+}
+
+// apply sets every configured header the handler has not set
+func (hw *headerWriter) apply() {
+	if hw.applied {
+		return
+	}
+	hw.applied = true
+
+	h := hw.Header()
+	for k, v := range hw.headers {
+		if h.Get(k) == "" {
+			h.Set(k, v)
+		}
+	}
+}
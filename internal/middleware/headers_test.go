@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseHeaders(t *testing.T) {
+	static := map[string]string{
+		"X-Served-By":   "ilinden",
+		"Cache-Control": "max-age=60",
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    map[string]string
+	}{
+		{
+			name: "added on body write",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			},
+			want: map[string]string{"X-Served-By": "ilinden", "Cache-Control": "max-age=60"},
+		},
+		{
+			name: "added on explicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusNoContent)
+			},
+			want: map[string]string{"X-Served-By": "ilinden", "Cache-Control": "max-age=60"},
+		},
+		{
+			name: "added on flush",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+			},
+			want: map[string]string{"X-Served-By": "ilinden", "Cache-Control": "max-age=60"},
+		},
+		{
+			name: "handler header wins",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Cache-Control", "no-cache")
+				io.WriteString(w, "ok")
+			},
+			want: map[string]string{"X-Served-By": "ilinden", "Cache-Control": "no-cache"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ResponseHeaders(static)(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			for k, want := range tt.want {
+				if got := rec.Header().Get(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestResponseHeadersEmptyIsPassthrough(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, wrapped := w.(*headerWriter); wrapped {
+			t.Error("writer wrapped without configured headers")
+		}
+	})
+	ResponseHeaders(nil)(next).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
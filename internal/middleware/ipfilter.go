@@ -0,0 +1,72 @@
+// IP allow/deny list middleware
+//
+// Network-level access control:
+// - IPv4 and IPv6 CIDR lists
+// - Deny rules take precedence over allow rules
+// - Trusted proxy aware client IP resolution
+// - 403 responses for rejected clients
+
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// IPFilterOptions configures the IP filter middleware
+type IPFilterOptions struct {
+	Allow          []string // CIDRs allowed access; empty allows all not denied
+	Deny           []string // CIDRs denied access
+	TrustedProxies []string // CIDRs whose forwarding headers are trusted
+}
+
+// IPFilter returns a middleware that rejects clients whose effective IP is
+// denied, or not allowed when an allow list is configured
+func IPFilter(opts IPFilterOptions) (Middleware, error) {
+	allow, err := utils.ParseCIDRs(opts.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := utils.ParseCIDRs(opts.Deny)
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := utils.ParseCIDRs(opts.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(allow) == 0 && len(deny) == 0 {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := utils.ClientIP(r, trusted)
+			if !ipPermitted(ip, allow, deny) {
+				apiErr := api.NewError("Forbidden", "ip_forbidden", http.StatusForbidden)
+				api.WriteError(w, apiErr)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}, nil
+}
+
+// ipPermitted evaluates the allow and deny lists for an IP
+func ipPermitted(ip net.IP, allow, deny []*net.IPNet) bool {
+	if ip == nil {
+		return len(allow) == 0 && len(deny) == 0
+	}
+	if utils.ContainsIP(deny, ip) {
+		return false
+	}
+	if len(allow) > 0 {
+		return utils.ContainsIP(allow, ip)
+	}
+	return true
+}
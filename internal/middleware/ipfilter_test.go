@@ -0,0 +1,103 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	tests := []struct {
+		name       string
+		opts       IPFilterOptions
+		remote     string
+		forwarded  string
+		wantStatus int
+	}{
+		{name: "no lists", opts: IPFilterOptions{}, remote: "203.0.113.7:1234", wantStatus: http.StatusOK},
+		{name: "allowed", opts: IPFilterOptions{Allow: []string{"10.0.0.0/8"}}, remote: "10.1.2.3:1234", wantStatus: http.StatusOK},
+		{name: "not allowed", opts: IPFilterOptions{Allow: []string{"10.0.0.0/8"}}, remote: "203.0.113.7:1234", wantStatus: http.StatusForbidden},
+		{name: "denied", opts: IPFilterOptions{Deny: []string{"203.0.113.0/24"}}, remote: "203.0.113.7:1234", wantStatus: http.StatusForbidden},
+		{name: "deny wins over allow", opts: IPFilterOptions{Allow: []string{"10.0.0.0/8"}, Deny: []string{"10.1.0.0/16"}}, remote: "10.1.2.3:1234", wantStatus: http.StatusForbidden},
+		{name: "IPv6 allowed", opts: IPFilterOptions{Allow: []string{"2001:db8::/32"}}, remote: "[2001:db8::1]:1234", wantStatus: http.StatusOK},
+		{
+			name:       "forwarded from trusted proxy",
+			opts:       IPFilterOptions{Deny: []string{"203.0.113.0/24"}, TrustedProxies: []string{"10.0.0.0/8"}},
+			remote:     "10.0.0.1:1234",
+			forwarded:  "203.0.113.7",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "forwarded from untrusted client",
+			opts:       IPFilterOptions{Allow: []string{"10.0.0.0/8"}},
+			remote:     "203.0.113.7:1234",
+			forwarded:  "10.0.0.5",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := IPFilter(tt.opts)
+			if err != nil {
+				t.Fatalf("IPFilter: %v", err)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remote
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			rec := httptest.NewRecorder()
+			filter(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestIPFilterInvalidCIDR(t *testing.T) {
+	if _, err := IPFilter(IPFilterOptions{Allow: []string{"10.0.0.0/33"}}); err == nil {
+		t.Fatal("invalid CIDR accepted")
+	}
+}
+
+func TestAdminChainFiltersBeforeAuth(t *testing.T) {
+	filter, err := IPFilter(IPFilterOptions{Allow: []string{"10.0.0.0/8"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin := NewChain().
+		Require(DefaultOrder...).
+		AppendNamed(NameIPFilter, filter).
+		AppendNamed(NameAuth, AdminAuth("secret"))
+	handler, err := admin.Build(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remote     string
+		token      string
+		wantStatus int
+	}{
+		{"allowed with token", "10.0.0.9:1234", "secret", http.StatusOK},
+		{"allowed without token", "10.0.0.9:1234", "", http.StatusUnauthorized},
+		{"outside allow list with token", "203.0.113.7:1234", "secret", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/admin/status", nil)
+			req.RemoteAddr = tt.remote
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
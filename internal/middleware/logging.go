@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // responseWriter is a wrapper for http.ResponseWriter that captures the status code
@@ -48,8 +49,22 @@ func (rw *responseWriter) Size() int {
 	return rw.size
 }
 
-// Logging returns a middleware that logs requests
-func Logging(logger telemetry.Logger) Middleware {
+// Flush sends buffered data to the client if the underlying writer supports
+// it. Hijacking, pushes and deadlines go through http.ResponseController,
+// which finds them via Unwrap only when the underlying writer has them.
+func (rw *responseWriter) Flush() {
+	http.NewResponseController(rw.ResponseWriter).Flush()
+}
+
+// Unwrap returns the underlying writer for http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Logging returns a middleware that logs requests. The values of the given
+// token parameters, and anything shaped like a JWT, are masked in the
+// logged path and query.
+func Logging(logger telemetry.Logger, tokenParams ...string) Middleware {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			start := time.Now()
@@ -70,7 +85,8 @@ func Logging(logger telemetry.Logger) Middleware {
 			// Log the request
 			logger.Info("Request",
 				"method", r.Method,
-				"path", r.URL.Path,
+				"path", utils.RedactPath(r.URL.Path),
+				"query", utils.RedactQuery(r.URL.RawQuery, tokenParams),
 				"status", rw.Status(),
 				"duration", duration.String(),
 				"size", rw.Size(),
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // Metrics returns a middleware that collects metrics
@@ -41,7 +42,8 @@ func Metrics(metrics telemetry.Metrics) Middleware {
 			
 			// Record metrics
 			metrics.IncCounter("response.status." + strconv.Itoa(rw.Status()))
-			metrics.ObserveRequestDuration(r.URL.Path, duration)
+			// Path labels never carry tokens
+			metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), duration)
 			
 			// Record size metrics
 			sizeKB := float64(rw.Size()) / 1024.0
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// connWriter is a response writer backed by a connection, which can be
+// hijacked and given deadlines
+type connWriter struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+	deadline time.Time
+}
+
+func (c *connWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	c.hijacked = true
+	return nil, nil, nil
+}
+
+func (c *connWriter) SetWriteDeadline(t time.Time) error {
+	c.deadline = t
+	return nil
+}
+
+func TestWrappersExposeOnlyUnderlyingCapabilities(t *testing.T) {
+	middlewares := []struct {
+		name string
+		mw   Middleware
+	}{
+		{"logging", Logging(telemetry.NewLogger("error", "", "stdout"))},
+		{"response headers", ResponseHeaders(map[string]string{"X-Edge": "ilinden"})},
+		{"compression", Compression(DefaultCompressionOptions())},
+	}
+
+	for _, m := range middlewares {
+		t.Run(m.name+"/plain writer", func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			m.mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := w.(http.Hijacker); ok {
+					t.Error("wrapper claims to be a Hijacker")
+				}
+				if _, ok := w.(http.Pusher); ok {
+					t.Error("wrapper claims to be a Pusher")
+				}
+				rc := http.NewResponseController(w)
+				if err := rc.SetWriteDeadline(time.Now()); !errors.Is(err, http.ErrNotSupported) {
+					t.Errorf("SetWriteDeadline: got %v, want ErrNotSupported", err)
+				}
+				if err := rc.Flush(); err != nil {
+					t.Errorf("Flush: %v", err)
+				}
+			})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if !rec.Flushed {
+				t.Error("flush did not reach the underlying writer")
+			}
+		})
+
+		t.Run(m.name+"/connection writer", func(t *testing.T) {
+			cw := &connWriter{ResponseRecorder: httptest.NewRecorder()}
+			deadline := time.Now().Add(time.Minute)
+			m.mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				rc := http.NewResponseController(w)
+				if err := rc.SetWriteDeadline(deadline); err != nil {
+					t.Errorf("SetWriteDeadline: %v", err)
+				}
+				if _, _, err := rc.Hijack(); err != nil {
+					t.Errorf("Hijack: %v", err)
+				}
+			})).ServeHTTP(cw, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if !cw.hijacked {
+				t.Error("hijack did not reach the underlying writer")
+			}
+			if !cw.deadline.Equal(deadline) {
+				t.Error("write deadline did not reach the underlying writer")
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+// Request classification
+//
+// Decides whether a target URL is a playlist or a segment:
+// - .m3u8 suffix matching by default
+// - Configurable regular expressions for playlists
+// - Segment patterns that take precedence
+// - Patterns compiled once and matched against path and query
+
+package playlist
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+)
+
+// Classifier classifies target URLs as playlists or segments
+type Classifier struct {
+	playlists []*regexp.Regexp
+	segments  []*regexp.Regexp
+}
+
+// NewClassifier compiles the playlist and segment patterns. Patterns are
+// matched against the URL path followed by "?" and the query, if any.
+func NewClassifier(playlistPatterns, segmentPatterns []string) (*Classifier, error) {
+	playlists, err := compilePatterns(playlistPatterns)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := compilePatterns(segmentPatterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Classifier{
+		playlists: playlists,
+		segments:  segments,
+	}, nil
+}
+
+// IsPlaylist reports whether the target URL refers to a playlist. Segment
+// patterns win over playlist patterns and the .m3u8 suffix.
+func (c *Classifier) IsPlaylist(u *url.URL) bool {
+	subject := u.Path
+	if u.RawQuery != "" {
+		subject += "?" + u.RawQuery
+	}
+
+	if c != nil {
+		for _, re := range c.segments {
+			if re.MatchString(subject) {
+				return false
+			}
+		}
+	}
+
+	if IsM3U8(u.Path) {
+		return true
+	}
+
+	if c != nil {
+		for _, re := range c.playlists {
+			if re.MatchString(subject) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// compilePatterns compiles a list of regular expressions
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,65 @@
+package playlist
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestClassifierIsPlaylist(t *testing.T) {
+	tests := []struct {
+		name      string
+		playlists []string
+		segments  []string
+		target    string
+		want      bool
+	}{
+		{"m3u8 suffix by default", nil, nil, "https://origin/live/index.m3u8", true},
+		{"segment by default", nil, nil, "https://origin/live/seg1.ts", false},
+		{"suffixless playlist pattern", []string{`/manifest$`}, nil, "https://origin/live/manifest", true},
+		{"pattern matches query", []string{`\?format=m3u8`}, nil, "https://origin/play?format=m3u8", true},
+		{"no pattern match", []string{`/manifest$`}, nil, "https://origin/live/chunk", false},
+		{"segment pattern beats suffix", nil, []string{`/ads/.*\.m3u8$`}, "https://origin/ads/break.m3u8", false},
+		{"segment pattern beats playlist pattern", []string{`/live/`}, []string{`\.ts$`}, "https://origin/live/seg1.ts", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			classifier, err := NewClassifier(tt.playlists, tt.segments)
+			if err != nil {
+				t.Fatalf("NewClassifier: %v", err)
+			}
+			target, _ := url.Parse(tt.target)
+			if got := classifier.IsPlaylist(target); got != tt.want {
+				t.Errorf("IsPlaylist(%s) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNilClassifierUsesSuffix(t *testing.T) {
+	var classifier *Classifier
+	playlist, _ := url.Parse("https://origin/live/index.m3u8")
+	segment, _ := url.Parse("https://origin/live/seg1.ts")
+	if !classifier.IsPlaylist(playlist) || classifier.IsPlaylist(segment) {
+		t.Error("nil classifier does not fall back to the .m3u8 suffix")
+	}
+}
+
+func TestNewClassifierRejectsInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name      string
+		playlists []string
+		segments  []string
+	}{
+		{"invalid playlist pattern", []string{`(`}, nil},
+		{"invalid segment pattern", nil, []string{`[a-`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewClassifier(tt.playlists, tt.segments); err == nil {
+				t.Fatal("invalid pattern compiled")
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+// Variant filtering
+//
+// Removes master playlist variants outside configured limits:
+// - Bandwidth bounds
+// - Maximum resolution
+// - I-frame streams filtered alongside the variants they pair with
+// - Never removes every variant
+
+package playlist
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// VariantFilter removes variants outside bandwidth and resolution limits.
+// Zero fields impose no limit.
+type VariantFilter struct {
+	MinBandwidth uint64
+	MaxBandwidth uint64
+	MaxWidth     int
+	MaxHeight    int
+}
+
+// keepsVariant reports whether a variant is within the limits. Variants
+// without a RESOLUTION pass the resolution limits.
+func (f *VariantFilter) keepsVariant(v *hls.Variant) bool {
+	if f.MinBandwidth > 0 && v.Bandwidth < f.MinBandwidth {
+		return false
+	}
+	if f.MaxBandwidth > 0 && v.Bandwidth > f.MaxBandwidth {
+		return false
+	}
+	return f.keepsResolution(v.Resolution)
+}
+
+// keepsResolution reports whether a RESOLUTION is within the limits
+func (f *VariantFilter) keepsResolution(resolution string) bool {
+	width, height, ok := parseResolution(resolution)
+	if !ok {
+		return true
+	}
+	if f.MaxWidth > 0 && width > f.MaxWidth {
+		return false
+	}
+	return f.MaxHeight <= 0 || height <= f.MaxHeight
+}
+
+// Apply removes the variants outside the limits from a master playlist,
+// together with the I-frame streams paired with them: those whose
+// resolution exceeds the limits, or matches only removed variants. A
+// master whose every variant would be removed is left unchanged, since an
+// empty master cannot be played. It returns the number of variants and
+// I-frame streams removed.
+func (f *VariantFilter) Apply(master *hls.MasterPlaylist) (variants, iframes int) {
+	kept := make([]hls.Variant, 0, len(master.Variants))
+	keptResolutions := make(map[string]bool)
+	removedResolutions := make(map[string]bool)
+	for _, v := range master.Variants {
+		resolution := strings.Trim(v.Resolution, `"`)
+		if f.keepsVariant(&v) {
+			kept = append(kept, v)
+			keptResolutions[resolution] = true
+		} else {
+			removedResolutions[resolution] = true
+		}
+	}
+	if len(kept) == 0 {
+		return 0, 0
+	}
+	variants = len(master.Variants) - len(kept)
+	master.Variants = kept
+
+	keptIFrames := make([]hls.IFrameStream, 0, len(master.IFrameStreams))
+	for _, s := range master.IFrameStreams {
+		resolution := strings.Trim(s.Resolution, `"`)
+		orphaned := resolution != "" && removedResolutions[resolution] && !keptResolutions[resolution]
+		if orphaned || !f.keepsResolution(resolution) {
+			continue
+		}
+		keptIFrames = append(keptIFrames, s)
+	}
+	iframes = len(master.IFrameStreams) - len(keptIFrames)
+	master.IFrameStreams = keptIFrames
+	return variants, iframes
+}
+
+// parseResolution parses a WIDTHxHEIGHT resolution, quoted or not
+func parseResolution(resolution string) (width, height int, ok bool) {
+	w, h, found := strings.Cut(strings.Trim(resolution, `"`), "x")
+	if !found {
+		return 0, 0, false
+	}
+	width, err := strconv.Atoi(w)
+	if err != nil {
+		return 0, 0, false
+	}
+	height, err = strconv.Atoi(h)
+	if err != nil {
+		return 0, 0, false
+	}
+	return width, height, true
+}
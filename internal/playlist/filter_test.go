@@ -0,0 +1,136 @@
+package playlist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// filterMaster has three variants and an I-frame stream per resolution,
+// plus one without a resolution
+var filterMaster = []string{
+	"#EXTM3U",
+	"#EXT-X-STREAM-INF:BANDWIDTH=500000,RESOLUTION=640x360",
+	"360p.m3u8",
+	"#EXT-X-STREAM-INF:BANDWIDTH=1500000,RESOLUTION=1280x720",
+	"720p.m3u8",
+	"#EXT-X-STREAM-INF:BANDWIDTH=4000000,RESOLUTION=1920x1080",
+	"1080p.m3u8",
+	`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=50000,RESOLUTION=640x360,URI="360p-iframe.m3u8"`,
+	`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=150000,RESOLUTION=1280x720,URI="720p-iframe.m3u8"`,
+	`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=400000,RESOLUTION=1920x1080,URI="1080p-iframe.m3u8"`,
+	`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=40000,URI="any-iframe.m3u8"`,
+}
+
+func TestVariantFilterApply(t *testing.T) {
+	tests := []struct {
+		name         string
+		filter       VariantFilter
+		wantVariants []string
+		wantIFrames  []string
+	}{
+		{
+			name:         "no limits",
+			wantVariants: []string{"360p.m3u8", "720p.m3u8", "1080p.m3u8"},
+			wantIFrames:  []string{"360p-iframe.m3u8", "720p-iframe.m3u8", "1080p-iframe.m3u8", "any-iframe.m3u8"},
+		},
+		{
+			name:         "max height",
+			filter:       VariantFilter{MaxHeight: 720},
+			wantVariants: []string{"360p.m3u8", "720p.m3u8"},
+			wantIFrames:  []string{"360p-iframe.m3u8", "720p-iframe.m3u8", "any-iframe.m3u8"},
+		},
+		{
+			name:         "max width",
+			filter:       VariantFilter{MaxWidth: 640},
+			wantVariants: []string{"360p.m3u8"},
+			wantIFrames:  []string{"360p-iframe.m3u8", "any-iframe.m3u8"},
+		},
+		{
+			name:         "min bandwidth drops paired I-frames",
+			filter:       VariantFilter{MinBandwidth: 1000000},
+			wantVariants: []string{"720p.m3u8", "1080p.m3u8"},
+			wantIFrames:  []string{"720p-iframe.m3u8", "1080p-iframe.m3u8", "any-iframe.m3u8"},
+		},
+		{
+			name:         "bandwidth window",
+			filter:       VariantFilter{MinBandwidth: 1000000, MaxBandwidth: 2000000},
+			wantVariants: []string{"720p.m3u8"},
+			wantIFrames:  []string{"720p-iframe.m3u8", "any-iframe.m3u8"},
+		},
+		{
+			name:         "never empties the master",
+			filter:       VariantFilter{MaxBandwidth: 100},
+			wantVariants: []string{"360p.m3u8", "720p.m3u8", "1080p.m3u8"},
+			wantIFrames:  []string{"360p-iframe.m3u8", "720p-iframe.m3u8", "1080p-iframe.m3u8", "any-iframe.m3u8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist := parseMedia(t, filterMaster...)
+			variants, iframes := tt.filter.Apply(&playlist.Master)
+
+			if got := variantURIs(playlist.Master); !reflect.DeepEqual(got, tt.wantVariants) {
+				t.Errorf("variants %v, want %v", got, tt.wantVariants)
+			}
+			if got := iframeURIs(playlist.Master); !reflect.DeepEqual(got, tt.wantIFrames) {
+				t.Errorf("I-frame streams %v, want %v", got, tt.wantIFrames)
+			}
+			if variants != 3-len(tt.wantVariants) || iframes != 4-len(tt.wantIFrames) {
+				t.Errorf("removed %d variants and %d I-frame streams", variants, iframes)
+			}
+		})
+	}
+}
+
+func TestVariantFilterSharedResolution(t *testing.T) {
+	// An I-frame stream stays while any variant of its resolution does
+	playlist := parseMedia(t,
+		"#EXTM3U",
+		"#EXT-X-STREAM-INF:BANDWIDTH=1500000,RESOLUTION=1280x720",
+		"720p-high.m3u8",
+		"#EXT-X-STREAM-INF:BANDWIDTH=900000,RESOLUTION=1280x720",
+		"720p-low.m3u8",
+		`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=150000,RESOLUTION=1280x720,URI="720p-iframe.m3u8"`,
+	)
+	filter := VariantFilter{MaxBandwidth: 1000000}
+	filter.Apply(&playlist.Master)
+
+	if got := iframeURIs(playlist.Master); !reflect.DeepEqual(got, []string{"720p-iframe.m3u8"}) {
+		t.Errorf("I-frame streams %v, want the 720p one kept", got)
+	}
+}
+
+func TestMasterProcessorVariantFilter(t *testing.T) {
+	options := DefaultProcessorOptions()
+	options.VariantFilter = &VariantFilter{MaxHeight: 720}
+	out := process(t, strings.Join(filterMaster, "\n")+"\n", "abc", options)
+
+	for _, removed := range []string{"1080p.m3u8", "1080p-iframe.m3u8"} {
+		if strings.Contains(out, removed) {
+			t.Errorf("output still lists %s:\n%s", removed, out)
+		}
+	}
+	if n := strings.Count(out, "#EXT-X-I-FRAME-STREAM-INF:"); n != 3 {
+		t.Errorf("%d I-frame streams written, want 3:\n%s", n, out)
+	}
+}
+
+func variantURIs(master hls.MasterPlaylist) []string {
+	uris := []string{}
+	for _, v := range master.Variants {
+		uris = append(uris, v.URI)
+	}
+	return uris
+}
+
+func iframeURIs(master hls.MasterPlaylist) []string {
+	uris := []string{}
+	for _, s := range master.IFrameStreams {
+		uris = append(uris, s.URI)
+	}
+	return uris
+}
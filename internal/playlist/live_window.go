@@ -0,0 +1,122 @@
+// Live window trimming
+//
+// Shortens live media playlists to their most recent segments:
+// - Segment count and total duration limits
+// - Never shorter than three target durations (RFC 8216, section 6.2.2)
+// - MEDIA-SEQUENCE and DISCONTINUITY-SEQUENCE kept consistent
+// - Program date-time, key, map and byte offset carried over to the new
+//   first segment
+// - VOD and EVENT playlists left untouched
+
+package playlist
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// LiveWindow limits the segments listed in live media playlists. Zero
+// fields impose no limit.
+type LiveWindow struct {
+	MaxSegments int
+	MaxDuration time.Duration
+}
+
+// Apply removes segments from the front of a live media playlist until it
+// fits the window. Only live playlists are trimmed: those without ENDLIST
+// and without a PLAYLIST-TYPE, since EVENT playlists must not lose
+// segments. Trimming stops before the playlist would last less than three
+// target durations, which live playlists must not (RFC 8216, section
+// 6.2.2), so a small window may be exceeded. The last segment is always
+// kept. It returns the number of segments removed.
+func (w *LiveWindow) Apply(media *hls.MediaPlaylist) int {
+	if media.EndList || media.PlaylistType != "" {
+		return 0
+	}
+
+	segments := media.Segments
+	total := 0.0
+	for _, s := range segments {
+		total += s.Duration
+	}
+	minDuration := 3 * media.TargetDuration
+
+	drop := 0
+	for drop < len(segments)-1 {
+		overCount := w.MaxSegments > 0 && len(segments)-drop > w.MaxSegments
+		overDuration := w.MaxDuration > 0 && total > w.MaxDuration.Seconds()
+		if !overCount && !overDuration {
+			break
+		}
+		if total-segments[drop].Duration < minDuration {
+			break
+		}
+		total -= segments[drop].Duration
+		drop++
+	}
+	if drop <= 0 {
+		return 0
+	}
+
+	// Each removed discontinuity advances the discontinuity sequence, and
+	// the first remaining segment keeps its place on the clock, its key and
+	// map, and where its byte range starts
+	var dateTime string
+	var elapsed float64
+	var key *hls.Key
+	var initMap *hls.Map
+	var rangeEnd int64
+	for _, s := range segments[:drop] {
+		if s.Discontinuity {
+			media.DiscontinuitySeq++
+		}
+		if s.ProgramDateTime != "" {
+			dateTime, elapsed = s.ProgramDateTime, 0
+		}
+		elapsed += s.Duration
+		if s.Key != nil {
+			key = s.Key
+		}
+		if s.Map != nil {
+			initMap = s.Map
+		}
+		rangeEnd = nextRangeOffset(s.ByteRange, rangeEnd)
+	}
+
+	kept := append([]hls.Segment(nil), segments[drop:]...)
+	first := &kept[0]
+	if first.ProgramDateTime == "" && dateTime != "" {
+		if t, err := time.Parse(time.RFC3339Nano, dateTime); err == nil {
+			offset := time.Duration(elapsed * float64(time.Second))
+			first.ProgramDateTime = t.Add(offset).Format("2006-01-02T15:04:05.000Z07:00")
+		}
+	}
+	if first.Key == nil {
+		first.Key = key
+	}
+	if first.Map == nil {
+		first.Map = initMap
+	}
+	if br, hasOffset, err := hls.ParseByteRange(first.ByteRange); err == nil && !hasOffset {
+		first.ByteRange = strconv.FormatInt(br.Length, 10) + "@" + strconv.FormatInt(rangeEnd, 10)
+	}
+
+	media.MediaSequence += uint64(drop)
+	media.Segments = kept
+	return drop
+}
+
+// nextRangeOffset returns where the sub-range after a segment's byte range
+// starts, given where the segment's own range starts when it has no offset
+func nextRangeOffset(byteRange string, offset int64) int64 {
+	br, hasOffset, err := hls.ParseByteRange(byteRange)
+	if err != nil {
+		return 0
+	}
+	if hasOffset {
+		offset = br.Offset
+	}
+	return offset + br.Length
+}
@@ -0,0 +1,245 @@
+package playlist
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// parseMedia parses a media playlist from lines
+func parseMedia(t *testing.T, lines ...string) *hls.Playlist {
+	t.Helper()
+	playlist, err := hls.New().Parse(strings.NewReader(strings.Join(lines, "\n")))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	return playlist
+}
+
+func TestLiveWindowApply(t *testing.T) {
+	live := []string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXT-X-MEDIA-SEQUENCE:10",
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z",
+		"#EXTINF:6,",
+		"seg1.ts",
+		"#EXT-X-DISCONTINUITY",
+		"#EXTINF:4,",
+		"seg2.ts",
+		"#EXTINF:6,",
+		"seg3.ts",
+		"#EXTINF:6,",
+		"seg4.ts",
+		"#EXTINF:6,",
+		"seg5.ts",
+	}
+	short := []string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXTINF:4,", "seg1.ts",
+		"#EXTINF:4,", "seg2.ts",
+		"#EXTINF:4,", "seg3.ts",
+		"#EXTINF:4,", "seg4.ts",
+		"#EXTINF:4,", "seg5.ts",
+		"#EXTINF:4,", "seg6.ts",
+	}
+	untargeted := []string{
+		"#EXTM3U",
+		"#EXTINF:6,", "seg1.ts",
+		"#EXTINF:6,", "seg2.ts",
+	}
+
+	tests := []struct {
+		name      string
+		lines     []string
+		window    LiveWindow
+		wantDrop  int
+		wantSeq   uint64
+		wantDisc  uint64
+		wantFirst string
+		wantPDT   string
+	}{
+		{
+			name:      "segment limit",
+			lines:     live,
+			window:    LiveWindow{MaxSegments: 3},
+			wantDrop:  2,
+			wantSeq:   12,
+			wantDisc:  1,
+			wantFirst: "seg3.ts",
+			wantPDT:   "2024-01-01T00:00:10.000Z",
+		},
+		{
+			name:      "duration limit",
+			lines:     live,
+			window:    LiveWindow{MaxDuration: 24 * time.Second},
+			wantDrop:  1,
+			wantSeq:   11,
+			wantFirst: "seg2.ts",
+			wantPDT:   "2024-01-01T00:00:06.000Z",
+		},
+		{
+			name:      "within window",
+			lines:     live,
+			window:    LiveWindow{MaxSegments: 5},
+			wantSeq:   10,
+			wantFirst: "seg1.ts",
+			wantPDT:   "2024-01-01T00:00:00.000Z",
+		},
+		{
+			name:      "small segment limit keeps three target durations",
+			lines:     live,
+			window:    LiveWindow{MaxSegments: 1},
+			wantDrop:  2,
+			wantSeq:   12,
+			wantDisc:  1,
+			wantFirst: "seg3.ts",
+			wantPDT:   "2024-01-01T00:00:10.000Z",
+		},
+		{
+			name:      "small duration limit keeps three target durations",
+			lines:     live,
+			window:    LiveWindow{MaxDuration: time.Second},
+			wantDrop:  2,
+			wantSeq:   12,
+			wantDisc:  1,
+			wantFirst: "seg3.ts",
+			wantPDT:   "2024-01-01T00:00:10.000Z",
+		},
+		{
+			name:      "floor counts duration, not segments",
+			lines:     short,
+			window:    LiveWindow{MaxDuration: 8 * time.Second},
+			wantDrop:  1,
+			wantSeq:   1,
+			wantFirst: "seg2.ts",
+		},
+		{
+			name:      "last segment always kept",
+			lines:     untargeted,
+			window:    LiveWindow{MaxDuration: time.Second},
+			wantDrop:  1,
+			wantSeq:   1,
+			wantFirst: "seg2.ts",
+		},
+		{
+			name:      "VOD untouched",
+			lines:     append(append([]string(nil), live...), "#EXT-X-ENDLIST"),
+			window:    LiveWindow{MaxSegments: 1},
+			wantSeq:   10,
+			wantFirst: "seg1.ts",
+			wantPDT:   "2024-01-01T00:00:00.000Z",
+		},
+		{
+			name:      "EVENT untouched",
+			lines:     append([]string{"#EXTM3U", "#EXT-X-PLAYLIST-TYPE:EVENT"}, live[1:]...),
+			window:    LiveWindow{MaxSegments: 1},
+			wantSeq:   10,
+			wantFirst: "seg1.ts",
+			wantPDT:   "2024-01-01T00:00:00.000Z",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist := parseMedia(t, tt.lines...)
+			window := tt.window
+			if got := window.Apply(&playlist.Media); got != tt.wantDrop {
+				t.Errorf("dropped %d, want %d", got, tt.wantDrop)
+			}
+			media := playlist.Media
+			if media.MediaSequence != tt.wantSeq {
+				t.Errorf("MEDIA-SEQUENCE %d, want %d", media.MediaSequence, tt.wantSeq)
+			}
+			if media.DiscontinuitySeq != tt.wantDisc {
+				t.Errorf("DISCONTINUITY-SEQUENCE %d, want %d", media.DiscontinuitySeq, tt.wantDisc)
+			}
+			if media.Segments[0].URI != tt.wantFirst {
+				t.Errorf("first segment %s, want %s", media.Segments[0].URI, tt.wantFirst)
+			}
+			if media.Segments[0].ProgramDateTime != tt.wantPDT {
+				t.Errorf("first date-time %s, want %s", media.Segments[0].ProgramDateTime, tt.wantPDT)
+			}
+		})
+	}
+}
+
+func TestLiveWindowTrimmedOutput(t *testing.T) {
+	playlist := parseMedia(t,
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z",
+		"#EXTINF:6,",
+		"seg1.ts",
+		"#EXT-X-DISCONTINUITY",
+		"#EXTINF:6,",
+		"seg2.ts",
+		"#EXTINF:6,",
+		"seg3.ts",
+		"#EXTINF:6,",
+		"seg4.ts",
+		"#EXTINF:6,",
+		"seg5.ts",
+	)
+	window := LiveWindow{MaxSegments: 3}
+	window.Apply(&playlist.Media)
+
+	want := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXT-X-MEDIA-SEQUENCE:2",
+		"#EXT-X-DISCONTINUITY-SEQUENCE:1",
+		"#EXT-X-ALLOW-CACHE:NO",
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:12.000Z",
+		"#EXTINF:6,",
+		"seg3.ts",
+		"#EXTINF:6,",
+		"seg4.ts",
+		"#EXTINF:6,",
+		"seg5.ts",
+		"",
+	}, "\n")
+	if got := playlist.String(); got != want {
+		t.Errorf("trimmed playlist:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestLiveWindowCarriesKeyMapAndRange(t *testing.T) {
+	playlist := parseMedia(t,
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:2",
+		`#EXT-X-KEY:METHOD=AES-128,URI="k1.key"`,
+		`#EXT-X-MAP:URI="init.mp4"`,
+		"#EXTINF:2,",
+		"#EXT-X-BYTERANGE:1000@0",
+		"media.mp4",
+		"#EXTINF:2,",
+		"#EXT-X-BYTERANGE:2000",
+		"media.mp4",
+		"#EXTINF:2,",
+		"#EXT-X-BYTERANGE:1500",
+		"media.mp4",
+		"#EXTINF:2,",
+		"#EXT-X-BYTERANGE:1500",
+		"media.mp4",
+		"#EXTINF:2,",
+		"#EXT-X-BYTERANGE:1500",
+		"media.mp4",
+	)
+	window := LiveWindow{MaxSegments: 3}
+	window.Apply(&playlist.Media)
+
+	first := playlist.Media.Segments[0]
+	if first.Key == nil || first.Key.URI != "k1.key" {
+		t.Errorf("key not carried over: %+v", first.Key)
+	}
+	if first.Map == nil || first.Map.URI != "init.mp4" {
+		t.Errorf("map not carried over: %+v", first.Map)
+	}
+	if first.ByteRange != "1500@3000" {
+		t.Errorf("byte range %q, want 1500@3000", first.ByteRange)
+	}
+}
@@ -4,6 +4,9 @@
 // - BANDWIDTH, RESOLUTION tag preservation
 // - Variant stream handling
 // - Alternative stream handling
+// - Content steering server rewriting
+// - Variant and I-frame stream filtering
+// - Rendition filtering by characteristics and channels
 
 package playlist
 
@@ -36,6 +39,14 @@ func (p *MasterProcessor) Process(playlist *hls.Playlist, token string) error {
 		return ErrNotMasterPlaylist
 	}
 	
+	// Drop variants outside the configured limits before rewriting
+	if p.options.VariantFilter != nil {
+		p.options.VariantFilter.Apply(&playlist.Master)
+	}
+	if p.options.RenditionFilter != nil {
+		p.options.RenditionFilter.Apply(&playlist.Master)
+	}
+	
 	// Process each variant stream in the master playlist
 	for i := range playlist.Master.Variants {
 		if err := p.processVariant(&playlist.Master.Variants[i], token); err != nil {
@@ -59,6 +70,20 @@ func (p *MasterProcessor) Process(playlist *hls.Playlist, token string) error {
 		}
 	}
 	
+	// Process each session key
+	for i := range playlist.Master.SessionKeys {
+		if err := p.processSessionKey(&playlist.Master.SessionKeys[i], token); err != nil {
+			return err
+		}
+	}
+	
+	// Process the content steering server
+	if playlist.Master.ContentSteering != nil {
+		if err := p.processContentSteering(playlist.Master.ContentSteering, token); err != nil {
+			return err
+		}
+	}
+	
 	return nil
 }
 
@@ -69,6 +94,12 @@ func (p *MasterProcessor) processVariant(variant *hls.Variant, token string) err
 		return nil
 	}
 	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(variant.URI, p.proxyURL, token, p.options); ok {
+		variant.URI = uri
+		return nil
+	}
+	
 	// Resolve URI to absolute URL if it's relative
 	resolvedURL, err := resolveURL(p.baseURL, variant.URI)
 	if err != nil {
@@ -89,6 +120,12 @@ func (p *MasterProcessor) processIFrameStream(iframe *hls.IFrameStream, token st
 		return nil
 	}
 	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(iframe.URI, p.proxyURL, token, p.options); ok {
+		iframe.URI = uri
+		return nil
+	}
+	
 	// Resolve URI to absolute URL if it's relative
 	resolvedURL, err := resolveURL(p.baseURL, iframe.URI)
 	if err != nil {
@@ -109,6 +146,12 @@ func (p *MasterProcessor) processMediaGroup(media *hls.MediaGroup, token string)
 		return nil
 	}
 	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(media.URI, p.proxyURL, token, p.options); ok {
+		media.URI = uri
+		return nil
+	}
+	
 	// Resolve URI to absolute URL if it's relative
 	resolvedURL, err := resolveURL(p.baseURL, media.URI)
 	if err != nil {
@@ -122,6 +165,66 @@ func (p *MasterProcessor) processMediaGroup(media *hls.MediaGroup, token string)
 	return nil
 }
 
+// processSessionKey processes a session key in a master playlist
+func (p *MasterProcessor) processSessionKey(key *hls.Key, token string) error {
+	// Skip empty URIs
+	if key.URI == "" {
+		return nil
+	}
+	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(key.URI, p.proxyURL, token, p.options); ok {
+		key.URI = uri
+		return nil
+	}
+	
+	// Resolve URI to absolute URL if it's relative
+	resolvedURL, err := resolveURL(p.baseURL, key.URI)
+	if err != nil {
+		return err
+	}
+	
+	// Leave DRM system URIs such as skd:// untouched
+	if resolvedURL.Scheme != "http" && resolvedURL.Scheme != "https" {
+		return nil
+	}
+	
+	// Point directly to origin with token, like segment keys
+	key.URI = addTokenToURL(resolvedURL, p.options.originTokenParam(), token)
+	
+	return nil
+}
+
+// processContentSteering points the steering server URI back to our proxy
+// with the token, so steering manifests are fetched through it. A server on
+// another host than the origin is left alone unless absolute URL rewriting
+// proxies it, as the proxy would otherwise ask the origin for it.
+func (p *MasterProcessor) processContentSteering(steering *hls.ContentSteering, token string) error {
+	// Skip empty URIs
+	if steering.ServerURI == "" {
+		return nil
+	}
+	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(steering.ServerURI, p.proxyURL, token, p.options); ok {
+		steering.ServerURI = uri
+		return nil
+	}
+	
+	// Resolve URI to absolute URL if it's relative
+	resolvedURL, err := resolveURL(p.baseURL, steering.ServerURI)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(resolvedURL.Host, p.baseURL.Host) {
+		return nil
+	}
+	
+	steering.ServerURI = p.generateProxyPath(resolvedURL, token)
+	
+	return nil
+}
+
 // generateProxyPath creates a proxy path for the variant
 func (p *MasterProcessor) generateProxyPath(targetURL *url.URL, token string) string {
 	// Use proxy host as base
@@ -139,9 +242,7 @@ func (p *MasterProcessor) generateProxyPath(targetURL *url.URL, token string) st
 	// Add target URL as path or in special parameter
 	if p.options.UsePathParam {
 		// Add target as a query parameter
-		q := result.Query()
-		q.Set(p.options.PathParamName, targetURL.String())
-		result.RawQuery = q.Encode()
+		return proxyParamURL(p.proxyURL, targetURL, token, p.options)
 	} else {
 		// Add target as part of the path
 		newPath := strings.TrimSuffix(p.proxyURL.Path, "/")
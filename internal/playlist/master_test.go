@@ -0,0 +1,132 @@
+package playlist
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// process parses and rewrites content as if fetched from
+// https://origin.example.com/live/master.m3u8 and served under /proxy
+func process(t *testing.T, content string, token string, options ProcessorOptions) string {
+	t.Helper()
+	baseURL, _ := url.Parse("https://origin.example.com/live/master.m3u8")
+	proxyURL, _ := url.Parse("/proxy")
+	out, err := NewParser().ParseAndProcessBytes([]byte(content), baseURL, proxyURL, token, options)
+	if err != nil {
+		t.Fatalf("ParseAndProcessBytes: %v", err)
+	}
+	return string(out)
+}
+
+func TestMasterProcessorSessionKeys(t *testing.T) {
+	tests := []struct {
+		name  string
+		uri   string
+		token string
+		want  string
+	}{
+		{"relative URI", "keys/k.key", "abc", `URI="https://origin.example.com/live/keys/k.key?token=abc"`},
+		{"absolute URI", "https://keys.example.com/k.key?v=1", "abc", `URI="https://keys.example.com/k.key?token=abc&v=1"`},
+		{"no token", "keys/k.key", "", `URI="https://origin.example.com/live/keys/k.key"`},
+		{"DRM system URI", "skd://key-id", "abc", `URI="skd://key-id"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "#EXTM3U\n" +
+				`#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="` + tt.uri + `",KEYFORMAT="com.apple.streamingkeydelivery"` + "\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n"
+			out := process(t, content, tt.token, DefaultProcessorOptions())
+
+			if n := strings.Count(out, "#EXT-X-SESSION-KEY:"); n != 1 {
+				t.Fatalf("%d session keys written, want 1:\n%s", n, out)
+			}
+			if !strings.Contains(out, tt.want) {
+				t.Errorf("session key missing %s:\n%s", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestMasterProcessorMediaGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		media string
+		want  string
+	}{
+		{
+			name:  "separate audio",
+			media: `#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="English",LANGUAGE="en",DEFAULT=YES,CHANNELS="2",URI="audio/en.m3u8"`,
+			want:  `#EXT-X-MEDIA:TYPE=AUDIO,URI="/proxy/live/audio/en.m3u8?token=abc",GROUP-ID="aud",LANGUAGE="en",NAME="English",DEFAULT=YES,CHANNELS="2"`,
+		},
+		{
+			name:  "muxed audio",
+			media: `#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Main",DEFAULT=YES,AUTOSELECT=YES`,
+			want:  `#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Main",DEFAULT=YES,AUTOSELECT=YES`,
+		},
+		{
+			name:  "closed captions",
+			media: `#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"`,
+			want:  `#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"`,
+		},
+		{
+			name:  "unknown attribute kept",
+			media: `#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",URI="subs/en.m3u8",STABLE-RENDITION-ID="en1"`,
+			want:  `#EXT-X-MEDIA:TYPE=SUBTITLES,URI="/proxy/live/subs/en.m3u8?token=abc",GROUP-ID="subs",NAME="English",STABLE-RENDITION-ID="en1"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "#EXTM3U\n" + tt.media + "\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n"
+			out := process(t, content, "abc", DefaultProcessorOptions())
+
+			if n := strings.Count(out, "#EXT-X-MEDIA:"); n != 1 {
+				t.Fatalf("%d media tags written, want 1:\n%s", n, out)
+			}
+			if !strings.Contains(out, tt.want+"\n") {
+				t.Errorf("media tag not written as\n%s\ngot:\n%s", tt.want, out)
+			}
+		})
+	}
+}
+
+func TestMasterProcessorContentSteering(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		options func(*ProcessorOptions)
+		want    string
+	}{
+		{"relative server", "steer.json", nil, `SERVER-URI="/proxy/live/steer.json?token=abc",PATHWAY-ID="CDN-A"`},
+		{"absolute server on the origin", "https://origin.example.com/steer/s.json", nil, `SERVER-URI="/proxy/steer/s.json?token=abc",PATHWAY-ID="CDN-A"`},
+		{"server on another host", "https://steer.example.net/s.json", nil, `SERVER-URI="https://steer.example.net/s.json",PATHWAY-ID="CDN-A"`},
+		{
+			name: "other host proxied by absolute rewriting",
+			uri:  "https://steer.example.net/s.json",
+			options: func(o *ProcessorOptions) {
+				o.RewriteAbsolute = true
+				o.ProxyHosts = map[string]bool{"steer.example.net": true}
+			},
+			want: `SERVER-URI="/proxy?token=abc&url=https%3A%2F%2Fsteer.example.net%2Fs.json",PATHWAY-ID="CDN-A"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := "#EXTM3U\n" +
+				`#EXT-X-CONTENT-STEERING:SERVER-URI="` + tt.uri + `",PATHWAY-ID="CDN-A"` + "\n" +
+				`#EXT-X-STREAM-INF:BANDWIDTH=800000,PATHWAY-ID="CDN-A"` + "\nlow/index.m3u8\n"
+			options := DefaultProcessorOptions()
+			if tt.options != nil {
+				tt.options(&options)
+			}
+			out := process(t, content, "abc", options)
+
+			if !strings.Contains(out, "#EXT-X-CONTENT-STEERING:"+tt.want+"\n") {
+				t.Errorf("steering missing %s:\n%s", tt.want, out)
+			}
+		})
+	}
+}
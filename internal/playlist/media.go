@@ -3,8 +3,9 @@
 // Media playlist (chunklist) specific logic:
 // - Segment URL rewriting
 // - Media sequence handling
-// - Live window tracking
+// - Live window tracking and trimming
 // - Discontinuity handling
+// - Segment title hooks
 
 package playlist
 
@@ -36,6 +37,11 @@ func (p *MediaProcessor) Process(playlist *hls.Playlist, token string) error {
 		return ErrNotMediaPlaylist
 	}
 	
+	// Trim the live window before rewriting the remaining segments
+	if p.options.LiveWindow != nil {
+		p.options.LiveWindow.Apply(&playlist.Media)
+	}
+	
 	// Process each segment in the media playlist
 	for i := range playlist.Media.Segments {
 		if err := p.processSegment(&playlist.Media.Segments[i], token); err != nil {
@@ -53,15 +59,25 @@ func (p *MediaProcessor) processSegment(segment *hls.Segment, token string) erro
 		return nil
 	}
 	
-	// Resolve URI to absolute URL if it's relative
-	resolvedURL, err := resolveURL(p.baseURL, segment.URI)
-	if err != nil {
-		return err
+	// Let the title hook see the title before the URI is rewritten
+	if p.options.TitleHook != nil {
+		segment.Title = p.options.TitleHook(segment.Title, segment.URI)
 	}
 	
-	// For segments, point directly to origin with token
-	directURL := p.addTokenToURL(resolvedURL, token)
-	segment.URI = directURL
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(segment.URI, p.proxyURL, token, p.options); ok {
+		segment.URI = uri
+	} else {
+		// Resolve URI to absolute URL if it's relative
+		resolvedURL, err := resolveURL(p.baseURL, segment.URI)
+		if err != nil {
+			return err
+		}
+		
+		// For segments, point directly to origin with token
+		directURL := p.addTokenToURL(resolvedURL, token)
+		segment.URI = directURL
+	}
 	
 	// Process key if present
 	if segment.Key != nil {
@@ -87,6 +103,12 @@ func (p *MediaProcessor) processKey(key *hls.Key, token string) error {
 		return nil
 	}
 	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(key.URI, p.proxyURL, token, p.options); ok {
+		key.URI = uri
+		return nil
+	}
+	
 	// Resolve URI to absolute URL if it's relative
 	resolvedURL, err := resolveURL(p.baseURL, key.URI)
 	if err != nil {
@@ -107,6 +129,12 @@ func (p *MediaProcessor) processMap(m *hls.Map, token string) error {
 		return nil
 	}
 	
+	// Absolute URLs are proxied or left alone by host when enabled
+	if uri, ok := rewriteAbsolute(m.URI, p.proxyURL, token, p.options); ok {
+		m.URI = uri
+		return nil
+	}
+	
 	// Resolve URI to absolute URL if it's relative
 	resolvedURL, err := resolveURL(p.baseURL, m.URI)
 	if err != nil {
@@ -122,18 +150,5 @@ func (p *MediaProcessor) processMap(m *hls.Map, token string) error {
 
 // addTokenToURL adds a token to a URL
 func (p *MediaProcessor) addTokenToURL(targetURL *url.URL, token string) string {
-	// Skip if no token or no token param name
-	if token == "" || p.options.TokenParamName == "" {
-		return targetURL.String()
-	}
-	
-	// Clone the URL to avoid modifying the original
-	result := *targetURL
-	
-	// Add token to query string
-	q := result.Query()
-	q.Set(p.options.TokenParamName, token)
-	result.RawQuery = q.Encode()
-	
-	return result.String()
+	return addTokenToURL(targetURL, p.options.originTokenParam(), token)
 }
\ No newline at end of file
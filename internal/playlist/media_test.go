@@ -0,0 +1,195 @@
+package playlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOriginTokenParam(t *testing.T) {
+	const media = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" +
+		`#EXT-X-KEY:METHOD=AES-128,URI="k.key"` + "\n" +
+		"#EXTINF:6,\nseg1.ts\n"
+	const master = "#EXTM3U\n" +
+		`#EXT-X-SESSION-KEY:METHOD=AES-128,URI="k.key"` + "\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=1000\nlow.m3u8\n"
+
+	tests := []struct {
+		name        string
+		content     string
+		originParam string
+		want        []string
+		wantAbsent  []string
+	}{
+		{
+			name:       "media uses the JWT parameter by default",
+			content:    media,
+			want:       []string{"seg1.ts?token=abc", "k.key?token=abc"},
+			wantAbsent: []string{"auth="},
+		},
+		{
+			name:        "media uses the route parameter",
+			content:     media,
+			originParam: "auth",
+			want:        []string{"seg1.ts?auth=abc", "k.key?auth=abc"},
+			wantAbsent:  []string{"token="},
+		},
+		{
+			name:        "session keys use the route parameter",
+			content:     master,
+			originParam: "auth",
+			want:        []string{"k.key?auth=abc"},
+		},
+		{
+			name:        "proxied variants keep the JWT parameter",
+			content:     master,
+			originParam: "auth",
+			want:        []string{"token=abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultProcessorOptions()
+			options.OriginTokenParamName = tt.originParam
+			out := process(t, tt.content, "abc", options)
+
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("missing %q:\n%s", want, out)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(out, absent) {
+					t.Errorf("unexpected %q:\n%s", absent, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRewriteAbsoluteURLs(t *testing.T) {
+	const master = "#EXTM3U\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=1000\nhttps://cdn2.example.com/a/index.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=2000\nhttps://other.example.net/b/index.m3u8\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=3000\nc/index.m3u8\n"
+	const media = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" +
+		"#EXTINF:6,\nhttps://origin.example.com/x/1.ts\n" +
+		"#EXTINF:6,\nhttps://other.example.net/2.ts\n" +
+		"#EXTINF:6,\n3.ts\n"
+
+	tests := []struct {
+		name       string
+		content    string
+		rewrite    bool
+		want       []string
+		wantAbsent []string
+	}{
+		{
+			name:    "master variants on proxied hosts",
+			content: master,
+			rewrite: true,
+			want: []string{
+				"/proxy?token=abc&url=https%3A%2F%2Fcdn2.example.com%2Fa%2Findex.m3u8\n",
+				"\nhttps://other.example.net/b/index.m3u8\n",
+				"/proxy/live/c/index.m3u8?token=abc\n",
+			},
+		},
+		{
+			name:    "media segments on proxied hosts",
+			content: media,
+			rewrite: true,
+			want: []string{
+				"/proxy?token=abc&url=https%3A%2F%2Forigin.example.com%2Fx%2F1.ts\n",
+				"\nhttps://other.example.net/2.ts\n",
+				"https://origin.example.com/live/3.ts?token=abc\n",
+			},
+		},
+		{
+			name:       "disabled",
+			content:    media,
+			want:       []string{"https://origin.example.com/x/1.ts?token=abc\n", "https://other.example.net/2.ts?token=abc\n"},
+			wantAbsent: []string{"url="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultProcessorOptions()
+			options.RewriteAbsolute = tt.rewrite
+			options.ProxyHosts = map[string]bool{"origin.example.com": true, "cdn2.example.com": true}
+			out := process(t, tt.content, "abc", options)
+
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(out, absent) {
+					t.Errorf("output contains %q:\n%s", absent, out)
+				}
+			}
+		})
+	}
+}
+
+func TestTitleHook(t *testing.T) {
+	const media = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" +
+		`#EXTINF:6,{"cue":"ad-start"}` + "\nseg1.ts\n" +
+		"#EXTINF:6,Main feature\nseg2.ts\n"
+
+	var seen []string
+	tests := []struct {
+		name       string
+		hook       TitleHook
+		want       []string
+		wantAbsent []string
+	}{
+		{
+			name: "no hook keeps titles",
+			want: []string{`#EXTINF:6,{"cue":"ad-start"}` + "\n", "#EXTINF:6,Main feature\n"},
+		},
+		{
+			name:       "strip",
+			hook:       StripTitles,
+			want:       []string{"#EXTINF:6,\n"},
+			wantAbsent: []string{"cue", "Main feature"},
+		},
+		{
+			name: "strip metadata only",
+			hook: func(title, uri string) string {
+				seen = append(seen, uri)
+				if strings.HasPrefix(title, "{") {
+					return ""
+				}
+				return strings.ToUpper(title)
+			},
+			want:       []string{"#EXTINF:6,\n", "#EXTINF:6,MAIN FEATURE\n"},
+			wantAbsent: []string{"cue"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := DefaultProcessorOptions()
+			options.TitleHook = tt.hook
+			out := process(t, media, "abc", options)
+
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("output missing %q:\n%s", want, out)
+				}
+			}
+			for _, absent := range tt.wantAbsent {
+				if strings.Contains(out, absent) {
+					t.Errorf("output contains %q:\n%s", absent, out)
+				}
+			}
+		})
+	}
+
+	// The hook sees URIs as the origin wrote them, before rewriting
+	if len(seen) != 2 || seen[0] != "seg1.ts" || seen[1] != "seg2.ts" {
+		t.Errorf("hook saw URIs %v, want [seg1.ts seg2.ts]", seen)
+	}
+}
@@ -5,6 +5,7 @@
 // - Relative to absolute URL conversion
 // - Base URL handling
 // - URL validation and encoding
+// - Absolute origin URLs routed through the proxy
 
 package playlist
 
@@ -13,6 +14,7 @@ import (
 	"net/url"
 	"strings"
 
+	"github.com/ilijajolevski/ilinden/internal/utils"
 	"github.com/ilijajolevski/ilinden/pkg/hls"
 )
 
@@ -29,9 +31,27 @@ var (
 
 // ProcessorOptions configures the playlist processor
 type ProcessorOptions struct {
-	TokenParamName string // Query parameter name for the token
-	PathParamName  string // Parameter name for the path in the proxy URL
-	UsePathParam   bool   // Whether to use the path parameter for the target URL
+	TokenParamName       string // Query parameter name for the token
+	OriginTokenParamName string // Token parameter for URLs pointing directly to origin; empty uses TokenParamName
+	PathParamName        string // Parameter name for the path in the proxy URL
+	UsePathParam         bool   // Whether to use the path parameter for the target URL
+	RewriteAbsolute      bool   // Route absolute URLs on ProxyHosts through the proxy and leave other hosts untouched
+	ProxyHosts           map[string]bool // Canonical host names routed through the proxy by RewriteAbsolute
+	TitleHook            TitleHook       // Called for each segment title; nil keeps titles as they are
+	VariantFilter        *VariantFilter  // Removes master variants and their I-frame streams; nil keeps all
+	RenditionFilter      *RenditionFilter // Removes master renditions by characteristics and channels; nil keeps all
+	LiveWindow           *LiveWindow     // Trims live media playlists to their latest segments; nil keeps all
+}
+
+// TitleHook inspects or transforms the EXTINF title of a media segment, for
+// workflows that carry cue data or other metadata in it. It receives the
+// title and the segment URI as the origin wrote them and returns the title
+// to write.
+type TitleHook func(title, uri string) string
+
+// StripTitles is a TitleHook that removes every segment title
+func StripTitles(title, uri string) string {
+	return ""
 }
 
 // DefaultProcessorOptions returns the default processor options
@@ -43,6 +63,15 @@ func DefaultProcessorOptions() ProcessorOptions {
 	}
 }
 
+// originTokenParam returns the token parameter name for URLs that point
+// directly to the origin, such as segments and keys
+func (o ProcessorOptions) originTokenParam() string {
+	if o.OriginTokenParamName != "" {
+		return o.OriginTokenParamName
+	}
+	return o.TokenParamName
+}
+
 // Modifier handles playlist URL modification
 type Modifier struct {
 	options ProcessorOptions
@@ -70,10 +99,8 @@ func (m *Modifier) Process(playlist *hls.Playlist, baseURL, proxyURL *url.URL, t
 		return ErrInvalidPlaylist
 	}
 	
-	if token == "" {
-		return ErrEmptyToken
-	}
-	
+	// An empty token is allowed for anonymous access; URLs are then
+	// rewritten without one
 	if m.options.TokenParamName == "" {
 		return ErrEmptyTokenParamName
 	}
@@ -115,6 +142,56 @@ func resolveURL(baseURL *url.URL, urlStr string) (*url.URL, error) {
 	return baseURL.ResolveReference(parsedURL), nil
 }
 
+// addTokenToURL returns the URL with the token set in the named query parameter
+func addTokenToURL(targetURL *url.URL, paramName, token string) string {
+	// Skip if no token or no token param name
+	if token == "" || paramName == "" {
+		return targetURL.String()
+	}
+	
+	// Clone the URL to avoid modifying the original
+	result := *targetURL
+	
+	// Add token to query string
+	q := result.Query()
+	q.Set(paramName, token)
+	result.RawQuery = q.Encode()
+	
+	return result.String()
+}
+
+// rewriteAbsolute applies absolute URL rewriting to a URI. Absolute http(s)
+// URIs on a proxied host are pointed at the proxy with the target in the
+// path parameter; those on any other host are returned unchanged, without
+// the token. It reports false for relative URIs, other schemes, or when the
+// option is off, leaving the URI to the usual rules.
+func rewriteAbsolute(uri string, proxyURL *url.URL, token string, options ProcessorOptions) (string, bool) {
+	if !options.RewriteAbsolute {
+		return "", false
+	}
+	target, err := url.Parse(uri)
+	if err != nil || !target.IsAbs() || (target.Scheme != "http" && target.Scheme != "https") {
+		return "", false
+	}
+	if !options.ProxyHosts[utils.CanonicalHostname(target.Host)] {
+		return uri, true
+	}
+	return proxyParamURL(proxyURL, target, token, options), true
+}
+
+// proxyParamURL returns a proxy URL carrying the target in the path
+// parameter and the token in the token parameter
+func proxyParamURL(proxyURL, target *url.URL, token string, options ProcessorOptions) string {
+	result := &url.URL{Path: proxyURL.Path}
+	q := url.Values{}
+	if options.TokenParamName != "" && token != "" {
+		q.Set(options.TokenParamName, token)
+	}
+	q.Set(options.PathParamName, target.String())
+	result.RawQuery = q.Encode()
+	return result.String()
+}
+
 // IsM3U8 checks if a URL is likely an M3U8 playlist
 func IsM3U8(urlStr string) bool {
 	return strings.HasSuffix(strings.ToLower(urlStr), ".m3u8")
@@ -0,0 +1,136 @@
+// Parsed playlist cache
+//
+// Reuses parsed origin playlists across tokens:
+// - Keyed on the token-independent origin URL
+// - Entries valid only while the origin content is unchanged
+// - LRU bounded by the size of the cached playlists
+// - Each use rewrites a private copy
+
+package playlist
+
+import (
+	"bytes"
+	"container/list"
+	"net/url"
+	"sync"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// parsedOverhead approximates the memory of a parsed playlist relative to
+// its source text
+const parsedOverhead = 3
+
+// ParsedCache holds parsed playlists by origin URL
+type ParsedCache struct {
+	maxBytes    int64
+	tokenParams []string // Query parameters left out of the key
+	size        int64
+	mu       sync.Mutex
+	lru      *list.List
+	entries  map[string]*list.Element
+}
+
+// parsedEntry is a cached parse of one origin playlist version
+type parsedEntry struct {
+	url      string
+	source   []byte
+	playlist *hls.Playlist
+	size     int64
+}
+
+// NewParsedCache creates a cache holding up to maxBytes of playlists, or
+// nil if maxBytes disables it. The token parameters are ignored when
+// keying origin URLs, so every token shares one entry.
+func NewParsedCache(maxBytes int64, tokenParams []string) *ParsedCache {
+	if maxBytes <= 0 {
+		return nil
+	}
+	return &ParsedCache{
+		maxBytes:    maxBytes,
+		tokenParams: tokenParams,
+		lru:         list.New(),
+		entries:     make(map[string]*list.Element),
+	}
+}
+
+// key returns the cache key for an origin URL, with token parameters and
+// tokens embedded in the path masked
+func (c *ParsedCache) key(u *url.URL) string {
+	return utils.RedactURL(u, c.tokenParams)
+}
+
+// get returns a copy of the playlist parsed from source at url, if cached
+func (c *ParsedCache) get(url string, source []byte) (*hls.Playlist, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	element, ok := c.entries[url]
+	if !ok {
+		c.mu.Unlock()
+		return nil, false
+	}
+	entry := element.Value.(*parsedEntry)
+	if !bytes.Equal(entry.source, source) {
+		c.mu.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.mu.Unlock()
+
+	// Cached playlists are never modified, so copying needs no lock
+	return entry.playlist.Clone(), true
+}
+
+// put caches a copy of the playlist parsed from source at url, replacing
+// any older version
+func (c *ParsedCache) put(url string, source []byte, playlist *hls.Playlist) {
+	if c == nil {
+		return
+	}
+
+	size := int64(len(url)+len(source)) * parsedOverhead
+	if size > c.maxBytes {
+		return
+	}
+	entry := &parsedEntry{
+		url:      url,
+		source:   append([]byte(nil), source...),
+		playlist: playlist.Clone(),
+		size:     size,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, ok := c.entries[url]; ok {
+		c.remove(element)
+	}
+	c.entries[url] = c.lru.PushFront(entry)
+	c.size += size
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an entry; the caller holds the lock
+func (c *ParsedCache) remove(element *list.Element) {
+	entry := c.lru.Remove(element).(*parsedEntry)
+	delete(c.entries, entry.url)
+	c.size -= entry.size
+}
+
+// Len returns the number of cached playlists
+func (c *ParsedCache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
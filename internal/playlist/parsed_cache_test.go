@@ -0,0 +1,96 @@
+package playlist
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+)
+
+const cachedMedia = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"
+
+func TestParsedCacheSharedAcrossTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		first   string
+		second  string
+		content string
+		wantHit bool
+		wantLen int
+	}{
+		{"token parameter", "https://origin/live/a.m3u8?token=one", "https://origin/live/a.m3u8?token=two", cachedMedia, true, 1},
+		{"route token parameter", "https://origin/live/a.m3u8?sig=one&q=1", "https://origin/live/a.m3u8?sig=two&q=1", cachedMedia, true, 1},
+		{"token in path", "https://origin/eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIxIn0.c2ln/a.m3u8", "https://origin/eyJhbGciOiJIUzI1NiJ9.eyJzdWIiOiIyIn0.c2ln/a.m3u8", cachedMedia, true, 1},
+		{"other parameter", "https://origin/live/a.m3u8?q=1", "https://origin/live/a.m3u8?q=2", cachedMedia, false, 2},
+		{"other playlist", "https://origin/live/a.m3u8?token=one", "https://origin/live/b.m3u8?token=one", cachedMedia, false, 2},
+		{"changed content replaces", "https://origin/live/a.m3u8?token=one", "https://origin/live/a.m3u8?token=two", cachedMedia + "#EXTINF:6,\nseg2.ts\n", false, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache := NewParsedCache(1<<20, []string{"token", "sig"})
+			parser := NewParser()
+			parser.SetParsedCache(cache)
+
+			first, _ := url.Parse(tt.first)
+			if _, err := parser.parseCached([]byte(cachedMedia), first); err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			second, _ := url.Parse(tt.second)
+			_, hit := cache.get(cache.key(second), []byte(tt.content))
+			if hit != tt.wantHit {
+				t.Errorf("hit = %v, want %v", hit, tt.wantHit)
+			}
+			if _, err := parser.parseCached([]byte(tt.content), second); err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			if cache.Len() != tt.wantLen {
+				t.Errorf("cached %d playlists, want %d", cache.Len(), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestParsedCacheReturnsPrivateCopies(t *testing.T) {
+	cache := NewParsedCache(1<<20, nil)
+	parser := NewParser()
+	parser.SetParsedCache(cache)
+	u, _ := url.Parse("https://origin/live/a.m3u8")
+
+	first, err := parser.parseCached([]byte(cachedMedia), u)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	first.Media.Segments[0].URI = "rewritten.ts"
+
+	second, err := parser.parseCached([]byte(cachedMedia), u)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := second.Media.Segments[0].URI; got != "seg1.ts" {
+		t.Errorf("cached playlist modified through a copy: %s", got)
+	}
+}
+
+func TestParsedCacheEvictsBeyondBudget(t *testing.T) {
+	entrySize := int64(len("https://origin/live/a.m3u8")+len(cachedMedia)) * parsedOverhead
+	cache := NewParsedCache(2*entrySize, nil)
+
+	for _, name := range []string{"a", "b", "c"} {
+		u, _ := url.Parse("https://origin/live/" + name + ".m3u8")
+		playlist, err := NewParser().Parse(strings.NewReader(cachedMedia))
+		if err != nil {
+			t.Fatalf("parse: %v", err)
+		}
+		cache.put(cache.key(u), []byte(cachedMedia), playlist)
+	}
+
+	if cache.Len() != 2 {
+		t.Fatalf("cached %d playlists, want 2", cache.Len())
+	}
+	oldest, _ := url.Parse("https://origin/live/a.m3u8")
+	if _, hit := cache.get(cache.key(oldest), []byte(cachedMedia)); hit {
+		t.Error("least recently used playlist not evicted")
+	}
+}
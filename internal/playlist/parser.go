@@ -18,21 +18,70 @@ import (
 	"github.com/ilijajolevski/ilinden/pkg/hls"
 )
 
-// Parser handles HLS playlist parsing
+// Parser handles HLS playlist parsing. It is safe for concurrent use.
 type Parser struct {
-	hlsParser *hls.Parser
+	options hls.Options
+	parsed  *ParsedCache
 }
 
 // NewParser creates a new HLS playlist parser
 func NewParser() *Parser {
-	return &Parser{
-		hlsParser: hls.New(),
+	return &Parser{}
+}
+
+// NewParserWithValidation creates a parser applying the given compliance
+// mode: "off", "warn" (record warnings on the playlist) or "strict" (reject
+// non-compliant playlists)
+func NewParserWithValidation(mode string) *Parser {
+	switch mode {
+	case "warn":
+		return &Parser{options: hls.Options{ValidateDurations: true, ValidateAttributes: true}}
+	case "strict":
+		return &Parser{options: hls.Options{ValidateDurations: true, ValidateAttributes: true, Strict: true}}
+	default:
+		return NewParser()
 	}
 }
 
-// Parse parses an HLS playlist from a reader
+// SetParsedCache makes the parser reuse parsed origin playlists across
+// tokens. It must be called before the parser is used.
+func (p *Parser) SetParsedCache(c *ParsedCache) {
+	p.parsed = c
+}
+
+// SetLenientBandwidth makes the parser accept variants and I-frame streams
+// with a missing or invalid BANDWIDTH, giving them defaultBandwidth and a
+// warning instead of rejecting the playlist. It must be called before the
+// parser is used.
+func (p *Parser) SetLenientBandwidth(defaultBandwidth uint64) {
+	p.options.LenientBandwidth = true
+	p.options.DefaultBandwidth = defaultBandwidth
+}
+
+// parseCached parses playlist bytes fetched from baseURL, reusing an earlier
+// parse of the same content
+func (p *Parser) parseCached(playlistData []byte, baseURL *url.URL) (*hls.Playlist, error) {
+	if p.parsed == nil || baseURL == nil {
+		return p.Parse(bytes.NewReader(playlistData))
+	}
+
+	key := p.parsed.key(baseURL)
+	if playlist, ok := p.parsed.get(key, playlistData); ok {
+		return playlist, nil
+	}
+
+	playlist, err := p.Parse(bytes.NewReader(playlistData))
+	if err != nil {
+		return nil, err
+	}
+	p.parsed.put(key, playlistData, playlist)
+	return playlist, nil
+}
+
+// Parse parses an HLS playlist from a reader. Each call uses a fresh
+// low-level parser, since hls.Parser accumulates state per playlist.
 func (p *Parser) Parse(r io.Reader) (*hls.Playlist, error) {
-	return p.hlsParser.Parse(r)
+	return hls.NewWithOptions(p.options).Parse(r)
 }
 
 // ParseAndProcess parses and processes a playlist
@@ -55,21 +104,27 @@ func (p *Parser) ParseAndProcess(r io.Reader, baseURL, proxyURL *url.URL, token
 
 // ParseAndProcessBytes parses and processes a playlist from bytes
 func (p *Parser) ParseAndProcessBytes(playlistData []byte, baseURL, proxyURL *url.URL, token string, options ProcessorOptions) ([]byte, error) {
-	// Parse the playlist
-	reader := bytes.NewReader(playlistData)
-	playlist, err := p.Parse(reader)
+	processed, _, err := p.ParseAndProcessBytesStats(playlistData, baseURL, proxyURL, token, options)
+	return processed, err
+}
+
+// ParseAndProcessBytesStats parses and processes a playlist from bytes,
+// also reporting how many URIs of each kind were rewritten
+func (p *Parser) ParseAndProcessBytesStats(playlistData []byte, baseURL, proxyURL *url.URL, token string, options ProcessorOptions) ([]byte, RewriteStats, error) {
+	// Parse the playlist, or copy an earlier parse of the same content
+	playlist, err := p.parseCached(playlistData, baseURL)
 	if err != nil {
-		return nil, err
+		return nil, RewriteStats{}, err
 	}
 	
 	// Process the playlist
 	modifier := NewModifier(options)
 	if err := modifier.Process(playlist, baseURL, proxyURL, token); err != nil {
-		return nil, err
+		return nil, RewriteStats{}, err
 	}
 	
 	// Convert back to bytes
-	return []byte(playlist.String()), nil
+	return []byte(playlist.String()), rewriteStats(playlist), nil
 }
 
 // ParseAndProcessResponse parses and processes a playlist from an HTTP response
@@ -103,4 +158,27 @@ func DetectPlaylistType(content []byte) hls.PlaylistType {
 	
 	// Unknown or invalid
 	return hls.PlaylistTypeUnknown
-}
\ No newline at end of file
+}
+
+// HasEndList reports whether a media playlist is complete, i.e. carries
+// #EXT-X-ENDLIST, so it will not change again (VOD)
+func HasEndList(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) == hls.TagEndList {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaPlaylistType returns the EXT-X-PLAYLIST-TYPE of a media playlist,
+// "VOD" or "EVENT", or "" when the tag is absent (live sliding window)
+func MediaPlaylistType(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if value, ok := strings.CutPrefix(line, hls.TagPlaylistType+":"); ok {
+			return strings.ToUpper(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,78 @@
+package playlist
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHasEndList(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"live", "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n", false},
+		{"complete", "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n#EXT-X-ENDLIST\n", true},
+		{"CRLF line endings", "#EXTM3U\r\n#EXTINF:6,\r\nseg1.ts\r\n#EXT-X-ENDLIST\r\n", true},
+		{"no trailing newline", "#EXTM3U\n#EXTINF:6,\nseg1.ts\n#EXT-X-ENDLIST", true},
+		{"tag inside a URI", "#EXTM3U\n#EXTINF:6,\nseg.ts?x=#EXT-X-ENDLIST\n", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasEndList([]byte(tt.content)); got != tt.want {
+				t.Errorf("HasEndList() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMediaPlaylistType(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"live", "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n", ""},
+		{"VOD", "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXTINF:6,\nseg1.ts\n", "VOD"},
+		{"EVENT", "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXTINF:6,\nseg1.ts\n", "EVENT"},
+		{"lower case and CRLF", "#EXTM3U\r\n#EXT-X-PLAYLIST-TYPE:event\r\n", "EVENT"},
+		{"tag inside a URI", "#EXTM3U\n#EXTINF:6,\nseg.ts?x=#EXT-X-PLAYLIST-TYPE:VOD\n", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MediaPlaylistType([]byte(tt.content)); got != tt.want {
+				t.Errorf("MediaPlaylistType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewParserWithValidation(t *testing.T) {
+	const nonCompliant = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:8,\nseg1.ts\n"
+
+	tests := []struct {
+		mode         string
+		wantWarnings int
+		wantErr      bool
+	}{
+		{"off", 0, false},
+		{"", 0, false},
+		{"warn", 1, false},
+		{"strict", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			playlist, err := NewParserWithValidation(tt.mode).Parse(strings.NewReader(nonCompliant))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(playlist.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings %q, want %d", playlist.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// Rendition filtering
+//
+// Removes master playlist renditions (EXT-X-MEDIA) by capability:
+// - Accessibility and other CHARACTERISTICS
+// - Audio channel count from CHANNELS
+// - A DEFAULT rendition promoted when the default is removed
+// - Never empties a rendition group
+
+package playlist
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// RenditionFilter removes renditions carrying excluded characteristics or
+// more audio channels than allowed. Zero fields impose no limit.
+type RenditionFilter struct {
+	ExcludeCharacteristics []string // e.g. public.accessibility.describes-video
+	MaxChannels            int      // e.g. 2 keeps only mono and stereo audio
+}
+
+// keepsRendition reports whether a rendition passes the filter. Renditions
+// without CHARACTERISTICS or with an unreadable CHANNELS pass.
+func (f *RenditionFilter) keepsRendition(m *hls.MediaGroup) bool {
+	if len(f.ExcludeCharacteristics) > 0 && m.Characteristics != "" {
+		for _, c := range strings.Split(m.Characteristics, ",") {
+			for _, excluded := range f.ExcludeCharacteristics {
+				if strings.TrimSpace(c) == excluded {
+					return false
+				}
+			}
+		}
+	}
+	if f.MaxChannels > 0 && m.Type == string(hls.MediaTypeAudio) {
+		if channels, ok := parseChannels(m.Channels); ok && channels > f.MaxChannels {
+			return false
+		}
+	}
+	return true
+}
+
+// Apply removes the renditions that fail the filter from a master playlist.
+// A rendition group whose every rendition would be removed is left
+// unchanged, since variants still refer to it. When a group loses its
+// DEFAULT rendition, the first remaining one becomes the default. It
+// returns the number of renditions removed.
+func (f *RenditionFilter) Apply(master *hls.MasterPlaylist) int {
+	removed := 0
+	for mediaType, renditions := range master.MediaGroups {
+		// Decide per group, keeping the renditions' order
+		keep := make([]bool, len(renditions))
+		kept := make(map[string]int)
+		for i := range renditions {
+			keep[i] = f.keepsRendition(&renditions[i])
+			if keep[i] {
+				kept[renditions[i].GroupID]++
+			}
+		}
+
+		filtered := make([]hls.MediaGroup, 0, len(renditions))
+		lostDefault := make(map[string]bool)
+		for i, m := range renditions {
+			if keep[i] || kept[m.GroupID] == 0 {
+				filtered = append(filtered, m)
+				continue
+			}
+			if m.Default {
+				lostDefault[m.GroupID] = true
+			}
+			removed++
+		}
+
+		for i := range filtered {
+			if m := &filtered[i]; lostDefault[m.GroupID] {
+				m.Default = true
+				m.Autoselect = true
+				delete(lostDefault, m.GroupID)
+			}
+		}
+		master.MediaGroups[mediaType] = filtered
+	}
+	return removed
+}
+
+// parseChannels parses the channel count leading a CHANNELS value such as
+// "2" or "16/JOC"
+func parseChannels(channels string) (int, bool) {
+	count, _, _ := strings.Cut(strings.Trim(channels, `"`), "/")
+	n, err := strconv.Atoi(count)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
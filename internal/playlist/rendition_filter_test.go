@@ -0,0 +1,153 @@
+package playlist
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// renditionMaster has stereo, surround and audio-described audio, and
+// subtitles with and without accessibility characteristics
+var renditionMaster = []string{
+	"#EXTM3U",
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Surround",LANGUAGE="en",DEFAULT=YES,AUTOSELECT=YES,CHANNELS="6",URI="surround.m3u8"`,
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Stereo",LANGUAGE="en",CHANNELS="2",URI="stereo.m3u8"`,
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Described",LANGUAGE="en",CHANNELS="2",CHARACTERISTICS="public.accessibility.describes-video",URI="described.m3u8"`,
+	`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="atmos",NAME="Atmos",LANGUAGE="en",DEFAULT=YES,CHANNELS="16/JOC",URI="atmos.m3u8"`,
+	`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English",LANGUAGE="en",DEFAULT=YES,URI="subs.m3u8"`,
+	`#EXT-X-MEDIA:TYPE=SUBTITLES,GROUP-ID="subs",NAME="English CC",LANGUAGE="en",CHARACTERISTICS="public.accessibility.transcribes-spoken-dialog,public.accessibility.describes-music-and-sound",URI="cc.m3u8"`,
+	`#EXT-X-STREAM-INF:BANDWIDTH=1500000,AUDIO="aud",SUBTITLES="subs"`,
+	"720p.m3u8",
+	`#EXT-X-STREAM-INF:BANDWIDTH=4000000,AUDIO="atmos",SUBTITLES="subs"`,
+	"1080p.m3u8",
+}
+
+func TestRenditionFilterApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      RenditionFilter
+		wantAudio   []string
+		wantSubs    []string
+		wantDefault []string
+	}{
+		{
+			name:        "no limits",
+			wantAudio:   []string{"surround.m3u8", "stereo.m3u8", "described.m3u8", "atmos.m3u8"},
+			wantSubs:    []string{"subs.m3u8", "cc.m3u8"},
+			wantDefault: []string{"surround.m3u8", "atmos.m3u8", "subs.m3u8"},
+		},
+		{
+			name:        "audio description",
+			filter:      RenditionFilter{ExcludeCharacteristics: []string{"public.accessibility.describes-video"}},
+			wantAudio:   []string{"surround.m3u8", "stereo.m3u8", "atmos.m3u8"},
+			wantSubs:    []string{"subs.m3u8", "cc.m3u8"},
+			wantDefault: []string{"surround.m3u8", "atmos.m3u8", "subs.m3u8"},
+		},
+		{
+			name:        "one of several characteristics",
+			filter:      RenditionFilter{ExcludeCharacteristics: []string{"public.accessibility.describes-music-and-sound"}},
+			wantAudio:   []string{"surround.m3u8", "stereo.m3u8", "described.m3u8", "atmos.m3u8"},
+			wantSubs:    []string{"subs.m3u8"},
+			wantDefault: []string{"surround.m3u8", "atmos.m3u8", "subs.m3u8"},
+		},
+		{
+			name:        "stereo promotes a new default",
+			filter:      RenditionFilter{MaxChannels: 2},
+			wantAudio:   []string{"stereo.m3u8", "described.m3u8", "atmos.m3u8"},
+			wantSubs:    []string{"subs.m3u8", "cc.m3u8"},
+			wantDefault: []string{"stereo.m3u8", "atmos.m3u8", "subs.m3u8"},
+		},
+		{
+			name:        "stereo without description",
+			filter:      RenditionFilter{MaxChannels: 2, ExcludeCharacteristics: []string{"public.accessibility.describes-video"}},
+			wantAudio:   []string{"stereo.m3u8", "atmos.m3u8"},
+			wantSubs:    []string{"subs.m3u8", "cc.m3u8"},
+			wantDefault: []string{"stereo.m3u8", "atmos.m3u8", "subs.m3u8"},
+		},
+		{
+			name:        "channel limit ignores subtitles",
+			filter:      RenditionFilter{MaxChannels: 1},
+			wantAudio:   []string{"surround.m3u8", "stereo.m3u8", "described.m3u8", "atmos.m3u8"},
+			wantSubs:    []string{"subs.m3u8", "cc.m3u8"},
+			wantDefault: []string{"surround.m3u8", "atmos.m3u8", "subs.m3u8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist := parseMedia(t, renditionMaster...)
+			removed := tt.filter.Apply(&playlist.Master)
+
+			audio := renditionURIs(playlist.Master, hls.MediaTypeAudio)
+			subs := renditionURIs(playlist.Master, hls.MediaTypeSubtitles)
+			if !reflect.DeepEqual(audio, tt.wantAudio) {
+				t.Errorf("audio %v, want %v", audio, tt.wantAudio)
+			}
+			if !reflect.DeepEqual(subs, tt.wantSubs) {
+				t.Errorf("subtitles %v, want %v", subs, tt.wantSubs)
+			}
+			if want := 6 - len(tt.wantAudio) - len(tt.wantSubs); removed != want {
+				t.Errorf("removed %d renditions, want %d", removed, want)
+			}
+
+			var defaults []string
+			for _, mediaType := range []hls.MediaType{hls.MediaTypeAudio, hls.MediaTypeSubtitles} {
+				for _, m := range playlist.Master.MediaGroups[string(mediaType)] {
+					if m.Default {
+						defaults = append(defaults, m.URI)
+					}
+				}
+			}
+			if !reflect.DeepEqual(defaults, tt.wantDefault) {
+				t.Errorf("defaults %v, want %v", defaults, tt.wantDefault)
+			}
+		})
+	}
+}
+
+func TestParseChannels(t *testing.T) {
+	tests := []struct {
+		channels string
+		want     int
+		wantOK   bool
+	}{
+		{"2", 2, true},
+		{`"6"`, 6, true},
+		{"16/JOC", 16, true},
+		{"", 0, false},
+		{"stereo", 0, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseChannels(tt.channels)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("parseChannels(%q) = %d, %v, want %d, %v", tt.channels, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestMasterProcessorRenditionFilter(t *testing.T) {
+	options := DefaultProcessorOptions()
+	options.RenditionFilter = &RenditionFilter{MaxChannels: 2}
+	out := process(t, strings.Join(renditionMaster, "\n")+"\n", "abc", options)
+
+	if strings.Contains(out, "surround.m3u8") {
+		t.Errorf("output still lists the surround rendition:\n%s", out)
+	}
+	if !strings.Contains(out, `NAME="Stereo",DEFAULT=YES,AUTOSELECT=YES`) {
+		t.Errorf("stereo rendition not promoted to default:\n%s", out)
+	}
+	if n := strings.Count(out, "#EXT-X-MEDIA:"); n != 5 {
+		t.Errorf("%d renditions written, want 5:\n%s", n, out)
+	}
+}
+
+func renditionURIs(master hls.MasterPlaylist, mediaType hls.MediaType) []string {
+	uris := []string{}
+	for _, m := range master.MediaGroups[string(mediaType)] {
+		uris = append(uris, m.URI)
+	}
+	return uris
+}
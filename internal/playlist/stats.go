@@ -0,0 +1,143 @@
+// Rewrite statistics
+//
+// Describes what a playlist rewrite touched:
+// - URIs rewritten per element type
+// - Longest rewritten URI
+// - Parse error classification for metrics
+
+package playlist
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// RewriteStats counts the URIs rewritten in a playlist
+type RewriteStats struct {
+	Type          hls.PlaylistType
+	Variants      int // EXT-X-STREAM-INF
+	IFrameStreams int // EXT-X-I-FRAME-STREAM-INF
+	Renditions    int // EXT-X-MEDIA with a URI
+	Segments      int
+	Keys          int // EXT-X-KEY and EXT-X-SESSION-KEY with a URI
+	Maps          int // EXT-X-MAP
+	Warnings      []string // Spec violations tolerated by the parser
+	LongestURI    string   // Longest URI as rewritten
+}
+
+// rewriteStats counts the URIs of a processed playlist
+func rewriteStats(playlist *hls.Playlist) RewriteStats {
+	stats := RewriteStats{Type: playlist.Type, Warnings: playlist.Warnings}
+
+	if playlist.IsMaster() {
+		for _, v := range playlist.Master.Variants {
+			if v.URI != "" {
+				stats.Variants++
+			}
+		}
+		for _, iframe := range playlist.Master.IFrameStreams {
+			if iframe.URI != "" {
+				stats.IFrameStreams++
+			}
+		}
+		for _, group := range playlist.Master.MediaGroups {
+			for _, media := range group {
+				if media.URI != "" {
+					stats.Renditions++
+				}
+			}
+		}
+		for _, key := range playlist.Master.SessionKeys {
+			if key.URI != "" {
+				stats.Keys++
+			}
+		}
+	}
+
+	if playlist.IsMedia() {
+		for _, seg := range playlist.Media.Segments {
+			if seg.URI != "" {
+				stats.Segments++
+			}
+			if seg.Key != nil && seg.Key.URI != "" {
+				stats.Keys++
+			}
+			if seg.Map != nil && seg.Map.URI != "" {
+				stats.Maps++
+			}
+		}
+	}
+
+	stats.LongestURI = longestURI(playlist)
+	return stats
+}
+
+// longestURI returns the longest URI of a processed playlist
+func longestURI(playlist *hls.Playlist) string {
+	longest := ""
+	keep := func(uri string) {
+		if len(uri) > len(longest) {
+			longest = uri
+		}
+	}
+
+	if playlist.IsMaster() {
+		for _, v := range playlist.Master.Variants {
+			keep(v.URI)
+		}
+		for _, iframe := range playlist.Master.IFrameStreams {
+			keep(iframe.URI)
+		}
+		for _, group := range playlist.Master.MediaGroups {
+			for _, media := range group {
+				keep(media.URI)
+			}
+		}
+		for _, key := range playlist.Master.SessionKeys {
+			keep(key.URI)
+		}
+	}
+
+	if playlist.IsMedia() {
+		for _, seg := range playlist.Media.Segments {
+			keep(seg.URI)
+			if seg.Key != nil {
+				keep(seg.Key.URI)
+			}
+			if seg.Map != nil {
+				keep(seg.Map.URI)
+			}
+		}
+	}
+
+	return longest
+}
+
+// ParseErrorKind classifies a parse or rewrite error for metrics
+func ParseErrorKind(err error) string {
+	var urlErr *url.Error
+	var numErr *strconv.NumError
+	switch {
+	case errors.Is(err, hls.ErrPlaylistHeader):
+		return "header"
+	case errors.Is(err, hls.ErrTagFormat):
+		return "tag"
+	case errors.Is(err, hls.ErrPlaylistFormat):
+		return "format"
+	case errors.Is(err, hls.ErrSegmentDuration):
+		return "duration"
+	case errors.Is(err, ErrInvalidPlaylist),
+		errors.Is(err, ErrNotMasterPlaylist),
+		errors.Is(err, ErrNotMediaPlaylist):
+		return "type"
+	case errors.As(err, &urlErr):
+		return "url"
+	case errors.As(err, &numErr):
+		return "value"
+	default:
+		return "syntax"
+	}
+}
@@ -0,0 +1,128 @@
+package playlist
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+func TestParseAndProcessBytesStats(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    RewriteStats
+	}{
+		{
+			name: "master",
+			content: "#EXTM3U\n" +
+				`#EXT-X-SESSION-KEY:METHOD=AES-128,URI="k.key"` + "\n" +
+				`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="en",URI="en.m3u8"` + "\n" +
+				`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="cc1",INSTREAM-ID="CC1"` + "\n" +
+				`#EXT-X-I-FRAME-STREAM-INF:BANDWIDTH=100,URI="iframe.m3u8"` + "\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=1000\nlow.m3u8\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=2000\nhigh.m3u8\n",
+			want: RewriteStats{Type: hls.PlaylistTypeMaster, Variants: 2, IFrameStreams: 1, Renditions: 1, Keys: 1},
+		},
+		{
+			name: "media",
+			content: "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" +
+				`#EXT-X-MAP:URI="init.mp4"` + "\n" +
+				`#EXT-X-KEY:METHOD=AES-128,URI="k1.key"` + "\n" +
+				"#EXTINF:6,\nseg1.m4s\n#EXTINF:6,\nseg2.m4s\n#EXTINF:6,\nseg3.m4s\n",
+			want: RewriteStats{Type: hls.PlaylistTypeMedia, Segments: 3, Keys: 1, Maps: 1},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, _ := url.Parse("https://origin.example.com/live/index.m3u8")
+			proxyURL, _ := url.Parse("/proxy")
+			_, stats, err := NewParser().ParseAndProcessBytesStats([]byte(tt.content), baseURL, proxyURL, "abc", DefaultProcessorOptions())
+			if err != nil {
+				t.Fatalf("ParseAndProcessBytesStats: %v", err)
+			}
+			// Compare the counts only
+			stats.Warnings, stats.LongestURI = nil, ""
+			if !reflect.DeepEqual(stats, tt.want) {
+				t.Errorf("stats %+v, want %+v", stats, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrorKind(t *testing.T) {
+	_, numErr := strconv.Atoi("x")
+	_, urlErr := url.Parse("http://[::1")
+
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"missing header", hls.ErrPlaylistHeader, "header"},
+		{"wrapped tag error", fmt.Errorf("line 3: %w", hls.ErrTagFormat), "tag"},
+		{"format", hls.ErrPlaylistFormat, "format"},
+		{"wrong playlist type", ErrNotMediaPlaylist, "type"},
+		{"segment duration", fmt.Errorf("%w: segment 1", hls.ErrSegmentDuration), "duration"},
+		{"URL", urlErr, "url"},
+		{"number", numErr, "value"},
+		{"other", errors.New("unexpected"), "syntax"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseErrorKind(tt.err); got != tt.want {
+				t.Errorf("ParseErrorKind(%v) = %s, want %s", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteStatsLongestURI(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{
+			name:    "segment",
+			content: "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\ns1.ts\n#EXTINF:6,\nlonger-segment.ts\n",
+			want:    "longer-segment.ts",
+		},
+		{
+			name: "key in media playlist",
+			content: "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" +
+				`#EXT-X-KEY:METHOD=AES-128,URI="keys/a-rather-long-key-name.key"` + "\n" +
+				"#EXTINF:6,\ns1.ts\n",
+			want: "keys/a-rather-long-key-name.key",
+		},
+		{
+			name: "rendition in master",
+			content: "#EXTM3U\n" +
+				`#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="en",URI="audio/english-stereo.m3u8"` + "\n" +
+				"#EXT-X-STREAM-INF:BANDWIDTH=1000,AUDIO=\"aud\"\nlow.m3u8\n",
+			want: "audio/english-stereo.m3u8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			baseURL, _ := url.Parse("https://origin.example.com/live/index.m3u8")
+			proxyURL, _ := url.Parse("/proxy")
+			_, stats, err := NewParser().ParseAndProcessBytesStats([]byte(tt.content), baseURL, proxyURL, "abc", DefaultProcessorOptions())
+			if err != nil {
+				t.Fatalf("ParseAndProcessBytesStats: %v", err)
+			}
+			// The longest URI is reported as rewritten
+			if !strings.Contains(stats.LongestURI, tt.want) || !strings.Contains(stats.LongestURI, "abc") {
+				t.Errorf("longest URI %q, want the rewritten %s", stats.LongestURI, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/jwt"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+func TestHandlerAccessWindow(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	// A broadcast available 19:00-22:00 and a 48h rental, both issued at noon
+	issued := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	broadcast := map[string]interface{}{
+		"accessStart": "2024-01-01T19:00:00Z",
+		"accessEnd":   "2024-01-01T22:00:00Z",
+	}
+	rental := map[string]interface{}{"accessEnd": "48h"}
+	allowAll := jwt.AuthorizerFunc(func(*jwt.Claims, *http.Request) jwt.Decision { return jwt.Allow() })
+
+	tests := []struct {
+		name       string
+		window     map[string]interface{}
+		now        time.Time
+		composed   bool // Checked by DefaultAuthorizer in an embedder's policy
+		wantStatus int
+	}{
+		{"before broadcast", broadcast, time.Date(2024, 1, 1, 18, 30, 0, 0, time.UTC), false, http.StatusForbidden},
+		{"during broadcast", broadcast, time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), false, http.StatusOK},
+		{"after broadcast", broadcast, time.Date(2024, 1, 1, 22, 0, 0, 0, time.UTC), false, http.StatusForbidden},
+		{"rental started", rental, issued.Add(time.Hour), false, http.StatusOK},
+		{"rental expired", rental, issued.Add(49 * time.Hour), false, http.StatusForbidden},
+		{"composed before broadcast", broadcast, time.Date(2024, 1, 1, 18, 30, 0, 0, time.UTC), true, http.StatusForbidden},
+		{"composed during broadcast", broadcast, time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC), true, http.StatusOK},
+		{"composed rental started", rental, issued.Add(time.Hour), true, http.StatusOK},
+		{"composed rental expired", rental, issued.Add(49 * time.Hour), true, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = "test-secret"
+			cfg.JWT.AccessStartClaim = "accessStart"
+			cfg.JWT.AccessEndClaim = "accessEnd"
+			clock := utils.NewFakeClock(tt.now)
+			opts := HandlerOptions{
+				Config:  cfg,
+				Cache:   cache.NewMemory(),
+				Logger:  telemetry.NewLogger("error", "", "stdout"),
+				Metrics: telemetry.NewMetrics(),
+				Clock:   clock,
+			}
+			if tt.composed {
+				opts.Authorizer = jwt.All(DefaultAuthorizer(cfg, clock.Now), allowAll)
+			}
+			h := NewHandler(opts)
+
+			claims := map[string]interface{}{
+				"sub": "player-1",
+				"iat": issued.Unix(),
+				"exp": issued.Add(72 * time.Hour).Unix(),
+			}
+			for name, value := range tt.window {
+				claims[name] = value
+			}
+			rec := serve(h, "/live/seg1.ts?token="+signToken(t, cfg.JWT.Secret, claims))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestHandlerAnonymousAccess(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"))
+			return
+		}
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	const secret = "test-secret"
+	valid := signToken(t, secret, map[string]interface{}{"sub": "player-1", "exp": time.Now().Add(time.Hour).Unix()})
+	expired := signToken(t, secret, map[string]interface{}{"sub": "player-1", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	tests := []struct {
+		name          string
+		configure     func(*config.JWTConfig)
+		target        string
+		token         string
+		wantStatus    int
+		wantAnonymous int
+	}{
+		{"required, no token", nil, "/live/seg1.ts", "", http.StatusUnauthorized, 0},
+		{"required, valid token", nil, "/live/seg1.ts", valid, http.StatusOK, 0},
+		{"disabled", func(c *config.JWTConfig) { c.Enabled = false }, "/live/seg1.ts", "", http.StatusOK, 0},
+		{"optional, no token", func(c *config.JWTConfig) { c.Optional = true }, "/live/seg1.ts", "", http.StatusOK, 1},
+		{"optional, expired token", func(c *config.JWTConfig) { c.Optional = true }, "/live/seg1.ts", expired, http.StatusUnauthorized, 0},
+		{"public path, no token", func(c *config.JWTConfig) { c.PublicPaths = []string{"/free/"} }, "/free/seg1.ts", "", http.StatusOK, 1},
+		{"public path, expired token", func(c *config.JWTConfig) { c.PublicPaths = []string{"/free/"} }, "/free/seg1.ts", expired, http.StatusUnauthorized, 0},
+		{"outside public path", func(c *config.JWTConfig) { c.PublicPaths = []string{"/free/"} }, "/live/seg1.ts", "", http.StatusUnauthorized, 0},
+		{"optional playlist", func(c *config.JWTConfig) { c.Optional = true }, "/live/index.m3u8", "", http.StatusOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = secret
+			if tt.configure != nil {
+				tt.configure(&cfg.JWT)
+			}
+			h := newTestHandler(t, cfg)
+
+			target := tt.target
+			if tt.token != "" {
+				target += "?token=" + tt.token
+			}
+			rec := serve(h, target)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if got := counter(h.metrics, "auth.anonymous"); got != tt.wantAnonymous {
+				t.Errorf("anonymous requests %d, want %d", got, tt.wantAnonymous)
+			}
+			if strings.HasSuffix(tt.target, ".m3u8") && strings.Contains(rec.Body.String(), "token=") {
+				t.Errorf("anonymous playlist rewritten with a token:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
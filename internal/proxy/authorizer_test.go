@@ -0,0 +1,214 @@
+package proxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/jwt"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// signToken returns an HS256 token for claims signed with secret
+func signToken(t *testing.T, secret string, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "typ": "JWT"})
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestHandlerAuthorizerOption(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	geoBlock := jwt.AuthorizerFunc(func(*jwt.Claims, *http.Request) jwt.Decision { return jwt.Deny("region not licensed") })
+	allowAll := jwt.AuthorizerFunc(func(*jwt.Claims, *http.Request) jwt.Decision { return jwt.Allow() })
+
+	tests := []struct {
+		name       string
+		roles      []interface{}
+		authorizer func(cfg *config.Config) jwt.Authorizer
+		wantStatus int
+	}{
+		{name: "configured role held", roles: []interface{}{"subscriber"}, wantStatus: http.StatusOK},
+		{name: "configured role missing", roles: []interface{}{"guest"}, wantStatus: http.StatusForbidden},
+		{
+			name:       "replacement ignores configured role",
+			roles:      []interface{}{"guest"},
+			authorizer: func(*config.Config) jwt.Authorizer { return allowAll },
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:  "composed policy denies",
+			roles: []interface{}{"subscriber"},
+			authorizer: func(cfg *config.Config) jwt.Authorizer {
+				return jwt.All(DefaultAuthorizer(cfg, time.Now), geoBlock)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:  "composed defaults still apply",
+			roles: []interface{}{"guest"},
+			authorizer: func(cfg *config.Config) jwt.Authorizer {
+				return jwt.All(DefaultAuthorizer(cfg, time.Now), allowAll)
+			},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:  "composed policy allows",
+			roles: []interface{}{"subscriber"},
+			authorizer: func(cfg *config.Config) jwt.Authorizer {
+				return jwt.All(DefaultAuthorizer(cfg, time.Now), allowAll)
+			},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = "test-secret"
+			cfg.JWT.RequiredRoles = []string{"subscriber"}
+
+			opts := HandlerOptions{
+				Config:  cfg,
+				Cache:   cache.NewMemory(),
+				Logger:  telemetry.NewLogger("error", "", "stdout"),
+				Metrics: telemetry.NewMetrics(),
+			}
+			if tt.authorizer != nil {
+				opts.Authorizer = tt.authorizer(cfg)
+			}
+			h := NewHandler(opts)
+
+			token := signToken(t, cfg.JWT.Secret, map[string]interface{}{
+				"sub":   "player-1",
+				"exp":   time.Now().Add(time.Hour).Unix(),
+				"roles": tt.roles,
+			})
+			rec := serve(h, "/live/seg1.ts?token="+token)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerStreamClaim(t *testing.T) {
+	var fetched string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched = r.URL.EscapedPath()
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name       string
+		target     string
+		wantStatus int
+		wantFetch  string
+	}{
+		{"path granted", "/live/seg1.ts", http.StatusOK, "/live/seg1.ts"},
+		{"path refused", "/vod/seg1.ts", http.StatusForbidden, ""},
+		{"explicit target granted", "/proxy?url=" + origin.URL + "/live/seg1.ts", http.StatusOK, "/live/seg1.ts"},
+		{"explicit target refused", "/live/proxy?url=" + origin.URL + "/vod/seg1.ts", http.StatusForbidden, ""},
+		{"dot segments in path", "/live/../vod/seg1.ts", http.StatusForbidden, ""},
+		{"dot segments in target", "/proxy?url=" + origin.URL + "/live/../vod/seg1.ts", http.StatusForbidden, ""},
+		{"escaped dot segments in target", "/proxy?url=" + origin.URL + "/live/%2e%2e/vod/seg1.ts", http.StatusForbidden, ""},
+		{"double-escaped dot segments in target", "/proxy?url=" + url.QueryEscape(origin.URL+"/live/%2e%2e/vod/seg1.ts"), http.StatusForbidden, ""},
+		{"dot segments resolving into grant", "/proxy?url=" + origin.URL + "/vod/../live/./seg1.ts", http.StatusOK, "/live/seg1.ts"},
+		{"dot segments in path resolving into grant", "/vod/../live/seg1.ts", http.StatusOK, "/live/seg1.ts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetched = ""
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = "test-secret"
+			cfg.JWT.StreamClaim = "streams"
+			h := newTestHandler(t, cfg)
+
+			token := signToken(t, cfg.JWT.Secret, map[string]interface{}{
+				"sub":     "player-1",
+				"exp":     time.Now().Add(time.Hour).Unix(),
+				"streams": []interface{}{"/live/"},
+			})
+			sep := "?"
+			if strings.Contains(tt.target, "?") {
+				sep = "&"
+			}
+			rec := serve(h, tt.target+sep+"token="+token)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if fetched != tt.wantFetch {
+				t.Errorf("origin fetched %q, want %q", fetched, tt.wantFetch)
+			}
+		})
+	}
+}
+
+func TestHandlerTokenBinding(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userAgent  string
+		wantStatus int
+	}{
+		{"bound client", "198.51.100.7:4000", "Player/1.0", http.StatusOK},
+		{"other address", "203.0.113.9:4000", "Player/1.0", http.StatusForbidden},
+		{"other user agent", "198.51.100.7:4000", "Curl/8.0", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = "test-secret"
+			cfg.JWT.BindIP = true
+			cfg.JWT.BindUserAgent = true
+			h := newTestHandler(t, cfg)
+
+			token := signToken(t, cfg.JWT.Secret, map[string]interface{}{
+				"sub": "player-1",
+				"exp": time.Now().Add(time.Hour).Unix(),
+				"ip":  "198.51.100.7",
+				"ua":  "Player/1.0",
+			})
+			req := httptest.NewRequest(http.MethodGet, "/live/seg1.ts?token="+token, nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerMissingBandwidth(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n#EXT-X-STREAM-INF:RESOLUTION=640x360\nlow/index.m3u8\n#EXT-X-STREAM-INF:BANDWIDTH=800000\nhigh/index.m3u8\n")
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		mode         string
+		wantOK       bool
+		wantWarnings int
+	}{
+		{"", false, 0},
+		{"reject", false, 0},
+		{"default", true, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			if tt.mode != "" {
+				cfg.Origin.MissingBandwidth = tt.mode
+			}
+			cfg.Origin.DefaultBandwidth = 300000
+			h := newTestHandler(t, cfg)
+
+			rec := serve(h, "/live/master.m3u8")
+			if (rec.Code == http.StatusOK) != tt.wantOK {
+				t.Fatalf("status %d, want success %v", rec.Code, tt.wantOK)
+			}
+			if got := counter(h.metrics, "playlist.warning"); got != tt.wantWarnings {
+				t.Errorf("playlist warnings %d, want %d", got, tt.wantWarnings)
+			}
+			if tt.wantOK && strings.Count(rec.Body.String(), "#EXT-X-STREAM-INF:") != 2 {
+				t.Errorf("variants dropped:\n%s", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/redis"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+func TestHandlerBeacons(t *testing.T) {
+	var fetches atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	const secret = "test-secret"
+	token := signToken(t, secret, map[string]interface{}{"sub": "player-1", "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		name        string
+		target      string
+		wantStatus  int
+		wantFetches int32
+		wantBeacon  int
+		wantTracked bool
+	}{
+		{"beacon", "/beacon/ping?token=" + token, http.StatusNoContent, 0, 1, true},
+		{"beacon without a token", "/beacon/ping", http.StatusUnauthorized, 0, 0, false},
+		{"other path", "/live/seg1.ts?token=" + token, http.StatusOK, 1, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fetches.Store(0)
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = secret
+			cfg.Redis.BeaconPaths = []string{"/beacon/"}
+			tracker := redis.NewTracker(&cfg.Redis, nil)
+			h := NewHandler(HandlerOptions{
+				Config:       cfg,
+				Cache:        cache.NewMemory(),
+				Logger:       telemetry.NewLogger("error", "", "stdout"),
+				Metrics:      telemetry.NewMetrics(),
+				RedisTracker: tracker,
+			})
+
+			rec := serve(h, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNoContent && rec.Body.Len() != 0 {
+				t.Errorf("beacon answered with a body: %q", rec.Body.String())
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+			if got := counter(h.metrics, "beacon"); got != tt.wantBeacon {
+				t.Errorf("beacon counter %d, want %d", got, tt.wantBeacon)
+			}
+			if tracked := tracker.GetPlayerInfo("player-1") != nil; tracked != tt.wantTracked {
+				t.Errorf("player tracked = %v, want %v", tracked, tt.wantTracked)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+// Degradable cache access
+//
+// Keeps serving when the cache backend fails:
+// - Backend errors are treated as misses
+// - Requests proceed to the origin
+// - Degradation logged once per outage and exposed as a gauge
+
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+)
+
+// cacheGet looks up a cached response. A failing backend is reported as a
+// miss so the request is served from the origin instead.
+func (h *Handler) cacheGet(r *http.Request, key cache.Key) (interface{}, bool) {
+	value, found, err := cache.GetChecked(r.Context(), h.cache, key)
+	if err != nil {
+		h.cacheFailed("get", err)
+		return nil, false
+	}
+	h.cacheHealthy()
+	return value, found
+}
+
+// cacheSet stores a response, outliving the client's request. A failing
+// backend only skips caching.
+func (h *Handler) cacheSet(r *http.Request, key cache.Key, value interface{}, ttl time.Duration) {
+	if err := cache.SetChecked(cacheContext(r), h.cache, key, value, ttl); err != nil {
+		h.cacheFailed("set", err)
+		return
+	}
+	h.cacheHealthy()
+}
+
+// cacheStore caches a response for ttl, keeping positive entries for
+// staleTTL longer as stale copies, and wakes requests waiting on the key's
+// fetch
+func (h *Handler) cacheStore(r *http.Request, key cache.Key, entry *cachedResponse, ttl time.Duration) {
+	entry.StoredAt = h.clock.Now()
+	if staleTTL := h.config.Cache.StaleTTL; staleTTL > 0 && ttl > 0 && !entry.isNegative() {
+		entry.FreshUntil = entry.StoredAt.Add(ttl)
+		ttl += staleTTL
+	}
+	h.cacheSet(r, key, entry, ttl)
+	h.fetchLocks.done(key)
+}
+
+// cacheFailed records a cache backend error, logging when the handler
+// starts degrading to the origin
+func (h *Handler) cacheFailed(op string, err error) {
+	h.metrics.IncCounter("cache.backend_error." + op)
+	if !h.cacheDegraded.Swap(true) {
+		h.logger.Warn("Cache backend unavailable, serving from origin", "op", op, "error", err.Error())
+		h.metrics.SetGauge("cache.degraded", 1)
+	}
+}
+
+// cacheHealthy records a successful cache operation, logging when the
+// backend recovers from an outage
+func (h *Handler) cacheHealthy() {
+	if h.cacheDegraded.Swap(false) {
+		h.logger.Info("Cache backend recovered")
+		h.metrics.SetGauge("cache.degraded", 0)
+	}
+}
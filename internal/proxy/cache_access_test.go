@@ -0,0 +1,72 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+func TestHandlerDegradesWhenCacheFails(t *testing.T) {
+	var fetches atomic.Int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.Cache.Enabled = true
+	backend := cache.NewFake()
+	metrics := telemetry.NewMetrics()
+	h := NewHandler(HandlerOptions{
+		Config:  cfg,
+		Cache:   backend,
+		Logger:  telemetry.NewLogger("error", "", "stdout"),
+		Metrics: metrics,
+	})
+
+	// Requests run in order: the backend fails, then recovers
+	tests := []struct {
+		name         string
+		backendErr   error
+		wantCache    string
+		wantFetches  int32
+		wantDegraded float64
+	}{
+		{"backend down", cache.ErrBackendUnavailable, "MISS", 1, 1},
+		{"still down", cache.ErrBackendUnavailable, "MISS", 2, 1},
+		{"recovered", nil, "MISS", 3, 0},
+		{"cached after recovery", nil, "HIT", 3, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend.FailWith(tt.backendErr)
+			rec := serve(h, "/live/seg1.ts")
+
+			if rec.Code != http.StatusOK || rec.Body.String() != "segment" {
+				t.Fatalf("status %d body %q, want the origin segment", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache %q, want %q", got, tt.wantCache)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+			dump := metrics.(*telemetry.SimpleMetrics).DumpMetrics()
+			if got := dump["gauge_cache.degraded"]; got != tt.wantDegraded {
+				t.Errorf("cache.degraded = %v, want %v", got, tt.wantDegraded)
+			}
+		})
+	}
+
+	dump := metrics.(*telemetry.SimpleMetrics).DumpMetrics()
+	if dump["counter_cache.backend_error.get"] != 2 || dump["counter_cache.backend_error.set"] != 2 {
+		t.Errorf("backend errors get=%v set=%v, want 2 each", dump["counter_cache.backend_error.get"], dump["counter_cache.backend_error.set"])
+	}
+}
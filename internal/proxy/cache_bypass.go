@@ -0,0 +1,111 @@
+// Forced cache bypass
+//
+// Lets trusted clients skip the cache for a request:
+// - Requested by header or query flag
+// - Honoured only for allowed client networks or the admin token
+// - The flag and admin token never reach the origin or the cache key
+// - The fetched response still refreshes the cache
+
+package proxy
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+const (
+	// cacheBypassValue is the header value and query flag value requesting
+	// a bypass
+	cacheBypassValue = "bypass"
+
+	// adminTokenHeader carries the admin token on proxied requests, where
+	// Authorization may hold the viewer's token
+	adminTokenHeader = "X-Ilinden-Admin-Token"
+)
+
+// cacheBypass reports whether the request asks to skip the cache and is
+// allowed to. The bypass header, query flag and admin token are removed
+// from the returned request whether or not a bypass is honoured, so they
+// are neither forwarded to the origin nor part of the cache key.
+func (h *Handler) cacheBypass(r *http.Request) (*http.Request, bool) {
+	cfg := &h.config.Cache.Bypass
+	if !cfg.Enabled {
+		return r, false
+	}
+
+	requested := strings.EqualFold(r.Header.Get(cfg.Header), cacheBypassValue)
+	adminToken := r.Header.Get(adminTokenHeader)
+	if cfg.Param != "" {
+		if rest, value, found := cutQueryParam(r.URL.RawQuery, cfg.Param); found {
+			requested = requested || isBypassFlag(value)
+			r = r.Clone(r.Context())
+			r.URL.RawQuery = rest
+		}
+	}
+	if r.Header.Get(cfg.Header) != "" || adminToken != "" {
+		r = r.Clone(r.Context())
+		r.Header.Del(cfg.Header)
+		r.Header.Del(adminTokenHeader)
+	}
+	if !requested {
+		return r, false
+	}
+
+	if !h.bypassAllowed(r, adminToken) {
+		h.metrics.IncCounter("cache.bypass.denied")
+		return r, false
+	}
+	h.metrics.IncCounter("cache.bypass")
+	return r, true
+}
+
+// bypassAllowed reports whether the client may bypass the cache: it
+// connects from an allowed network or presents the admin token
+func (h *Handler) bypassAllowed(r *http.Request, presented string) bool {
+	if utils.ContainsIP(h.bypassNets, utils.ClientIP(r, h.trustedProxies)) {
+		return true
+	}
+	token := h.config.Admin.Token
+	return token != "" && presented != "" && subtle.ConstantTimeCompare([]byte(presented), []byte(token)) == 1
+}
+
+// isBypassFlag reports whether a query flag value requests a bypass; a
+// bare flag counts
+func isBypassFlag(value string) bool {
+	switch strings.ToLower(value) {
+	case "", "1", "true", cacheBypassValue:
+		return true
+	}
+	return false
+}
+
+// cutQueryParam removes every occurrence of a parameter from a raw query,
+// leaving the rest of it as it was so the cache key is unchanged. It
+// returns the remaining query and the parameter's first value.
+func cutQueryParam(rawQuery, param string) (rest, value string, found bool) {
+	if rawQuery == "" {
+		return rawQuery, "", false
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	kept := pairs[:0]
+	for _, pair := range pairs {
+		name, v, _ := strings.Cut(pair, "=")
+		if decoded, err := url.QueryUnescape(name); err == nil {
+			name = decoded
+		}
+		if name != param {
+			kept = append(kept, pair)
+			continue
+		}
+		if !found {
+			value, _ = url.QueryUnescape(v)
+			found = true
+		}
+	}
+	return strings.Join(kept, "&"), value, found
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestCutQueryParam(t *testing.T) {
+	tests := []struct {
+		name      string
+		rawQuery  string
+		wantRest  string
+		wantValue string
+		wantFound bool
+	}{
+		{"empty", "", "", "", false},
+		{"absent", "a=1&b=2", "a=1&b=2", "", false},
+		{"only parameter", "refresh=1", "", "1", true},
+		{"keeps the rest in order", "b=2&refresh=true&a=1", "b=2&a=1", "true", true},
+		{"bare flag", "a=1&refresh", "a=1", "", true},
+		{"every occurrence removed", "refresh=1&a=1&refresh=0", "a=1", "1", true},
+		{"escaped name", "re%66resh=bypass&a=1", "a=1", "bypass", true},
+		{"prefix not matched", "refreshed=1", "refreshed=1", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, value, found := cutQueryParam(tt.rawQuery, "refresh")
+			if rest != tt.wantRest || value != tt.wantValue || found != tt.wantFound {
+				t.Errorf("cutQueryParam(%q) = %q, %q, %v, want %q, %q, %v",
+					tt.rawQuery, rest, value, found, tt.wantRest, tt.wantValue, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestHandlerCacheBypass(t *testing.T) {
+	const adminToken = "s3cret"
+
+	tests := []struct {
+		name        string
+		bypass      config.CacheBypassConfig
+		target      string
+		header      http.Header
+		wantFetches int32
+		wantDenied  int
+	}{
+		{
+			name:        "disabled",
+			bypass:      config.CacheBypassConfig{Header: "X-Ilinden-Cache", AllowedCIDRs: []string{"192.0.2.0/24"}},
+			header:      http.Header{"X-Ilinden-Cache": {"bypass"}},
+			wantFetches: 1,
+		},
+		{
+			name:        "header from allowed network",
+			bypass:      config.CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache", AllowedCIDRs: []string{"192.0.2.0/24"}},
+			header:      http.Header{"X-Ilinden-Cache": {"Bypass"}},
+			wantFetches: 2,
+		},
+		{
+			name:        "header from other network",
+			bypass:      config.CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache", AllowedCIDRs: []string{"198.51.100.0/24"}},
+			header:      http.Header{"X-Ilinden-Cache": {"bypass"}},
+			wantFetches: 1,
+			wantDenied:  1,
+		},
+		{
+			name:        "admin token",
+			bypass:      config.CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache"},
+			header:      http.Header{"X-Ilinden-Cache": {"bypass"}, "X-Ilinden-Admin-Token": {adminToken}},
+			wantFetches: 2,
+		},
+		{
+			name:        "wrong admin token",
+			bypass:      config.CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache"},
+			header:      http.Header{"X-Ilinden-Cache": {"bypass"}, "X-Ilinden-Admin-Token": {"guess"}},
+			wantFetches: 1,
+			wantDenied:  1,
+		},
+		{
+			name:        "query flag",
+			bypass:      config.CacheBypassConfig{Enabled: true, Param: "refresh", AllowedCIDRs: []string{"192.0.2.1/32"}},
+			target:      "?refresh=1",
+			wantFetches: 2,
+		},
+		{
+			name:        "query flag off",
+			bypass:      config.CacheBypassConfig{Enabled: true, Param: "refresh", AllowedCIDRs: []string{"192.0.2.1/32"}},
+			target:      "?refresh=0",
+			wantFetches: 1,
+		},
+		{
+			name:        "other header value",
+			bypass:      config.CacheBypassConfig{Enabled: true, Header: "X-Ilinden-Cache", AllowedCIDRs: []string{"192.0.2.0/24"}},
+			header:      http.Header{"X-Ilinden-Cache": {"no-store"}},
+			wantFetches: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches int32
+			var leaked int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := atomic.AddInt32(&fetches, 1)
+				if r.Header.Get("X-Ilinden-Cache") != "" || r.Header.Get("X-Ilinden-Admin-Token") != "" || r.URL.RawQuery != "" {
+					atomic.AddInt32(&leaked, 1)
+				}
+				w.Header().Set("Content-Type", "video/mp2t")
+				fmt.Fprintf(w, "version %d", n)
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Enabled = true
+			cfg.Cache.Bypass = tt.bypass
+			cfg.Admin.Token = adminToken
+			h := newTestHandler(t, cfg)
+
+			// Prime the cache, then ask for a bypass
+			if rec := serve(h, "/live/seg1.ts"); rec.Body.String() != "version 1" {
+				t.Fatalf("priming request: status %d body %q", rec.Code, rec.Body.String())
+			}
+			req := httptest.NewRequest(http.MethodGet, "/live/seg1.ts"+tt.target, nil)
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			wantBody := fmt.Sprintf("version %d", tt.wantFetches)
+			if rec.Code != http.StatusOK || rec.Body.String() != wantBody {
+				t.Fatalf("bypass request: status %d body %q, want %q", rec.Code, rec.Body.String(), wantBody)
+			}
+			if got := atomic.LoadInt32(&fetches); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+			if got := counter(h.metrics, "cache.bypass.denied"); got != tt.wantDenied {
+				t.Errorf("denied bypasses %d, want %d", got, tt.wantDenied)
+			}
+			if atomic.LoadInt32(&leaked) != 0 {
+				t.Error("bypass header, admin token or flag forwarded to the origin")
+			}
+
+			// A bypass refreshes the entry that later requests are served from
+			if rec := serve(h, "/live/seg1.ts"); rec.Body.String() != wantBody {
+				t.Errorf("next request body %q, want the refreshed %q", rec.Body.String(), wantBody)
+			}
+			if got := atomic.LoadInt32(&fetches); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times after the bypass, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
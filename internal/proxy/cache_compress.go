@@ -0,0 +1,86 @@
+// Compressed cache storage
+//
+// Rewritten playlists kept gzip-compressed in the cache:
+// - Compressed once when stored, above the compression threshold
+// - Sent as is to gzip-capable clients
+// - Decompressed on read for everyone else
+
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// gzipWriters reuses gzip writers, whose compression state dominates the
+// cost of compressing a playlist
+var gzipWriters = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(io.Discard) },
+}
+
+// compressForStorage returns the entry to cache for a rewritten playlist:
+// a gzip-compressed copy when storage compression is on and the body is
+// large enough, otherwise the entry itself
+func (h *Handler) compressForStorage(entry *cachedResponse) *cachedResponse {
+	if !h.config.Cache.CompressStorage || entry.ContentEncoding != "" || len(entry.Body) < h.config.Server.CompressMinBytes {
+		return entry
+	}
+
+	compressed, err := gzipBytes(entry.Body)
+	if err != nil {
+		return entry
+	}
+	stored := *entry
+	stored.Body = compressed
+	stored.StoredGzip = true
+	h.metrics.IncCounter("cache.compressed")
+	return &stored
+}
+
+// bodyFor returns the body to send to the client and its content coding.
+// Bodies stored compressed go to gzip clients as they are and are
+// decompressed for the others.
+func (c *cachedResponse) bodyFor(r *http.Request) ([]byte, string, error) {
+	if !c.StoredGzip {
+		return c.Body, c.ContentEncoding, nil
+	}
+	if acceptsGzip(r) {
+		return c.Body, "gzip", nil
+	}
+
+	body, err := gunzipBytes(c.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, "", nil
+}
+
+// gzipBytes compresses a body with gzip
+func gzipBytes(body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(gw)
+
+	gw.Reset(&buf)
+	if _, err := gw.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// gunzipBytes decompresses a gzip body
+func gunzipBytes(body []byte) ([]byte, error) {
+	gr, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer gr.Close()
+
+	return io.ReadAll(gr)
+}
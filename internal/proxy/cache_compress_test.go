@@ -0,0 +1,232 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// largePlaylist returns a media playlist of n segments
+func largePlaylist(n int) string {
+	var sb strings.Builder
+	sb.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:6\n")
+	for i := 0; i < n; i++ {
+		sb.WriteString("#EXTINF:6.006,\nsegment_1080p_000123.ts\n")
+	}
+	return sb.String()
+}
+
+func TestGzipBytesRoundTrip(t *testing.T) {
+	binary := make([]byte, 4096)
+	for i := range binary {
+		binary[i] = byte(i * 7)
+	}
+
+	tests := []struct {
+		name string
+		body []byte
+	}{
+		{"empty", []byte{}},
+		{"short", []byte("#EXTM3U\n")},
+		{"playlist", []byte(largePlaylist(500))},
+		{"binary", binary},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compressed, err := gzipBytes(tt.body)
+			if err != nil {
+				t.Fatalf("gzipBytes: %v", err)
+			}
+			// Compress again so a pooled writer is reused
+			if again, err := gzipBytes(tt.body); err != nil || !bytes.Equal(again, compressed) {
+				t.Errorf("second compression differs: %v", err)
+			}
+			got, err := gunzipBytes(compressed)
+			if err != nil {
+				t.Fatalf("gunzipBytes: %v", err)
+			}
+			if !bytes.Equal(got, tt.body) {
+				t.Errorf("round trip changed the body: %d bytes, want %d", len(got), len(tt.body))
+			}
+		})
+	}
+}
+
+func TestCompressForStorage(t *testing.T) {
+	playlist := []byte(largePlaylist(50))
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		minBytes   int
+		encoding   string
+		wantStored bool
+	}{
+		{"disabled", false, 100, "", false},
+		{"enabled", true, 100, "", true},
+		{"below threshold", true, len(playlist) + 1, "", false},
+		{"origin encoded", true, 100, "br", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("http://origin.invalid")
+			cfg.Cache.CompressStorage = tt.enabled
+			cfg.Server.CompressMinBytes = tt.minBytes
+			h := newTestHandler(t, cfg)
+
+			entry := &cachedResponse{Body: playlist, ContentType: "application/vnd.apple.mpegurl", ContentEncoding: tt.encoding}
+			stored := h.compressForStorage(entry)
+			if stored.StoredGzip != tt.wantStored {
+				t.Fatalf("stored compressed = %v, want %v", stored.StoredGzip, tt.wantStored)
+			}
+			if !bytes.Equal(entry.Body, playlist) || entry.StoredGzip {
+				t.Error("original entry modified")
+			}
+			if tt.wantStored && len(stored.Body) >= len(playlist) {
+				t.Errorf("stored %d bytes, not less than %d", len(stored.Body), len(playlist))
+			}
+			if stored.ContentType != entry.ContentType {
+				t.Errorf("content type %q lost", stored.ContentType)
+			}
+		})
+	}
+}
+
+func TestCachedResponseBodyFor(t *testing.T) {
+	playlist := []byte(largePlaylist(50))
+	compressed, err := gzipBytes(playlist)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		entry        cachedResponse
+		accept       string
+		wantBody     []byte
+		wantEncoding string
+		wantErr      bool
+	}{
+		{"plain entry", cachedResponse{Body: playlist}, "gzip", playlist, "", false},
+		{"origin encoded entry", cachedResponse{Body: compressed, ContentEncoding: "gzip"}, "", compressed, "gzip", false},
+		{"stored gzip to gzip client", cachedResponse{Body: compressed, StoredGzip: true}, "gzip, br", compressed, "gzip", false},
+		{"stored gzip to plain client", cachedResponse{Body: compressed, StoredGzip: true}, "", playlist, "", false},
+		{"stored gzip refused", cachedResponse{Body: compressed, StoredGzip: true}, "gzip;q=0", playlist, "", false},
+		{"corrupt stored body", cachedResponse{Body: []byte("not gzip"), StoredGzip: true}, "", nil, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/live/index.m3u8", nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept-Encoding", tt.accept)
+			}
+			body, encoding, err := tt.entry.bodyFor(r)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("bodyFor: %v, want error %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(body, tt.wantBody) || encoding != tt.wantEncoding {
+				t.Errorf("got %d bytes %q, want %d bytes %q", len(body), encoding, len(tt.wantBody), tt.wantEncoding)
+			}
+		})
+	}
+}
+
+func TestHandlerCompressedStorage(t *testing.T) {
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, largePlaylist(200))
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.Cache.Enabled = true
+	cfg.Cache.CompressStorage = true
+	cfg.Server.CompressMinBytes = 100
+	h := newTestHandler(t, cfg)
+
+	// The first response is the rewritten playlist that every later one,
+	// decompressed where needed, must match byte for byte
+	var want []byte
+	for i, accept := range []string{"", "", "gzip", "identity", "gzip"} {
+		req := httptest.NewRequest(http.MethodGet, "/live/index.m3u8", nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status %d", i, rec.Code)
+		}
+
+		body := rec.Body.Bytes()
+		gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+		if gzipped != (accept == "gzip") {
+			t.Fatalf("request %d: gzip %v for Accept-Encoding %q", i, gzipped, accept)
+		}
+		if gzipped {
+			var err error
+			if body, err = gunzipBytes(body); err != nil {
+				t.Fatalf("request %d: %v", i, err)
+			}
+		}
+		if got := rec.Header().Get("Content-Length"); got != "" && got != strconv.Itoa(rec.Body.Len()) {
+			t.Errorf("request %d: Content-Length %s for %d bytes", i, got, rec.Body.Len())
+		}
+		if want == nil {
+			want = body
+			if !strings.HasPrefix(string(want), "#EXTM3U\n") {
+				t.Fatalf("not a playlist:\n%s", want)
+			}
+		} else if !bytes.Equal(body, want) {
+			t.Errorf("request %d: body differs from the first response", i)
+		}
+	}
+
+	if got := atomic.LoadInt32(&fetches); got != 1 {
+		t.Errorf("origin fetched %d times, want 1", got)
+	}
+	if got := counter(h.metrics, "cache.compressed"); got != 1 {
+		t.Errorf("compressed for storage %d times, want 1", got)
+	}
+}
+
+// BenchmarkCompressedStorage measures the cost of storing a rewritten
+// playlist compressed and of serving it to a client without gzip, and
+// reports the stored size against the original
+func BenchmarkCompressedStorage(b *testing.B) {
+	playlist := []byte(largePlaylist(1000))
+	compressed, err := gzipBytes(playlist)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("store", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(playlist)))
+		for i := 0; i < b.N; i++ {
+			if _, err := gzipBytes(playlist); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(len(playlist))/float64(len(compressed)), "ratio")
+	})
+	b.Run("read", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(playlist)))
+		for i := 0; i < b.N; i++ {
+			if _, err := gunzipBytes(compressed); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,134 @@
+// Cached response representation
+//
+// Values stored in the response cache:
+// - Body bytes
+// - Content headers needed to replay the response
+// - Content-encoding awareness
+// - Bodies compressed for storage
+// - Byte-range awareness
+// - Insertion time for Age headers
+// - Freshness for stale copies kept past their TTL
+
+package proxy
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// knownEncodings are the content codings an origin may apply to a body
+// that is passed through to the client unmodified
+var knownEncodings = []string{"br", "deflate", "gzip", "zstd"}
+
+// cachedResponse is a response body stored in the cache together with the
+// headers needed to serve it again
+type cachedResponse struct {
+	Body            []byte
+	ContentType     string
+	ContentEncoding string
+	ContentRange    string    // Set for partial content, replayed as 206, and for cached 416s
+	StatusCode      int       // Non-zero for negatively cached origin errors
+	StoredAt        time.Time // When the entry was cached
+	FreshUntil      time.Time // End of the TTL for entries kept as stale copies; zero is always fresh
+	StoredGzip      bool      // Body is gzip-compressed for storage rather than by the origin
+}
+
+// Size returns the size of the cached body in bytes
+func (c *cachedResponse) Size() int {
+	return len(c.Body)
+}
+
+// writeAge sets the Age header, and X-Cache-Age when enabled, from the time
+// the entry has spent in the cache as of now
+func (c *cachedResponse) writeAge(header http.Header, now time.Time, cacheAgeHeader bool) {
+	if c.StoredAt.IsZero() {
+		return
+	}
+	age := strconv.FormatInt(int64(now.Sub(c.StoredAt)/time.Second), 10)
+	header.Set("Age", age)
+	if cacheAgeHeader {
+		header.Set("X-Cache-Age", age)
+	}
+}
+
+// fresh reports whether the entry is still within its TTL at now rather
+// than a stale copy kept for stampede protection
+func (c *cachedResponse) fresh(now time.Time) bool {
+	return c.FreshUntil.IsZero() || now.Before(c.FreshUntil)
+}
+
+// isNegative reports whether the entry records an origin error response
+func (c *cachedResponse) isNegative() bool {
+	return c.StatusCode >= http.StatusBadRequest
+}
+
+// playlistCacheKey returns the cache key for a processed playlist
+func (h *Handler) playlistCacheKey(targetURL *url.URL, token string) cache.Key {
+	return h.urlCacheKey("playlist:", targetURL) + cache.Key(":"+token)
+}
+
+// segmentCacheKey returns the cache key for raw content. Raw content keeps
+// the origin's encoding, which was negotiated with the client's
+// Accept-Encoding, so the key includes the client's encoding variant.
+func (h *Handler) segmentCacheKey(targetURL *url.URL, token, variant string) cache.Key {
+	return h.urlCacheKey("segment:", targetURL) + cache.Key(":"+token+"|"+variant)
+}
+
+// urlCacheKey keys an origin URL, sorting its query parameters when
+// normalizeQuery is set so reordered queries share an entry
+func (h *Handler) urlCacheKey(prefix string, targetURL *url.URL) cache.Key {
+	opts := []cache.KeyOption{cache.WithPrefix(prefix)}
+	if !h.config.Cache.NormalizeQuery {
+		opts = append(opts, cache.DisableQueryNormalization())
+	}
+	return cache.FromURL(targetURL.String(), opts...)
+}
+
+// servableTo reports whether the cached body can be sent to the client,
+// i.e. the client accepts the encoding the body is stored in
+func (c *cachedResponse) servableTo(r *http.Request) bool {
+	if c.ContentEncoding == "" || strings.EqualFold(c.ContentEncoding, "identity") {
+		return true
+	}
+	return utils.AcceptsEncoding(r.Header.Get("Accept-Encoding"), c.ContentEncoding)
+}
+
+// encodingVariant returns a cache key suffix identifying the set of content
+// codings the client accepts. Origin responses are negotiated with the
+// client's Accept-Encoding, so clients in different sets must not share
+// cached bodies.
+func encodingVariant(r *http.Request) string {
+	acceptEncoding := r.Header.Get("Accept-Encoding")
+	if acceptEncoding == "" {
+		return "identity"
+	}
+
+	var accepted []string
+	for _, enc := range knownEncodings {
+		if utils.AcceptsEncoding(acceptEncoding, enc) {
+			accepted = append(accepted, enc)
+		}
+	}
+	if len(accepted) == 0 {
+		return "identity"
+	}
+	return strings.Join(accepted, ",")
+}
+
+// rangeVariant returns a cache key suffix identifying the byte range the
+// client asked for, so partial bodies (such as an EXT-X-MAP init segment
+// with a BYTERANGE) are never served for another range or the whole
+// resource
+func rangeVariant(r *http.Request) string {
+	byteRange := strings.ReplaceAll(r.Header.Get("Range"), " ", "")
+	if byteRange == "" {
+		return ""
+	}
+	return "|range=" + byteRange
+}
@@ -0,0 +1,109 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEncodingVariant(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "identity"},
+		{"identity", "identity"},
+		{"gzip", "gzip"},
+		{"gzip, br", "br,gzip"},
+		{"br, gzip", "br,gzip"},
+		{"gzip, br;q=0", "gzip"},
+		{"*", "br,deflate,gzip,zstd"},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/seg.ts", nil)
+		r.Header.Set("Accept-Encoding", tt.accept)
+		if got := encodingVariant(r); got != tt.want {
+			t.Errorf("encodingVariant(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestCachedResponseServableTo(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		accept   string
+		want     bool
+	}{
+		{"plain body", "", "", true},
+		{"identity body", "identity", "", true},
+		{"gzip to gzip client", "gzip", "gzip, br", true},
+		{"gzip to identity client", "gzip", "", false},
+		{"gzip to brotli client", "gzip", "br", false},
+		{"gzip refused", "gzip", "gzip;q=0", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/seg.ts", nil)
+			r.Header.Set("Accept-Encoding", tt.accept)
+			entry := &cachedResponse{ContentEncoding: tt.encoding}
+			if got := entry.servableTo(r); got != tt.want {
+				t.Errorf("servableTo = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerNeverSendsGzipToIdentityClients(t *testing.T) {
+	const body = "segment payload"
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		if strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Write(encode(t, "gzip", []byte(body)))
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer origin.Close()
+	h := newTestHandler(t, testConfig(origin.URL))
+
+	// Each request follows the previous ones, so the cache is warm with
+	// whatever the earlier clients were sent
+	tests := []struct {
+		name         string
+		accept       string
+		wantEncoding string
+		wantCache    string
+	}{
+		{"gzip client", "gzip", "gzip", "MISS"},
+		{"gzip client again", "gzip", "gzip", "HIT"},
+		{"identity client", "", "", "MISS"},
+		{"brotli-only client", "br", "", "MISS"},
+		{"identity client again", "", "", "HIT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/live/seg1.ts", nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept-Encoding", tt.accept)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+				t.Errorf("Content-Encoding = %q, want %q", got, tt.wantEncoding)
+			}
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if tt.wantEncoding == "" && rec.Body.String() != body {
+				t.Errorf("body = %q, want %q", rec.Body.String(), body)
+			}
+		})
+	}
+}
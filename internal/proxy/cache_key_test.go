@@ -0,0 +1,49 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandlerNormalizesCacheKeyQuery(t *testing.T) {
+	tests := []struct {
+		name        string
+		normalize   bool
+		first       string
+		second      string
+		wantFetches int32
+	}{
+		{"reordered parameters", true, "/live/seg1.ts?a=1&b=2", "/live/seg1.ts?b=2&a=1", 1},
+		{"reordered repeated values", true, "/live/seg1.ts?a=2&a=1", "/live/seg1.ts?a=1&a=2", 1},
+		{"different values", true, "/live/seg1.ts?a=1", "/live/seg1.ts?a=2", 2},
+		{"normalization disabled", false, "/live/seg1.ts?a=1&b=2", "/live/seg1.ts?b=2&a=1", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&fetches, 1)
+				w.Header().Set("Content-Type", "video/mp2t")
+				w.Write([]byte("segment"))
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Enabled = true
+			cfg.Cache.NormalizeQuery = tt.normalize
+			h := newTestHandler(t, cfg)
+
+			for _, target := range []string{tt.first, tt.second} {
+				if rec := serve(h, target); rec.Code != http.StatusOK {
+					t.Fatalf("%s: status %d", target, rec.Code)
+				}
+			}
+			if got := atomic.LoadInt32(&fetches); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+// Playlist capture for debugging
+//
+// Sampled logging of rewritten playlists:
+// - Opt-in, with a configurable sample rate
+// - Parse warnings alongside the body
+// - Tokens masked, bodies capped in size
+
+package proxy
+
+import (
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// playlistCapture logs a sample of rewritten playlists
+type playlistCapture struct {
+	rate        float64
+	maxBytes    int
+	tokenParams []string
+	logger      telemetry.Logger
+	sample      func() float64 // Uniform in [0, 1)
+}
+
+// newPlaylistCapture creates a capture for the debug settings, nil when
+// capturing is disabled
+func newPlaylistCapture(cfg *config.DebugConfig, tokenParams []string, logger telemetry.Logger) *playlistCapture {
+	if cfg.CaptureSampleRate <= 0 {
+		return nil
+	}
+
+	maxBytes := cfg.CaptureMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = 4096
+	}
+	return &playlistCapture{
+		rate:        cfg.CaptureSampleRate,
+		maxBytes:    maxBytes,
+		tokenParams: tokenParams,
+		logger:      logger,
+		sample:      rand.Float64,
+	}
+}
+
+// playlist logs a rewritten playlist and its parse warnings when the request
+// is sampled. Safe to call on a nil capture.
+func (c *playlistCapture) playlist(r *http.Request, body []byte, warnings []string) {
+	if c == nil || c.sample() >= c.rate {
+		return
+	}
+
+	captured := utils.RedactText(string(body), c.tokenParams)
+	truncated := len(captured) > c.maxBytes
+	if truncated {
+		captured = captured[:c.maxBytes]
+	}
+
+	c.logger.Info("Playlist capture",
+		"path", utils.RedactPath(r.URL.Path),
+		"size", strconv.Itoa(len(body)),
+		"truncated", strconv.FormatBool(truncated),
+		"warnings", utils.RedactText(strings.Join(warnings, "; "), c.tokenParams),
+		"body", captured,
+	)
+}
@@ -0,0 +1,74 @@
+package proxy
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// infoRecorder records the fields of info lines
+type infoRecorder struct {
+	telemetry.Logger
+	lines []map[string]interface{}
+}
+
+func (l *infoRecorder) Info(msg string, args ...interface{}) {
+	fields := map[string]interface{}{"msg": msg}
+	for i := 0; i+1 < len(args); i += 2 {
+		fields[args[i].(string)] = args[i+1]
+	}
+	l.lines = append(l.lines, fields)
+}
+
+func TestPlaylistCapture(t *testing.T) {
+	const body = "#EXTM3U\n#EXTINF:6,\nseg1.ts?token=secret\n"
+
+	tests := []struct {
+		name          string
+		rate          float64
+		maxBytes      int
+		sample        float64
+		wantCaptured  bool
+		wantBody      string
+		wantTruncated string
+	}{
+		{"disabled", 0, 0, 0, false, "", ""},
+		{"sampled", 0.5, 0, 0.1, true, "#EXTM3U\n#EXTINF:6,\nseg1.ts?token=[REDACTED]\n", "false"},
+		{"not sampled", 0.5, 0, 0.7, false, "", ""},
+		{"always", 1, 0, 0.99, true, "#EXTM3U\n#EXTINF:6,\nseg1.ts?token=[REDACTED]\n", "false"},
+		{"size cap", 1, 10, 0, true, "#EXTM3U\n#E", "true"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &infoRecorder{Logger: telemetry.NewLogger("error", "", "stdout")}
+			capture := newPlaylistCapture(&config.DebugConfig{CaptureSampleRate: tt.rate, CaptureMaxBytes: tt.maxBytes}, []string{"token"}, logger)
+			if capture != nil {
+				capture.sample = func() float64 { return tt.sample }
+			} else if tt.rate > 0 {
+				t.Fatal("capture disabled with a positive sample rate")
+			}
+
+			capture.playlist(httptest.NewRequest("GET", "/live/index.m3u8?token=secret", nil), []byte(body), []string{"uri seg1.ts?token=secret too long"})
+
+			if captured := len(logger.lines) > 0; captured != tt.wantCaptured {
+				t.Fatalf("captured = %v, want %v", captured, tt.wantCaptured)
+			}
+			if !tt.wantCaptured {
+				return
+			}
+			line := logger.lines[0]
+			if line["body"] != tt.wantBody || line["truncated"] != tt.wantTruncated {
+				t.Errorf("body %q truncated %v, want %q %v", line["body"], line["truncated"], tt.wantBody, tt.wantTruncated)
+			}
+			for key, value := range line {
+				if strings.Contains(value.(string), "secret") {
+					t.Errorf("%s leaks the token: %q", key, value)
+				}
+			}
+		})
+	}
+}
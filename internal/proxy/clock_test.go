@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/jwt"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+	"github.com/ilijajolevski/ilinden/pkg/jwtheader"
+)
+
+// newClockedHandler builds a test handler whose time comes from clock
+func newClockedHandler(t *testing.T, cfg *config.Config, clock utils.Clock) *Handler {
+	t.Helper()
+	return NewHandler(HandlerOptions{
+		Config:  cfg,
+		Cache:   cache.NewMemory(),
+		Logger:  telemetry.NewLogger("error", "", "stdout"),
+		Metrics: telemetry.NewMetrics(),
+		Clock:   clock,
+	})
+}
+
+func TestHandlerTokenTTLUsesClock(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expiresIn := func(d time.Duration) *jwt.Claims {
+		return &jwt.Claims{JWTClaims: &jwtheader.JWTClaims{ExpirationTime: now.Add(d).Unix()}}
+	}
+
+	tests := []struct {
+		name   string
+		clamp  bool
+		ttl    time.Duration
+		claims *jwt.Claims
+		want   time.Duration
+		wantOK bool
+	}{
+		{"clamp disabled", false, time.Minute, expiresIn(10 * time.Second), time.Minute, true},
+		{"no claims", true, time.Minute, nil, time.Minute, true},
+		{"no expiry", true, time.Minute, &jwt.Claims{JWTClaims: &jwtheader.JWTClaims{}}, time.Minute, true},
+		{"expiry after TTL", true, time.Minute, expiresIn(time.Hour), time.Minute, true},
+		{"expiry before TTL", true, time.Minute, expiresIn(10 * time.Second), 10 * time.Second, true},
+		{"unbounded TTL", true, 0, expiresIn(30 * time.Second), 30 * time.Second, true},
+		{"expired token", true, time.Minute, expiresIn(-time.Second), 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("http://origin.invalid")
+			cfg.Cache.ClampTTLToToken = tt.clamp
+			h := newClockedHandler(t, cfg, utils.NewFakeClock(now))
+
+			got, ok := h.tokenTTL(tt.ttl, tt.claims)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("tokenTTL = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestHandlerCacheAgeAndFreshnessUseClock(t *testing.T) {
+	const media = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"
+
+	tests := []struct {
+		name           string
+		advance        time.Duration
+		cacheAgeHeader bool
+		wantCache      string
+		wantAge        string
+		wantFetches    int32
+	}{
+		{"fresh hit", 1500 * time.Millisecond, false, "HIT", "1", 1},
+		{"fresh hit with X-Cache-Age", 1500 * time.Millisecond, true, "HIT", "1", 1},
+		{"stale copy refetched", 5 * time.Second, false, "MISS", "", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+				io.WriteString(w, media)
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.TTLMedia = 2 * time.Second
+			cfg.Cache.StaleTTL = 10 * time.Second
+			cfg.Cache.CacheAgeHeader = tt.cacheAgeHeader
+			clock := utils.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+			h := newClockedHandler(t, cfg, clock)
+
+			if rec := serve(h, "/live/a.m3u8"); rec.Code != http.StatusOK {
+				t.Fatalf("first request: status %d", rec.Code)
+			}
+			clock.Advance(tt.advance)
+			rec := serve(h, "/live/a.m3u8")
+
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if got := rec.Header().Get("Age"); got != tt.wantAge {
+				t.Errorf("Age = %q, want %q", got, tt.wantAge)
+			}
+			wantCacheAge := ""
+			if tt.cacheAgeHeader {
+				wantCacheAge = tt.wantAge
+			}
+			if got := rec.Header().Get("X-Cache-Age"); got != wantCacheAge {
+				t.Errorf("X-Cache-Age = %q, want %q", got, wantCacheAge)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
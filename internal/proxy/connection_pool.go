@@ -15,11 +15,13 @@ import (
 	"time"
 
 	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // ConnectionPool manages HTTP client connection pooling
 type ConnectionPool struct {
 	transport     *http.Transport
+	roundTripper  http.RoundTripper // Shared by all clients when set
 	originClients map[string]*http.Client
 	config        *config.OriginConfig
 	mu            sync.RWMutex
@@ -27,6 +29,9 @@ type ConnectionPool struct {
 
 // NewConnectionPool creates a new connection pool
 func NewConnectionPool(config *config.OriginConfig) *ConnectionPool {
+	// Client certificates and CAs are validated with the config
+	tlsConfig, _ := config.TLS.ClientConfig()
+
 	// Create base transport
 	transport := &http.Transport{
 		Proxy: http.ProxyFromEnvironment,
@@ -41,6 +46,7 @@ func NewConnectionPool(config *config.OriginConfig) *ConnectionPool {
 		IdleConnTimeout:       config.IdleConnTimeout,
 		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
 		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		TLSClientConfig:       tlsConfig,
 	}
 
 	return &ConnectionPool{
@@ -50,8 +56,35 @@ func NewConnectionPool(config *config.OriginConfig) *ConnectionPool {
 	}
 }
 
-// GetClient returns a client for the given origin
+// SetTransport makes every client use rt instead of a pooled transport.
+// It must be called before clients are handed out.
+func (p *ConnectionPool) SetTransport(rt http.RoundTripper) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.roundTripper = rt
+}
+
+// SetDialContext replaces how pooled transports dial origin connections.
+// It must be called before clients are handed out.
+func (p *ConnectionPool) SetDialContext(dial DialContextFunc) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transport.DialContext = dial
+}
+
+// newTransport returns the round tripper for a new client
+func (p *ConnectionPool) newTransport() http.RoundTripper {
+	if p.roundTripper != nil {
+		return p.roundTripper
+	}
+	return p.transport.Clone()
+}
+
+// GetClient returns a client for the given origin host. Equivalent spellings
+// of a host, such as IPv6 literals with or without brackets, share a client.
 func (p *ConnectionPool) GetClient(originHost string) *http.Client {
+	originHost = utils.CanonicalHost("", originHost)
+
 	p.mu.RLock()
 	client, exists := p.originClients[originHost]
 	p.mu.RUnlock()
@@ -69,12 +102,9 @@ func (p *ConnectionPool) GetClient(originHost string) *http.Client {
 		return client
 	}
 
-	// Create a clone of the transport
-	transportClone := p.transport.Clone()
-
-	// Create a new client
+	// Create a new client with its own transport
 	client = &http.Client{
-		Transport: transportClone,
+		Transport: p.newTransport(),
 		Timeout:   p.config.Timeout,
 	}
 
@@ -94,8 +124,11 @@ func (p *ConnectionPool) CloseIdleConnections() {
 
 // GetDefaultClient returns a default client
 func (p *ConnectionPool) GetDefaultClient() *http.Client {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
 	return &http.Client{
-		Transport: p.transport.Clone(),
+		Transport: p.newTransport(),
 		Timeout:   p.config.Timeout,
 	}
 }
\ No newline at end of file
@@ -0,0 +1,70 @@
+// Origin content decoding
+//
+// Decodes compressed origin bodies that must be parsed:
+// - gzip, deflate and brotli content codings
+// - Identity bodies returned unchanged
+// - Unknown codings reported as errors
+// - Decoded size capped against compression bombs
+
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// ErrDecodedTooLarge is returned when a decoded body exceeds its limit
+var ErrDecodedTooLarge = errors.New("decoded body too large")
+
+// decodeBody returns body with the given content coding removed, failing
+// once the decoded content exceeds maxBytes (0 means no limit)
+func decodeBody(body []byte, contentEncoding string, maxBytes int64) ([]byte, error) {
+	var reader io.Reader
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		gz, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		reader = gz
+	case "deflate":
+		// "deflate" is zlib-wrapped per RFC 9110, but some servers send raw
+		// deflate data
+		zr, err := zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			fr := flate.NewReader(bytes.NewReader(body))
+			defer fr.Close()
+			reader = fr
+		} else {
+			defer zr.Close()
+			reader = zr
+		}
+	case "br":
+		reader = brotli.NewReader(bytes.NewReader(body))
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+	}
+
+	if maxBytes <= 0 {
+		return io.ReadAll(reader)
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(decoded)) > maxBytes {
+		return nil, fmt.Errorf("%w: over %d bytes", ErrDecodedTooLarge, maxBytes)
+	}
+	return decoded, nil
+}
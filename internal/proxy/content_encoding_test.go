@@ -0,0 +1,124 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encode compresses data with the given content coding
+func encode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		return data
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	playlist := []byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n")
+	bomb := bytes.Repeat([]byte{'#'}, 1<<20)
+
+	tests := []struct {
+		name     string
+		coding   string
+		header   string
+		data     []byte
+		maxBytes int64
+		wantErr  error
+	}{
+		{name: "identity", coding: "", header: "", data: playlist},
+		{name: "gzip", coding: "gzip", header: "gzip", data: playlist},
+		{name: "x-gzip", coding: "gzip", header: " X-GZIP ", data: playlist},
+		{name: "zlib deflate", coding: "deflate", header: "deflate", data: playlist},
+		{name: "raw deflate", coding: "raw-deflate", header: "deflate", data: playlist},
+		{name: "brotli", coding: "br", header: "br", data: playlist},
+		{name: "exactly at limit", coding: "gzip", header: "gzip", data: playlist, maxBytes: int64(len(playlist))},
+		{name: "gzip bomb", coding: "gzip", header: "gzip", data: bomb, maxBytes: 64 << 10, wantErr: ErrDecodedTooLarge},
+		{name: "brotli bomb", coding: "br", header: "br", data: bomb, maxBytes: 64 << 10, wantErr: ErrDecodedTooLarge},
+		{name: "no limit", coding: "gzip", header: "gzip", data: bomb},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBody(encode(t, tt.coding, tt.data), tt.header, tt.maxBytes)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("got %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeBody: %v", err)
+			}
+			if !bytes.Equal(got, tt.data) {
+				t.Errorf("decoded %d bytes, want %d", len(got), len(tt.data))
+			}
+		})
+	}
+}
+
+func TestDecodeBodyUnsupported(t *testing.T) {
+	if _, err := decodeBody([]byte("x"), "compress", 0); err == nil {
+		t.Fatal("unsupported coding decoded")
+	}
+}
+
+func TestHandlerRejectsOversizedDecodedPlaylist(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" + strings.Repeat("#EXTINF:6,\nseg.ts\n", 4096)
+	compressed := encode(t, "gzip", []byte(playlist))
+
+	tests := []struct {
+		name       string
+		maxBytes   int64
+		wantStatus int
+	}{
+		{"within limit", int64(len(playlist)), http.StatusOK},
+		{"over limit", int64(len(playlist)) - 1, http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+				w.Header().Set("Content-Encoding", "gzip")
+				w.Write(compressed)
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Origin.MaxDecodedBytes = tt.maxBytes
+			// A client accepting gzip keeps the origin from being decoded
+			// by the transport
+			req := httptest.NewRequest(http.MethodGet, "/live/index.m3u8", nil)
+			req.Header.Set("Accept-Encoding", "gzip")
+			rec := httptest.NewRecorder()
+			newTestHandler(t, cfg).ServeHTTP(rec, req)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
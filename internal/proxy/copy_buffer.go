@@ -0,0 +1,48 @@
+// Streaming copy buffers
+//
+// Pooled buffers for copying streamed bodies:
+// - Configurable buffer size
+// - Buffers reused across responses to spare the GC
+// - Returned to the pool however the copy ends
+
+package proxy
+
+import (
+	"io"
+	"sync"
+)
+
+// copyBuffers pools the buffers used to copy streamed response bodies
+type copyBuffers struct {
+	pool sync.Pool
+}
+
+// newCopyBuffers creates a pool of size byte buffers, or nil if size
+// disables pooling
+func newCopyBuffers(size int) *copyBuffers {
+	if size <= 0 {
+		return nil
+	}
+	return &copyBuffers{
+		pool: sync.Pool{
+			New: func() interface{} {
+				buf := make([]byte, size)
+				return &buf
+			},
+		},
+	}
+}
+
+// copy copies src to dst through a pooled buffer. Without a pool it falls
+// back to io.Copy.
+func (b *copyBuffers) copy(dst io.Writer, src io.Reader) (int64, error) {
+	if b == nil {
+		return io.Copy(dst, src)
+	}
+
+	bufp := b.pool.Get().(*[]byte)
+	defer b.pool.Put(bufp)
+
+	// Hide ReadFrom so the copy cannot bypass the pooled buffer
+	return io.CopyBuffer(struct{ io.Writer }{dst}, src, *bufp)
+}
@@ -0,0 +1,140 @@
+package proxy
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+)
+
+// readFromTrap records whether a copy bypassed the buffer via ReadFrom
+type readFromTrap struct {
+	bytes.Buffer
+	bypassed bool
+}
+
+func (w *readFromTrap) ReadFrom(r io.Reader) (int64, error) {
+	w.bypassed = true
+	return w.Buffer.ReadFrom(r)
+}
+
+func TestCopyBuffers(t *testing.T) {
+	errRead := errors.New("origin reset")
+	body := strings.Repeat("segment-", 1000)
+
+	tests := []struct {
+		name    string
+		size    int
+		src     func() io.Reader
+		want    string
+		wantErr error
+	}{
+		{"unpooled", 0, func() io.Reader { return strings.NewReader(body) }, body, nil},
+		{"small buffer", 7, func() io.Reader { return strings.NewReader(body) }, body, nil},
+		{"large buffer", 32 << 10, func() io.Reader { return strings.NewReader(body) }, body, nil},
+		{"read error", 64, func() io.Reader {
+			return io.MultiReader(strings.NewReader(body[:100]), iotest.ErrReader(errRead))
+		}, body[:100], errRead},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := &readFromTrap{}
+			n, err := newCopyBuffers(tt.size).copy(dst, tt.src())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			}
+			if n != int64(len(tt.want)) || dst.String() != tt.want {
+				t.Errorf("copied %d bytes, want %d", n, len(tt.want))
+			}
+			// Without a pool io.Copy may use ReadFrom
+			if tt.size > 0 && dst.bypassed {
+				t.Error("copy bypassed the pooled buffer")
+			}
+		})
+	}
+}
+
+func TestCopyBuffersReturnedOnError(t *testing.T) {
+	const copies = 100
+	var allocated int32
+	b := newCopyBuffers(64)
+	b.pool.New = func() interface{} {
+		atomic.AddInt32(&allocated, 1)
+		buf := make([]byte, 64)
+		return &buf
+	}
+
+	for i := 0; i < copies; i++ {
+		src := io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(io.ErrUnexpectedEOF))
+		if _, err := b.copy(io.Discard, src); err == nil {
+			t.Fatal("copy swallowed the read error")
+		}
+	}
+
+	// Failed copies still return their buffer; the race detector makes the
+	// pool drop some at random, so only require most to be reused
+	if got := atomic.LoadInt32(&allocated); got > copies/2 {
+		t.Errorf("%d buffers allocated for %d sequential copies", got, copies)
+	}
+}
+
+func TestHandlerStreamsThroughPooledBuffers(t *testing.T) {
+	body := strings.Repeat("x", 100<<10)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Write([]byte(body))
+	}))
+	defer origin.Close()
+
+	for _, size := range []int{0, 1024, 32 << 10} {
+		cfg := testConfig(origin.URL)
+		cfg.Cache.StreamThresholdBytes = 1024
+		cfg.Cache.StreamBufferBytes = size
+		h := newTestHandler(t, cfg)
+
+		rec := serve(h, "/live/seg1.ts")
+		if rec.Code != http.StatusOK || rec.Body.String() != body {
+			t.Errorf("buffer %d: status %d, %d bytes", size, rec.Code, rec.Body.Len())
+		}
+		if counter(h.metrics, "response.streamed") != 1 {
+			t.Errorf("buffer %d: response not streamed", size)
+		}
+	}
+}
+
+// BenchmarkStreamCopy compares allocations of pooled and per-response
+// buffers under concurrent streaming
+func BenchmarkStreamCopy(b *testing.B) {
+	segment := bytes.Repeat([]byte{0x47}, 1<<20)
+
+	for _, bc := range []struct {
+		name string
+		size int
+	}{
+		{"per-response", 0},
+		{"pooled", 32 << 10},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			buffers := newCopyBuffers(bc.size)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(segment)))
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					// Hide WriterTo and ReaderFrom as a network body and
+					// response writer would
+					src := struct{ io.Reader }{bytes.NewReader(segment)}
+					dst := struct{ io.Writer }{io.Discard}
+					if _, err := buffers.copy(dst, src); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		})
+	}
+}
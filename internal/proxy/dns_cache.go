@@ -0,0 +1,151 @@
+// Origin DNS caching
+//
+// Caches origin host name resolution in the dialer:
+// - Configurable resolver address
+// - Fixed TTL, kept short so DNS failover takes effect quickly
+// - Failed lookups are never cached
+// - Entries dropped when none of their addresses can be dialed
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// Resolver looks up the addresses of a host, like net.Resolver
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dnsEntry is a cached resolution
+type dnsEntry struct {
+	addrs   []net.IPAddr
+	expires time.Time
+}
+
+// dnsCache resolves origin hosts through a resolver, reusing answers for a
+// fixed TTL
+type dnsCache struct {
+	resolver Resolver
+	ttl      time.Duration
+	clock    utils.Clock
+	metrics  telemetry.Metrics
+
+	mu      sync.Mutex
+	entries map[string]dnsEntry
+}
+
+// newDNSCache creates the DNS cache for origin connections, or returns nil
+// when caching is disabled. A nil resolver uses the configured resolver
+// address, or the system resolver when none is set.
+func newDNSCache(cfg *config.OriginDNSConfig, resolver Resolver, metrics telemetry.Metrics) *dnsCache {
+	if cfg.CacheTTL <= 0 {
+		return nil
+	}
+	if resolver == nil {
+		resolver = newResolver(cfg)
+	}
+	return &dnsCache{
+		resolver: resolver,
+		ttl:      cfg.CacheTTL,
+		clock:    utils.RealClock{},
+		metrics:  metrics,
+		entries:  make(map[string]dnsEntry),
+	}
+}
+
+// newResolver returns the resolver for the configured address: a Go
+// resolver querying that server, or the system resolver when it is empty
+func newResolver(cfg *config.OriginDNSConfig) *net.Resolver {
+	if cfg.Resolver == "" {
+		return net.DefaultResolver
+	}
+	dialer := &net.Dialer{Timeout: cfg.ResolverTimeout}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, network, cfg.Resolver)
+		},
+	}
+}
+
+// lookup returns the addresses of host, from the cache while the entry is
+// fresh. Failed lookups are not cached, so the next dial retries them.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]net.IPAddr, error) {
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	entry, found := c.entries[host]
+	c.mu.Unlock()
+	if found && now.Before(entry.expires) {
+		c.incCounter("origin.dns.hit")
+		return entry.addrs, nil
+	}
+
+	c.incCounter("origin.dns.miss")
+	addrs, err := c.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		c.incCounter("origin.dns.error")
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses", Name: host, IsNotFound: true}
+	}
+
+	c.mu.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: now.Add(c.ttl)}
+	c.mu.Unlock()
+	return addrs, nil
+}
+
+// forget drops the cached addresses of host
+func (c *dnsCache) forget(host string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, host)
+}
+
+// dialContext wraps dial so that host names are resolved through the
+// cache. Addresses are tried in order, like net.Dialer does; when none of
+// them can be dialed the entry is dropped, so a host that failed over to
+// new addresses is resolved again on the next dial.
+func (c *dnsCache) dialContext(dial DialContextFunc) DialContextFunc {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dial(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range addrs {
+			var conn net.Conn
+			conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port))
+			if err == nil {
+				return conn, nil
+			}
+			if ctx.Err() != nil {
+				return nil, err
+			}
+		}
+		c.forget(host)
+		return nil, err
+	}
+}
+
+// incCounter increments a DNS metric when metrics are configured
+func (c *dnsCache) incCounter(name string) {
+	if c.metrics != nil {
+		c.metrics.IncCounter(name)
+	}
+}
@@ -0,0 +1,237 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// stubResolver answers lookups from a fixed table, counting them
+type stubResolver struct {
+	mu      sync.Mutex
+	answers map[string][]net.IPAddr
+	err     error
+	lookups int
+}
+
+func (r *stubResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lookups++
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.answers[host], nil
+}
+
+func (r *stubResolver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.lookups
+}
+
+// ipAddrs parses IP addresses for stub answers
+func ipAddrs(ips ...string) []net.IPAddr {
+	addrs := make([]net.IPAddr, 0, len(ips))
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+	return addrs
+}
+
+// newTestDNSCache creates a DNS cache on a fake clock
+func newTestDNSCache(resolver Resolver, ttl time.Duration) (*dnsCache, *utils.FakeClock) {
+	clock := utils.NewFakeClock(time.Unix(1700000000, 0))
+	c := newDNSCache(&config.OriginDNSConfig{CacheTTL: ttl}, resolver, telemetry.NewMetrics())
+	c.clock = clock
+	return c, clock
+}
+
+func TestNewDNSCacheDisabled(t *testing.T) {
+	if c := newDNSCache(&config.OriginDNSConfig{}, &stubResolver{}, nil); c != nil {
+		t.Fatal("DNS cache created with a zero TTL")
+	}
+}
+
+func TestDNSCacheLookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		resolverErr error
+		answers     []net.IPAddr
+		wait        time.Duration
+		wantLookups int
+		wantErr     bool
+	}{
+		{"fresh answer reused", nil, ipAddrs("192.0.2.10"), 9 * time.Second, 1, false},
+		{"expired answer resolved again", nil, ipAddrs("192.0.2.10"), 10 * time.Second, 2, false},
+		{"failed lookup not cached", errors.New("servfail"), nil, 0, 2, true},
+		{"empty answer not cached", nil, nil, 0, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &stubResolver{answers: map[string][]net.IPAddr{"origin.test": tt.answers}, err: tt.resolverErr}
+			c, clock := newTestDNSCache(resolver, 10*time.Second)
+
+			for i := 0; i < 2; i++ {
+				addrs, err := c.lookup(context.Background(), "origin.test")
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("lookup %d: error %v, want error %v", i, err, tt.wantErr)
+				}
+				if !tt.wantErr && !reflect.DeepEqual(addrs, tt.answers) {
+					t.Errorf("lookup %d: %v, want %v", i, addrs, tt.answers)
+				}
+				clock.Advance(tt.wait)
+			}
+
+			if resolver.count() != tt.wantLookups {
+				t.Errorf("resolver queried %d times, want %d", resolver.count(), tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestDNSCacheDialContext(t *testing.T) {
+	tests := []struct {
+		name        string
+		addr        string
+		unreachable []string
+		wantDialed  []string
+		wantErr     bool
+		wantLookups int
+	}{
+		{
+			name:        "first address",
+			addr:        "origin.test:443",
+			wantDialed:  []string{"192.0.2.10:443", "192.0.2.10:443"},
+			wantLookups: 1,
+		},
+		{
+			name:        "falls through to the next address",
+			addr:        "origin.test:443",
+			unreachable: []string{"192.0.2.10:443"},
+			wantDialed:  []string{"192.0.2.10:443", "192.0.2.11:443", "192.0.2.10:443", "192.0.2.11:443"},
+			wantLookups: 1,
+		},
+		{
+			name:        "unreachable addresses resolved again",
+			addr:        "origin.test:443",
+			unreachable: []string{"192.0.2.10:443", "192.0.2.11:443"},
+			wantDialed:  []string{"192.0.2.10:443", "192.0.2.11:443", "192.0.2.10:443", "192.0.2.11:443"},
+			wantErr:     true,
+			wantLookups: 2,
+		},
+		{
+			name:        "IP literal bypasses the resolver",
+			addr:        "198.51.100.1:80",
+			wantDialed:  []string{"198.51.100.1:80", "198.51.100.1:80"},
+			wantLookups: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &stubResolver{answers: map[string][]net.IPAddr{"origin.test": ipAddrs("192.0.2.10", "192.0.2.11")}}
+			c, _ := newTestDNSCache(resolver, time.Minute)
+
+			var dialed []string
+			dial := c.dialContext(func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialed = append(dialed, addr)
+				for _, down := range tt.unreachable {
+					if addr == down {
+						return nil, errors.New("connection refused")
+					}
+				}
+				client, server := net.Pipe()
+				server.Close()
+				return client, nil
+			})
+
+			for i := 0; i < 2; i++ {
+				conn, err := dial(context.Background(), "tcp", tt.addr)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("dial %d: error %v, want error %v", i, err, tt.wantErr)
+				}
+				if conn != nil {
+					conn.Close()
+				}
+			}
+
+			if !reflect.DeepEqual(dialed, tt.wantDialed) {
+				t.Errorf("dialed %v, want %v", dialed, tt.wantDialed)
+			}
+			if resolver.count() != tt.wantLookups {
+				t.Errorf("resolver queried %d times, want %d", resolver.count(), tt.wantLookups)
+			}
+		})
+	}
+}
+
+func TestHandlerDNSCache(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// A fresh connection per request, so each fetch dials again
+		w.Header().Set("Connection", "close")
+		w.Header().Set("Content-Type", "video/mp2t")
+		io.WriteString(w, "segment")
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name        string
+		ttl         time.Duration
+		wantLookups int
+	}{
+		{"cached", time.Minute, 1},
+		{"disabled", 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver := &stubResolver{answers: map[string][]net.IPAddr{"origin.test": ipAddrs("127.0.0.1")}}
+			_, port, _ := net.SplitHostPort(origin.Listener.Addr().String())
+			cfg := testConfig("http://origin.test:" + port)
+			cfg.Origin.DNS.CacheTTL = tt.ttl
+
+			// Without the cache the stub resolver is never asked, so the
+			// disabled case dials the real address directly
+			var dials int32
+			opts := HandlerOptions{
+				Config:   cfg,
+				Cache:    cache.NewMemory(),
+				Logger:   telemetry.NewLogger("error", "", "stdout"),
+				Metrics:  telemetry.NewMetrics(),
+				Resolver: resolver,
+			}
+			if tt.ttl == 0 {
+				opts.DialContext = redirectDial(origin.Listener.Addr().String(), &dials)
+			}
+			h := NewHandler(opts)
+
+			for _, target := range []string{"/live/seg1.ts", "/live/seg2.ts", "/live/seg3.ts"} {
+				if rec := serve(h, target); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "segment") {
+					t.Fatalf("%s: status %d body %q", target, rec.Code, rec.Body.String())
+				}
+			}
+			if resolver.count() != tt.wantLookups {
+				t.Errorf("resolver queried %d times, want %d", resolver.count(), tt.wantLookups)
+			}
+			if tt.ttl > 0 && counter(h.metrics, "origin.dns.hit") != 2 {
+				t.Errorf("DNS cache hits %d, want 2", counter(h.metrics, "origin.dns.hit"))
+			}
+		})
+	}
+}
@@ -7,11 +7,15 @@ import (
 	"net/http"
 	"strconv"
 	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+	"github.com/ilijajolevski/ilinden/internal/jwt"
 )
 
 // ProxyError represents a proxy-specific error
 type ProxyError struct {
 	Code       int
+	ErrorCode  string // Machine-readable code sent to clients
 	Message    string
 	Err        error
 	RetryAfter time.Duration
@@ -47,32 +51,148 @@ func (e *ProxyError) WithRetry(retryAfter time.Duration) *ProxyError {
 	return e
 }
 
+// WithCode sets the machine-readable error code
+func (e *ProxyError) WithCode(code string) *ProxyError {
+	e.ErrorCode = code
+	return e
+}
+
 // WithField adds a log field to the error
 func (e *ProxyError) WithField(key string, value interface{}) *ProxyError {
 	e.LogFields[key] = value
 	return e
 }
 
+// APIError converts the error to the JSON error shape sent to clients
+func (e *ProxyError) APIError() *api.Error {
+	code := e.ErrorCode
+	if code == "" {
+		code = statusErrorCode(e.Code)
+	}
+	return api.NewError(e.Message, code, e.Code)
+}
+
 // WriteResponse writes the error response to the HTTP writer
 func (e *ProxyError) WriteResponse(w http.ResponseWriter) {
-	// Set status code
-	w.WriteHeader(e.Code)
-	
-	// Set retry header if needed
+	// Set retry header if needed; headers must precede the status
 	if e.RetryAfter > 0 {
-		w.Header().Set("Retry-After", strconv.Itoa(int(e.RetryAfter.Seconds())))
+		w.Header().Set("Retry-After", retryAfterSeconds(e.RetryAfter))
 	}
 	
-	// Write error message
-	w.Write([]byte(e.Message))
+	api.WriteError(w, e.APIError())
+}
+
+// retryAfterSeconds formats a delay as Retry-After seconds, rounding up so
+// clients never retry early
+func retryAfterSeconds(d time.Duration) string {
+	return strconv.Itoa(int((d + time.Second - 1) / time.Second))
 }
 
 // Common error types
 var (
-	ErrOriginTimeout  = NewProxyError(http.StatusGatewayTimeout, "Origin server timeout", errors.New("origin timeout"))
-	ErrOriginRefused  = NewProxyError(http.StatusBadGateway, "Origin server connection refused", errors.New("connection refused"))
-	ErrRateLimited    = NewProxyError(http.StatusTooManyRequests, "Rate limit exceeded", errors.New("rate limit"))
-	ErrCircuitOpen    = NewProxyError(http.StatusServiceUnavailable, "Service temporarily unavailable", errors.New("circuit open"))
-	ErrMalformedURL   = NewProxyError(http.StatusBadRequest, "Malformed URL", errors.New("malformed URL"))
-	ErrUnknownService = NewProxyError(http.StatusNotFound, "Unknown service", errors.New("unknown service"))
-)
\ No newline at end of file
+	ErrOriginTimeout  = NewProxyError(http.StatusGatewayTimeout, "Origin server timeout", errors.New("origin timeout")).WithCode("origin_timeout")
+	ErrOriginRefused  = NewProxyError(http.StatusBadGateway, "Origin server connection refused", errors.New("connection refused")).WithCode("origin_refused")
+	ErrRateLimited    = NewProxyError(http.StatusTooManyRequests, "Rate limit exceeded", errors.New("rate limit")).WithCode("rate_limited")
+	ErrCircuitOpen    = NewProxyError(http.StatusServiceUnavailable, "Service temporarily unavailable", errors.New("circuit open")).WithCode("circuit_open")
+	ErrMalformedURL   = NewProxyError(http.StatusBadRequest, "Malformed URL", errors.New("malformed URL")).WithCode("malformed_url")
+	ErrUnknownService = NewProxyError(http.StatusNotFound, "Unknown service", errors.New("unknown service")).WithCode("unknown_service")
+)
+
+// sentinelErrorCodes maps plain proxy errors to machine-readable codes
+var sentinelErrorCodes = []struct {
+	err  error
+	code string
+}{
+	{ErrNoTargetURL, "no_target_url"},
+	{ErrInvalidTargetURL, "invalid_target_url"},
+	{ErrTargetNotAllowed, "target_not_allowed"},
+	{ErrTargetURLTooLong, "target_url_too_long"},
+	{ErrParsingPlaylist, "playlist_parse_error"},
+}
+
+// statusErrorCode returns the generic code for an HTTP error status
+func statusErrorCode(status int) string {
+	switch {
+	case status == http.StatusBadRequest:
+		return "bad_request"
+	case status == http.StatusUnauthorized:
+		return "unauthorized"
+	case status == http.StatusForbidden:
+		return "forbidden"
+	case status == http.StatusNotFound:
+		return "not_found"
+	case status == http.StatusGone:
+		return "gone"
+	case status == http.StatusRequestedRangeNotSatisfiable:
+		return "range_not_satisfiable"
+	case status == http.StatusTooManyRequests:
+		return "rate_limited"
+	case status == http.StatusBadGateway:
+		return "origin_error"
+	case status == http.StatusServiceUnavailable:
+		return "service_unavailable"
+	case status == http.StatusGatewayTimeout:
+		return "origin_timeout"
+	case status >= 500:
+		return "internal_error"
+	default:
+		return "request_error"
+	}
+}
+
+// statusMessage returns the generic message for an HTTP error status
+func statusMessage(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "Bad request"
+	case http.StatusUnauthorized:
+		return "Unauthorized"
+	case http.StatusForbidden:
+		return "Forbidden"
+	case http.StatusNotFound:
+		return "Not found"
+	case http.StatusGone:
+		return "Gone"
+	case http.StatusRequestURITooLong:
+		return "URI too long"
+	case http.StatusRequestedRangeNotSatisfiable:
+		return "Range not satisfiable"
+	case http.StatusTooManyRequests:
+		return "Too many requests"
+	case http.StatusBadGateway:
+		return "Origin server error"
+	case http.StatusServiceUnavailable:
+		return "Service temporarily unavailable"
+	case http.StatusGatewayTimeout:
+		return "Origin server timeout"
+	default:
+		if status < 500 {
+			return "Request error"
+		}
+		return "Internal server error"
+	}
+}
+
+// errorResponse maps an error and its status to the client error response.
+// Token and proxy errors carry their own codes; other errors are identified
+// by sentinel or fall back to a code derived from the status.
+func errorResponse(err error, statusCode int) *api.Error {
+	var tokenErr *jwt.TokenError
+	if errors.As(err, &tokenErr) {
+		return api.NewError(tokenErr.Error(), tokenErr.ErrorCode(), tokenErr.StatusCode)
+	}
+
+	var proxyErr *ProxyError
+	if errors.As(err, &proxyErr) {
+		return proxyErr.APIError()
+	}
+
+	code := statusErrorCode(statusCode)
+	for _, sentinel := range sentinelErrorCodes {
+		if errors.Is(err, sentinel.err) {
+			code = sentinel.code
+			break
+		}
+	}
+	return api.NewError(statusMessage(statusCode), code, statusCode)
+}
\ No newline at end of file
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+	"github.com/ilijajolevski/ilinden/internal/jwt"
+)
+
+func TestErrorResponse(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		status     int
+		wantCode   string
+		wantStatus int
+	}{
+		{"missing token", jwt.NewTokenRequiredError(), http.StatusUnauthorized, "token_required", http.StatusUnauthorized},
+		{"expired token", jwt.NewTokenExpiredError(), http.StatusUnauthorized, "token_expired", http.StatusUnauthorized},
+		{"token status wins", jwt.NewForbiddenError("no"), http.StatusUnauthorized, "token_forbidden", http.StatusForbidden},
+		{"proxy error code", ErrOriginRefused, http.StatusBadGateway, "origin_refused", http.StatusBadGateway},
+		{"wrapped proxy error", fmt.Errorf("fetch: %w", ErrMalformedURL), http.StatusBadRequest, "malformed_url", http.StatusBadRequest},
+		{"proxy error without code", NewProxyError(http.StatusGone, "Gone", nil), http.StatusGone, "gone", http.StatusGone},
+		{"sentinel", ErrNoTargetURL, http.StatusBadRequest, "no_target_url", http.StatusBadRequest},
+		{"wrapped sentinel", fmt.Errorf("route: %w", ErrTargetNotAllowed), http.StatusForbidden, "target_not_allowed", http.StatusForbidden},
+		{"unknown error", errors.New("boom"), http.StatusInternalServerError, "internal_error", http.StatusInternalServerError},
+		{"unknown client error", errors.New("teapot"), http.StatusTeapot, "request_error", http.StatusTeapot},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := errorResponse(tt.err, tt.status)
+			if got.Code != tt.wantCode || got.Status != tt.wantStatus {
+				t.Errorf("got %s %d, want %s %d", got.Code, got.Status, tt.wantCode, tt.wantStatus)
+			}
+			if got.Message == "" {
+				t.Error("empty message")
+			}
+		})
+	}
+}
+
+func TestProxyErrorWriteResponse(t *testing.T) {
+	rec := httptest.NewRecorder()
+	NewProxyError(http.StatusServiceUnavailable, "Try later", nil).WithCode("circuit_open").WithRetry(30 * time.Second).WriteResponse(rec)
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status %d, want 503", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "30" {
+		t.Errorf("Retry-After %q, want 30", rec.Header().Get("Retry-After"))
+	}
+	var body api.Error
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+	if body.Code != "circuit_open" || body.Message != "Try later" {
+		t.Errorf("body %+v", body)
+	}
+}
+
+func TestHandlerErrorsAreJSON(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name       string
+		jwt        bool
+		target     string
+		wantStatus int
+		wantCode   string
+	}{
+		{"missing token", true, "/live/seg1.ts", http.StatusUnauthorized, "token_required"},
+		{"target not allowed", false, "/proxy?url=https://elsewhere.example.com/seg1.ts", http.StatusForbidden, "target_not_allowed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = tt.jwt
+			cfg.JWT.Secret = "test-secret"
+			cfg.Origin.AllowedHosts = []string{"cdn.example.com"}
+			rec := serve(newTestHandler(t, cfg), tt.target)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Content-Type %q, want application/json", ct)
+			}
+			var body api.Error
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if body.Code != tt.wantCode || body.Status != tt.wantStatus {
+				t.Errorf("body %+v, want code %s", body, tt.wantCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHandlerFailsOverToBackupOrigins(t *testing.T) {
+	tests := []struct {
+		name          string
+		primaryStatus int
+		breaker       bool
+		wantBody      string
+		wantPrimary   int32 // Primary fetches over two requests
+		wantServed    string
+	}{
+		{"healthy primary", http.StatusOK, true, "primary", 2, "default"},
+		{"primary 5xx fails over", http.StatusBadGateway, false, "backup", 2, ""},
+		{"open circuit skips primary", http.StatusBadGateway, true, "backup", 1, ""},
+		{"client errors do not fail over", http.StatusNotFound, true, "", 2, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var primaryFetches atomic.Int32
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				primaryFetches.Add(1)
+				w.WriteHeader(tt.primaryStatus)
+				io.WriteString(w, "primary")
+			}))
+			defer primary.Close()
+			backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "backup")
+			}))
+			defer backup.Close()
+
+			cfg := testConfig(primary.URL)
+			cfg.Origin.Backups = []string{backup.URL}
+			cfg.Origin.CircuitBreaker = tt.breaker
+			cfg.Origin.BreakerThreshold = 1
+			cfg.Cache.Enabled = false
+			h := newTestHandler(t, cfg)
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				rec = serve(h, "/live/seg1.ts")
+			}
+
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("served %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := primaryFetches.Load(); got != tt.wantPrimary {
+				t.Errorf("primary fetched %d times, want %d", got, tt.wantPrimary)
+			}
+			served := tt.wantServed
+			if served == "" {
+				served = backup.Listener.Addr().String()
+			}
+			if got := counter(h.metrics, "origin.served."+served); got == 0 {
+				t.Errorf("origin.served.%s not counted", served)
+			}
+		})
+	}
+}
+
+func TestHandlerRecoveringOriginGetsOneTrial(t *testing.T) {
+	const (
+		requests = 20
+		cooldown = 30 * time.Millisecond
+	)
+
+	tests := []struct {
+		name        string
+		trialStatus int
+		wantBody    string // Served after the trial
+		wantPrimary int32  // Primary fetches after the cooldown
+	}{
+		{"trial succeeds", http.StatusOK, "primary", 2},
+		{"trial fails", http.StatusBadGateway, "backup", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var recovering atomic.Bool
+			var primaryFetches atomic.Int32
+			release := make(chan struct{})
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if !recovering.Load() {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				if primaryFetches.Add(1) == 1 {
+					<-release
+				}
+				w.WriteHeader(tt.trialStatus)
+				io.WriteString(w, "primary")
+			}))
+			defer primary.Close()
+			backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "backup")
+			}))
+			defer backup.Close()
+
+			cfg := testConfig(primary.URL)
+			cfg.Origin.Backups = []string{backup.URL}
+			cfg.Origin.CircuitBreaker = true
+			cfg.Origin.BreakerThreshold = 1
+			cfg.Origin.BreakerCooldown = cooldown
+			cfg.Cache.Enabled = false
+			h := newTestHandler(t, cfg)
+
+			// Open the primary's circuit, then let the cooldown pass
+			serve(h, "/live/seg1.ts")
+			recovering.Store(true)
+			time.Sleep(2 * cooldown)
+
+			var completed atomic.Int32
+			var wg sync.WaitGroup
+			for i := 0; i < requests; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					serve(h, "/live/seg1.ts")
+					completed.Add(1)
+				}()
+			}
+
+			// Everything but the trial is served by the backup meanwhile
+			deadline := time.Now().Add(2 * time.Second)
+			for completed.Load() < requests-1 && time.Now().Before(deadline) {
+				time.Sleep(time.Millisecond)
+			}
+			if got := primaryFetches.Load(); got != 1 {
+				t.Errorf("recovering primary fetched %d times by %d concurrent requests, want 1", got, requests)
+			}
+			close(release)
+			wg.Wait()
+
+			rec := serve(h, "/live/seg1.ts")
+			if rec.Body.String() != tt.wantBody {
+				t.Errorf("after the trial served %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+			if got := primaryFetches.Load(); got != tt.wantPrimary {
+				t.Errorf("primary fetched %d times, want %d", got, tt.wantPrimary)
+			}
+		})
+	}
+}
+
+func TestHandlerGivesBackUnusedTrials(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "primary")
+	}))
+	defer primary.Close()
+	backup := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "backup")
+	}))
+	defer backup.Close()
+
+	cfg := testConfig(primary.URL)
+	cfg.Origin.Backups = []string{backup.URL}
+	cfg.Origin.CircuitBreaker = true
+	cfg.Origin.BreakerThreshold = 1
+	cfg.Origin.BreakerCooldown = time.Hour
+	cfg.Cache.Enabled = false
+	h := newTestHandler(t, cfg)
+
+	// Both origins are half-open; the request claims a trial on each but
+	// only needs the primary
+	for _, up := range h.origins.fallback.upstreams {
+		up.breaker.Hold(time.Nanosecond)
+	}
+	time.Sleep(time.Millisecond)
+	if rec := serve(h, "/live/seg1.ts"); rec.Body.String() != "primary" {
+		t.Fatalf("served %q, want primary", rec.Body.String())
+	}
+
+	backupBreaker := h.origins.fallback.upstreams[1].breaker
+	if !backupBreaker.Allow() {
+		t.Error("trial on the untried backup not given back")
+	}
+	if got := h.origins.fallback.upstreams[0].breaker.State(); got != "closed" {
+		t.Errorf("primary breaker %s after a successful trial, want closed", got)
+	}
+}
@@ -0,0 +1,99 @@
+// Cache stampede protection
+//
+// Coalesces concurrent misses on one cache key:
+// - The first request fetches from the origin
+// - Later requests wait a bounded time for its result
+// - On timeout they serve a stale copy or fetch themselves
+
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+)
+
+// lockOutcome says how a request should proceed after acquiring a fetch lock
+type lockOutcome int
+
+const (
+	lockFetch   lockOutcome = iota // Fetch from the origin: the caller holds the lock, or locking is off
+	lockShared                     // Another fetch finished while the caller waited
+	lockTimeout                    // The wait elapsed with the other fetch still running
+)
+
+// fetchLocks tracks the origin fetches in flight per cache key
+type fetchLocks struct {
+	wait     time.Duration // Longest wait for another request's fetch
+	mu       sync.Mutex
+	inflight map[cache.Key]chan struct{}
+}
+
+// newFetchLocks creates the lock registry, or nil if wait disables it
+func newFetchLocks(wait time.Duration) *fetchLocks {
+	if wait <= 0 {
+		return nil
+	}
+	return &fetchLocks{
+		wait:     wait,
+		inflight: make(map[cache.Key]chan struct{}),
+	}
+}
+
+// acquire takes the fetch lock for key, or waits up to the configured
+// duration for the request holding it. The returned function releases a
+// held lock and is a no-op otherwise.
+func (l *fetchLocks) acquire(ctx context.Context, key cache.Key) (func(), lockOutcome) {
+	if l == nil {
+		return func() {}, lockFetch
+	}
+
+	l.mu.Lock()
+	done, busy := l.inflight[key]
+	if !busy {
+		done = make(chan struct{})
+		l.inflight[key] = done
+		l.mu.Unlock()
+		return func() { l.release(key, done) }, lockFetch
+	}
+	l.mu.Unlock()
+
+	timer := time.NewTimer(l.wait)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return func() {}, lockShared
+	case <-timer.C:
+		return func() {}, lockTimeout
+	case <-ctx.Done():
+		return func() {}, lockTimeout
+	}
+}
+
+// done wakes the requests waiting on key once its response is cached,
+// without waiting for the fetching request to finish writing to its client
+func (l *fetchLocks) done(key cache.Key) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if ch, ok := l.inflight[key]; ok {
+		delete(l.inflight, key)
+		close(ch)
+	}
+}
+
+// release drops the lock for key if it is still the one the caller took
+func (l *fetchLocks) release(key cache.Key, ch chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[key] == ch {
+		delete(l.inflight, key)
+		close(ch)
+	}
+}
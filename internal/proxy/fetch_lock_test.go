@@ -0,0 +1,229 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+func TestFetchLocksAcquire(t *testing.T) {
+	const key = cache.Key("segment:a")
+
+	tests := []struct {
+		name   string
+		wait   time.Duration
+		held   bool
+		finish func(l *fetchLocks, release func())
+		cancel bool
+		want   lockOutcome
+	}{
+		{name: "disabled", want: lockFetch},
+		{name: "free key", wait: time.Second, want: lockFetch},
+		{
+			name: "shared after the fetch is stored",
+			wait: time.Second,
+			held: true,
+			finish: func(l *fetchLocks, release func()) {
+				l.done(key)
+			},
+			want: lockShared,
+		},
+		{
+			name: "shared after the holder releases",
+			wait: time.Second,
+			held: true,
+			finish: func(l *fetchLocks, release func()) {
+				release()
+			},
+			want: lockShared,
+		},
+		{name: "wait elapses", wait: 20 * time.Millisecond, held: true, want: lockTimeout},
+		{name: "request canceled", wait: time.Second, held: true, cancel: true, want: lockTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newFetchLocks(tt.wait)
+			if tt.held {
+				release, outcome := l.acquire(context.Background(), key)
+				if outcome != lockFetch {
+					t.Fatalf("first acquire: %v, want lockFetch", outcome)
+				}
+				defer release()
+				if tt.finish != nil {
+					time.AfterFunc(20*time.Millisecond, func() { tt.finish(l, release) })
+				}
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancel {
+				cancel()
+			}
+			release, outcome := l.acquire(ctx, key)
+			release()
+			if outcome != tt.want {
+				t.Errorf("outcome %v, want %v", outcome, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchLocksReleaseOnlyOwnLock(t *testing.T) {
+	const key = cache.Key("segment:a")
+	l := newFetchLocks(20 * time.Millisecond)
+
+	stale, _ := l.acquire(context.Background(), key)
+	l.done(key)
+	current, outcome := l.acquire(context.Background(), key)
+	if outcome != lockFetch {
+		t.Fatalf("acquire after done: %v, want lockFetch", outcome)
+	}
+	defer current()
+
+	// A late release from the first holder leaves the new lock alone
+	stale()
+	if _, outcome := l.acquire(context.Background(), key); outcome != lockTimeout {
+		t.Errorf("acquire while held: %v, want lockTimeout", outcome)
+	}
+}
+
+// blockingOrigin serves segments, holding the fetches numbered in block
+// until release is closed. started receives each fetch number on arrival.
+type blockingOrigin struct {
+	*httptest.Server
+	fetches atomic.Int32
+	started chan int32
+	release chan struct{}
+}
+
+func newBlockingOrigin(t *testing.T, block ...int32) *blockingOrigin {
+	t.Helper()
+	o := &blockingOrigin{started: make(chan int32, 8), release: make(chan struct{})}
+	o.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := o.fetches.Add(1)
+		o.started <- n
+		for _, b := range block {
+			if b == n {
+				<-o.release
+			}
+		}
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Write([]byte("segment"))
+	}))
+	t.Cleanup(o.Close)
+	return o
+}
+
+// serveAsync serves target through h in the background
+func serveAsync(h http.Handler, target string, wg *sync.WaitGroup) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	}()
+	return rec
+}
+
+func TestHandlerStampedeWaitShares(t *testing.T) {
+	origin := newBlockingOrigin(t, 1)
+	cfg := testConfig(origin.URL)
+	cfg.Cache.Enabled = true
+	cfg.Cache.StampedeWait = 2 * time.Second
+	h := newTestHandler(t, cfg)
+
+	var wg sync.WaitGroup
+	first := serveAsync(h, "/live/seg1.ts", &wg)
+	<-origin.started
+	second := serveAsync(h, "/live/seg1.ts", &wg)
+
+	// Let the second request reach the lock before the fetch completes
+	time.Sleep(50 * time.Millisecond)
+	close(origin.release)
+	wg.Wait()
+
+	if got := origin.fetches.Load(); got != 1 {
+		t.Errorf("origin fetched %d times, want 1", got)
+	}
+	for name, rec := range map[string]*httptest.ResponseRecorder{"first": first, "second": second} {
+		if rec.Code != http.StatusOK || rec.Body.String() != "segment" {
+			t.Errorf("%s: status %d body %q", name, rec.Code, rec.Body.String())
+		}
+	}
+	if got := second.Header().Get("X-Cache"); got != "HIT" {
+		t.Errorf("waiting request X-Cache %q, want HIT", got)
+	}
+	if got := counter(h.metrics, "cache.stampede.shared"); got != 1 {
+		t.Errorf("shared counter %d, want 1", got)
+	}
+}
+
+func TestHandlerStampedeWaitTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		staleTTL    time.Duration
+		wantCache   string
+		wantFetches int32
+		wantCounter string
+	}{
+		{"fetches itself without a stale copy", 0, "MISS", 3, "cache.stampede.timeout"},
+		{"serves the stale copy", time.Hour, "STALE", 2, "cache.stampede.stale"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The second fetch, a refresh after the entry expires, hangs
+			origin := newBlockingOrigin(t, 2)
+			defer close(origin.release)
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Enabled = true
+			cfg.Cache.StampedeWait = 50 * time.Millisecond
+			cfg.Cache.StaleTTL = tt.staleTTL
+			cfg.Cache.TTLMedia = 100 * time.Millisecond
+			clock := utils.NewFakeClock(time.Now())
+			h := newClockedHandler(t, cfg, clock)
+
+			if rec := serve(h, "/live/seg1.ts"); rec.Code != http.StatusOK {
+				t.Fatalf("priming request: status %d", rec.Code)
+			}
+			<-origin.started
+			clock.Advance(time.Second)
+			if tt.staleTTL == 0 {
+				// Without a stale copy the memory cache drops the entry itself
+				time.Sleep(150 * time.Millisecond)
+			}
+
+			var wg sync.WaitGroup
+			serveAsync(h, "/live/seg1.ts", &wg)
+			<-origin.started
+			rec := serve(h, "/live/seg1.ts")
+
+			if rec.Code != http.StatusOK || rec.Body.String() != "segment" {
+				t.Errorf("status %d body %q", rec.Code, rec.Body.String())
+			}
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache %q, want %q", got, tt.wantCache)
+			}
+			if tt.wantFetches > 2 {
+				<-origin.started
+			}
+			if got := origin.fetches.Load(); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+			if got := counter(h.metrics, tt.wantCounter); got != 1 {
+				t.Errorf("%s counter %d, want 1", tt.wantCounter, got)
+			}
+
+			origin.release <- struct{}{}
+			wg.Wait()
+		})
+	}
+}
@@ -0,0 +1,78 @@
+// Origin request tracing headers
+//
+// Standard proxy headers on origin requests:
+// - Via with the configured pseudonym
+// - X-Forwarded-For with the client IP appended
+// - X-Forwarded-Proto and X-Forwarded-Host
+// - Incoming values trusted only from trusted proxies
+
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// applyForwardedHeaders sets the tracing headers on an origin request made
+// for the client request r
+func (h *Handler) applyForwardedHeaders(r, req *http.Request) {
+	if via := h.config.Origin.Via; via != "" {
+		req.Header.Set("Via", appendHeader(r.Header.Get("Via"), viaProtocol(r)+" "+via))
+	}
+	
+	if !h.config.Origin.ForwardedHeaders {
+		return
+	}
+	
+	// Forwarding headers from anyone but a trusted proxy may be forged
+	peer := utils.ClientIP(r, nil)
+	trusted := peer != nil && utils.ContainsIP(h.trustedProxies, peer)
+	
+	forwardedFor := ""
+	if trusted {
+		forwardedFor = r.Header.Get("X-Forwarded-For")
+	}
+	if peer != nil {
+		forwardedFor = appendHeader(forwardedFor, peer.String())
+	}
+	if forwardedFor != "" {
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	host := r.Host
+	if trusted {
+		if v := r.Header.Get("X-Forwarded-Proto"); v != "" {
+			proto = v
+		}
+		if v := r.Header.Get("X-Forwarded-Host"); v != "" {
+			host = v
+		}
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+	if host != "" {
+		req.Header.Set("X-Forwarded-Host", host)
+	}
+}
+
+// viaProtocol returns the received-protocol of a Via entry, such as "1.1",
+// or "2" for HTTP/2 and later
+func viaProtocol(r *http.Request) string {
+	if r.ProtoMajor >= 2 {
+		return strconv.Itoa(r.ProtoMajor)
+	}
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
+
+// appendHeader appends a value to a comma-separated header list
+func appendHeader(list, value string) string {
+	if list == "" {
+		return value
+	}
+	return list + ", " + value
+}
@@ -0,0 +1,99 @@
+package proxy
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestHandlerForwardedHeaders(t *testing.T) {
+	// Test requests come from 192.0.2.1 for host example.com
+	incoming := http.Header{
+		"Via":               {"1.1 edge"},
+		"X-Forwarded-For":   {"203.0.113.7"},
+		"X-Forwarded-Proto": {"https"},
+		"X-Forwarded-Host":  {"cdn.example.com"},
+	}
+
+	tests := []struct {
+		name      string
+		forwarded bool
+		via       string
+		trusted   []string
+		header    http.Header
+		want      map[string]string
+	}{
+		{
+			name: "off by default",
+			want: map[string]string{"Via": "", "X-Forwarded-For": "", "X-Forwarded-Proto": "", "X-Forwarded-Host": ""},
+		},
+		{
+			name: "via only",
+			via:  "ilinden",
+			want: map[string]string{"Via": "1.1 ilinden", "X-Forwarded-For": ""},
+		},
+		{
+			name:   "via appended",
+			via:    "ilinden",
+			header: incoming,
+			want:   map[string]string{"Via": "1.1 edge, 1.1 ilinden"},
+		},
+		{
+			name:      "direct client",
+			forwarded: true,
+			want:      map[string]string{"X-Forwarded-For": "192.0.2.1", "X-Forwarded-Proto": "http", "X-Forwarded-Host": "example.com"},
+		},
+		{
+			name:      "untrusted peer values replaced",
+			forwarded: true,
+			header:    incoming,
+			want:      map[string]string{"X-Forwarded-For": "192.0.2.1", "X-Forwarded-Proto": "http", "X-Forwarded-Host": "example.com"},
+		},
+		{
+			name:      "trusted proxy values extended",
+			forwarded: true,
+			trusted:   []string{"192.0.2.0/24"},
+			header:    incoming,
+			want:      map[string]string{"X-Forwarded-For": "203.0.113.7, 192.0.2.1", "X-Forwarded-Proto": "https", "X-Forwarded-Host": "cdn.example.com"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := originHeaders(t, func(c *config.Config) {
+				c.Origin.ForwardedHeaders = tt.forwarded
+				c.Origin.Via = tt.via
+				c.Server.TrustedProxies = tt.trusted
+				c.Origin.ForwardHeaders = []string{"Range"}
+			}, "/live/seg1.ts", tt.header)
+
+			for name, want := range tt.want {
+				if v := got.Get(name); v != want {
+					t.Errorf("%s %q, want %q", name, v, want)
+				}
+			}
+		})
+	}
+}
+
+func TestViaProtocol(t *testing.T) {
+	tests := []struct {
+		major, minor int
+		want         string
+	}{
+		{1, 0, "1.0"},
+		{1, 1, "1.1"},
+		{2, 0, "2"},
+		{3, 0, "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			r := &http.Request{ProtoMajor: tt.major, ProtoMinor: tt.minor}
+			if got := viaProtocol(r); got != tt.want {
+				t.Errorf("viaProtocol = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
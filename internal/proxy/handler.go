@@ -9,13 +9,17 @@
 package proxy
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/ilijajolevski/ilinden/internal/api"
@@ -25,6 +29,8 @@ import (
 	"github.com/ilijajolevski/ilinden/internal/playlist"
 	"github.com/ilijajolevski/ilinden/internal/redis"
 	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+	"github.com/ilijajolevski/ilinden/pkg/hls"
 )
 
 // Common errors
@@ -40,12 +46,33 @@ type Handler struct {
 	config         *config.Config
 	jwtExtractor   *jwt.Extractor
 	jwtValidator   *jwt.Validator
+	authorizer     jwt.Authorizer
+	jwtKeys        *jwt.KeySet
 	cache          cache.Cache
 	logger         telemetry.Logger
 	metrics        telemetry.Metrics
 	playlistParser *playlist.Parser
 	redisTracker   *redis.Tracker
 	originClient   *http.Client
+	origins        *OriginRouter
+	prefetcher     *Prefetcher
+	trustedProxies []*net.IPNet
+	bypassNets     []*net.IPNet
+	classifier     *playlist.Classifier
+	passthrough    *passthroughRules
+	headerPolicy   *headerPolicy
+	responsePolicy *responseHeaderPolicy
+	titleHook      playlist.TitleHook
+	variantFilter  *playlist.VariantFilter
+	renditions     *playlist.RenditionFilter
+	liveWindow     *playlist.LiveWindow
+	tokenParams    []string    // Query parameters masked in logs
+	capture        *playlistCapture
+	parseLimiter   *parseLimiter
+	fetchLocks     *fetchLocks
+	copyBuffers    *copyBuffers
+	cacheDegraded  atomic.Bool // Set while the cache backend is failing
+	clock          utils.Clock
 }
 
 // HandlerOptions contains options for creating a new handler
@@ -55,64 +82,200 @@ type HandlerOptions struct {
 	Logger       telemetry.Logger
 	Metrics      telemetry.Metrics
 	RedisTracker *redis.Tracker
+
+	// Transport replaces the origin transport entirely, e.g. a stub in tests
+	// or a client-certificate transport; DialContext only replaces dialing
+	Transport   http.RoundTripper
+	DialContext DialContextFunc
+
+	// Resolver replaces the resolver used by the origin DNS cache
+	Resolver Resolver
+
+	// TitleHook inspects or rewrites segment titles, overriding the
+	// configured segmentTitles handling
+	TitleHook playlist.TitleHook
+
+	// Authorizer replaces the configured role, scope, stream and access
+	// window checks; to add a policy such as geo or entitlements to them,
+	// compose it with DefaultAuthorizer using jwt.All, passing it the same
+	// Clock
+	Authorizer jwt.Authorizer
+
+	// Clock replaces the time source for cache ages and token expiry, e.g.
+	// with a fake clock in tests
+	Clock utils.Clock
 }
 
 // NewHandler creates a new proxy handler
 func NewHandler(opts HandlerOptions) *Handler {
-	// Create origin client
-	originClient := &http.Client{
-		Timeout: opts.Config.Origin.Timeout,
-		Transport: &http.Transport{
-			MaxIdleConns:          opts.Config.Origin.MaxIdleConns,
-			MaxIdleConnsPerHost:   opts.Config.Origin.MaxIdleConnsPerHost,
-			MaxConnsPerHost:       opts.Config.Origin.MaxConnsPerHost,
-			IdleConnTimeout:       opts.Config.Origin.IdleConnTimeout,
-			TLSHandshakeTimeout:   opts.Config.Origin.TLSHandshakeTimeout,
-			ExpectContinueTimeout: opts.Config.Origin.ExpectContinueTimeout,
-		},
+	// Create origin client; timeouts are applied per request by the route so
+	// that large bodies are not cut off while they are still making progress
+	dial := originDialer(opts)
+	transport, err := originRoundTripper(&opts.Config.Origin, opts.Transport, dial)
+	if err != nil {
+		opts.Logger.Error("Invalid origin TLS configuration, using defaults", "error", err.Error())
+		plain := opts.Config.Origin
+		plain.TLS = config.OriginTLSConfig{}
+		transport, _ = originRoundTripper(&plain, nil, dial)
 	}
+	originClient := &http.Client{Transport: transport}
+	warnInsecureOrigins(&opts.Config.Origin, opts.Logger)
+
+	// Scope all cache keys to the configured namespace
+	responseCache := cache.WithNamespace(opts.Cache, opts.Config.Cache.Namespace)
 
 	// Create JWT components
 	jwtExtractor := jwt.NewExtractor(&opts.Config.JWT)
-	jwtValidator := jwt.NewValidator(&opts.Config.JWT, opts.Cache)
+	jwtValidator := jwt.NewValidator(&opts.Config.JWT, responseCache)
+	var jwtKeys *jwt.KeySet
+	if opts.Config.JWT.KeysURL != "" {
+		jwtKeys = jwt.NewKeySet(&opts.Config.JWT, opts.Metrics)
+		jwtValidator.SetKeySet(jwtKeys)
+	}
+
+	// Create origin router, falling back to the default origin only
+	origins, err := NewOriginRouter(&opts.Config.Origin, originClient)
+	if err != nil {
+		opts.Logger.Error("Invalid origin routes, using default origin only", "error", err.Error())
+		origins = &OriginRouter{fallback: &originRoute{
+			name:        "default",
+			client:      originClient,
+			timeout:     opts.Config.Origin.Timeout,
+			bodyTimeout: opts.Config.Origin.BodyTimeout,
+		}}
+	}
+	origins.setLimiter(newHostLimiter(opts.Config.Origin.MaxFetchesPerHost, opts.Metrics))
+
+	// Proxies trusted to report the client address, validated with the config
+	trustedProxies, err := utils.ParseCIDRs(opts.Config.Server.TrustedProxies)
+	if err != nil {
+		opts.Logger.Error("Invalid trusted proxies, ignoring forwarded addresses", "error", err.Error())
+	}
+
+	// Clients allowed to bypass the cache, validated with the config
+	bypassNets, err := utils.ParseCIDRs(opts.Config.Cache.Bypass.AllowedCIDRs)
+	if err != nil {
+		opts.Logger.Error("Invalid cache bypass networks, allowing the admin token only", "error", err.Error())
+	}
+	
+	// Playlist and segment URL patterns, validated with the config
+	classifier, err := playlist.NewClassifier(opts.Config.Origin.PlaylistPatterns, opts.Config.Origin.SegmentPatterns)
+	if err != nil {
+		opts.Logger.Error("Invalid URL patterns, classifying by .m3u8 suffix only", "error", err.Error())
+	}
+
+	// Paths proxied verbatim, validated with the config
+	passthrough, err := newPassthroughRules(opts.Config.Origin.PassthroughPatterns)
+	if err != nil {
+		opts.Logger.Error("Invalid passthrough patterns, disabling passthrough", "error", err.Error())
+	}
+
+	if len(opts.Config.Origin.RequestHeaders) > 0 {
+		opts.Logger.Info("Injecting origin request headers",
+			"headers", fmt.Sprint(utils.RedactHeaders(opts.Config.Origin.RequestHeaders, opts.Config.Origin.SensitiveHeaders)))
+	}
 
-	return &Handler{
+	h := &Handler{
 		config:         opts.Config,
 		jwtExtractor:   jwtExtractor,
 		jwtValidator:   jwtValidator,
-		cache:          opts.Cache,
+		jwtKeys:        jwtKeys,
+		cache:          responseCache,
 		logger:         opts.Logger,
 		metrics:        opts.Metrics,
-		playlistParser: playlist.NewParser(),
+		playlistParser: playlist.NewParserWithValidation(opts.Config.Origin.PlaylistValidation),
 		redisTracker:   opts.RedisTracker,
 		originClient:   originClient,
+		origins:        origins,
+		trustedProxies: trustedProxies,
+		bypassNets:     bypassNets,
+		classifier:     classifier,
+		passthrough:    passthrough,
+		headerPolicy:   newHeaderPolicy(&opts.Config.Origin),
+		responsePolicy: newResponseHeaderPolicy(&opts.Config.Origin),
+		titleHook:      titleHook(opts),
+		variantFilter:  variantFilter(&opts.Config.Origin.VariantFilter),
+		renditions:     renditionFilter(&opts.Config.Origin.RenditionFilter),
+		liveWindow:     liveWindow(&opts.Config.Origin.LiveWindow),
+		tokenParams:    opts.Config.TokenParams(),
+		clock:          utils.ClockOrReal(opts.Clock),
+	}
+	jwtValidator.SetClock(h.clock)
+	h.authorizer = authorizer(opts, jwtValidator)
+	h.capture = newPlaylistCapture(&opts.Config.Debug, h.tokenParams, opts.Logger)
+	h.playlistParser.SetParsedCache(playlist.NewParsedCache(opts.Config.Cache.ParsedPlaylistBytes, h.tokenParams))
+	if opts.Config.Origin.MissingBandwidth == "default" {
+		h.playlistParser.SetLenientBandwidth(uint64(opts.Config.Origin.DefaultBandwidth))
+	}
+	h.parseLimiter = newParseLimiter(opts.Config.Origin.MaxConcurrentParses, opts.Config.Origin.ParseQueueTimeout, opts.Metrics)
+	h.fetchLocks = newFetchLocks(opts.Config.Cache.StampedeWait)
+	h.copyBuffers = newCopyBuffers(opts.Config.Cache.StreamBufferBytes)
+	
+	// Create the child playlist prefetcher if enabled
+	if opts.Config.Cache.Enabled && opts.Config.Cache.Prefetch {
+		h.prefetcher = NewPrefetcher(h, opts.Config.Cache.PrefetchVariants, opts.Config.Cache.PrefetchConcurrency)
+	}
+	
+	return h
+}
+
+// TokenValidator returns the validator used for request tokens
+func (h *Handler) TokenValidator() *jwt.Validator {
+	return h.jwtValidator
+}
+
+// UpstreamStatus returns the circuit breaker state of every origin upstream
+func (h *Handler) UpstreamStatus() []UpstreamStatus {
+	return h.origins.Status()
+}
+
+// Close finishes in-flight prefetches and releases origin and JWKS
+// connections. It runs after the servers have drained.
+func (h *Handler) Close() error {
+	h.prefetcher.Wait()
+	h.origins.CloseIdleConnections()
+	if h.jwtKeys != nil {
+		h.jwtKeys.Close()
+	}
+	return nil
+}
+
+// ReadinessChecks returns the checks the handler contributes to readiness
+func (h *Handler) ReadinessChecks() map[string]func() bool {
+	checks := map[string]func() bool{}
+	if h.jwtKeys != nil {
+		checks["jwks"] = h.jwtKeys.Ready
 	}
+	return checks
 }
 
 // ServeHTTP handles HTTP requests
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Start timing
 	startTime := time.Now()
+	timing := newServerTiming(h.config.Server.ServerTiming)
 	
-	// Extract token
-	token, err := h.jwtExtractor.Extract(r)
-	if err != nil {
-		h.handleError(w, r, err, http.StatusUnauthorized)
+	// Proxy passthrough paths verbatim, without auth or rewriting
+	if h.passthrough.matches(r) {
+		h.servePassthrough(w, r, startTime)
 		return
 	}
 	
-	// Validate token
-	claims, err := h.jwtValidator.ValidateToken(token)
-	if err != nil {
-		h.handleError(w, r, err, http.StatusUnauthorized)
+	// Authenticate the request; anonymous requests have no token or claims
+	token, claims, ok := h.authenticate(w, r)
+	if !ok {
 		return
 	}
 	
 	// Get player ID for tracking
-	playerID, err := claims.GetPlayerID()
-	if err != nil {
-		h.logger.Warn("Failed to get player ID from token", "error", err.Error())
-		// Continue without player ID
+	var playerID string
+	if claims != nil {
+		var err error
+		playerID, err = claims.GetPlayerID()
+		if err != nil {
+			h.logger.Warn("Failed to get player ID from token", "error", err.Error())
+			// Continue without player ID
+		}
 	}
 	
 	// Track player if tracking is enabled
@@ -120,68 +283,131 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		h.redisTracker.TrackPlayer(playerID, r.URL.Path, r.Header.Get("User-Agent"))
 	}
 	
-	// Determine target URL
-	targetURL, err := h.getTargetURL(r)
+	// Heartbeats only register activity
+	if h.isBeacon(r) {
+		h.metrics.IncCounter("beacon")
+		w.WriteHeader(http.StatusNoContent)
+		h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
+		return
+	}
+	
+	// Trusted clients may force a fresh origin fetch
+	r, bypass := h.cacheBypass(r)
+	
+	// Select the origin and determine target URL
+	route := h.origins.Match(r)
+	targetURL, err := h.getTargetURL(r, route)
 	if err != nil {
-		h.handleError(w, r, err, http.StatusBadRequest)
+		statusCode := http.StatusBadRequest
+		if errors.Is(err, ErrTargetNotAllowed) {
+			statusCode = http.StatusForbidden
+		}
+		h.handleError(w, r, err, statusCode)
+		return
+	}
+	if err := h.checkTargetLength(targetURL); err != nil {
+		h.handleError(w, r, err, http.StatusRequestURITooLong)
 		return
 	}
 	
 	// Check if the target is an HLS playlist
-	isM3U8 := playlist.IsM3U8(targetURL.Path)
+	isM3U8 := h.classifier.IsPlaylist(targetURL)
 	
 	// Set cache key based on URL and token
-	keyPrefix := "playlist:"
+	var cacheKey cache.Key
 	if isM3U8 {
-		keyPrefix = "playlist:"
+		cacheKey = h.playlistCacheKey(targetURL, token) + cache.Key(h.renditionVariant(r))
 	} else {
-		keyPrefix = "segment:"
+		cacheKey = h.segmentCacheKey(targetURL, token, encodingVariant(r)+rangeVariant(r))
 	}
-	cacheKey := cache.Key(keyPrefix + targetURL.String() + ":" + token)
 	
-	// Check cache first
-	if h.config.Cache.Enabled {
-		cachedContent, found := h.cache.Get(cacheKey)
-		if found {
-			if cachedBytes, ok := cachedContent.([]byte); ok {
-				h.metrics.IncCounter("cache.hit")
-				contentType := "application/octet-stream"
-				if isM3U8 {
-					contentType = "application/vnd.apple.mpegurl"
-				}
-				
-				w.Header().Set("Content-Type", contentType)
-				w.Header().Set("Content-Length", strconv.Itoa(len(cachedBytes)))
-				w.Header().Set("X-Cache", "HIT")
-				w.Write(cachedBytes)
-				
-				// Record metrics
-				h.metrics.ObserveRequestDuration(r.URL.Path, time.Since(startTime))
+	// Check cache first, unless bypassed; the response is still cached
+	if h.config.Cache.Enabled && !bypass {
+		lookupStart := time.Now()
+		entry, found := h.lookupCached(r, cacheKey)
+		timing.since("cache", lookupStart)
+		if found && entry.fresh(h.clock.Now()) {
+			h.serveCached(w, r, cacheKey, entry, isM3U8, "HIT", timing)
+			h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
+			return
+		}
+		stale := entry
+		h.metrics.IncCounter("cache.miss")
+		
+		// Wait for another request's fetch of the same key, if one is running
+		release, outcome := h.fetchLocks.acquire(r.Context(), cacheKey)
+		defer release()
+		switch outcome {
+		case lockShared:
+			if entry, found := h.lookupCached(r, cacheKey); found && entry.fresh(h.clock.Now()) {
+				h.metrics.IncCounter("cache.stampede.shared")
+				h.serveCached(w, r, cacheKey, entry, isM3U8, "HIT", timing)
+				h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
+				return
+			}
+		case lockTimeout:
+			if stale != nil {
+				h.metrics.IncCounter("cache.stampede.stale")
+				h.serveCached(w, r, cacheKey, stale, isM3U8, "STALE", timing)
+				h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
 				return
 			}
+			h.metrics.IncCounter("cache.stampede.timeout")
 		}
-		h.metrics.IncCounter("cache.miss")
 	}
 	
-	// Create request to origin
-	originReq, err := http.NewRequestWithContext(r.Context(), "GET", targetURL.String(), nil)
-	if err != nil {
-		h.handleError(w, r, err, http.StatusInternalServerError)
+	// Send request to origin, failing over to backups if needed
+	originStart := time.Now()
+	originResp, servedURL, err := h.fetchOrigin(r, route)
+	timing.since("origin", originStart)
+	if err != nil && isM3U8 && h.serveFallback(w, r, route, err) {
+		h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
 		return
 	}
-	
-	// Copy relevant headers from original request
-	h.copyHeaders(r.Header, originReq.Header)
-	
-	// Send request to origin
-	originResp, err := h.originClient.Do(originReq)
 	if err != nil {
-		h.handleError(w, r, err, http.StatusBadGateway)
+		statusCode := http.StatusBadGateway
+		if errors.Is(err, ErrOriginTimeout) {
+			statusCode = http.StatusGatewayTimeout
+		}
+		h.handleError(w, r, err, statusCode)
 		return
 	}
 	
+	// Origins that answer with a server error are as good as unreachable
+	if originResp.StatusCode >= http.StatusInternalServerError && isM3U8 {
+		if h.serveFallback(w, r, route, fmt.Errorf("%w: status %d", ErrOriginError, originResp.StatusCode)) {
+			originResp.Body.Close()
+			h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
+			return
+		}
+	}
+	
 	// Check if origin returned an error
 	if originResp.StatusCode >= 400 {
+		originResp.Body.Close()
+		
+		// Tell the client the resource size for an unsatisfiable range
+		contentRange := ""
+		if originResp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+			contentRange = originUnsatisfiedRange(originResp)
+		}
+		
+		// Briefly cache selected error statuses to shield the origin
+		if h.config.Cache.Enabled && h.isNegativeCacheable(originResp.StatusCode) {
+			h.cacheStore(r, cacheKey, &cachedResponse{StatusCode: originResp.StatusCode, ContentRange: contentRange}, h.cacheTTL(h.config.Cache.TTLNegative))
+		}
+		
+		if contentRange != "" {
+			w.Header().Set("Content-Range", contentRange)
+		}
+		
+		// Pass on the origin's back-off hint
+		if isBackoffStatus(originResp.StatusCode) {
+			if retryAfter := originResp.Header.Get("Retry-After"); retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+		}
+		
 		h.handleError(w, r, ErrOriginError, originResp.StatusCode)
 		return
 	}
@@ -189,24 +415,268 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	// Process the response
 	if isM3U8 {
 		// For M3U8 playlists, we need to process the content
-		h.handlePlaylist(w, r, originResp, targetURL, token, cacheKey)
+		h.handlePlaylist(w, r, route, originResp, servedURL, token, claims, cacheKey, timing)
 	} else {
 		// For other content, just proxy the response
-		h.handleRawContent(w, r, originResp, cacheKey)
+		h.handleRawContent(w, r, originResp, cacheKey, timing)
 	}
 	
 	// Record metrics
-	h.metrics.ObserveRequestDuration(r.URL.Path, time.Since(startTime))
+	h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
+}
+
+// lookupCached returns the cached response for key if it can be served to
+// the client, fresh or stale
+func (h *Handler) lookupCached(r *http.Request, key cache.Key) (*cachedResponse, bool) {
+	cachedContent, found := h.cacheGet(r, key)
+	if !found {
+		return nil, false
+	}
+	entry, ok := cachedContent.(*cachedResponse)
+	if !ok || (!entry.isNegative() && !entry.servableTo(r)) {
+		return nil, false
+	}
+	return entry, true
+}
+
+// serveCached replays a cached response, marking it with the given X-Cache
+// status. Playlists are sent precompressed to gzip clients when a gzip copy
+// is cached or the playlist is stored compressed.
+func (h *Handler) serveCached(w http.ResponseWriter, r *http.Request, key cache.Key, entry *cachedResponse, isM3U8 bool, status string, timing *serverTiming) {
+	if entry.isNegative() {
+		// Known-missing resource: replay the origin status
+		h.metrics.IncCounter("cache.hit.negative")
+		w.Header().Set("X-Cache", status)
+		if entry.ContentRange != "" {
+			w.Header().Set("Content-Range", entry.ContentRange)
+		}
+		entry.writeAge(w.Header(), h.clock.Now(), h.config.Cache.CacheAgeHeader)
+		timing.writeHeader(w.Header())
+		h.handleError(w, r, ErrOriginError, entry.StatusCode)
+		return
+	}
+	
+	h.metrics.IncCounter("cache.hit")
+	precompressed := isM3U8 && (h.config.Cache.PrecompressPlaylists || entry.StoredGzip)
+	if precompressed && !entry.StoredGzip {
+		if gzipped, ok := h.gzipVariant(r, key); ok {
+			entry = gzipped
+		}
+	}
+	body, encoding, err := entry.bodyFor(r)
+	if err != nil {
+		h.metrics.IncCounter("cache.decompress.error")
+		h.handleError(w, r, err, http.StatusInternalServerError)
+		return
+	}
+	contentType := entry.ContentType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+		if isM3U8 {
+			contentType = "application/vnd.apple.mpegurl"
+		}
+	}
+	
+	w.Header().Set("Content-Type", contentType)
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
+	}
+	if encoding != "" || precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Header().Set("X-Cache", status)
+	entry.writeAge(w.Header(), h.clock.Now(), h.config.Cache.CacheAgeHeader)
+	timing.writeHeader(w.Header())
+	if entry.ContentRange != "" {
+		w.Header().Set("Content-Range", entry.ContentRange)
+		w.WriteHeader(http.StatusPartialContent)
+	}
+	w.Write(body)
+}
+
+// authenticate extracts and validates the request token. A token that is
+// present is always validated; a missing token is accepted when JWT is
+// disabled, in optional mode, or on a public path. On failure the error
+// response has been written and ok is false.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (token string, claims *jwt.Claims, ok bool) {
+	if !h.config.JWT.Enabled {
+		return "", nil, true
+	}
+	
+	// Extract token
+	token, err := h.jwtExtractor.Extract(r)
+	if errors.Is(err, jwt.ErrTokenRequired) && h.allowsAnonymous(r) {
+		h.metrics.IncCounter("auth.anonymous")
+		return "", nil, true
+	}
+	if err != nil {
+		h.handleError(w, r, err, http.StatusUnauthorized)
+		return "", nil, false
+	}
+	
+	// Validate token
+	claims, err = h.jwtValidator.ValidateToken(token)
+	if err != nil {
+		h.handleError(w, r, err, http.StatusUnauthorized)
+		return "", nil, false
+	}
+	
+	// Check the token grants access
+	if err := h.authorizer.Authorize(claims, r).Err(); err != nil {
+		h.handleError(w, r, err, http.StatusForbidden)
+		return "", nil, false
+	}
+	
+	// Check the token is presented by the client it was issued to
+	if err := h.jwtValidator.CheckBinding(claims, utils.ClientIP(r, h.trustedProxies), r.UserAgent()); err != nil {
+		h.handleError(w, r, err, http.StatusForbidden)
+		return "", nil, false
+	}
+	
+	return token, claims, true
+}
+
+// serveFallback answers a playlist request whose origins could not be
+// reached, or only answered with server errors, with the route's fallback
+// playlist. It reports false if the route has none or the client went away.
+func (h *Handler) serveFallback(w http.ResponseWriter, r *http.Request, route *originRoute, err error) bool {
+	if route.fallback == nil || r.Context().Err() != nil {
+		return false
+	}
+	
+	h.logger.Warn("Origin unavailable, serving fallback playlist", "origin", route.name, "error", h.redactError(err))
+	h.metrics.IncCounter("origin.fallback")
+	
+	w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+	w.Header().Set("Content-Length", strconv.Itoa(len(route.fallback)))
+	w.Header().Set("Cache-Control", "max-age="+strconv.FormatInt(int64(h.config.Origin.FallbackTTL/time.Second), 10))
+	w.Header().Set("X-Cache", "FALLBACK")
+	w.Write(route.fallback)
+	return true
+}
+
+// isBeacon reports whether the request is a player heartbeat served
+// without content
+func (h *Handler) isBeacon(r *http.Request) bool {
+	for _, prefix := range h.config.Redis.BeaconPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsAnonymous reports whether a request without a token may proceed
+func (h *Handler) allowsAnonymous(r *http.Request) bool {
+	if h.config.JWT.Optional {
+		return true
+	}
+	for _, prefix := range h.config.JWT.PublicPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchOrigin sends the request to the route's origins in order until one
+// responds without a server error. It returns the response and the URL that
+// served it; the last origin's response is returned even if it failed.
+func (h *Handler) fetchOrigin(r *http.Request, route *originRoute) (*http.Response, *url.URL, error) {
+	// Respect Retry-After holds instead of probing the origin early
+	if wait := route.holdoff(r); wait > 0 {
+		h.metrics.IncCounter("origin.retry_after.held")
+		return nil, nil, NewProxyError(http.StatusServiceUnavailable, "Service temporarily unavailable", ErrCircuitOpen).WithCode("circuit_open").WithRetry(wait)
+	}
+	
+	attempts, allowed := route.attempts(r)
+	
+	// Trials granted to upstreams that end up not being tried, or whose
+	// attempt says nothing about them, are given back
+	tried := 0
+	defer func() {
+		if !allowed {
+			return
+		}
+		for _, up := range attempts[tried:] {
+			up.breaker.Cancel()
+		}
+	}()
+	
+	for i, up := range attempts {
+		target, err := route.targetURLFor(r, up)
+		if err != nil {
+			return nil, nil, err
+		}
+		
+		// Create request to origin
+		originReq, err := http.NewRequestWithContext(r.Context(), "GET", target.String(), nil)
+		if err != nil {
+			return nil, nil, err
+		}
+		
+		// Copy relevant headers from original request
+		h.copyHeaders(r.Header, originReq.Header)
+		h.applyForwardedHeaders(r, originReq)
+		h.applyOriginHeaders(originReq)
+		
+		resp, err := route.do(originReq)
+		if r.Context().Err() != nil {
+			// The client went away; this says nothing about the origin
+			if resp != nil {
+				resp.Body.Close()
+			}
+			return nil, nil, r.Context().Err()
+		}
+		tried = i + 1
+		
+		// An origin asking to back off is held for the requested delay
+		if err == nil && up != nil && isBackoffStatus(resp.StatusCode) {
+			if wait := utils.ParseRetryAfter(resp.Header.Get("Retry-After"), time.Now()); wait > 0 {
+				if max := h.config.Origin.RetryAfterMax; max > 0 && wait > max {
+					wait = max
+				}
+				up.breaker.Hold(wait)
+			}
+		}
+		
+		if err == nil && resp.StatusCode < http.StatusInternalServerError && resp.StatusCode != http.StatusTooManyRequests {
+			if up != nil {
+				up.breaker.Success()
+				h.metrics.IncCounter("origin.served." + up.name)
+			}
+			return resp, target, nil
+		}
+		
+		if up != nil {
+			up.breaker.Failure()
+		}
+		if i == len(attempts)-1 {
+			if err != nil {
+				return nil, nil, err
+			}
+			if up != nil {
+				h.metrics.IncCounter("origin.served." + up.name)
+			}
+			return resp, target, nil
+		}
+		
+		if resp != nil {
+			resp.Body.Close()
+		}
+		h.metrics.IncCounter("origin.failover")
+		h.logger.Warn("Origin request failed, trying next origin", "origin", up.name, "url", h.redactURL(target))
+	}
+	
+	return nil, nil, ErrOriginError
 }
 
 // handlePlaylist processes an HLS playlist
-func (h *Handler) handlePlaylist(w http.ResponseWriter, r *http.Request, originResp *http.Response, targetURL *url.URL, token string, cacheKey cache.Key) {
+func (h *Handler) handlePlaylist(w http.ResponseWriter, r *http.Request, route *originRoute, originResp *http.Response, targetURL *url.URL, token string, claims *jwt.Claims, cacheKey cache.Key, timing *serverTiming) {
 	// Get processor options
-	procOptions := playlist.ProcessorOptions{
-		TokenParamName: h.config.JWT.ParamName,
-		PathParamName:  "url",
-		UsePathParam:   false,
-	}
+	procOptions := h.processorOptions(route)
+	procOptions.RenditionFilter = h.requestRenditionFilter(r)
 	
 	// Create a proxy URL based on the current request
 	proxyURL := &url.URL{
@@ -215,19 +685,49 @@ func (h *Handler) handlePlaylist(w http.ResponseWriter, r *http.Request, originR
 		Path:   r.URL.Path,
 	}
 	
+	// Read the original playlist
+	originalContent, err := io.ReadAll(originResp.Body)
+	originResp.Body.Close()
+	if err != nil {
+		h.handleError(w, r, err, http.StatusBadGateway)
+		return
+	}
+	
+	// Remove any origin compression before parsing; the rewritten playlist
+	// is sent plain and compressed per client negotiation downstream
+	originalContent, err = decodeBody(originalContent, originResp.Header.Get("Content-Encoding"), h.config.Origin.MaxDecodedBytes)
+	if err != nil {
+		h.handleError(w, r, fmt.Errorf("%w: %v", ErrParsingPlaylist, err), http.StatusBadGateway)
+		return
+	}
+	
+	// Wait for a parse slot, shedding load when none frees up in time
+	release, err := h.parseLimiter.acquire(r.Context())
+	if err != nil {
+		h.handleError(w, r, NewProxyError(http.StatusServiceUnavailable, "Playlist processing overloaded", err).
+			WithCode("parse_overloaded").WithRetry(time.Second), http.StatusServiceUnavailable)
+		return
+	}
+	
 	// Process the playlist
-	processedContent, err := h.playlistParser.ParseAndProcessResponse(
-		originResp.Body,
+	parseStart := time.Now()
+	processedContent, stats, err := h.playlistParser.ParseAndProcessBytesStats(
+		originalContent,
 		targetURL,
 		proxyURL,
 		token,
 		procOptions,
 	)
+	release()
+	timing.since("parse", parseStart)
+	h.recordRewrite(stats, time.Since(parseStart), err)
 	
 	if err != nil {
 		h.handleError(w, r, fmt.Errorf("%w: %v", ErrParsingPlaylist, err), http.StatusInternalServerError)
 		return
 	}
+	h.capture.playlist(r, processedContent, stats.Warnings)
+	h.checkRewrittenLength(r, proxyURL, stats.LongestURI)
 	
 	// Set appropriate headers
 	contentType := originResp.Header.Get("Content-Type")
@@ -241,140 +741,435 @@ func (h *Handler) handlePlaylist(w http.ResponseWriter, r *http.Request, originR
 	
 	// Copy other relevant headers
 	h.copyHeadersToResponse(originResp.Header, w.Header())
+	w.Header().Del("Content-Encoding")
 	
 	// Cache the processed content if caching is enabled
+	var gzipped *cachedResponse
 	if h.config.Cache.Enabled {
-		// Determine TTL based on playlist type
-		var ttl time.Duration
-		if strings.Contains(string(processedContent), "#EXT-X-STREAM-INF") {
-			ttl = h.config.Cache.TTLMaster
-		} else {
-			ttl = h.config.Cache.TTLMedia
+		// Determine TTL based on playlist type, within the token's validity
+		if ttl, ok := h.tokenTTL(h.playlistTTL(processedContent), claims); ok {
+			entry := h.compressForStorage(&cachedResponse{
+				Body:        processedContent,
+				ContentType: contentType,
+			})
+			h.cacheStore(r, cacheKey, entry, ttl)
+			if entry.StoredGzip {
+				gzipped = entry
+			} else {
+				gzipped = h.storeGzipVariant(r, cacheKey, contentType, processedContent, ttl)
+			}
 		}
-		
-		h.cache.Set(cacheKey, processedContent, ttl)
 	}
 	
-	// Write the response
-	w.Write(processedContent)
+	// Write the response, precompressed for gzip clients when available
+	body := processedContent
+	if gzipped != nil {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if acceptsGzip(r) {
+			body = gzipped.Body
+			w.Header().Set("Content-Encoding", "gzip")
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		}
+	}
+	timing.writeHeader(w.Header())
+	w.Write(body)
+	
+	// Warm the cache with the master's media playlists
+	if h.prefetcher != nil && playlist.DetectPlaylistType(originalContent) == hls.PlaylistTypeMaster {
+		h.prefetcher.Prefetch(processedContent, r, token, claims)
+	}
+}
+
+// streamPath returns the path identifying the requested stream for access
+// checks: the explicit target's path for ?url= requests, otherwise the
+// request path. Dot segments are resolved as they are for the origin fetch,
+// so "/live/../vod/" is checked as "/vod/".
+func streamPath(r *http.Request) string {
+	if targetStr := r.URL.Query().Get("url"); targetStr != "" {
+		if target, err := url.Parse(targetStr); err == nil {
+			return utils.CleanPath(target.Path)
+		}
+	}
+	return utils.CleanPath(r.URL.Path)
+}
+
+// cacheContext returns the context for storing a response in the cache. It
+// keeps the request's values for tracing but not its cancellation, so a
+// fully fetched response is still cached if the client goes away.
+func cacheContext(r *http.Request) context.Context {
+	return context.WithoutCancel(r.Context())
+}
+
+// processorOptions returns the playlist processor options for the handler.
+// Playlists from a route that names its own token parameter carry the token
+// under that name in URLs pointing directly to the origin; absolute URLs on
+// the route's origin hosts may be routed through the proxy.
+func (h *Handler) processorOptions(route *originRoute) playlist.ProcessorOptions {
+	return playlist.ProcessorOptions{
+		TokenParamName:       h.config.JWT.ParamName,
+		OriginTokenParamName: route.tokenParam,
+		PathParamName:        "url",
+		UsePathParam:         false,
+		RewriteAbsolute:      h.config.Origin.RewriteAbsoluteURLs,
+		ProxyHosts:           route.proxyHosts,
+		TitleHook:            h.titleHook,
+		VariantFilter:        h.variantFilter,
+		RenditionFilter:      h.renditions,
+		LiveWindow:           h.liveWindow,
+	}
+}
+
+// variantFilter returns the master playlist variant filter, or nil when no
+// limit is configured
+func variantFilter(cfg *config.VariantFilterConfig) *playlist.VariantFilter {
+	if *cfg == (config.VariantFilterConfig{}) {
+		return nil
+	}
+	return &playlist.VariantFilter{
+		MinBandwidth: uint64(cfg.MinBandwidth),
+		MaxBandwidth: uint64(cfg.MaxBandwidth),
+		MaxWidth:     cfg.MaxWidth,
+		MaxHeight:    cfg.MaxHeight,
+	}
+}
+
+// liveWindow returns the live playlist window, or nil when no limit is
+// configured
+func liveWindow(cfg *config.LiveWindowConfig) *playlist.LiveWindow {
+	if *cfg == (config.LiveWindowConfig{}) {
+		return nil
+	}
+	return &playlist.LiveWindow{
+		MaxSegments: cfg.MaxSegments,
+		MaxDuration: cfg.MaxDuration,
+	}
+}
+
+// authorizer returns the access policy for validated tokens: the
+// embedder's authorizer if set, otherwise the configured checks, which
+// follow validator configuration updates
+func authorizer(opts HandlerOptions, validator *jwt.Validator) jwt.Authorizer {
+	if opts.Authorizer != nil {
+		return opts.Authorizer
+	}
+	return validator.Authorizer(streamPath)
+}
+
+// DefaultAuthorizer returns the configured role, scope, stream and access
+// window checks for cfg, which HandlerOptions.Authorizer replaces, so that
+// embedders can compose their own policy with them. The access window is
+// checked against clock, which should be the handler's clock, e.g.
+// HandlerOptions.Clock.Now
+func DefaultAuthorizer(cfg *config.Config, clock func() time.Time) jwt.Authorizer {
+	return jwt.ConfigAuthorizer(&cfg.JWT, streamPath, clock)
+}
+
+// titleHook returns the segment title hook: the embedder's hook if set,
+// otherwise the one for the configured segmentTitles mode
+func titleHook(opts HandlerOptions) playlist.TitleHook {
+	if opts.TitleHook != nil {
+		return opts.TitleHook
+	}
+	if opts.Config.Origin.SegmentTitles == "strip" {
+		return playlist.StripTitles
+	}
+	return nil
+}
+
+// recordRewrite records metrics for a playlist rewrite: URIs rewritten per
+// playlist, rewrite latency, and parse errors by kind
+func (h *Handler) recordRewrite(stats playlist.RewriteStats, elapsed time.Duration, err error) {
+	if err != nil {
+		h.metrics.IncCounter("playlist.parse_error." + playlist.ParseErrorKind(err))
+		return
+	}
+	
+	h.metrics.ObserveHistogram("playlist.rewrite_ms", float64(elapsed.Microseconds())/1000)
+	switch stats.Type {
+	case hls.PlaylistTypeMaster:
+		h.metrics.IncCounter("playlist.rewritten.master")
+		h.metrics.ObserveHistogram("playlist.rewrite.variants", float64(stats.Variants))
+		h.metrics.ObserveHistogram("playlist.rewrite.renditions", float64(stats.Renditions+stats.IFrameStreams))
+	case hls.PlaylistTypeMedia:
+		h.metrics.IncCounter("playlist.rewritten.media")
+		h.metrics.ObserveHistogram("playlist.rewrite.segments", float64(stats.Segments))
+	}
+	if stats.Keys > 0 {
+		h.metrics.IncCounterBy("playlist.rewrite.keys", stats.Keys)
+	}
+	if len(stats.Warnings) > 0 {
+		h.metrics.IncCounterBy("playlist.warning", len(stats.Warnings))
+	}
+}
+
+// playlistTTL returns the cache TTL for a processed playlist. Complete
+// (VOD or ended) media playlists never change and are kept longer than live
+// ones; EVENT playlists only grow, so a cached copy is merely missing its
+// newest segments.
+func (h *Handler) playlistTTL(content []byte) time.Duration {
+	if strings.Contains(string(content), "#EXT-X-STREAM-INF") {
+		return h.cacheTTL(h.config.Cache.TTLMaster)
+	}
+	
+	playlistType := playlist.MediaPlaylistType(content)
+	if h.config.Cache.TTLVOD > 0 && (playlistType == "VOD" || playlist.HasEndList(content)) {
+		return h.cacheTTL(h.config.Cache.TTLVOD)
+	}
+	if h.config.Cache.TTLEvent > 0 && playlistType == "EVENT" {
+		return h.cacheTTL(h.config.Cache.TTLEvent)
+	}
+	return h.cacheTTL(h.config.Cache.TTLMedia)
+}
+
+// tokenTTL limits a playlist TTL to the remaining validity of the token it
+// was rewritten for, when configured. It reports false if the playlist must
+// not be cached because the token has expired.
+func (h *Handler) tokenTTL(ttl time.Duration, claims *jwt.Claims) (time.Duration, bool) {
+	if !h.config.Cache.ClampTTLToToken || claims == nil || claims.ExpirationTime == 0 {
+		return ttl, true
+	}
+	
+	remaining := time.Unix(claims.ExpirationTime, 0).Sub(h.clock.Now())
+	if remaining <= 0 {
+		return 0, false
+	}
+	if ttl <= 0 || ttl > remaining {
+		ttl = remaining
+	}
+	return ttl, true
+}
+
+// rawTTL returns the cache TTL for unmodified content such as segments, keys
+// and subtitles: the TTL configured for its content type, else the media TTL
+func (h *Handler) rawTTL(contentType string) time.Duration {
+	if ttl, ok := cache.ContentTypeTTL(h.config.Cache.TTLByContentType, contentType); ok {
+		return h.cacheTTL(ttl)
+	}
+	return h.cacheTTL(h.config.Cache.TTLMedia)
+}
+
+// cacheTTL applies the configured TTL ceiling to a computed TTL
+func (h *Handler) cacheTTL(ttl time.Duration) time.Duration {
+	return cache.ClampTTL(ttl, h.config.Cache.MaxTTL)
 }
 
 // handleRawContent proxies raw content without modification
-func (h *Handler) handleRawContent(w http.ResponseWriter, r *http.Request, originResp *http.Response, cacheKey cache.Key) {
+func (h *Handler) handleRawContent(w http.ResponseWriter, r *http.Request, originResp *http.Response, cacheKey cache.Key, timing *serverTiming) {
+	// The origin ignored a range that lies beyond the body
+	if ignoredRangeUnsatisfiable(r, originResp) {
+		originResp.Body.Close()
+		w.Header().Set("Content-Range", unsatisfiedRange(originResp.ContentLength))
+		h.handleError(w, r, ErrOriginError, http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
+	
 	// Set appropriate headers
 	w.Header().Set("Content-Type", originResp.Header.Get("Content-Type"))
-	w.Header().Set("Content-Length", originResp.Header.Get("Content-Length"))
 	w.Header().Set("X-Cache", "MISS")
 	
 	// Copy other relevant headers
 	h.copyHeadersToResponse(originResp.Header, w.Header())
 	
-	// Read and write the response body
-	contentBytes, err := io.ReadAll(originResp.Body)
+	// Large and unknown-length responses are streamed without caching
+	if h.streams(originResp) {
+		h.streamRawContent(w, r, originResp, timing)
+		return
+	}
+	
+	// Read the response body; an unknown-length body that outgrows the
+	// stream threshold is streamed after all
+	contentBytes, complete, err := h.readRawBody(originResp)
 	if err != nil {
+		originResp.Body.Close()
 		h.handleError(w, r, err, http.StatusInternalServerError)
 		return
 	}
+	if !complete {
+		originResp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(contentBytes), originResp.Body), originResp.Body}
+		h.streamRawContent(w, r, originResp, timing)
+		return
+	}
+	originResp.Body.Close()
+	
+	// The buffered length is authoritative, whatever the origin declared
+	w.Header().Set("Content-Length", strconv.Itoa(len(contentBytes)))
 	
 	// Cache the content if caching is enabled
 	if h.config.Cache.Enabled {
-		// Use a shorter TTL for segments
-		h.cache.Set(cacheKey, contentBytes, h.config.Cache.TTLMedia)
+		h.cacheStore(r, cacheKey, &cachedResponse{
+			Body:            contentBytes,
+			ContentType:     originResp.Header.Get("Content-Type"),
+			ContentEncoding: originResp.Header.Get("Content-Encoding"),
+			ContentRange:    partialContentRange(originResp),
+		}, h.rawTTL(originResp.Header.Get("Content-Type")))
 	}
 	
-	// Write the response
+	// Write the response, keeping a 206 for byte-range requests
+	timing.writeHeader(w.Header())
+	w.WriteHeader(originResp.StatusCode)
 	w.Write(contentBytes)
 }
 
-// getTargetURL extracts the target URL from the request
-func (h *Handler) getTargetURL(r *http.Request) (*url.URL, error) {
-	// Check if target URL is provided as a query parameter
-	targetStr := r.URL.Query().Get("url")
-	if targetStr != "" {
-		targetURL, err := url.Parse(targetStr)
-		if err != nil {
-			return nil, ErrInvalidTargetURL
+// partialContentRange returns the Content-Range of a 206 response, or ""
+// for a complete one
+func partialContentRange(resp *http.Response) string {
+	if resp.StatusCode != http.StatusPartialContent {
+		return ""
+	}
+	return resp.Header.Get("Content-Range")
+}
+
+// streams reports whether a raw response is streamed rather than buffered
+// and cached: responses above the stream threshold, and unknown-length
+// responses unless they are configured to be buffered
+func (h *Handler) streams(resp *http.Response) bool {
+	threshold := h.config.Cache.StreamThresholdBytes
+	if resp.ContentLength < 0 {
+		switch h.config.Cache.UnknownLength {
+		case "stream":
+			return true
+		case "buffer":
+			return false
 		}
-		return targetURL, nil
+		return threshold > 0
 	}
-	
-	// Otherwise, use the request path with the origin base URL
-	originBaseURL := h.config.Origin.BaseURL
-	if originBaseURL == "" {
-		// If no base URL is configured, we cannot determine the target
-		return nil, ErrNoTargetURL
+	return threshold > 0 && resp.ContentLength > threshold
+}
+
+// readRawBody buffers a raw response body. A body of unknown length is read
+// up to the stream threshold, if any; complete is false when it is longer,
+// leaving the rest of it unread.
+func (h *Handler) readRawBody(resp *http.Response) (body []byte, complete bool, err error) {
+	threshold := h.config.Cache.StreamThresholdBytes
+	if resp.ContentLength >= 0 || threshold <= 0 {
+		body, err = io.ReadAll(resp.Body)
+		return body, err == nil, err
 	}
 	
-	// Parse origin base URL
-	baseURL, err := url.Parse(originBaseURL)
+	h.metrics.IncCounter("response.unknown_length")
+	body, err = io.ReadAll(io.LimitReader(resp.Body, threshold+1))
 	if err != nil {
-		return nil, ErrInvalidTargetURL
+		return nil, false, err
 	}
+	return body, int64(len(body)) <= threshold, nil
+}
+
+// streamRawContent copies a raw response to the client as it arrives
+func (h *Handler) streamRawContent(w http.ResponseWriter, r *http.Request, originResp *http.Response, timing *serverTiming) {
+	defer originResp.Body.Close()
 	
-	// Combine with request path
-	return baseURL.ResolveReference(&url.URL{Path: r.URL.Path, RawQuery: r.URL.RawQuery}), nil
+	// Without a known length the response is sent chunked
+	if originResp.ContentLength >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(originResp.ContentLength, 10))
+	}
+	h.metrics.IncCounter("response.streamed")
+	
+	timing.writeHeader(w.Header())
+	w.WriteHeader(originResp.StatusCode)
+	if _, err := h.copyBuffers.copy(w, originResp.Body); err != nil {
+		// Headers are already sent; the client sees a truncated body
+		h.logger.Warn("Streaming response failed", "error", err.Error(), "path", r.URL.Path)
+	}
+}
+
+// redactURL returns a URL for logging with token parameters masked
+func (h *Handler) redactURL(u *url.URL) string {
+	return utils.RedactURL(u, h.tokenParams)
+}
+
+// redactError returns an error message for logging with the token masked in
+// any origin URL it quotes
+func (h *Handler) redactError(err error) string {
+	msg := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		if u, perr := url.Parse(urlErr.URL); perr == nil {
+			msg = strings.ReplaceAll(msg, urlErr.URL, h.redactURL(u))
+		}
+	}
+	return msg
+}
+
+// getTargetURL extracts the target URL from the request using the selected origin route
+func (h *Handler) getTargetURL(r *http.Request, route *originRoute) (*url.URL, error) {
+	return route.targetURL(r)
+}
+
+// isNegativeCacheable reports whether an origin error status may be cached
+func (h *Handler) isNegativeCacheable(statusCode int) bool {
+	if h.config.Cache.TTLNegative <= 0 {
+		return false
+	}
+	for _, code := range h.config.Cache.NegativeStatuses {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// isBackoffStatus reports whether an origin status may carry a Retry-After
+// asking clients to back off
+func isBackoffStatus(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode == http.StatusServiceUnavailable
 }
 
 // handleError handles errors in a consistent way
 func (h *Handler) handleError(w http.ResponseWriter, r *http.Request, err error, statusCode int) {
+	// Proxy and token errors carry their own status
+	var proxyErr *ProxyError
+	var tokenErr *jwt.TokenError
+	if errors.As(err, &proxyErr) {
+		statusCode = proxyErr.Code
+	} else if errors.As(err, &tokenErr) {
+		statusCode = tokenErr.StatusCode
+	}
+	
 	// Log the error
-	h.logger.Error("Proxy error", "error", err.Error(), "path", r.URL.Path, "status", statusCode)
+	h.logger.Error("Proxy error", "error", h.redactError(err), "path", utils.RedactPath(r.URL.Path), "status", statusCode)
 	
 	// Increment error metric
 	h.metrics.IncCounter("error." + strconv.Itoa(statusCode))
 	
-	// JWT-specific errors
-	var tokenErr *jwt.TokenError
-	if errors.As(err, &tokenErr) {
-		// Use the status code from the token error
-		statusCode = tokenErr.StatusCode
-		
-		// Create API error response
-		apiErr := api.NewError(tokenErr.Error(), "token_error", statusCode)
-		api.WriteError(w, apiErr)
-		return
-	}
-	
-	// Generic error response
-	message := "Internal server error"
-	if statusCode == http.StatusBadRequest {
-		message = "Bad request"
-	} else if statusCode == http.StatusUnauthorized {
-		message = "Unauthorized"
-	} else if statusCode == http.StatusForbidden {
-		message = "Forbidden"
-	} else if statusCode == http.StatusNotFound {
-		message = "Not found"
-	} else if statusCode == http.StatusBadGateway {
-		message = "Origin server error"
+	// Retry hints from proxy errors
+	if proxyErr != nil && proxyErr.RetryAfter > 0 {
+		w.Header().Set("Retry-After", retryAfterSeconds(proxyErr.RetryAfter))
 	}
 	
-	apiErr := api.NewError(message, "proxy_error", statusCode)
-	api.WriteError(w, apiErr)
+	// Every error is sent as JSON with a stable machine-readable code
+	api.WriteError(w, errorResponse(err, statusCode))
 }
 
-// copyHeaders copies headers from src to dst
+// copyHeaders copies the client headers permitted by the forwarding policy
+// from src to dst
 func (h *Handler) copyHeaders(src, dst http.Header) {
-	for k, vv := range src {
-		// Skip certain headers
-		if strings.HasPrefix(strings.ToLower(k), "x-") {
-			continue
-		}
-		for _, v := range vv {
-			dst.Add(k, v)
-		}
+	if dropped := h.headerPolicy.copy(src, dst); dropped > 0 {
+		h.metrics.IncCounterBy("origin.headers.dropped", dropped)
 	}
 }
 
-// copyHeadersToResponse copies headers from origin response to client response
-func (h *Handler) copyHeadersToResponse(src, dst http.Header) {
-	for k, vv := range src {
-		// Skip content headers that we set specifically
-		if strings.ToLower(k) == "content-length" || strings.ToLower(k) == "content-type" {
+// applyOriginHeaders sets the headers the proxy sends to every origin.
+// Configured request headers are applied last and override copied ones.
+func (h *Handler) applyOriginHeaders(req *http.Request) {
+	if ua := h.config.Origin.UserAgent; ua != "" {
+		req.Header.Set("User-Agent", ua)
+	}
+	
+	for k, v := range h.config.Origin.RequestHeaders {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
 			continue
 		}
-		for _, v := range vv {
-			dst.Add(k, v)
-		}
+		req.Header.Set(k, v)
 	}
+}
+
+// copyHeadersToResponse copies the origin response headers permitted by the
+// response policy to the client response
+func (h *Handler) copyHeadersToResponse(src, dst http.Header) {
+	// Skip content headers that we set specifically
+	h.responsePolicy.copy(src, dst, "Content-Length", "Content-Type")
 }
\ No newline at end of file
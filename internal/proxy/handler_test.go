@@ -0,0 +1,362 @@
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// testConfig returns a default configuration proxying to originURL without
+// token checks
+func testConfig(originURL string) *config.Config {
+	cfg := &config.Config{}
+	config.SetDefaults(cfg)
+	cfg.JWT.Enabled = false
+	cfg.Origin.BaseURL = originURL
+	return cfg
+}
+
+// newTestHandler creates a handler for cfg with an in-memory cache
+func newTestHandler(t *testing.T, cfg *config.Config) *Handler {
+	t.Helper()
+	return NewHandler(HandlerOptions{
+		Config:  cfg,
+		Cache:   cache.NewMemory(),
+		Logger:  telemetry.NewLogger("error", "", "stdout"),
+		Metrics: telemetry.NewMetrics(),
+	})
+}
+
+// counter returns the value of a counter recorded in m
+func counter(m telemetry.Metrics, name string) int {
+	value, _ := m.(*telemetry.SimpleMetrics).DumpMetrics()["counter_"+name].(int)
+	return value
+}
+
+// serve sends a GET for target through h and returns the recorded response
+func serve(h http.Handler, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+	return rec
+}
+
+func TestHandlerServesFallbackPlaylist(t *testing.T) {
+	const slate = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nslate.ts\n#EXT-X-ENDLIST\n"
+	fallbackPath := filepath.Join(t.TempDir(), "slate.m3u8")
+	if err := os.WriteFile(fallbackPath, []byte(slate), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	downURL := down.URL
+	down.Close()
+
+	tests := []struct {
+		name         string
+		status       int
+		unreachable  bool
+		path         string
+		wantFallback bool
+		wantStatus   int
+	}{
+		{name: "unreachable origin", unreachable: true, path: "/live/index.m3u8", wantFallback: true, wantStatus: http.StatusOK},
+		{name: "origin server error", status: http.StatusInternalServerError, path: "/live/index.m3u8", wantFallback: true, wantStatus: http.StatusOK},
+		{name: "origin unavailable", status: http.StatusServiceUnavailable, path: "/live/index.m3u8", wantFallback: true, wantStatus: http.StatusOK},
+		{name: "origin not found", status: http.StatusNotFound, path: "/live/index.m3u8", wantStatus: http.StatusNotFound},
+		{name: "segment server error", status: http.StatusInternalServerError, path: "/live/seg1.ts", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originURL := downURL
+			if !tt.unreachable {
+				origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(tt.status)
+				}))
+				defer origin.Close()
+				originURL = origin.URL
+			}
+
+			cfg := testConfig(originURL)
+			cfg.Origin.FallbackPlaylist = fallbackPath
+			h := newTestHandler(t, cfg)
+
+			rec := serve(h, tt.path)
+			body, _ := io.ReadAll(rec.Body)
+			gotFallback := rec.Header().Get("X-Cache") == "FALLBACK"
+			if gotFallback != tt.wantFallback {
+				t.Fatalf("fallback served = %v, want %v (status %d)", gotFallback, tt.wantFallback, rec.Code)
+			}
+			if tt.wantFallback && string(body) != slate {
+				t.Errorf("body %q, want the fallback playlist", body)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestHandlerServesInitSegmentByteRanges(t *testing.T) {
+	media := make([]byte, 4096)
+	for i := range media {
+		media[i] = byte(i % 251)
+	}
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "media.mp4", time.Time{}, bytes.NewReader(media))
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.Cache.Enabled = true
+	h := newTestHandler(t, cfg)
+
+	// Requests run in order against one cache, as a player fetching the
+	// EXT-X-MAP range (720@0) and then the first segment would
+	tests := []struct {
+		name      string
+		byteRange string
+		wantCode  int
+		wantRange string
+		wantBody  []byte
+		wantCache string
+	}{
+		{"init segment", "bytes=0-719", http.StatusPartialContent, "bytes 0-719/4096", media[:720], "MISS"},
+		{"init segment cached", "bytes=0-719", http.StatusPartialContent, "bytes 0-719/4096", media[:720], "HIT"},
+		{"first segment", "bytes=720-1719", http.StatusPartialContent, "bytes 720-1719/4096", media[720:1720], "MISS"},
+		{"whole resource", "", http.StatusOK, "", media, "MISS"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/vod/media.mp4", nil)
+			if tt.byteRange != "" {
+				req.Header.Set("Range", tt.byteRange)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantCode)
+			}
+			if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+				t.Errorf("Content-Range %q, want %q", got, tt.wantRange)
+			}
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache %q, want %q", got, tt.wantCache)
+			}
+			if !bytes.Equal(rec.Body.Bytes(), tt.wantBody) {
+				t.Errorf("body of %d bytes does not match the requested range", rec.Body.Len())
+			}
+		})
+	}
+}
+
+func TestHandlerCachesNegativeResponses(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		ttlNegative time.Duration
+		wantCache   string
+		wantFetches int32
+	}{
+		{"not found cached", http.StatusNotFound, 5 * time.Second, "HIT", 1},
+		{"gone cached", http.StatusGone, 5 * time.Second, "HIT", 1},
+		{"server error not cached", http.StatusInternalServerError, 5 * time.Second, "", 2},
+		{"negative caching disabled", http.StatusNotFound, 0, "", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				w.WriteHeader(tt.status)
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.TTLNegative = tt.ttlNegative
+			h := newTestHandler(t, cfg)
+
+			serve(h, "/live/missing.ts")
+			rec := serve(h, "/live/missing.ts")
+
+			if rec.Code != tt.status {
+				t.Errorf("status %d, want %d", rec.Code, tt.status)
+			}
+			if got := rec.Header().Get("X-Cache"); got != tt.wantCache {
+				t.Errorf("X-Cache = %q, want %q", got, tt.wantCache)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+		})
+	}
+}
+
+// originHeaders serves target through a handler built from cfg and returns
+// the headers and host of the resulting origin request
+func originHeaders(t *testing.T, cfg func(*config.Config), target string, header http.Header) (http.Header, string) {
+	t.Helper()
+	var got http.Header
+	var host string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		host = r.Host
+	}))
+	defer origin.Close()
+
+	c := testConfig(origin.URL)
+	if cfg != nil {
+		cfg(c)
+	}
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, vv := range header {
+		req.Header[k] = vv
+	}
+	newTestHandler(t, c).ServeHTTP(httptest.NewRecorder(), req)
+	if got == nil {
+		t.Fatal("origin not reached")
+	}
+	return got, host
+}
+
+func TestHandlerOriginUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		client    string
+		want      string
+	}{
+		{"default replaces client", "Ilinden-HLS-Proxy", "Player/1.0", "Ilinden-HLS-Proxy"},
+		{"custom", "MyProxy/2.0", "Player/1.0", "MyProxy/2.0"},
+		{"empty keeps client", "", "Player/1.0", "Player/1.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, _ := originHeaders(t, func(c *config.Config) {
+				c.Origin.UserAgent = tt.userAgent
+			}, "/live/seg1.ts", http.Header{"User-Agent": {tt.client}})
+			if got := headers.Get("User-Agent"); got != tt.want {
+				t.Errorf("origin User-Agent = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if ua := testConfig("").Origin.UserAgent; ua != "Ilinden-HLS-Proxy" {
+		t.Errorf("default userAgent = %q", ua)
+	}
+}
+
+func TestHandlerOriginRequestHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		client   http.Header
+		check    string
+		want     string
+		wantHost string
+	}{
+		{"static header added", map[string]string{"X-Api-Key": "secret"}, nil, "X-Api-Key", "secret", ""},
+		{"overrides client header", map[string]string{"X-Customer": "proxy"}, http.Header{"X-Customer": {"client"}}, "X-Customer", "proxy", ""},
+		{"overrides user agent", map[string]string{"User-Agent": "Custom/1.0"}, nil, "User-Agent", "Custom/1.0", ""},
+		{"host header", map[string]string{"Host": "cdn.example.com"}, nil, "", "", "cdn.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers, host := originHeaders(t, func(c *config.Config) {
+				c.Origin.RequestHeaders = tt.headers
+			}, "/live/seg1.ts", tt.client)
+			if tt.check != "" {
+				if got := headers.Get(tt.check); got != tt.want {
+					t.Errorf("origin %s = %q, want %q", tt.check, got, tt.want)
+				}
+			}
+			if tt.wantHost != "" && host != tt.wantHost {
+				t.Errorf("origin Host = %q, want %q", host, tt.wantHost)
+			}
+		})
+	}
+}
+
+func TestHandlerRawTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		maxTTL      time.Duration
+		want        time.Duration
+	}{
+		{"configured type", "video/mp4", 0, time.Hour},
+		{"wildcard type", "text/vtt; charset=utf-8", 0, 30 * time.Second},
+		{"unconfigured type uses media TTL", "application/octet-stream", 0, 2 * time.Second},
+		{"ceiling applies", "video/mp4", time.Minute, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("https://origin.example.com")
+			cfg.Cache.TTLMedia = 2 * time.Second
+			cfg.Cache.MaxTTL = tt.maxTTL
+			cfg.Cache.TTLByContentType = map[string]time.Duration{"video/mp4": time.Hour, "text/*": 30 * time.Second}
+			if got := newTestHandler(t, cfg).rawTTL(tt.contentType); got != tt.want {
+				t.Errorf("rawTTL(%q) = %v, want %v", tt.contentType, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerPlaylistTTL(t *testing.T) {
+	const (
+		master   = "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nlow.m3u8\n"
+		live     = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"
+		complete = live + "#EXT-X-ENDLIST\n"
+		event    = "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:EVENT\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"
+		vod      = "#EXTM3U\n#EXT-X-PLAYLIST-TYPE:VOD\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"
+	)
+
+	tests := []struct {
+		name     string
+		content  string
+		ttlVOD   time.Duration
+		ttlEvent time.Duration
+		maxTTL   time.Duration
+		want     time.Duration
+	}{
+		{"master", master, 5 * time.Minute, 0, 0, 10 * time.Second},
+		{"live media", live, 5 * time.Minute, 0, 0, 2 * time.Second},
+		{"complete media", complete, 5 * time.Minute, 0, 0, 5 * time.Minute},
+		{"VOD TTL disabled", complete, 0, 0, 0, 2 * time.Second},
+		{"VOD TTL capped", complete, 5 * time.Minute, 0, time.Minute, time.Minute},
+		{"VOD type without ENDLIST", vod, 5 * time.Minute, 0, 0, 5 * time.Minute},
+		{"EVENT type", event, 5 * time.Minute, 4 * time.Second, 0, 4 * time.Second},
+		{"EVENT TTL disabled", event, 5 * time.Minute, 0, 0, 2 * time.Second},
+		{"ended EVENT is immutable", event + "#EXT-X-ENDLIST\n", 5 * time.Minute, 4 * time.Second, 0, 5 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("https://origin.example.com")
+			cfg.Cache.TTLMaster = 10 * time.Second
+			cfg.Cache.TTLMedia = 2 * time.Second
+			cfg.Cache.TTLVOD = tt.ttlVOD
+			cfg.Cache.TTLEvent = tt.ttlEvent
+			cfg.Cache.MaxTTL = tt.maxTTL
+			if got := newTestHandler(t, cfg).playlistTTL([]byte(tt.content)); got != tt.want {
+				t.Errorf("playlistTTL() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
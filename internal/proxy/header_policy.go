@@ -0,0 +1,157 @@
+// Origin header forwarding policy
+//
+// Controls which client headers reach the origin:
+// - Optional allowlist of header names
+// - Denylist that always wins
+// - Cap on the total size of forwarded headers
+// - Deterministic truncation in header name order
+//
+// And which origin response headers reach the client:
+// - Optional allowlist and a denylist of internal headers
+// - Content framing headers always pass
+
+package proxy
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+// headerPolicy decides which client request headers are forwarded
+type headerPolicy struct {
+	allow    map[string]bool // Canonical names; empty forwards all but X- headers
+	deny     map[string]bool
+	maxBytes int // Total forwarded header size, 0 = unlimited
+}
+
+// newHeaderPolicy creates the forwarding policy from configuration
+func newHeaderPolicy(cfg *config.OriginConfig) *headerPolicy {
+	return &headerPolicy{
+		allow:    headerNameSet(cfg.ForwardHeaders),
+		deny:     headerNameSet(cfg.DropHeaders),
+		maxBytes: cfg.MaxForwardedHeaderBytes,
+	}
+}
+
+// headerNameSet builds a lookup set of canonical header names
+func headerNameSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+	return set
+}
+
+// forwards reports whether a header may be forwarded. Without an allowlist
+// all headers except X- headers are forwarded.
+func (p *headerPolicy) forwards(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if p.deny[name] {
+		return false
+	}
+	if len(p.allow) > 0 {
+		return p.allow[name]
+	}
+	return !strings.HasPrefix(strings.ToLower(name), "x-")
+}
+
+// copy forwards the permitted headers from src to dst. Headers that would
+// push the total past the size cap are dropped whole, visiting names in
+// sorted order so the outcome does not depend on map iteration. It returns
+// the number of headers dropped for size.
+func (p *headerPolicy) copy(src, dst http.Header) int {
+	names := make([]string, 0, len(src))
+	for name := range src {
+		if p.forwards(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	total, dropped := 0, 0
+	for _, name := range names {
+		values := src[name]
+		if p.maxBytes > 0 {
+			size := headerSize(name, values)
+			if total+size > p.maxBytes {
+				dropped++
+				continue
+			}
+			total += size
+		}
+		for _, v := range values {
+			dst.Add(name, v)
+		}
+	}
+	return dropped
+}
+
+// headerSize returns the wire size of a header's lines, "Name: value\r\n"
+func headerSize(name string, values []string) int {
+	size := 0
+	for _, v := range values {
+		size += len(name) + len(v) + 4
+	}
+	return size
+}
+
+// framingHeaders describe the response body and always reach the client;
+// dropping one would make the body unreadable
+var framingHeaders = map[string]bool{
+	"Content-Encoding": true,
+	"Content-Length":   true,
+	"Content-Range":    true,
+	"Content-Type":     true,
+}
+
+// responseHeaderPolicy decides which origin response headers are passed to
+// the client
+type responseHeaderPolicy struct {
+	allow map[string]bool // Canonical names; empty passes all
+	deny  map[string]bool
+}
+
+// newResponseHeaderPolicy creates the response policy from configuration
+func newResponseHeaderPolicy(cfg *config.OriginConfig) *responseHeaderPolicy {
+	return &responseHeaderPolicy{
+		allow: headerNameSet(cfg.ResponseForwardHeaders),
+		deny:  headerNameSet(cfg.ResponseDropHeaders),
+	}
+}
+
+// forwards reports whether an origin response header may reach the client
+func (p *responseHeaderPolicy) forwards(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if framingHeaders[name] {
+		return true
+	}
+	if p.deny[name] {
+		return false
+	}
+	return len(p.allow) == 0 || p.allow[name]
+}
+
+// copy adds the permitted headers from src to dst, except those in skip
+func (p *responseHeaderPolicy) copy(src, dst http.Header, skip ...string) {
+	for name, values := range src {
+		if !p.forwards(name) || containsHeader(skip, name) {
+			continue
+		}
+		for _, v := range values {
+			dst.Add(name, v)
+		}
+	}
+}
+
+// containsHeader reports whether names holds name, ignoring case
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
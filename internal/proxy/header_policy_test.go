@@ -0,0 +1,181 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestHeaderPolicyCopy(t *testing.T) {
+	client := http.Header{
+		"Accept":       {"*/*"},
+		"Range":        {"bytes=0-99"},
+		"Cookie":       {"session=abc"},
+		"X-Request-Id": {"req-1"},
+		"X-Custom":     {"value"},
+	}
+
+	tests := []struct {
+		name        string
+		cfg         config.OriginConfig
+		want        []string
+		wantDropped int
+	}{
+		{
+			name: "default skips X- headers",
+			want: []string{"Accept", "Cookie", "Range"},
+		},
+		{
+			name: "allowlist",
+			cfg:  config.OriginConfig{ForwardHeaders: []string{"range", " X-Request-Id "}},
+			want: []string{"Range", "X-Request-Id"},
+		},
+		{
+			name: "denylist",
+			cfg:  config.OriginConfig{DropHeaders: []string{"cookie"}},
+			want: []string{"Accept", "Range"},
+		},
+		{
+			name: "denylist wins over allowlist",
+			cfg:  config.OriginConfig{ForwardHeaders: []string{"Cookie", "Range"}, DropHeaders: []string{"Cookie"}},
+			want: []string{"Range"},
+		},
+		{
+			// Accept (13 bytes) and Cookie (21) fit in 40; Range (19) does not
+			name:        "size cap drops whole headers in name order",
+			cfg:         config.OriginConfig{MaxForwardedHeaderBytes: 40},
+			want:        []string{"Accept", "Cookie"},
+			wantDropped: 1,
+		},
+		{
+			name:        "size cap below every header",
+			cfg:         config.OriginConfig{MaxForwardedHeaderBytes: 5},
+			want:        []string{},
+			wantDropped: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := http.Header{}
+			dropped := newHeaderPolicy(&tt.cfg).copy(client, dst)
+
+			got := []string{}
+			for name := range dst {
+				got = append(got, name)
+			}
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("forwarded %v, want %v", got, tt.want)
+			}
+			if dropped != tt.wantDropped {
+				t.Errorf("dropped %d for size, want %d", dropped, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestHeaderSize(t *testing.T) {
+	if got := headerSize("Range", []string{"bytes=0-99", "bytes=100-"}); got != 2*len("Range: \r\n")+len("bytes=0-99")+len("bytes=100-") {
+		t.Errorf("headerSize = %d", got)
+	}
+}
+
+func TestHandlerForwardedHeaderPolicy(t *testing.T) {
+	header := http.Header{"Cookie": {"session=abc"}, "Range": {"bytes=0-99"}}
+	got, _ := originHeaders(t, func(c *config.Config) {
+		c.Origin.DropHeaders = []string{"Cookie"}
+	}, "/live/seg1.ts", header)
+
+	if got.Get("Cookie") != "" {
+		t.Error("dropped header reached the origin")
+	}
+	if got.Get("Range") != "bytes=0-99" {
+		t.Errorf("Range %q, want forwarded", got.Get("Range"))
+	}
+}
+
+func TestResponseHeaderPolicyForwards(t *testing.T) {
+	defaults := &config.Config{}
+	config.SetDefaults(defaults)
+
+	tests := []struct {
+		name   string
+		cfg    config.OriginConfig
+		header string
+		want   bool
+	}{
+		{"default drops Server", defaults.Origin, "server", false},
+		{"default drops X-Powered-By", defaults.Origin, "X-Powered-By", false},
+		{"default passes others", defaults.Origin, "Cache-Control", true},
+		{"allowlist passes listed", config.OriginConfig{ResponseForwardHeaders: []string{"cache-control"}}, "Cache-Control", true},
+		{"allowlist drops unlisted", config.OriginConfig{ResponseForwardHeaders: []string{"Cache-Control"}}, "ETag", false},
+		{"denylist wins over allowlist", config.OriginConfig{ResponseForwardHeaders: []string{"ETag"}, ResponseDropHeaders: []string{"ETag"}}, "ETag", false},
+		{"framing passes the allowlist", config.OriginConfig{ResponseForwardHeaders: []string{"ETag"}}, "Content-Range", true},
+		{"framing passes the denylist", config.OriginConfig{ResponseDropHeaders: []string{"Content-Encoding"}}, "Content-Encoding", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := newResponseHeaderPolicy(&tt.cfg).forwards(tt.header); got != tt.want {
+				t.Errorf("forwards(%s) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerResponseHeaderPolicy(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Header().Set("Server", "nginx/1.2.3")
+		w.Header().Set("X-Backend-Server", "edge-7.internal")
+		w.Header().Set("X-Internal-Route", "pool-b")
+		w.Header().Set("ETag", `"abc"`)
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name    string
+		target  string
+		drop    []string
+		allowed []string
+		denied  []string
+	}{
+		{"default denylist", "/live/seg1.ts", nil, []string{"ETag", "X-Internal-Route"}, []string{"Server", "X-Backend-Server"}},
+		{"configured denylist", "/live/seg1.ts", []string{"X-Internal-Route"}, []string{"ETag", "Server"}, []string{"X-Internal-Route"}},
+		{"passthrough", "/raw/seg1.ts", nil, []string{"ETag"}, []string{"Server", "X-Backend-Server"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.Origin.PassthroughPatterns = []string{`^/raw/`}
+			if tt.drop != nil {
+				cfg.Origin.ResponseDropHeaders = tt.drop
+			}
+			rec := serve(newTestHandler(t, cfg), tt.target)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+
+			for _, name := range tt.allowed {
+				if rec.Header().Get(name) == "" {
+					t.Errorf("%s not forwarded", name)
+				}
+			}
+			for _, name := range tt.denied {
+				if got := rec.Header().Get(name); got != "" {
+					t.Errorf("%s reached the client: %q", name, got)
+				}
+			}
+			if got := rec.Header().Get("Content-Type"); got != "video/mp2t" {
+				t.Errorf("Content-Type %q, want video/mp2t", got)
+			}
+		})
+	}
+}
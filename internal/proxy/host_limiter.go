@@ -0,0 +1,74 @@
+// Per-host origin concurrency
+//
+// Limits concurrent outbound fetches to each origin host:
+// - One semaphore per host, created on demand
+// - Waiters bounded by the request context
+// - Slots held until the response body is closed
+// - Wait times recorded as metrics
+
+package proxy
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// hostLimiter bounds the number of in-flight fetches per origin host
+type hostLimiter struct {
+	limit   int
+	metrics telemetry.Metrics
+	mu      sync.Mutex
+	hosts   map[string]chan struct{}
+}
+
+// newHostLimiter creates a limiter, or nil if limit disables it
+func newHostLimiter(limit int, metrics telemetry.Metrics) *hostLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &hostLimiter{
+		limit:   limit,
+		metrics: metrics,
+		hosts:   make(map[string]chan struct{}),
+	}
+}
+
+// acquire waits for a fetch slot for host until ctx is done. The returned
+// function releases the slot; calls after the first do nothing.
+func (l *hostLimiter) acquire(ctx context.Context, host string) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	l.mu.Lock()
+	sem, ok := l.hosts[host]
+	if !ok {
+		sem = make(chan struct{}, l.limit)
+		l.hosts[host] = sem
+	}
+	l.mu.Unlock()
+
+	var once sync.Once
+	release := func() { once.Do(func() { <-sem }) }
+
+	// Fast path without recording a wait
+	select {
+	case sem <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	start := time.Now()
+	l.metrics.IncCounter("origin.host_wait")
+	select {
+	case sem <- struct{}{}:
+		l.metrics.ObserveHistogram("origin.host_wait_ms", float64(time.Since(start).Milliseconds()))
+		return release, nil
+	case <-ctx.Done():
+		l.metrics.IncCounter("origin.host_wait.timeout")
+		return nil, ctx.Err()
+	}
+}
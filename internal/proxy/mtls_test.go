@@ -0,0 +1,227 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// testPKI holds a CA and PEM files of a server and client certificate it
+// issued
+type testPKI struct {
+	pool       *x509.CertPool
+	serverCert tls.Certificate
+	caFile     string
+	certFile   string
+	keyFile    string
+}
+
+// newTestPKI issues a server certificate for 127.0.0.1 and a client
+// certificate from a fresh CA, writing the CA and client files to a
+// temporary directory
+func newTestPKI(t *testing.T) *testPKI {
+	t.Helper()
+	dir := t.TempDir()
+
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	issue := func(serial int64, usage x509.ExtKeyUsage) ([]byte, *ecdsa.PrivateKey) {
+		key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "test"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+			IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return der, key
+	}
+	writePEM := func(name, blockType string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	serverDER, serverKey := issue(2, x509.ExtKeyUsageServerAuth)
+	clientDER, clientKey := issue(3, x509.ExtKeyUsageClientAuth)
+	clientKeyDER, _ := x509.MarshalECPrivateKey(clientKey)
+
+	pki := &testPKI{
+		pool:       x509.NewCertPool(),
+		serverCert: tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey},
+		caFile:     writePEM("ca.pem", "CERTIFICATE", caDER),
+		certFile:   writePEM("client.pem", "CERTIFICATE", clientDER),
+		keyFile:    writePEM("client-key.pem", "EC PRIVATE KEY", clientKeyDER),
+	}
+	pki.pool.AddCert(ca)
+	return pki
+}
+
+// newMTLSOrigin starts an origin requiring a client certificate from pki
+func newMTLSOrigin(t *testing.T, pki *testPKI) *httptest.Server {
+	t.Helper()
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Write([]byte("segment"))
+	}))
+	origin.TLS = &tls.Config{
+		Certificates: []tls.Certificate{pki.serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pki.pool,
+	}
+	// Rejected handshakes are expected
+	origin.Config.ErrorLog = log.New(io.Discard, "", 0)
+	origin.StartTLS()
+	t.Cleanup(origin.Close)
+	return origin
+}
+
+func TestHandlerOriginClientCertificates(t *testing.T) {
+	pki := newTestPKI(t)
+	origin := newMTLSOrigin(t, pki)
+	withCert := config.OriginTLSConfig{CertFile: pki.certFile, KeyFile: pki.keyFile, CAFile: pki.caFile}
+	caOnly := config.OriginTLSConfig{CAFile: pki.caFile}
+
+	tests := []struct {
+		name       string
+		defaultTLS config.OriginTLSConfig
+		routeTLS   *config.OriginTLSConfig
+		target     string
+		wantStatus int
+	}{
+		{"client certificate", withCert, nil, "/live/seg1.ts", http.StatusOK},
+		{"no client certificate", caOnly, nil, "/live/seg1.ts", http.StatusBadGateway},
+		{"route certificate", caOnly, &withCert, "/secure/seg1.ts", http.StatusOK},
+		{"route without certificate", withCert, &caOnly, "/secure/seg1.ts", http.StatusBadGateway},
+		{"default certificate beside a route", withCert, &caOnly, "/live/seg1.ts", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.Origin.TLS = tt.defaultTLS
+			if tt.routeTLS != nil {
+				cfg.Origin.Routes = []config.OriginRoute{{Name: "secure", PathPrefix: "/secure/", BaseURL: origin.URL, StripPrefix: true, TLS: tt.routeTLS}}
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+
+			rec := serve(newTestHandler(t, cfg), tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerOriginCustomCA(t *testing.T) {
+	pki := newTestPKI(t)
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		w.Write([]byte("segment"))
+	}))
+	origin.TLS = &tls.Config{Certificates: []tls.Certificate{pki.serverCert}}
+	origin.Config.ErrorLog = log.New(io.Discard, "", 0)
+	origin.StartTLS()
+	defer origin.Close()
+
+	tests := []struct {
+		name       string
+		tls        config.OriginTLSConfig
+		wantStatus int
+	}{
+		{"untrusted CA", config.OriginTLSConfig{}, http.StatusBadGateway},
+		{"trusted CA", config.OriginTLSConfig{CAFile: pki.caFile}, http.StatusOK},
+		{"verification skipped", config.OriginTLSConfig{InsecureSkipVerify: true}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.Origin.TLS = tt.tls
+			rec := serve(newTestHandler(t, cfg), "/live/seg1.ts")
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+// warnRecorder records the messages of warnings
+type warnRecorder struct {
+	telemetry.Logger
+	warnings []string
+}
+
+func (w *warnRecorder) Warn(msg string, args ...interface{}) {
+	w.warnings = append(w.warnings, msg)
+}
+
+func TestWarnInsecureOrigins(t *testing.T) {
+	tests := []struct {
+		name         string
+		defaultSkip  bool
+		routeTLS     *config.OriginTLSConfig
+		wantWarnings int
+	}{
+		{"verified", false, nil, 0},
+		{"default skipped", true, nil, 1},
+		{"route skipped", false, &config.OriginTLSConfig{InsecureSkipVerify: true}, 1},
+		{"route verified", false, &config.OriginTLSConfig{}, 0},
+		{"both skipped", true, &config.OriginTLSConfig{InsecureSkipVerify: true}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("https://origin.example.com")
+			cfg.Origin.TLS.InsecureSkipVerify = tt.defaultSkip
+			cfg.Origin.Routes = []config.OriginRoute{{Name: "r", PathPrefix: "/r/", BaseURL: "https://r.example.com", TLS: tt.routeTLS}}
+
+			logger := &warnRecorder{Logger: telemetry.NewLogger("error", "", "stdout")}
+			warnInsecureOrigins(&cfg.Origin, logger)
+			if len(logger.warnings) != tt.wantWarnings {
+				t.Errorf("warnings %q, want %d", logger.warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
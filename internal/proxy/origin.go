@@ -18,6 +18,7 @@ import (
 
 	"github.com/ilijajolevski/ilinden/internal/config"
 	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // OriginHandler manages communication with origin servers
@@ -38,6 +39,9 @@ type OriginRequest struct {
 
 // NewOriginHandler creates a new origin handler
 func NewOriginHandler(config *config.OriginConfig, metrics telemetry.Metrics, logger telemetry.Logger) *OriginHandler {
+	// Client certificates and CAs are validated with the config
+	tlsConfig, _ := config.TLS.ClientConfig()
+
 	// Create transport with connection pooling
 	transport := &http.Transport{
 		MaxIdleConns:          config.MaxIdleConns,
@@ -46,6 +50,7 @@ func NewOriginHandler(config *config.OriginConfig, metrics telemetry.Metrics, lo
 		IdleConnTimeout:       config.IdleConnTimeout,
 		TLSHandshakeTimeout:   config.TLSHandshakeTimeout,
 		ExpectContinueTimeout: config.ExpectContinueTimeout,
+		TLSClientConfig:       tlsConfig,
 	}
 
 	// Create client with timeout
@@ -62,6 +67,12 @@ func NewOriginHandler(config *config.OriginConfig, metrics telemetry.Metrics, lo
 	}
 }
 
+// SetTransport replaces the transport used for origin requests, e.g. with a
+// stub in tests or a client-certificate transport
+func (h *OriginHandler) SetTransport(rt http.RoundTripper) {
+	h.client.Transport = rt
+}
+
 // Do sends a request to the origin server
 func (h *OriginHandler) Do(ctx context.Context, req *OriginRequest) (*http.Response, error) {
 	// Start timing
@@ -84,12 +95,12 @@ func (h *OriginHandler) Do(ctx context.Context, req *OriginRequest) (*http.Respo
 	resp, err := h.client.Do(httpReq)
 	
 	// Record metrics
-	h.metrics.ObserveOriginDuration(req.URL.Host, time.Since(startTime))
+	h.metrics.ObserveOriginDuration(utils.CanonicalHost(req.URL.Scheme, req.URL.Host), time.Since(startTime))
 	
 	// Handle errors
 	if err != nil {
 		h.metrics.IncCounter("origin.error")
-		h.logger.Error("Origin request failed", "error", err.Error(), "url", req.URL.String())
+		h.logger.Error("Origin request failed", "error", err.Error(), "url", utils.RedactURL(req.URL, nil))
 		return nil, h.mapError(err)
 	}
 	
@@ -0,0 +1,162 @@
+// Origin health checking
+//
+// Periodic origin reachability probing:
+// - HEAD requests to a configured health path
+// - Failure and success thresholds against flapping
+// - Readiness state for the /readyz endpoint
+// - Probe state for status documents
+
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// OriginHealthStatus reports the probe state for status documents
+type OriginHealthStatus struct {
+	URL                  string `json:"url"`
+	Healthy              bool   `json:"healthy"`
+	ConsecutiveFailures  int    `json:"consecutiveFailures"`
+	ConsecutiveSuccesses int    `json:"consecutiveSuccesses"`
+}
+
+// OriginHealth probes the default origin and tracks whether it is reachable
+type OriginHealth struct {
+	cfg       config.OriginHealthConfig
+	probeURL  string
+	client    *http.Client
+	logger    telemetry.Logger
+	mu        sync.RWMutex
+	healthy   bool
+	failures  int
+	successes int
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// NewOriginHealth creates an origin health checker probing baseURL joined
+// with the configured path. The origin is considered healthy until the
+// failure threshold is reached.
+func NewOriginHealth(cfg config.OriginHealthConfig, baseURL string, logger telemetry.Logger) (*OriginHealth, error) {
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	probe, err := url.Parse(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = 1
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = 1
+	}
+
+	return &OriginHealth{
+		cfg:      cfg,
+		probeURL: base.ResolveReference(probe).String(),
+		client:   &http.Client{Timeout: cfg.Timeout},
+		logger:   logger,
+		healthy:  true,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+// Start begins probing in the background, running the first probe immediately
+func (o *OriginHealth) Start() {
+	go func() {
+		defer close(o.done)
+
+		ticker := time.NewTicker(o.cfg.Interval)
+		defer ticker.Stop()
+
+		for {
+			o.record(o.probe())
+
+			select {
+			case <-ticker.C:
+			case <-o.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops probing and waits for the background loop to exit
+func (o *OriginHealth) Stop() {
+	close(o.stop)
+	<-o.done
+}
+
+// Ready reports whether the origin is currently considered reachable
+func (o *OriginHealth) Ready() bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.healthy
+}
+
+// Status returns the current probe state
+func (o *OriginHealth) Status() OriginHealthStatus {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return OriginHealthStatus{
+		URL:                  o.probeURL,
+		Healthy:              o.healthy,
+		ConsecutiveFailures:  o.failures,
+		ConsecutiveSuccesses: o.successes,
+	}
+}
+
+// probe sends a single HEAD request to the origin. Any response below 500
+// counts as reachable; the probe checks connectivity, not content.
+func (o *OriginHealth) probe() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), o.cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, o.probeURL, nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+// record updates the health state, switching only after the configured
+// number of consecutive failures or successes
+func (o *OriginHealth) record(ok bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if ok {
+		o.failures = 0
+		o.successes++
+		if !o.healthy && o.successes >= o.cfg.SuccessThreshold {
+			o.healthy = true
+			o.logger.Info("Origin is reachable again", "url", o.probeURL)
+		}
+		return
+	}
+
+	o.successes = 0
+	o.failures++
+	if o.healthy && o.failures >= o.cfg.FailureThreshold {
+		o.healthy = false
+		o.logger.Warn("Origin is unreachable", "url", o.probeURL, "failures", o.failures)
+	}
+}
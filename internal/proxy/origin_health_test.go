@@ -0,0 +1,93 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// healthConfig returns an origin health configuration with the given
+// thresholds
+func healthConfig(failures, successes int) config.OriginHealthConfig {
+	return config.OriginHealthConfig{
+		Enabled:          true,
+		Path:             "/health",
+		Interval:         time.Hour,
+		Timeout:          time.Second,
+		FailureThreshold: failures,
+		SuccessThreshold: successes,
+	}
+}
+
+func TestOriginHealthThresholds(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		successes int
+		probes    []bool
+		want      []bool // Readiness after each probe
+	}{
+		{"single failure tolerated", 3, 2, []bool{false, true, false}, []bool{true, true, true}},
+		{"failure threshold reached", 3, 2, []bool{false, false, false}, []bool{true, true, false}},
+		{"success threshold to recover", 1, 2, []bool{false, true, false, true, true}, []bool{false, false, false, false, true}},
+		{"zero thresholds act as one", 0, 0, []bool{false, true}, []bool{false, true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := NewOriginHealth(healthConfig(tt.failures, tt.successes), "https://origin.example.com", telemetry.NewLogger("error", "", "stdout"))
+			if err != nil {
+				t.Fatalf("NewOriginHealth: %v", err)
+			}
+			for i, ok := range tt.probes {
+				h.record(ok)
+				if got := h.Ready(); got != tt.want[i] {
+					t.Errorf("after probe %d (%v): ready = %v, want %v", i, ok, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOriginHealthProbe(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		down   bool
+		want   bool
+	}{
+		{"ok", http.StatusOK, false, true},
+		{"not found is reachable", http.StatusNotFound, false, true},
+		{"server error", http.StatusServiceUnavailable, false, false},
+		{"connection refused", http.StatusOK, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var method, path string
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				method, path = r.Method, r.URL.Path
+				w.WriteHeader(tt.status)
+			}))
+			defer origin.Close()
+			if tt.down {
+				origin.Close()
+			}
+
+			h, err := NewOriginHealth(healthConfig(1, 1), origin.URL, telemetry.NewLogger("error", "", "stdout"))
+			if err != nil {
+				t.Fatalf("NewOriginHealth: %v", err)
+			}
+			if got := h.probe(); got != tt.want {
+				t.Errorf("probe = %v, want %v", got, tt.want)
+			}
+			if !tt.down && (method != http.MethodHead || path != "/health") {
+				t.Errorf("probe sent %s %s, want HEAD /health", method, path)
+			}
+		})
+	}
+}
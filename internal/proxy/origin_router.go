@@ -0,0 +1,351 @@
+// Origin selection
+//
+// Routes requests to one of several origins:
+// - Host-based matching
+// - Path prefix matching
+// - Per-origin timeouts
+// - Target host allowlists
+// - Backup origins for failover
+// - Per-host fetch concurrency limits
+// - Fallback playlists while origins are down
+
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// ErrTargetNotAllowed is returned when a target URL points at a host
+// that is not in the selected origin's allowlist
+var ErrTargetNotAllowed = errors.New("target host not allowed")
+
+// upstream is one origin server a route can send requests to
+type upstream struct {
+	name    string
+	baseURL *url.URL
+	breaker *utils.CircuitBreaker
+}
+
+// originRoute is a resolved origin routing entry
+type originRoute struct {
+	name         string
+	host         string
+	pathPrefix   string
+	stripPrefix  bool
+	upstreams    []*upstream // Primary first, then backups in order
+	allowedHosts map[string]bool
+	proxyHosts   map[string]bool // Origin hosts whose absolute playlist URLs go through the proxy
+	client       *http.Client
+	timeout      time.Duration // Response header timeout
+	bodyTimeout  time.Duration // Maximum stall while reading the body
+	limiter      *hostLimiter  // Concurrent fetches per origin host, shared by all routes
+	tokenParam   string        // Token parameter expected by the origin, overriding the JWT one
+	fallback     []byte        // Playlist served when the origins cannot be reached
+}
+
+// UpstreamStatus reports an upstream's circuit breaker for status documents
+type UpstreamStatus struct {
+	Route    string `json:"route"`
+	Upstream string `json:"upstream"`
+	Breaker  string `json:"breaker"`
+	HeldFor  string `json:"heldFor,omitempty"` // Remaining Retry-After hold
+}
+
+// OriginRouter selects the origin that serves a request
+type OriginRouter struct {
+	routes   []*originRoute
+	fallback *originRoute
+}
+
+// NewOriginRouter creates an origin router from configuration. Routes share
+// the default client unless they set their own TLS; routes may override the
+// header timeout.
+func NewOriginRouter(cfg *config.OriginConfig, defaultClient *http.Client) (*OriginRouter, error) {
+	router := &OriginRouter{
+		fallback: &originRoute{
+			name:         "default",
+			allowedHosts: hostSet(cfg.AllowedHosts),
+			client:       defaultClient,
+			timeout:      cfg.Timeout,
+			bodyTimeout:  cfg.BodyTimeout,
+		},
+	}
+
+	if cfg.BaseURL != "" {
+		upstreams, err := newUpstreams("default", cfg.BaseURL, cfg.Backups, cfg)
+		if err != nil {
+			return nil, err
+		}
+		router.fallback.upstreams = upstreams
+	}
+	router.fallback.proxyHosts = proxyHostSet(router.fallback.upstreams, cfg.ProxyHosts)
+	fallback, err := readFallback(cfg.FallbackPlaylist)
+	if err != nil {
+		return nil, err
+	}
+	router.fallback.fallback = fallback
+
+	for _, rc := range cfg.Routes {
+		upstreams, err := newUpstreams(rc.Name, rc.BaseURL, rc.Backups, cfg)
+		if err != nil {
+			return nil, err
+		}
+		client, err := routeClient(defaultClient, rc.TLS)
+		if err != nil {
+			return nil, err
+		}
+
+		route := &originRoute{
+			name:         upstreams[0].name,
+			host:         utils.CanonicalHostname(rc.Host),
+			pathPrefix:   rc.PathPrefix,
+			stripPrefix:  rc.StripPrefix,
+			upstreams:    upstreams,
+			allowedHosts: hostSet(rc.AllowedHosts),
+			proxyHosts:   proxyHostSet(upstreams, cfg.ProxyHosts),
+			client:       client,
+			timeout:      cfg.Timeout,
+			bodyTimeout:  cfg.BodyTimeout,
+			tokenParam:   rc.TokenParam,
+			fallback:     router.fallback.fallback,
+		}
+
+		if rc.Timeout > 0 {
+			route.timeout = rc.Timeout
+		}
+		if rc.FallbackPlaylist != "" {
+			if route.fallback, err = readFallback(rc.FallbackPlaylist); err != nil {
+				return nil, err
+			}
+		}
+
+		router.routes = append(router.routes, route)
+	}
+
+	return router, nil
+}
+
+// readFallback loads a fallback playlist file, nil if none is configured
+func readFallback(path string) ([]byte, error) {
+	if path == "" {
+		return nil, nil
+	}
+	return os.ReadFile(path)
+}
+
+// newUpstreams builds the primary and backup upstreams of a route, each with
+// its own circuit breaker when breaking is enabled
+func newUpstreams(name, baseURL string, backups []string, cfg *config.OriginConfig) ([]*upstream, error) {
+	var upstreams []*upstream
+	for i, raw := range append([]string{baseURL}, backups...) {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		up := &upstream{
+			name:    utils.CanonicalHost(u.Scheme, u.Host),
+			baseURL: u,
+		}
+		if i == 0 && name != "" {
+			up.name = name
+		}
+		if cfg.CircuitBreaker {
+			up.breaker = utils.NewCircuitBreaker(cfg.BreakerThreshold, cfg.BreakerCooldown)
+		}
+		upstreams = append(upstreams, up)
+	}
+	return upstreams, nil
+}
+
+// Status reports every upstream of every route, the default origin first
+func (o *OriginRouter) Status() []UpstreamStatus {
+	var status []UpstreamStatus
+	for _, route := range append([]*originRoute{o.fallback}, o.routes...) {
+		for _, up := range route.upstreams {
+			s := UpstreamStatus{
+				Route:    route.name,
+				Upstream: up.name,
+				Breaker:  up.breaker.State(),
+			}
+			if held := up.breaker.Held(); held > 0 {
+				s.HeldFor = held.Round(time.Second).String()
+			}
+			status = append(status, s)
+		}
+	}
+	return status
+}
+
+// CloseIdleConnections closes the idle origin connections of every route
+// client
+func (o *OriginRouter) CloseIdleConnections() {
+	for _, route := range append([]*originRoute{o.fallback}, o.routes...) {
+		route.client.CloseIdleConnections()
+	}
+}
+
+// Match returns the most specific route for the request, falling back to
+// the default origin. Longer path prefixes win; host matches break ties.
+func (o *OriginRouter) Match(r *http.Request) *originRoute {
+	host := requestHost(r)
+	requestPath := utils.CleanPath(r.URL.Path)
+
+	var best *originRoute
+	for _, route := range o.routes {
+		if route.host != "" && route.host != host {
+			continue
+		}
+		if route.pathPrefix != "" && !strings.HasPrefix(requestPath, route.pathPrefix) {
+			continue
+		}
+
+		if best == nil ||
+			len(route.pathPrefix) > len(best.pathPrefix) ||
+			(len(route.pathPrefix) == len(best.pathPrefix) && route.host != "" && best.host == "") {
+			best = route
+		}
+	}
+
+	if best == nil {
+		return o.fallback
+	}
+	return best
+}
+
+// setLimiter applies a shared per-host fetch limiter to every route
+func (o *OriginRouter) setLimiter(limiter *hostLimiter) {
+	o.fallback.limiter = limiter
+	for _, route := range o.routes {
+		route.limiter = limiter
+	}
+}
+
+// targetURL builds the origin URL for the request on the primary origin.
+// It identifies the resource independently of which origin serves it.
+func (rt *originRoute) targetURL(r *http.Request) (*url.URL, error) {
+	var primary *upstream
+	if len(rt.upstreams) > 0 {
+		primary = rt.upstreams[0]
+	}
+	return rt.targetURLFor(r, primary)
+}
+
+// attempts returns the upstreams to try for the request in order, and
+// whether their breakers allowed them. Upstreams with an open circuit, or
+// with another request's trial in flight, are skipped unless all of them
+// are, in which case the primary is tried regardless. Explicit ?url=
+// targets are not mapped across origins and get a single attempt.
+func (rt *originRoute) attempts(r *http.Request) ([]*upstream, bool) {
+	if len(rt.upstreams) == 0 || r.URL.Query().Get("url") != "" {
+		return []*upstream{nil}, false
+	}
+
+	var available []*upstream
+	for _, up := range rt.upstreams {
+		if up.breaker.Allow() {
+			available = append(available, up)
+		}
+	}
+	if len(available) == 0 {
+		return rt.upstreams[:1], false
+	}
+	return available, true
+}
+
+// holdoff returns how long the origin asked to be left alone when every
+// upstream of the route is held by a Retry-After, or zero otherwise
+func (rt *originRoute) holdoff(r *http.Request) time.Duration {
+	if len(rt.upstreams) == 0 || r.URL.Query().Get("url") != "" {
+		return 0
+	}
+
+	var wait time.Duration
+	for i, up := range rt.upstreams {
+		held := up.breaker.Held()
+		if held <= 0 {
+			return 0
+		}
+		if i == 0 || held < wait {
+			wait = held
+		}
+	}
+	return wait
+}
+
+// targetURLFor builds the origin URL for the request on the given upstream,
+// preserving the path mapping across origins
+func (rt *originRoute) targetURLFor(r *http.Request, up *upstream) (*url.URL, error) {
+	// Check if target URL is provided as a query parameter
+	if targetStr := r.URL.Query().Get("url"); targetStr != "" {
+		targetURL, err := url.Parse(targetStr)
+		if err != nil {
+			return nil, ErrInvalidTargetURL
+		}
+		if !rt.allows(targetURL) {
+			return nil, ErrTargetNotAllowed
+		}
+		// Fetch the path the stream claim was checked against
+		if cleaned := utils.CleanPath(targetURL.Path); cleaned != targetURL.Path {
+			targetURL.Path, targetURL.RawPath = cleaned, ""
+		}
+		return targetURL, nil
+	}
+
+	// Otherwise, use the request path with the upstream base URL
+	if up == nil {
+		return nil, ErrNoTargetURL
+	}
+
+	path := utils.CleanPath(r.URL.Path)
+	if rt.stripPrefix && rt.pathPrefix != "" {
+		path = strings.TrimPrefix(path, rt.pathPrefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+
+	return up.baseURL.ResolveReference(&url.URL{Path: path, RawQuery: r.URL.RawQuery}), nil
+}
+
+// allows reports whether the target host is permitted for this route.
+// An empty allowlist permits any host.
+func (rt *originRoute) allows(target *url.URL) bool {
+	if len(rt.allowedHosts) == 0 {
+		return true
+	}
+	host := utils.CanonicalHostname(target.Host)
+	return rt.allowedHosts[host] || rt.proxyHosts[host]
+}
+
+// hostSet builds a lookup set of canonical host names
+func hostSet(hosts []string) map[string]bool {
+	set := make(map[string]bool, len(hosts))
+	for _, h := range hosts {
+		set[utils.CanonicalHostname(h)] = true
+	}
+	return set
+}
+
+// proxyHostSet builds the set of hosts whose absolute playlist URLs are
+// routed through the proxy: the route's origins and the extra proxy hosts
+func proxyHostSet(upstreams []*upstream, extra []string) map[string]bool {
+	set := hostSet(extra)
+	for _, up := range upstreams {
+		set[utils.CanonicalHostname(up.baseURL.Host)] = true
+	}
+	return set
+}
+
+// requestHost returns the canonical request host without its port
+func requestHost(r *http.Request) string {
+	return utils.CanonicalHostname(r.Host)
+}
@@ -0,0 +1,270 @@
+package proxy
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestOriginRouterMatch(t *testing.T) {
+	cfg := &config.OriginConfig{
+		BaseURL: "https://default.example.com",
+		Routes: []config.OriginRoute{
+			{Name: "live", PathPrefix: "/live/", BaseURL: "https://live.example.com"},
+			{Name: "sports", PathPrefix: "/live/sports/", BaseURL: "https://sports.example.com", StripPrefix: true},
+			{Name: "tenant", Host: "tenant.example.com", BaseURL: "https://tenant-origin.example.com"},
+			{Name: "tenant-live", Host: "tenant.example.com", PathPrefix: "/live/", BaseURL: "https://tenant-live.example.com"},
+		},
+	}
+	router, err := NewOriginRouter(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewOriginRouter: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		host       string
+		path       string
+		wantRoute  string
+		wantTarget string
+	}{
+		{"no match", "proxy.example.com", "/vod/a.m3u8", "default", "https://default.example.com/vod/a.m3u8"},
+		{"path prefix", "proxy.example.com", "/live/a.m3u8", "live", "https://live.example.com/live/a.m3u8"},
+		{"longer prefix wins", "proxy.example.com", "/live/sports/a.m3u8", "sports", "https://sports.example.com/a.m3u8"},
+		{"host", "tenant.example.com", "/vod/a.m3u8", "tenant", "https://tenant-origin.example.com/vod/a.m3u8"},
+		{"host with port", "tenant.example.com:8080", "/vod/a.m3u8", "tenant", "https://tenant-origin.example.com/vod/a.m3u8"},
+		{"host breaks prefix tie", "tenant.example.com", "/live/a.m3u8", "tenant-live", "https://tenant-live.example.com/live/a.m3u8"},
+		{"query kept", "proxy.example.com", "/live/a.m3u8?token=x", "live", "https://live.example.com/live/a.m3u8?token=x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			r.Host = tt.host
+
+			route := router.Match(r)
+			if route.name != tt.wantRoute {
+				t.Errorf("route = %s, want %s", route.name, tt.wantRoute)
+			}
+			target, err := route.targetURL(r)
+			if err != nil {
+				t.Fatalf("targetURL: %v", err)
+			}
+			if target.String() != tt.wantTarget {
+				t.Errorf("target = %s, want %s", target, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestOriginRouteAllowedHosts(t *testing.T) {
+	cfg := &config.OriginConfig{
+		BaseURL:      "https://default.example.com",
+		AllowedHosts: []string{"cdn.example.com"},
+	}
+	router, err := NewOriginRouter(cfg, http.DefaultClient)
+	if err != nil {
+		t.Fatalf("NewOriginRouter: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		target  string
+		wantErr error
+	}{
+		{"allowed host", "https://cdn.example.com/a.m3u8", nil},
+		{"allowed host any case", "https://CDN.example.com:443/a.m3u8", nil},
+		{"origin host", "https://default.example.com/a.m3u8", nil},
+		{"other host", "https://evil.example.com/a.m3u8", ErrTargetNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/proxy?url="+tt.target, nil)
+			if _, err := router.Match(r).targetURL(r); !errors.Is(err, tt.wantErr) {
+				t.Errorf("targetURL error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOriginRouteAllowedIPv6Hosts(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed string
+		target  string
+		wantErr error
+	}{
+		{"bare allowed, bracketed target", "2001:db8::1", "https://[2001:db8::1]/a.m3u8", nil},
+		{"bracketed allowed, target with port", "[2001:DB8::1]", "https://[2001:db8:0::1]:8443/a.m3u8", nil},
+		{"allowed with port", "cdn.example.com:443", "https://cdn.example.com/a.m3u8", nil},
+		{"other address", "2001:db8::1", "https://[2001:db8::2]/a.m3u8", ErrTargetNotAllowed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.OriginConfig{
+				BaseURL:      "https://default.example.com",
+				AllowedHosts: []string{tt.allowed},
+			}
+			router, err := NewOriginRouter(cfg, http.DefaultClient)
+			if err != nil {
+				t.Fatalf("NewOriginRouter: %v", err)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/proxy?url="+tt.target, nil)
+			if _, err := router.Match(r).targetURL(r); !errors.Is(err, tt.wantErr) {
+				t.Errorf("targetURL error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestConnectionPoolSharesClientsAcrossHostSpellings(t *testing.T) {
+	pool := NewConnectionPool(&config.OriginConfig{})
+	client := pool.GetClient("[2001:db8::1]")
+	for _, host := range []string{"2001:DB8::1", "2001:db8:0::1"} {
+		if pool.GetClient(host) != client {
+			t.Errorf("%s got a separate client", host)
+		}
+	}
+	if pool.GetClient("[2001:db8::2]") == client {
+		t.Error("different hosts share a client")
+	}
+}
+
+func TestHandlerRoutesByPathPrefix(t *testing.T) {
+	newOrigin := func(name string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name+":"+r.URL.Path)
+		}))
+	}
+	live, vod, fallback := newOrigin("live"), newOrigin("vod"), newOrigin("default")
+	defer live.Close()
+	defer vod.Close()
+	defer fallback.Close()
+
+	cfg := testConfig(fallback.URL)
+	cfg.Origin.Routes = []config.OriginRoute{
+		{Name: "live", PathPrefix: "/live/", BaseURL: live.URL},
+		{Name: "vod", PathPrefix: "/vod/", BaseURL: vod.URL, StripPrefix: true},
+	}
+	h := newTestHandler(t, cfg)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/live/seg1.ts", "live:/live/seg1.ts"},
+		{"/vod/movie/seg1.ts", "vod:/movie/seg1.ts"},
+		{"/other/seg1.ts", "default:/other/seg1.ts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serve(h, tt.path)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d", rec.Code)
+			}
+			if got := rec.Body.String(); got != tt.want {
+				t.Errorf("served %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerRouteTokenParam(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n")
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.JWT.Enabled = true
+	cfg.JWT.Secret = "test-secret"
+	cfg.Origin.Routes = []config.OriginRoute{
+		{Name: "signed", PathPrefix: "/signed/", BaseURL: origin.URL, TokenParam: "auth"},
+	}
+	h := newTestHandler(t, cfg)
+	token := signToken(t, cfg.JWT.Secret, map[string]interface{}{"sub": "player-1", "exp": time.Now().Add(time.Hour).Unix()})
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/signed/index.m3u8", "seg1.ts?auth=" + token},
+		{"/live/index.m3u8", "seg1.ts?token=" + token},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := serve(h, tt.path+"?token="+token)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("segment URL without %q:\n%s", tt.want, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandlerRewritesAbsoluteOriginURLs(t *testing.T) {
+	var originURL string
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/live/seg1.ts" {
+			io.WriteString(w, "segment")
+			return
+		}
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n"+
+			"#EXTINF:6,\n"+originURL+"/live/seg1.ts\n"+
+			"#EXTINF:6,\nhttps://cdn2.example.com/seg2.ts\n"+
+			"#EXTINF:6,\nhttps://third.example.net/seg3.ts\n")
+	}))
+	defer origin.Close()
+	originURL = origin.URL
+
+	cfg := testConfig(origin.URL)
+	cfg.Origin.RewriteAbsoluteURLs = true
+	cfg.Origin.ProxyHosts = []string{"cdn2.example.com"}
+	cfg.Origin.AllowedHosts = []string{"allowed.example.com"}
+	h := newTestHandler(t, cfg)
+
+	rec := serve(h, "/live/index.m3u8")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"origin host proxied", "?url=" + url.QueryEscape(origin.URL+"/live/seg1.ts") + "\n"},
+		{"proxy host proxied", "?url=" + url.QueryEscape("https://cdn2.example.com/seg2.ts") + "\n"},
+		{"third party untouched", "\nhttps://third.example.net/seg3.ts\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.want) {
+				t.Errorf("playlist missing %q:\n%s", tt.want, body)
+			}
+		})
+	}
+
+	// The rewritten URL is accepted as a target despite allowedHosts
+	rec = serve(h, "/proxy?url="+url.QueryEscape(origin.URL+"/live/seg1.ts"))
+	if rec.Code != http.StatusOK || rec.Body.String() != "segment" {
+		t.Errorf("proxied segment: status %d, body %q", rec.Code, rec.Body.String())
+	}
+	r := httptest.NewRequest(http.MethodGet, "/proxy?url=https://cdn2.example.com/seg2.ts", nil)
+	if _, err := h.origins.Match(r).targetURL(r); err != nil {
+		t.Errorf("proxy host target rejected: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+// Origin request timeouts
+//
+// Bounds origin requests without capping transfer time:
+// - Response header timeout per route
+// - Body idle timeout reset on every read that makes progress
+// - Slow but steady bodies stream to completion
+// - Per-host concurrency slots held for the whole transfer
+
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// do sends req to the route's origin. The wait for response headers is
+// bounded by the route timeout; after that the body may take as long as
+// it needs, provided no single read stalls longer than the body timeout.
+func (o *originRoute) do(req *http.Request) (*http.Response, error) {
+	// Wait for a fetch slot on the origin host; it is held until the body
+	// is closed
+	release, err := o.limiter.acquire(req.Context(), utils.CanonicalHost(req.URL.Scheme, req.URL.Host))
+	if err != nil {
+		return nil, err
+	}
+
+	// Timers only cancel the request; the slot is released once the
+	// request is finished with
+	ctx, cancelRequest := context.WithCancel(req.Context())
+	finish := func() {
+		cancelRequest()
+		release()
+	}
+	req = req.WithContext(ctx)
+
+	var headerTimer *time.Timer
+	if o.timeout > 0 {
+		headerTimer = time.AfterFunc(o.timeout, cancelRequest)
+	}
+
+	resp, err := o.client.Do(req)
+	if headerTimer != nil && !headerTimer.Stop() {
+		// The timer fired, so the request context is already canceled
+		if err == nil {
+			resp.Body.Close()
+		}
+		finish()
+		return nil, ErrOriginTimeout
+	}
+	if err != nil {
+		finish()
+		return nil, err
+	}
+
+	resp.Body = newIdleTimeoutBody(resp.Body, o.bodyTimeout, cancelRequest, finish)
+	return resp, nil
+}
+
+// idleTimeoutBody cancels a response when its body stops making progress
+type idleTimeoutBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	finish  func()
+	once    sync.Once
+}
+
+// newIdleTimeoutBody wraps body so that a read stalled for longer than
+// timeout cancels the request, and finish runs once the body is closed. A
+// zero timeout disables the bound.
+func newIdleTimeoutBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc, finish func()) *idleTimeoutBody {
+	b := &idleTimeoutBody{
+		body:    body,
+		timeout: timeout,
+		cancel:  cancel,
+		finish:  finish,
+	}
+	if timeout > 0 {
+		b.timer = time.AfterFunc(timeout, cancel)
+	}
+	return b
+}
+
+// Read reads from the body, extending the deadline whenever data arrives
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && b.timer != nil {
+		b.timer.Reset(b.timeout)
+	}
+	return n, err
+}
+
+// Close closes the body and finishes the request
+func (b *idleTimeoutBody) Close() error {
+	err := b.body.Close()
+	b.once.Do(func() {
+		if b.timer != nil {
+			b.timer.Stop()
+		}
+		b.finish()
+	})
+	return err
+}
@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// slotFree reports whether a fetch slot for host can be taken within a
+// short wait, releasing it again if so
+func slotFree(t *testing.T, l *hostLimiter, host string) bool {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	release, err := l.acquire(ctx, host)
+	if err != nil {
+		return false
+	}
+	release()
+	return true
+}
+
+func TestHostLimiterReleaseIsIdempotent(t *testing.T) {
+	l := newHostLimiter(1, telemetry.NewMetrics())
+
+	first, err := l.acquire(context.Background(), "origin:443")
+	if err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	second, err := l.acquire(context.Background(), "other:443")
+	if err != nil {
+		t.Fatalf("acquire other host: %v", err)
+	}
+	defer second()
+
+	first()
+	first()
+
+	// The double release must not free a slot held by someone else
+	held, err := l.acquire(context.Background(), "origin:443")
+	if err != nil {
+		t.Fatalf("acquire after release: %v", err)
+	}
+	defer held()
+	if slotFree(t, l, "origin:443") {
+		t.Fatal("second slot available with a limit of 1")
+	}
+}
+
+func TestOriginRouteLimitsFetchesPerHost(t *testing.T) {
+	const limit = 2
+
+	unblock := make(chan struct{})
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		select {
+		case <-unblock:
+		case <-r.Context().Done():
+		}
+	}))
+	defer origin.Close()
+	defer close(unblock)
+
+	route := &originRoute{
+		client:  origin.Client(),
+		limiter: newHostLimiter(limit, telemetry.NewMetrics()),
+	}
+	fetch := func(ctx context.Context) (*http.Response, error) {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, origin.URL+"/seg.ts", nil)
+		return route.do(req)
+	}
+
+	var open []*http.Response
+	for i := 0; i < limit; i++ {
+		resp, err := fetch(context.Background())
+		if err != nil {
+			t.Fatalf("fetch %d: %v", i, err)
+		}
+		open = append(open, resp)
+	}
+
+	// The fetch over the limit waits until its context gives up
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("fetch over the limit: got %v, want deadline exceeded", err)
+	}
+
+	// Closing a body frees exactly one slot, however often it is closed
+	open[0].Body.Close()
+	open[0].Body.Close()
+	resp, err := fetch(context.Background())
+	if err != nil {
+		t.Fatalf("fetch after close: %v", err)
+	}
+	defer resp.Body.Close()
+	defer open[1].Body.Close()
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := fetch(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("fetch over the limit after close: got %v, want deadline exceeded", err)
+	}
+}
+
+func TestOriginRouteTimeoutsReleaseSlotOnce(t *testing.T) {
+	tests := []struct {
+		name        string
+		timeout     time.Duration
+		bodyTimeout time.Duration
+		handler     http.HandlerFunc
+	}{
+		{
+			name:    "header timeout",
+			timeout: 20 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			},
+		},
+		{
+			name:        "body idle timeout",
+			bodyTimeout: 20 * time.Millisecond,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := httptest.NewServer(tt.handler)
+			defer origin.Close()
+
+			limiter := newHostLimiter(1, telemetry.NewMetrics())
+			route := &originRoute{
+				client:      origin.Client(),
+				timeout:     tt.timeout,
+				bodyTimeout: tt.bodyTimeout,
+				limiter:     limiter,
+			}
+			host := origin.Listener.Addr().String()
+
+			req, _ := http.NewRequest(http.MethodGet, origin.URL+"/seg.ts", nil)
+			resp, err := route.do(req)
+			if err == nil {
+				if _, err = io.ReadAll(resp.Body); err == nil {
+					t.Fatal("stalled body read succeeded")
+				}
+				// Let the idle timer fire before closing
+				time.Sleep(2 * tt.bodyTimeout)
+				resp.Body.Close()
+			} else if !errors.Is(err, ErrOriginTimeout) {
+				t.Fatalf("got %v, want origin timeout", err)
+			}
+
+			// One slot again, not two
+			held, err := limiter.acquire(context.Background(), host)
+			if err != nil {
+				t.Fatalf("acquire after timeout: %v", err)
+			}
+			defer held()
+			if slotFree(t, limiter, host) {
+				t.Fatal("second slot available with a limit of 1")
+			}
+		})
+	}
+}
+
+func TestHandlerOriginTimeouts(t *testing.T) {
+	const chunks = 6
+	tests := []struct {
+		name        string
+		headerDelay time.Duration
+		chunkDelay  time.Duration
+		wantStatus  int
+		wantBody    bool
+	}{
+		{"fast origin", 0, 0, http.StatusOK, true},
+		{"slow but steady body", 0, 20 * time.Millisecond, http.StatusOK, true},
+		{"slow headers", 150 * time.Millisecond, 0, http.StatusGatewayTimeout, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-time.After(tt.headerDelay):
+				case <-r.Context().Done():
+					return
+				}
+				w.Header().Set("Content-Type", "video/mp2t")
+				for i := 0; i < chunks; i++ {
+					io.WriteString(w, "chunk")
+					w.(http.Flusher).Flush()
+					time.Sleep(tt.chunkDelay)
+				}
+			}))
+			defer origin.Close()
+
+			// The whole body takes longer than either timeout, but no
+			// single read stalls for long
+			cfg := testConfig(origin.URL)
+			cfg.Origin.Timeout = 50 * time.Millisecond
+			cfg.Origin.BodyTimeout = 50 * time.Millisecond
+			rec := serve(newTestHandler(t, cfg), "/live/seg1.ts")
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantBody && rec.Body.Len() != chunks*len("chunk") {
+				t.Errorf("body %d bytes, want %d", rec.Body.Len(), chunks*len("chunk"))
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Playlist parse concurrency
+//
+// Bounds concurrent parse/rewrite work on playlists:
+// - Fixed number of parse slots
+// - Excess requests queue up to a timeout, then are shed
+// - Queue depth and wait times recorded as metrics
+
+package proxy
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// errParseOverloaded is returned when no parse slot frees up in time
+var errParseOverloaded = errors.New("playlist parse queue timeout")
+
+// parseLimiter bounds the number of playlists parsed at once
+type parseLimiter struct {
+	sem     chan struct{}
+	timeout time.Duration // Longest wait for a slot; 0 waits for the request
+	waiting atomic.Int64
+	metrics telemetry.Metrics
+}
+
+// newParseLimiter creates a limiter, or nil if limit disables it
+func newParseLimiter(limit int, timeout time.Duration, metrics telemetry.Metrics) *parseLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &parseLimiter{
+		sem:     make(chan struct{}, limit),
+		timeout: timeout,
+		metrics: metrics,
+	}
+}
+
+// acquire waits for a parse slot until ctx is done or the queue timeout
+// elapses. The returned function releases the slot.
+func (l *parseLimiter) acquire(ctx context.Context) (func(), error) {
+	if l == nil {
+		return func() {}, nil
+	}
+
+	// Fast path without queueing
+	select {
+	case l.sem <- struct{}{}:
+		return l.release, nil
+	default:
+	}
+
+	if l.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, l.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	l.metrics.SetGauge("playlist.parse_queue", float64(l.waiting.Add(1)))
+	defer func() {
+		l.metrics.SetGauge("playlist.parse_queue", float64(l.waiting.Add(-1)))
+	}()
+
+	select {
+	case l.sem <- struct{}{}:
+		l.metrics.ObserveHistogram("playlist.parse_wait_ms", float64(time.Since(start).Milliseconds()))
+		return l.release, nil
+	case <-ctx.Done():
+		l.metrics.IncCounter("playlist.parse_shed")
+		return nil, errParseOverloaded
+	}
+}
+
+// tryAcquire takes a parse slot only if one is free, for background work
+// that should never queue
+func (l *parseLimiter) tryAcquire() (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+
+	select {
+	case l.sem <- struct{}{}:
+		return l.release, true
+	default:
+		return nil, false
+	}
+}
+
+// release frees a parse slot
+func (l *parseLimiter) release() {
+	<-l.sem
+}
@@ -0,0 +1,127 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+func TestParseLimiterCapsConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   int
+		workers int
+		wantMax int32
+	}{
+		{"limit 1", 1, 8, 1},
+		{"limit 3", 3, 12, 3},
+		{"disabled", 0, 6, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newParseLimiter(tt.limit, 0, telemetry.NewMetrics())
+
+			var running, peak int32
+			start := make(chan struct{})
+			var wg sync.WaitGroup
+			for i := 0; i < tt.workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					<-start
+					release, err := l.acquire(context.Background())
+					if err != nil {
+						t.Errorf("acquire: %v", err)
+						return
+					}
+					defer release()
+
+					n := atomic.AddInt32(&running, 1)
+					for {
+						p := atomic.LoadInt32(&peak)
+						if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					atomic.AddInt32(&running, -1)
+				}()
+			}
+			close(start)
+			wg.Wait()
+
+			if peak > tt.wantMax {
+				t.Errorf("peak concurrency %d, want at most %d", peak, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestParseLimiterSheds(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		ctx     context.Context
+	}{
+		{"queue timeout", 20 * time.Millisecond, context.Background()},
+		{"request gone", 0, canceled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics := telemetry.NewMetrics()
+			l := newParseLimiter(1, tt.timeout, metrics)
+			release, _ := l.acquire(context.Background())
+			defer release()
+
+			if _, err := l.acquire(tt.ctx); !errors.Is(err, errParseOverloaded) {
+				t.Fatalf("acquire on a full limiter: %v, want %v", err, errParseOverloaded)
+			}
+			if _, ok := l.tryAcquire(); ok {
+				t.Error("tryAcquire took a slot from a full limiter")
+			}
+			if got := counter(metrics, "playlist.parse_shed"); got != 1 {
+				t.Errorf("parse_shed = %d, want 1", got)
+			}
+			if depth := metrics.(*telemetry.SimpleMetrics).DumpMetrics()["gauge_playlist.parse_queue"]; depth != float64(0) {
+				t.Errorf("queue depth %v after shedding, want 0", depth)
+			}
+		})
+	}
+}
+
+func TestHandlerShedsParsesWhenOverloaded(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"))
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.Origin.MaxConcurrentParses = 1
+	cfg.Origin.ParseQueueTimeout = 10 * time.Millisecond
+	h := newTestHandler(t, cfg)
+
+	// Hold the only slot, as a slow parse would
+	release, _ := h.parseLimiter.acquire(context.Background())
+	rec := serve(h, "/live/index.m3u8")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("status %d Retry-After %q, want 503 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	release()
+	if rec := serve(h, "/live/index.m3u8"); rec.Code != http.StatusOK {
+		t.Errorf("status %d after the slot freed, want 200", rec.Code)
+	}
+}
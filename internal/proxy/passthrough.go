@@ -0,0 +1,77 @@
+// Passthrough requests
+//
+// Proxies selected paths verbatim:
+// - Configurable request path patterns
+// - No token validation or playlist rewriting
+// - Origin status, headers and body streamed unchanged
+// - Never cached
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// passthroughRules matches request paths that are proxied verbatim
+type passthroughRules struct {
+	patterns []*regexp.Regexp
+}
+
+// newPassthroughRules compiles the passthrough path patterns
+func newPassthroughRules(patterns []string) (*passthroughRules, error) {
+	rules := &passthroughRules{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid passthrough pattern %q: %w", pattern, err)
+		}
+		rules.patterns = append(rules.patterns, re)
+	}
+	return rules, nil
+}
+
+// matches reports whether the request path should be passed through
+func (p *passthroughRules) matches(r *http.Request) bool {
+	if p == nil {
+		return false
+	}
+	for _, re := range p.patterns {
+		if re.MatchString(r.URL.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+// servePassthrough streams the origin response for r without validating a
+// token, rewriting the body or caching it
+func (h *Handler) servePassthrough(w http.ResponseWriter, r *http.Request, startTime time.Time) {
+	h.metrics.IncCounter("passthrough")
+	defer func() {
+		h.metrics.ObserveRequestDuration(utils.RedactPath(r.URL.Path), time.Since(startTime))
+	}()
+
+	route := h.origins.Match(r)
+	originResp, _, err := h.fetchOrigin(r, route)
+	if err != nil {
+		statusCode := http.StatusBadGateway
+		if errors.Is(err, ErrTargetNotAllowed) {
+			statusCode = http.StatusForbidden
+		} else if errors.Is(err, ErrOriginTimeout) {
+			statusCode = http.StatusGatewayTimeout
+		}
+		h.handleError(w, r, err, statusCode)
+		return
+	}
+	defer originResp.Body.Close()
+
+	h.responsePolicy.copy(originResp.Header, w.Header())
+	w.WriteHeader(originResp.StatusCode)
+	h.copyBuffers.copy(w, originResp.Body)
+}
@@ -0,0 +1,101 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPassthroughRulesMatch(t *testing.T) {
+	rules, err := newPassthroughRules([]string{`^/beacon/`, `^/status\.txt$`})
+	if err != nil {
+		t.Fatalf("newPassthroughRules: %v", err)
+	}
+
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"/beacon/ping", true},
+		{"/status.txt", true},
+		{"/status.txt.bak", false},
+		{"/live/beacon/ping", false},
+		{"/live/index.m3u8?next=/beacon/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.target, func(t *testing.T) {
+			if got := rules.matches(httptest.NewRequest(http.MethodGet, tt.target, nil)); got != tt.want {
+				t.Errorf("matches(%s) = %v, want %v", tt.target, got, tt.want)
+			}
+		})
+	}
+
+	var none *passthroughRules
+	if none.matches(httptest.NewRequest(http.MethodGet, "/beacon/ping", nil)) {
+		t.Error("nil rules matched")
+	}
+	if _, err := newPassthroughRules([]string{`(`}); err == nil {
+		t.Error("invalid pattern compiled")
+	}
+}
+
+func TestHandlerPassthrough(t *testing.T) {
+	const playlist = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n"
+
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		w.Header().Set("X-Origin", "kept")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(playlist))
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name        string
+		target      string
+		wantStatus  int
+		passthrough bool
+	}{
+		{"passthrough without a token", "/raw/index.m3u8", http.StatusAccepted, true},
+		{"other paths need a token", "/live/index.m3u8", http.StatusUnauthorized, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.JWT.Enabled = true
+			cfg.JWT.Secret = "test-secret"
+			cfg.Origin.PassthroughPatterns = []string{`^/raw/`}
+			h := newTestHandler(t, cfg)
+			atomic.StoreInt32(&fetches, 0)
+
+			var rec *httptest.ResponseRecorder
+			for i := 0; i < 2; i++ {
+				rec = serve(h, tt.target)
+			}
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if !tt.passthrough {
+				return
+			}
+
+			if rec.Body.String() != playlist {
+				t.Errorf("body rewritten:\n%s", rec.Body.String())
+			}
+			if rec.Header().Get("X-Origin") != "kept" {
+				t.Error("origin header dropped")
+			}
+			if got := atomic.LoadInt32(&fetches); got != 2 {
+				t.Errorf("origin fetched %d times for 2 requests, want no caching", got)
+			}
+			if got := counter(h.metrics, "passthrough"); got != 2 {
+				t.Errorf("passthrough counter %d, want 2", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+// Precompressed playlists
+//
+// Gzip bodies of rewritten playlists cached next to the plain ones:
+// - Compressed once, when the playlist is cached
+// - Served as is to gzip-capable clients, skipping response compression
+// - Plain body served to everyone else
+
+package proxy
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// gzipVariantKey returns the cache key of a playlist's gzip body
+func gzipVariantKey(key cache.Key) cache.Key {
+	return key + "|gzip"
+}
+
+// storeGzipVariant caches a gzip copy of a playlist body for ttl next to the
+// plain one. It returns nil when precompression is off or the body is below
+// the compression threshold.
+func (h *Handler) storeGzipVariant(r *http.Request, key cache.Key, contentType string, body []byte, ttl time.Duration) *cachedResponse {
+	if !h.config.Cache.PrecompressPlaylists || len(body) < h.config.Server.CompressMinBytes {
+		return nil
+	}
+
+	compressed, err := gzipBytes(body)
+	if err != nil {
+		return nil
+	}
+
+	entry := &cachedResponse{
+		Body:            compressed,
+		ContentType:     contentType,
+		ContentEncoding: "gzip",
+	}
+	h.cacheStore(r, gzipVariantKey(key), entry, ttl)
+	h.metrics.IncCounter("cache.precompressed")
+	return entry
+}
+
+// gzipVariant returns the cached gzip body of a playlist if the client
+// accepts gzip
+func (h *Handler) gzipVariant(r *http.Request, key cache.Key) (*cachedResponse, bool) {
+	if !h.config.Cache.PrecompressPlaylists || !acceptsGzip(r) {
+		return nil, false
+	}
+	return h.lookupCached(r, gzipVariantKey(key))
+}
+
+// acceptsGzip reports whether the client accepts gzip bodies
+func acceptsGzip(r *http.Request) bool {
+	return utils.AcceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip")
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/middleware"
+)
+
+func TestHandlerPrecompressedPlaylists(t *testing.T) {
+	var media strings.Builder
+	media.WriteString("#EXTM3U\n#EXT-X-TARGETDURATION:6\n")
+	for i := 0; i < 50; i++ {
+		media.WriteString("#EXTINF:6,\nseg.ts\n")
+	}
+
+	var fetches int32
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, media.String())
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name       string
+		enabled    bool
+		minBytes   int
+		encodings  []string // Accept-Encoding of each request in turn
+		wantGzip   []bool
+		wantStored int
+	}{
+		{"gzip miss then hit", true, 100, []string{"gzip", "gzip", "gzip"}, []bool{true, true, true}, 1},
+		{"plain client", true, 100, []string{"", "gzip", "identity"}, []bool{false, true, false}, 1},
+		{"below threshold", true, 1 << 20, []string{"gzip", "gzip"}, []bool{false, false}, 0},
+		{"disabled", false, 100, []string{"gzip", "gzip"}, []bool{false, false}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			atomic.StoreInt32(&fetches, 0)
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Enabled = true
+			cfg.Cache.PrecompressPlaylists = tt.enabled
+			cfg.Server.CompressMinBytes = tt.minBytes
+			h := newTestHandler(t, cfg)
+
+			var first []byte
+			for i, encoding := range tt.encodings {
+				req := httptest.NewRequest(http.MethodGet, "/live/index.m3u8", nil)
+				if encoding != "" {
+					req.Header.Set("Accept-Encoding", encoding)
+				}
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("request %d: status %d", i, rec.Code)
+				}
+
+				gzipped := rec.Header().Get("Content-Encoding") == "gzip"
+				if gzipped != tt.wantGzip[i] {
+					t.Fatalf("request %d: gzip %v, want %v", i, gzipped, tt.wantGzip[i])
+				}
+				body := rec.Body.Bytes()
+				if gzipped {
+					// Later gzip requests get the very bytes compressed once
+					if first == nil {
+						first = body
+					} else if !bytes.Equal(body, first) {
+						t.Errorf("request %d: compressed body differs from the cached one", i)
+					}
+					zr, err := gzip.NewReader(bytes.NewReader(body))
+					if err != nil {
+						t.Fatalf("request %d: %v", i, err)
+					}
+					if body, err = io.ReadAll(zr); err != nil {
+						t.Fatalf("request %d: %v", i, err)
+					}
+				}
+				if !strings.Contains(string(body), "#EXTINF:6,") {
+					t.Errorf("request %d: body is not the playlist", i)
+				}
+				if tt.enabled && tt.wantStored > 0 && !strings.Contains(rec.Header().Get("Vary"), "Accept-Encoding") {
+					t.Errorf("request %d: Vary %q lacks Accept-Encoding", i, rec.Header().Get("Vary"))
+				}
+			}
+
+			if got := counter(h.metrics, "cache.precompressed"); got != tt.wantStored {
+				t.Errorf("compressed %d times, want %d", got, tt.wantStored)
+			}
+			if got := atomic.LoadInt32(&fetches); got != 1 {
+				t.Errorf("origin fetched %d times, want 1", got)
+			}
+		})
+	}
+}
+
+func TestPrecompressedPlaylistSkipsResponseCompression(t *testing.T) {
+	playlist := "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" + strings.Repeat("#EXTINF:6,\nseg.ts\n", 50)
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, playlist)
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.Cache.Enabled = true
+	cfg.Cache.PrecompressPlaylists = true
+	cfg.Server.CompressMinBytes = 100
+	opts := middleware.DefaultCompressionOptions()
+	opts.MinSize = 100
+	h := middleware.Compression(opts)(newTestHandler(t, cfg))
+
+	for i := 0; i < 2; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/live/index.m3u8", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		// Decoding once must give the playlist: it was not compressed twice
+		zr, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(zr)
+		if !strings.HasPrefix(string(body), "#EXTM3U\n") {
+			t.Errorf("request %d: body is not a playlist after one decode", i)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Child playlist prefetching
+//
+// Warms the cache after a master playlist is served:
+// - Selects the highest bandwidth variants
+// - Fetches their media playlists asynchronously
+// - Bounded concurrency with load shedding
+// - Resolves targets exactly as a player request would
+
+package proxy
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/jwt"
+	"github.com/ilijajolevski/ilinden/internal/playlist"
+	"github.com/ilijajolevski/ilinden/pkg/hls"
+)
+
+// Prefetcher fetches and caches the media playlists referenced by a master
+type Prefetcher struct {
+	handler     *Handler
+	maxVariants int
+	sem         chan struct{}
+}
+
+// NewPrefetcher creates a prefetcher warming up to maxVariants media
+// playlists per master with at most concurrency fetches in flight
+func NewPrefetcher(h *Handler, maxVariants, concurrency int) *Prefetcher {
+	if maxVariants <= 0 {
+		maxVariants = 3
+	}
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	return &Prefetcher{
+		handler:     h,
+		maxVariants: maxVariants,
+		sem:         make(chan struct{}, concurrency),
+	}
+}
+
+// Prefetch schedules fetches for the top variants of a processed master
+// playlist served for r. It never blocks: variants that cannot get a fetch
+// slot are skipped.
+func (p *Prefetcher) Prefetch(master []byte, r *http.Request, token string, claims *jwt.Claims) {
+	parsed, err := playlist.NewParser().Parse(bytes.NewReader(master))
+	if err != nil || !parsed.IsMaster() {
+		return
+	}
+
+	// Resolve each variant to the origin target the handler would use
+	type job struct {
+		req    *http.Request
+		route  *originRoute
+		target *url.URL
+		key    cache.Key
+	}
+	var jobs []job
+	var keys []cache.Key
+	for _, req := range p.variantRequests(parsed, r) {
+		route := p.handler.origins.Match(req)
+		target, err := route.targetURL(req)
+		if err != nil {
+			continue
+		}
+
+		key := p.handler.playlistCacheKey(target, token)
+		jobs = append(jobs, job{req: req, route: route, target: target, key: key})
+		keys = append(keys, key)
+	}
+
+	// Skip variants that are already cached
+	cached := p.handler.cache.GetMulti(keys)
+
+	for _, j := range jobs {
+		if entry, found := cached[j.key]; found {
+			if resp, ok := entry.(*cachedResponse); !ok || resp.fresh(p.handler.clock.Now()) {
+				continue
+			}
+		}
+
+		select {
+		case p.sem <- struct{}{}:
+			go func(j job) {
+				defer func() { <-p.sem }()
+				p.fetch(j.req, j.route, j.target, token, claims)
+			}(j)
+		default:
+			p.handler.metrics.IncCounter("prefetch.skipped")
+		}
+	}
+}
+
+// Wait blocks until in-flight prefetches finish. It holds every fetch
+// slot, so prefetches scheduled afterwards are skipped; it is meant for
+// shutdown.
+func (p *Prefetcher) Wait() {
+	if p == nil {
+		return
+	}
+	for i := 0; i < cap(p.sem); i++ {
+		p.sem <- struct{}{}
+	}
+}
+
+// variantRequests builds the requests a player would make for the highest
+// bandwidth variants of the master
+func (p *Prefetcher) variantRequests(parsed *hls.Playlist, r *http.Request) []*http.Request {
+	variants := append([]hls.Variant(nil), parsed.Master.Variants...)
+	sort.SliceStable(variants, func(i, j int) bool {
+		return variants[i].Bandwidth > variants[j].Bandwidth
+	})
+
+	reqs := make([]*http.Request, 0, p.maxVariants)
+	seen := make(map[string]bool)
+	for _, v := range variants {
+		if len(reqs) >= p.maxVariants {
+			break
+		}
+
+		ref, err := url.Parse(v.URI)
+		if err != nil || v.URI == "" {
+			continue
+		}
+		resolved := r.URL.ResolveReference(ref)
+		if seen[resolved.String()] {
+			continue
+		}
+		seen[resolved.String()] = true
+
+		req, err := http.NewRequest(http.MethodGet, resolved.String(), nil)
+		if err != nil {
+			continue
+		}
+		req.Host = r.Host
+		reqs = append(reqs, req)
+	}
+	return reqs
+}
+
+// fetch retrieves, processes and caches a single media playlist
+func (p *Prefetcher) fetch(req *http.Request, route *originRoute, target *url.URL, token string, claims *jwt.Claims) {
+	h := p.handler
+
+	originReq, err := http.NewRequest(http.MethodGet, target.String(), nil)
+	if err != nil {
+		return
+	}
+	h.applyOriginHeaders(originReq)
+
+	resp, err := route.do(originReq)
+	if err != nil {
+		h.metrics.IncCounter("prefetch.error")
+		h.logger.Debug("Prefetch failed", "url", h.redactURL(target), "error", err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		h.metrics.IncCounter("prefetch.error")
+		return
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err == nil {
+		content, err = decodeBody(content, resp.Header.Get("Content-Encoding"), h.config.Origin.MaxDecodedBytes)
+	}
+	if err != nil {
+		h.metrics.IncCounter("prefetch.error")
+		return
+	}
+
+	// Only media playlists are cached; nested masters are left to the player
+	if playlist.DetectPlaylistType(content) != hls.PlaylistTypeMedia {
+		return
+	}
+
+	proxyURL := &url.URL{
+		Scheme: req.URL.Scheme,
+		Host:   req.URL.Host,
+		Path:   req.URL.Path,
+	}
+
+	// Warming is best effort and never queues for a parse slot
+	release, ok := h.parseLimiter.tryAcquire()
+	if !ok {
+		h.metrics.IncCounter("prefetch.skipped")
+		return
+	}
+	rewriteStart := time.Now()
+	processed, stats, err := h.playlistParser.ParseAndProcessBytesStats(content, target, proxyURL, token, h.processorOptions(route))
+	release()
+	h.recordRewrite(stats, time.Since(rewriteStart), err)
+	if err != nil {
+		h.metrics.IncCounter("prefetch.error")
+		return
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/vnd.apple.mpegurl"
+	}
+
+	ttl, ok := h.tokenTTL(h.playlistTTL(processed), claims)
+	if !ok {
+		return
+	}
+	
+	h.cacheStore(req, h.playlistCacheKey(target, token), h.compressForStorage(&cachedResponse{
+		Body:        processed,
+		ContentType: contentType,
+	}), ttl)
+	h.metrics.IncCounter("prefetch.success")
+}
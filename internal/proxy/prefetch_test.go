@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const prefetchMaster = "#EXTM3U\n" +
+	"#EXT-X-STREAM-INF:BANDWIDTH=800000\nlow/index.m3u8\n" +
+	"#EXT-X-STREAM-INF:BANDWIDTH=3000000\nhigh/index.m3u8\n" +
+	"#EXT-X-STREAM-INF:BANDWIDTH=1500000\nmid/index.m3u8\n"
+
+func TestPrefetcherWarmsTopVariants(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		if strings.HasSuffix(r.URL.Path, "master.m3u8") {
+			io.WriteString(w, prefetchMaster)
+			return
+		}
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n")
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name     string
+		variants int
+		wantHits map[string]bool
+	}{
+		{"highest only", 1, map[string]bool{"high": true}},
+		{"top two", 2, map[string]bool{"high": true, "mid": true}},
+		{"all", 5, map[string]bool{"high": true, "mid": true, "low": true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Prefetch = true
+			cfg.Cache.PrefetchVariants = tt.variants
+			h := newTestHandler(t, cfg)
+
+			rec := serve(h, "/live/master.m3u8")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("master: status %d", rec.Code)
+			}
+			h.prefetcher.Wait()
+
+			// Request the variants exactly as listed in the served master
+			checked := 0
+			for _, uri := range strings.Split(rec.Body.String(), "\n") {
+				if uri == "" || strings.HasPrefix(uri, "#") {
+					continue
+				}
+				variant := strings.TrimSuffix(uri[strings.LastIndex(uri, "/live/")+len("/live/"):], "/index.m3u8")
+				checked++
+				want := "MISS"
+				if tt.wantHits[variant] {
+					want = "HIT"
+				}
+				if got := serve(h, uri).Header().Get("X-Cache"); got != want {
+					t.Errorf("%s variant: X-Cache = %q, want %q", variant, got, want)
+				}
+			}
+			if checked != 3 {
+				t.Errorf("master lists %d variants, want 3", checked)
+			}
+		})
+	}
+}
+
+func TestPrefetchDisabledByDefault(t *testing.T) {
+	if h := newTestHandler(t, testConfig("http://origin.invalid")); h.prefetcher != nil {
+		t.Error("prefetcher created without cache.prefetch")
+	}
+}
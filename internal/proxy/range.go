@@ -0,0 +1,77 @@
+// Byte range requests
+//
+// Range handling on top of the origin's:
+// - Unsatisfiable ranges answered with 416 and Content-Range: bytes */size
+// - Origin 416 responses keep their Content-Range
+// - Ranges the origin ignored are checked against the full body's size
+
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// unsatisfiedRange returns the Content-Range of a 416 response for a
+// resource of size bytes
+func unsatisfiedRange(size int64) string {
+	return "bytes */" + strconv.FormatInt(size, 10)
+}
+
+// originUnsatisfiedRange returns the origin's Content-Range for a 416
+// response if it is of the bytes */size form, or ""
+func originUnsatisfiedRange(resp *http.Response) string {
+	contentRange := resp.Header.Get("Content-Range")
+	if !strings.HasPrefix(contentRange, "bytes */") {
+		return ""
+	}
+	return contentRange
+}
+
+// rangeSatisfiable reports whether any range of a Range header overlaps a
+// resource of size bytes. Headers that are not valid byte ranges are
+// ignored, as HTTP requires, and count as satisfiable.
+func rangeSatisfiable(header string, size int64) bool {
+	specs, ok := strings.CutPrefix(strings.TrimSpace(header), "bytes=")
+	if !ok {
+		return true
+	}
+
+	for _, spec := range strings.Split(specs, ",") {
+		first, last, ok := strings.Cut(strings.TrimSpace(spec), "-")
+		if !ok {
+			return true
+		}
+		if first == "" {
+			// Suffix range: the last n bytes
+			n, err := strconv.ParseInt(last, 10, 64)
+			if err != nil {
+				return true
+			}
+			if n > 0 && size > 0 {
+				return true
+			}
+			continue
+		}
+		start, err := strconv.ParseInt(first, 10, 64)
+		if err != nil {
+			return true
+		}
+		if start < size {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredRangeUnsatisfiable reports whether the origin answered a range
+// request the proxy must reject with the full body, because the requested
+// range lies beyond it
+func ignoredRangeUnsatisfiable(r *http.Request, resp *http.Response) bool {
+	rangeHeader := r.Header.Get("Range")
+	if rangeHeader == "" || resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return false
+	}
+	return !rangeSatisfiable(rangeHeader, resp.ContentLength)
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRangeSatisfiable(t *testing.T) {
+	tests := []struct {
+		header string
+		size   int64
+		want   bool
+	}{
+		{"bytes=0-99", 100, true},
+		{"bytes=99-", 100, true},
+		{"bytes=100-199", 100, false},
+		{"bytes=500-", 100, false},
+		{"bytes=500-600, 0-10", 100, true},
+		{"bytes=-10", 100, true},
+		{"bytes=-10", 0, false},
+		{"bytes=-0", 100, false},
+		{"items=0-10", 100, true},
+		{"bytes=abc-", 100, true},
+		{"bytes=5", 100, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := rangeSatisfiable(tt.header, tt.size); got != tt.want {
+				t.Errorf("rangeSatisfiable(%q, %d) = %v, want %v", tt.header, tt.size, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerUnsatisfiableRange(t *testing.T) {
+	media := bytes.Repeat([]byte{0x47}, 4096)
+
+	tests := []struct {
+		name         string
+		honorsRanges bool
+		byteRange    string
+		wantStatus   int
+		wantRange    string
+	}{
+		{"origin 416", true, "bytes=5000-5999", http.StatusRequestedRangeNotSatisfiable, "bytes */4096"},
+		{"origin ignored the range", false, "bytes=5000-5999", http.StatusRequestedRangeNotSatisfiable, "bytes */4096"},
+		{"satisfiable range", true, "bytes=0-99", http.StatusPartialContent, "bytes 0-99/4096"},
+		{"ignored satisfiable range", false, "bytes=0-99", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&fetches, 1)
+				if tt.honorsRanges {
+					http.ServeContent(w, r, "media.mp4", time.Time{}, bytes.NewReader(media))
+					return
+				}
+				w.Header().Set("Content-Type", "video/mp4")
+				w.Header().Set("Content-Length", strconv.Itoa(len(media)))
+				w.Write(media)
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Enabled = true
+			cfg.Cache.NegativeStatuses = []int{http.StatusNotFound, http.StatusRequestedRangeNotSatisfiable}
+			h := newTestHandler(t, cfg)
+
+			// The second request replays a cached 416 with its size
+			for i := 0; i < 2; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/vod/media.mp4", nil)
+				req.Header.Set("Range", tt.byteRange)
+				rec := httptest.NewRecorder()
+				h.ServeHTTP(rec, req)
+
+				if rec.Code != tt.wantStatus {
+					t.Fatalf("request %d: status %d, want %d", i, rec.Code, tt.wantStatus)
+				}
+				if got := rec.Header().Get("Content-Range"); got != tt.wantRange {
+					t.Errorf("request %d: Content-Range %q, want %q", i, got, tt.wantRange)
+				}
+			}
+			if tt.name == "origin 416" && atomic.LoadInt32(&fetches) != 1 {
+				t.Errorf("origin fetched %d times, want the 416 cached", fetches)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestHandlerRedactError(t *testing.T) {
+	const secret = "s3cr3t-value"
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{
+			name: "origin URL with token",
+			err:  &url.Error{Op: "Get", URL: "https://origin.example.com/live/a.m3u8?token=" + secret, Err: errors.New("connection refused")},
+			want: `Get "https://origin.example.com/live/a.m3u8?token=[REDACTED]": connection refused`,
+		},
+		{
+			name: "wrapped origin URL with route token",
+			err:  fmt.Errorf("fetch: %w", &url.Error{Op: "Get", URL: "https://cdn.example.com/a.ts?sig=" + secret, Err: errors.New("timeout")}),
+			want: `fetch: Get "https://cdn.example.com/a.ts?sig=[REDACTED]": timeout`,
+		},
+		{
+			name: "no URL",
+			err:  ErrOriginError,
+			want: ErrOriginError.Error(),
+		},
+	}
+
+	cfg := testConfig("https://origin.example.com")
+	cfg.Origin.Routes = []config.OriginRoute{{PathPrefix: "/cdn/", BaseURL: "https://cdn.example.com", TokenParam: "sig"}}
+	h := newTestHandler(t, cfg)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := h.redactError(tt.err)
+			if got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if strings.Contains(got, secret) {
+				t.Errorf("token leaked: %s", got)
+			}
+		})
+	}
+}
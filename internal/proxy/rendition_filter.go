@@ -0,0 +1,101 @@
+// Rendition filter selection
+//
+// Chooses the master playlist rendition filter for a request:
+// - Configured characteristics and channel limits
+// - Per-request narrowing through query parameters
+// - Cache key variants for filtered playlists
+
+package proxy
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/playlist"
+)
+
+const (
+	// Query parameters narrowing the rendition filter when queryControl is on
+	excludeCharacteristicsParam = "excludeCharacteristics"
+	maxChannelsParam            = "maxChannels"
+)
+
+// renditionFilter returns the configured rendition filter, or nil when it
+// removes nothing
+func renditionFilter(cfg *config.RenditionFilterConfig) *playlist.RenditionFilter {
+	if len(cfg.ExcludeCharacteristics) == 0 && cfg.MaxChannels <= 0 {
+		return nil
+	}
+	return &playlist.RenditionFilter{
+		ExcludeCharacteristics: cfg.ExcludeCharacteristics,
+		MaxChannels:            cfg.MaxChannels,
+	}
+}
+
+// requestRenditionFilter returns the rendition filter for a request. With
+// queryControl, the request may exclude further characteristics and lower
+// the channel limit, but never relax the configured filter. Invalid
+// maxChannels values are ignored.
+func (h *Handler) requestRenditionFilter(r *http.Request) *playlist.RenditionFilter {
+	cfg := &h.config.Origin.RenditionFilter
+	excluded, maxChannels := requestRenditionLimits(r, cfg)
+	if len(excluded) == len(cfg.ExcludeCharacteristics) && maxChannels == cfg.MaxChannels {
+		return h.renditions
+	}
+	return &playlist.RenditionFilter{
+		ExcludeCharacteristics: excluded,
+		MaxChannels:            maxChannels,
+	}
+}
+
+// requestRenditionLimits merges the request's query limits into the
+// configured ones
+func requestRenditionLimits(r *http.Request, cfg *config.RenditionFilterConfig) ([]string, int) {
+	excluded := cfg.ExcludeCharacteristics
+	maxChannels := cfg.MaxChannels
+	if !cfg.QueryControl {
+		return excluded, maxChannels
+	}
+
+	query := r.URL.Query()
+	for _, c := range strings.Split(query.Get(excludeCharacteristicsParam), ",") {
+		if c = strings.TrimSpace(c); c != "" && !containsString(excluded, c) {
+			excluded = append(excluded[:len(excluded):len(excluded)], c)
+		}
+	}
+	if n, err := strconv.Atoi(query.Get(maxChannelsParam)); err == nil && n > 0 {
+		if maxChannels <= 0 || n < maxChannels {
+			maxChannels = n
+		}
+	}
+	return excluded, maxChannels
+}
+
+// renditionVariant returns the cache key suffix for a playlist filtered by
+// the request's query limits, or "" when the configured filter applies.
+// Explicit ?url= targets do not carry the request query, so the key cannot
+// rely on the target URL to tell filtered playlists apart.
+func (h *Handler) renditionVariant(r *http.Request) string {
+	cfg := &h.config.Origin.RenditionFilter
+	excluded, maxChannels := requestRenditionLimits(r, cfg)
+	if len(excluded) == len(cfg.ExcludeCharacteristics) && maxChannels == cfg.MaxChannels {
+		return ""
+	}
+
+	sorted := append([]string(nil), excluded...)
+	sort.Strings(sorted)
+	return "|renditions:" + strings.Join(sorted, ",") + ";" + strconv.Itoa(maxChannels)
+}
+
+// containsString reports whether list holds s
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+const renditionMaster = `#EXTM3U
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Surround",DEFAULT=YES,CHANNELS="6",URI="surround.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Stereo",CHANNELS="2",URI="stereo.m3u8"
+#EXT-X-MEDIA:TYPE=AUDIO,GROUP-ID="aud",NAME="Described",CHANNELS="2",CHARACTERISTICS="public.accessibility.describes-video",URI="described.m3u8"
+#EXT-X-STREAM-INF:BANDWIDTH=1500000,AUDIO="aud"
+720p.m3u8
+`
+
+func TestRequestRenditionLimits(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config.RenditionFilterConfig
+		query        string
+		wantExcluded []string
+		wantChannels int
+	}{
+		{"configured only", config.RenditionFilterConfig{MaxChannels: 6}, "?maxChannels=2", nil, 6},
+		{"query narrows channels", config.RenditionFilterConfig{MaxChannels: 6, QueryControl: true}, "?maxChannels=2", nil, 2},
+		{"query cannot relax channels", config.RenditionFilterConfig{MaxChannels: 2, QueryControl: true}, "?maxChannels=8", nil, 2},
+		{"query sets unlimited channels", config.RenditionFilterConfig{QueryControl: true}, "?maxChannels=2", nil, 2},
+		{"invalid channels ignored", config.RenditionFilterConfig{MaxChannels: 6, QueryControl: true}, "?maxChannels=-1", nil, 6},
+		{
+			name:         "query adds characteristics",
+			cfg:          config.RenditionFilterConfig{ExcludeCharacteristics: []string{"a"}, QueryControl: true},
+			query:        "?excludeCharacteristics=b,+a,,c",
+			wantExcluded: []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.cfg
+			r := httptest.NewRequest(http.MethodGet, "/live/master.m3u8"+tt.query, nil)
+			excluded, channels := requestRenditionLimits(r, &cfg)
+
+			if strings.Join(excluded, ",") != strings.Join(tt.wantExcluded, ",") {
+				t.Errorf("excluded %v, want %v", excluded, tt.wantExcluded)
+			}
+			if channels != tt.wantChannels {
+				t.Errorf("max channels %d, want %d", channels, tt.wantChannels)
+			}
+			// The configured list must not grow through the shared array
+			if len(cfg.ExcludeCharacteristics) != len(tt.cfg.ExcludeCharacteristics) {
+				t.Errorf("configured characteristics changed to %v", cfg.ExcludeCharacteristics)
+			}
+		})
+	}
+}
+
+func TestHandlerRenditionFilter(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, renditionMaster)
+	}))
+	defer origin.Close()
+
+	cfg := testConfig(origin.URL)
+	cfg.Cache.Enabled = true
+	cfg.Origin.RenditionFilter = config.RenditionFilterConfig{
+		ExcludeCharacteristics: []string{"public.accessibility.describes-video"},
+		QueryControl:           true,
+	}
+	h := newTestHandler(t, cfg)
+
+	// Requests run in sequence against one handler. Explicit targets share
+	// the target URL, so a filtered playlist must not be served from the
+	// unfiltered cache entry or the other way round.
+	target := "/proxy?url=" + origin.URL + "/live/master.m3u8"
+	tests := []struct {
+		target string
+		want   []string
+		absent []string
+	}{
+		{target, []string{"surround.m3u8", "stereo.m3u8"}, []string{"described.m3u8"}},
+		{target + "&maxChannels=2", []string{"stereo.m3u8"}, []string{"surround.m3u8", "described.m3u8"}},
+		{target, []string{"surround.m3u8", "stereo.m3u8"}, []string{"described.m3u8"}},
+	}
+
+	for _, tt := range tests {
+		rec := serve(h, tt.target)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: status %d", tt.target, rec.Code)
+		}
+		body := rec.Body.String()
+		for _, uri := range tt.want {
+			if !strings.Contains(body, uri) {
+				t.Errorf("%s: %s missing:\n%s", tt.target, uri, body)
+			}
+		}
+		for _, uri := range tt.absent {
+			if strings.Contains(body, uri) {
+				t.Errorf("%s: %s not removed:\n%s", tt.target, uri, body)
+			}
+		}
+	}
+}
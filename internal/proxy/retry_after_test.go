@@ -0,0 +1,91 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+)
+
+func TestHandlerRetryAfter(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		retryAfter  string
+		max         time.Duration
+		wantFirst   string
+		wantHeld    bool
+		wantSecond  string
+		wantFetches int32
+	}{
+		{"503 holds the origin", http.StatusServiceUnavailable, "5", time.Minute, "5", true, "5", 1},
+		{"429 holds the origin", http.StatusTooManyRequests, "5", time.Minute, "5", true, "5", 1},
+		{"hold capped", http.StatusServiceUnavailable, "600", 2 * time.Second, "600", true, "2", 1},
+		{"no header, no hold", http.StatusServiceUnavailable, "", time.Minute, "", false, "", 2},
+		{"other statuses ignore the header", http.StatusBadGateway, "5", time.Minute, "", false, "", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var fetches atomic.Int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fetches.Add(1)
+				if tt.retryAfter != "" {
+					w.Header().Set("Retry-After", tt.retryAfter)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Origin.RetryAfterMax = tt.max
+			cfg.Cache.Enabled = false
+			h := newTestHandler(t, cfg)
+
+			first := serve(h, "/live/seg1.ts")
+			if got := first.Header().Get("Retry-After"); got != tt.wantFirst {
+				t.Errorf("first Retry-After %q, want %q", got, tt.wantFirst)
+			}
+
+			second := serve(h, "/live/seg1.ts")
+			if got := second.Header().Get("Retry-After"); got != tt.wantSecond {
+				t.Errorf("second Retry-After %q, want %q", got, tt.wantSecond)
+			}
+			if got := fetches.Load(); got != tt.wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, tt.wantFetches)
+			}
+			if got := counter(h.metrics, "origin.retry_after.held"); (got > 0) != tt.wantHeld {
+				t.Errorf("held requests %d, want held %v", got, tt.wantHeld)
+			}
+			if tt.wantHeld {
+				var body api.Error
+				json.Unmarshal(second.Body.Bytes(), &body)
+				if second.Code != http.StatusServiceUnavailable || body.Code != "circuit_open" {
+					t.Errorf("held request got %d %s, want 503 circuit_open", second.Code, body.Code)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{time.Second, "1"},
+		{1500 * time.Millisecond, "2"},
+		{time.Millisecond, "1"},
+		{time.Minute, "60"},
+	}
+
+	for _, tt := range tests {
+		if got := retryAfterSeconds(tt.d); got != tt.want {
+			t.Errorf("retryAfterSeconds(%v) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+func TestHandlerRecordsRewriteMetrics(t *testing.T) {
+	tests := []struct {
+		name          string
+		body          string
+		wantCounters  map[string]int
+		wantHistogram string
+	}{
+		{
+			name:          "media playlist",
+			body:          "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-KEY:METHOD=AES-128,URI=\"k.key\"\n#EXTINF:6,\nseg1.ts\n",
+			wantCounters:  map[string]int{"playlist.rewritten.media": 1, "playlist.rewrite.keys": 1},
+			wantHistogram: "playlist.rewrite.segments",
+		},
+		{
+			name:          "master playlist",
+			body:          "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nlow.m3u8\n",
+			wantCounters:  map[string]int{"playlist.rewritten.master": 1},
+			wantHistogram: "playlist.rewrite.variants",
+		},
+		{
+			name:          "fractional target duration",
+			body:          "#EXTM3U\n#EXT-X-TARGETDURATION:6.7\n#EXTINF:6,\nseg1.ts\n",
+			wantCounters:  map[string]int{"playlist.rewritten.media": 1, "playlist.warning": 1},
+			wantHistogram: "playlist.rewrite.segments",
+		},
+		{
+			name:         "parse error",
+			body:         "not a playlist\n",
+			wantCounters: map[string]int{"playlist.parse_error.header": 1, "playlist.rewritten.media": 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+				w.Write([]byte(tt.body))
+			}))
+			defer origin.Close()
+
+			h := newTestHandler(t, testConfig(origin.URL))
+			serve(h, "/live/index.m3u8")
+
+			for name, want := range tt.wantCounters {
+				if got := counter(h.metrics, name); got != want {
+					t.Errorf("%s = %d, want %d", name, got, want)
+				}
+			}
+			dump := h.metrics.(*telemetry.SimpleMetrics).DumpMetrics()
+			if tt.wantHistogram != "" {
+				if dump["histogram_"+tt.wantHistogram+"_count"] != 1 || dump["histogram_playlist.rewrite_ms_count"] != 1 {
+					t.Errorf("rewrite histograms not observed: %v", dump)
+				}
+			} else if dump["histogram_playlist.rewrite_ms_count"] != nil {
+				t.Error("latency observed for a failed rewrite")
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+// Server-Timing support
+//
+// Exposes internal request timings to clients:
+// - Cache lookup duration
+// - Origin fetch duration
+// - Playlist parse/rewrite duration
+
+package proxy
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timingEntry is a single Server-Timing metric
+type timingEntry struct {
+	name     string
+	duration time.Duration
+}
+
+// serverTiming collects durations for the Server-Timing header.
+// A nil *serverTiming is valid and records nothing.
+type serverTiming struct {
+	entries []timingEntry
+}
+
+// newServerTiming returns a collector if Server-Timing is enabled, or nil
+func newServerTiming(enabled bool) *serverTiming {
+	if !enabled {
+		return nil
+	}
+	return &serverTiming{entries: make([]timingEntry, 0, 3)}
+}
+
+// add records a duration under the given metric name
+func (st *serverTiming) add(name string, d time.Duration) {
+	if st == nil {
+		return
+	}
+	st.entries = append(st.entries, timingEntry{name: name, duration: d})
+}
+
+// since records the time elapsed since start under the given metric name
+func (st *serverTiming) since(name string, start time.Time) {
+	if st == nil {
+		return
+	}
+	st.add(name, time.Since(start))
+}
+
+// String formats the entries per the Server-Timing spec,
+// e.g. "cache;dur=0.1, origin;dur=12.3"
+func (st *serverTiming) String() string {
+	if st == nil {
+		return ""
+	}
+
+	parts := make([]string, 0, len(st.entries))
+	for _, e := range st.entries {
+		ms := float64(e.duration) / float64(time.Millisecond)
+		parts = append(parts, e.name+";dur="+strconv.FormatFloat(ms, 'f', 1, 64))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// writeHeader sets the Server-Timing header if any entries were recorded
+func (st *serverTiming) writeHeader(h http.Header) {
+	if st == nil || len(st.entries) == 0 {
+		return
+	}
+	h.Set("Server-Timing", st.String())
+}
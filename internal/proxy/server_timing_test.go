@@ -0,0 +1,71 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestServerTimingString(t *testing.T) {
+	tests := []struct {
+		name    string
+		enabled bool
+		entries []timingEntry
+		want    string
+	}{
+		{"disabled", false, []timingEntry{{"cache", time.Millisecond}}, ""},
+		{"empty", true, nil, ""},
+		{"one entry", true, []timingEntry{{"origin", 12300 * time.Microsecond}}, "origin;dur=12.3"},
+		{"several entries", true, []timingEntry{{"cache", 100 * time.Microsecond}, {"origin", 2 * time.Millisecond}, {"parse", 1050 * time.Microsecond}}, "cache;dur=0.1, origin;dur=2.0, parse;dur=1.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := newServerTiming(tt.enabled)
+			for _, e := range tt.entries {
+				st.add(e.name, e.duration)
+			}
+			if got := st.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+
+			header := http.Header{}
+			st.writeHeader(header)
+			if got := header.Get("Server-Timing"); got != tt.want {
+				t.Errorf("header = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandlerServerTimingHeader(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n")
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name    string
+		enabled bool
+		want    string
+	}{
+		{"disabled", false, `^$`},
+		{"enabled", true, `^cache;dur=\d+\.\d, origin;dur=\d+\.\d, parse;dur=\d+\.\d$`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.Server.ServerTiming = tt.enabled
+			rec := serve(newTestHandler(t, cfg), "/live/a.m3u8")
+
+			if got := rec.Header().Get("Server-Timing"); !regexp.MustCompile(tt.want).MatchString(got) {
+				t.Errorf("Server-Timing = %q, want match for %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandlerStreamThreshold(t *testing.T) {
+	tests := []struct {
+		name         string
+		threshold    int64
+		size         int
+		knownLength  bool
+		wantStreamed bool
+	}{
+		{"no threshold", 0, 4096, true, false},
+		{"below threshold", 1024, 512, true, false},
+		{"at threshold", 1024, 1024, true, false},
+		{"above threshold", 1024, 4096, true, true},
+		{"unknown length", 1024, 512, false, true},
+		{"unknown length without threshold", 0, 4096, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("x", tt.size)
+			var fetches int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&fetches, 1)
+				w.Header().Set("Content-Type", "video/mp2t")
+				if tt.knownLength {
+					w.Header().Set("Content-Length", strconv.Itoa(tt.size))
+				} else {
+					// Flushing before the body leaves the length unknown
+					w.(http.Flusher).Flush()
+				}
+				w.Write([]byte(body))
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.StreamThresholdBytes = tt.threshold
+			h := newTestHandler(t, cfg)
+
+			for i := 0; i < 2; i++ {
+				rec := serve(h, "/live/seg1.ts")
+				if rec.Code != http.StatusOK || rec.Body.String() != body {
+					t.Fatalf("request %d: status %d, %d bytes", i, rec.Code, rec.Body.Len())
+				}
+			}
+
+			// A streamed response is not cached, so both requests reach the origin
+			wantFetches := int32(1)
+			if tt.wantStreamed {
+				wantFetches = 2
+			}
+			if got := atomic.LoadInt32(&fetches); got != wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, wantFetches)
+			}
+			if streamed := counter(h.metrics, "response.streamed") > 0; streamed != tt.wantStreamed {
+				t.Errorf("streamed = %v, want %v", streamed, tt.wantStreamed)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/playlist"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+func TestHandlerSegmentTitles(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+		io.WriteString(w, "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,cue=ad\nseg1.ts\n")
+	}))
+	defer origin.Close()
+
+	tests := []struct {
+		name string
+		mode string
+		hook playlist.TitleHook
+		want string
+	}{
+		{"keep by default", "", nil, "#EXTINF:6,cue=ad\n"},
+		{"strip", "strip", nil, "#EXTINF:6,\n"},
+		{"embedder hook overrides the mode", "strip", func(title, uri string) string { return "[" + title + "]" }, "#EXTINF:6,[cue=ad]\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			if tt.mode != "" {
+				cfg.Origin.SegmentTitles = tt.mode
+			}
+			h := NewHandler(HandlerOptions{
+				Config:    cfg,
+				Cache:     cache.NewMemory(),
+				Logger:    telemetry.NewLogger("error", "", "stdout"),
+				Metrics:   telemetry.NewMetrics(),
+				TitleHook: tt.hook,
+			})
+
+			rec := serve(h, "/live/index.m3u8")
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", rec.Code, rec.Body.String())
+			}
+			if !strings.Contains(rec.Body.String(), tt.want) {
+				t.Errorf("playlist missing %q:\n%s", tt.want, rec.Body.String())
+			}
+		})
+	}
+}
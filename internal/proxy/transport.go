@@ -0,0 +1,109 @@
+// Origin transport construction
+//
+// Builds the HTTP transport used for origin requests:
+// - Pooling limits and timeouts from the origin config
+// - Optional custom dialer (custom DNS, sockets)
+// - Optional DNS cache in front of the dialer
+// - Client certificates, extra CAs and verification skipping, per route
+//   when configured
+// - Injectable round tripper for tests
+
+package proxy
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// DialContextFunc dials origin connections, like net.Dialer.DialContext
+type DialContextFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// newOriginTransport creates the pooled transport for origin requests,
+// dialing through dial when it is set
+func newOriginTransport(cfg *config.OriginConfig, dial DialContextFunc) (*http.Transport, error) {
+	tlsConfig, err := cfg.TLS.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:       cfg.MaxConnsPerHost,
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		TLSHandshakeTimeout:   cfg.TLSHandshakeTimeout,
+		ExpectContinueTimeout: cfg.ExpectContinueTimeout,
+		TLSClientConfig:       tlsConfig,
+	}
+	if dial != nil {
+		transport.DialContext = dial
+	}
+	return transport, nil
+}
+
+// originDialer returns how origin connections are dialed: the supplied
+// dialer, if any, behind the DNS cache when caching is enabled. It returns
+// nil for the transport's default dialing.
+func originDialer(opts HandlerOptions) DialContextFunc {
+	dns := newDNSCache(&opts.Config.Origin.DNS, opts.Resolver, opts.Metrics)
+	if dns == nil {
+		return opts.DialContext
+	}
+
+	dial := opts.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	}
+	return dns.dialContext(dial)
+}
+
+// originRoundTripper returns the round tripper for origin requests: the
+// supplied one when set, otherwise a transport built from the config
+func originRoundTripper(cfg *config.OriginConfig, rt http.RoundTripper, dial DialContextFunc) (http.RoundTripper, error) {
+	if rt != nil {
+		return rt, nil
+	}
+	return newOriginTransport(cfg, dial)
+}
+
+// routeClient returns the client for a route with its own TLS settings: a
+// copy of the default transport using them. Injected round trippers are
+// shared as they are.
+func routeClient(defaultClient *http.Client, tlsCfg *config.OriginTLSConfig) (*http.Client, error) {
+	if tlsCfg == nil {
+		return defaultClient, nil
+	}
+	base, ok := defaultClient.Transport.(*http.Transport)
+	if !ok {
+		return defaultClient, nil
+	}
+
+	tlsConfig, err := tlsCfg.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = tlsConfig
+
+	client := *defaultClient
+	client.Transport = transport
+	return &client, nil
+}
+
+// warnInsecureOrigins logs every origin whose certificates are not verified,
+// so that a test setting never goes unnoticed in production
+func warnInsecureOrigins(cfg *config.OriginConfig, logger telemetry.Logger) {
+	if cfg.TLS.InsecureSkipVerify {
+		logger.Warn("TLS certificate verification is DISABLED for origin connections", "origin", cfg.BaseURL)
+	}
+	for _, route := range cfg.Routes {
+		if route.TLS != nil && route.TLS.InsecureSkipVerify {
+			logger.Warn("TLS certificate verification is DISABLED for origin route", "route", route.Name, "origin", route.BaseURL)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/cache"
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+)
+
+// roundTripFunc is a stub origin transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// stubResponse returns a 200 response with body for r
+func stubResponse(r *http.Request, body string) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"video/mp2t"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       r,
+	}
+}
+
+// redirectDial dials addr whatever address is asked for, counting dials
+func redirectDial(addr string, dials *int32) DialContextFunc {
+	return func(ctx context.Context, network, _ string) (net.Conn, error) {
+		atomic.AddInt32(dials, 1)
+		return (&net.Dialer{}).DialContext(ctx, network, addr)
+	}
+}
+
+func TestHandlerInjectedOriginTransport(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		io.WriteString(w, "dialed")
+	}))
+	defer origin.Close()
+
+	var requested string
+	var dials int32
+	tests := []struct {
+		name     string
+		opts     HandlerOptions
+		wantBody string
+	}{
+		{
+			name: "transport",
+			opts: HandlerOptions{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				requested = r.URL.String()
+				return stubResponse(r, "stubbed"), nil
+			})},
+			wantBody: "stubbed",
+		},
+		{
+			name:     "dialer",
+			opts:     HandlerOptions{DialContext: redirectDial(origin.Listener.Addr().String(), &dials)},
+			wantBody: "dialed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The origin name does not resolve; only the injected transport
+			// or dialer can reach anything
+			opts := tt.opts
+			opts.Config = testConfig("http://origin.invalid")
+			opts.Cache = cache.NewMemory()
+			opts.Logger = telemetry.NewLogger("error", "", "stdout")
+			opts.Metrics = telemetry.NewMetrics()
+
+			rec := serve(NewHandler(opts), "/live/seg1.ts")
+			if rec.Code != http.StatusOK || rec.Body.String() != tt.wantBody {
+				t.Fatalf("status %d body %q, want 200 %q", rec.Code, rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+
+	if requested != "http://origin.invalid/live/seg1.ts" {
+		t.Errorf("stub transport saw %q", requested)
+	}
+	if atomic.LoadInt32(&dials) == 0 {
+		t.Error("injected dialer not used")
+	}
+}
+
+func TestConnectionPoolInjection(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "dialed")
+	}))
+	defer origin.Close()
+
+	var dials int32
+	tests := []struct {
+		name     string
+		inject   func(*ConnectionPool)
+		wantBody string
+	}{
+		{
+			name: "transport",
+			inject: func(p *ConnectionPool) {
+				p.SetTransport(roundTripFunc(func(r *http.Request) (*http.Response, error) {
+					return stubResponse(r, "stubbed"), nil
+				}))
+			},
+			wantBody: "stubbed",
+		},
+		{
+			name: "dialer",
+			inject: func(p *ConnectionPool) {
+				p.SetDialContext(redirectDial(origin.Listener.Addr().String(), &dials))
+			},
+			wantBody: "dialed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{}
+			config.SetDefaults(cfg)
+			pool := NewConnectionPool(&cfg.Origin)
+			tt.inject(pool)
+
+			for _, client := range []*http.Client{pool.GetClient("origin.invalid"), pool.GetDefaultClient()} {
+				resp, err := client.Get("http://origin.invalid/seg1.ts")
+				if err != nil {
+					t.Fatalf("Get: %v", err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != tt.wantBody {
+					t.Errorf("body %q, want %q", body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
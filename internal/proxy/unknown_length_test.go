@@ -0,0 +1,112 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestHandlerUnknownLength(t *testing.T) {
+	tests := []struct {
+		name         string
+		mode         string
+		threshold    int64
+		size         int
+		wantStreamed bool
+	}{
+		{"auto without threshold buffers", "auto", 0, 4096, false},
+		{"auto with threshold streams", "auto", 1024, 512, true},
+		{"unset mode is auto", "", 1024, 512, true},
+		{"stream", "stream", 0, 512, true},
+		{"buffer", "buffer", 0, 4096, false},
+		{"buffer within threshold", "buffer", 1024, 512, false},
+		{"buffer at threshold", "buffer", 1024, 1024, false},
+		{"buffer outgrows threshold", "buffer", 1024, 64 * 1024, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := strings.Repeat("0123456789abcdef", tt.size/16)
+			var fetches int32
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&fetches, 1)
+				w.Header().Set("Content-Type", "video/mp2t")
+				// Send the body in flushed chunks, so it arrives chunked
+				for i := 0; i < len(body); i += 256 {
+					end := i + 256
+					if end > len(body) {
+						end = len(body)
+					}
+					io.WriteString(w, body[i:end])
+					w.(http.Flusher).Flush()
+				}
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Cache.Enabled = true
+			cfg.Cache.UnknownLength = tt.mode
+			cfg.Cache.StreamThresholdBytes = tt.threshold
+			h := newTestHandler(t, cfg)
+
+			// Serve through a real server to see the framing clients get
+			proxy := httptest.NewServer(h)
+			defer proxy.Close()
+
+			for i := 0; i < 2; i++ {
+				resp, err := http.Get(proxy.URL + "/live/seg1.ts")
+				if err != nil {
+					t.Fatalf("request %d: %v", i, err)
+				}
+				got, err := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if err != nil || resp.StatusCode != http.StatusOK || string(got) != body {
+					t.Fatalf("request %d: status %d, %d bytes of %d, %v", i, resp.StatusCode, len(got), len(body), err)
+				}
+
+				// Buffered bodies carry their real length. Streamed ones are
+				// sent chunked, unless the server saw the whole body before
+				// writing headers and set its length itself; never a wrong
+				// or empty length.
+				switch {
+				case !tt.wantStreamed && resp.ContentLength != int64(len(body)):
+					t.Errorf("request %d: Content-Length %d, want %d", i, resp.ContentLength, len(body))
+				case tt.wantStreamed && resp.ContentLength != -1 && resp.ContentLength != int64(len(body)):
+					t.Errorf("request %d: Content-Length %d for a %d byte body", i, resp.ContentLength, len(body))
+				case tt.wantStreamed && len(body) > 4096 && len(resp.TransferEncoding) == 0:
+					t.Errorf("request %d: large streamed body not chunked", i)
+				}
+			}
+
+			// Only buffered responses are cached
+			wantFetches := int32(1)
+			if tt.wantStreamed {
+				wantFetches = 2
+			}
+			if got := atomic.LoadInt32(&fetches); got != wantFetches {
+				t.Errorf("origin fetched %d times, want %d", got, wantFetches)
+			}
+			if streamed := counter(h.metrics, "response.streamed") > 0; streamed != tt.wantStreamed {
+				t.Errorf("streamed = %v, want %v", streamed, tt.wantStreamed)
+			}
+		})
+	}
+}
+
+func TestHandlerKnownLengthOverridesOriginHeader(t *testing.T) {
+	// A buffered response is sent with the length actually read
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp2t")
+		io.WriteString(w, "segment")
+	}))
+	defer origin.Close()
+
+	h := newTestHandler(t, testConfig(origin.URL))
+	rec := serve(h, "/live/seg1.ts")
+	if got := rec.Header().Get("Content-Length"); got != "7" {
+		t.Errorf("Content-Length %q, want 7", got)
+	}
+}
@@ -0,0 +1,61 @@
+// URL length limits
+//
+// Keeps URLs within what CDNs and players accept:
+// - Target URLs over the limit are rejected
+// - Rewritten playlist URLs nearing the limit are reported
+
+package proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// ErrTargetURLTooLong is returned for target URLs over the maximum length
+var ErrTargetURLTooLong = errors.New("target URL too long")
+
+// urlLengthWarnPercent is the share of the maximum URL length at which a
+// rewritten URL is reported
+const urlLengthWarnPercent = 90
+
+// checkTargetLength rejects a target URL longer than the configured maximum
+func (h *Handler) checkTargetLength(target *url.URL) error {
+	limit := h.config.Origin.MaxURLLength
+	if limit <= 0 || len(target.String()) <= limit {
+		return nil
+	}
+	h.metrics.IncCounter("url.too_long")
+	return ErrTargetURLTooLong
+}
+
+// checkRewrittenLength reports when the longest URI of a rewritten playlist,
+// as the client resolves it, nears or exceeds the configured maximum. The
+// playlist is still served: the URLs are only rejected if they come back.
+func (h *Handler) checkRewrittenLength(r *http.Request, proxyURL *url.URL, longest string) {
+	limit := h.config.Origin.MaxURLLength
+	if limit <= 0 || longest == "" {
+		return
+	}
+
+	// Measure the absolute URL the client requests, with the longer scheme
+	ref, err := url.Parse(longest)
+	if err != nil {
+		return
+	}
+	base := &url.URL{Scheme: "https", Host: r.Host, Path: proxyURL.Path}
+	length := len(base.ResolveReference(ref).String())
+	if length*100 < limit*urlLengthWarnPercent {
+		return
+	}
+
+	h.metrics.IncCounter("url.rewritten_long")
+	h.logger.Warn("Rewritten playlist URL nears the maximum URL length; consider shorter tokens or paths",
+		"path", utils.RedactPath(r.URL.Path),
+		"length", strconv.Itoa(length),
+		"limit", strconv.Itoa(limit),
+	)
+}
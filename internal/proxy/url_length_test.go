@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ilijajolevski/ilinden/internal/api"
+)
+
+func TestHandlerMaxURLLength(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("segment"))
+	}))
+	defer origin.Close()
+
+	// Paths of a given length, so the target is origin.URL plus that many
+	// bytes
+	path := func(n int) string {
+		return "/live/" + strings.Repeat("a", n-len("/live/.ts")) + ".ts"
+	}
+	limit := len(origin.URL) + 200
+
+	tests := []struct {
+		name       string
+		maxLength  int
+		target     string
+		wantStatus int
+	}{
+		{"disabled", 0, path(2000), http.StatusOK},
+		{"below limit", limit, path(199), http.StatusOK},
+		{"at limit", limit, path(200), http.StatusOK},
+		{"beyond limit", limit, path(201), http.StatusRequestURITooLong},
+		{"explicit target beyond limit", limit, "/proxy?url=" + url.QueryEscape(origin.URL+path(201)), http.StatusRequestURITooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig(origin.URL)
+			cfg.Origin.MaxURLLength = tt.maxLength
+			h := newTestHandler(t, cfg)
+
+			rec := serve(h, tt.target)
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body.String())
+			}
+			if tt.wantStatus != http.StatusRequestURITooLong {
+				return
+			}
+			var body api.Error
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("body is not JSON: %v", err)
+			}
+			if body.Code != "target_url_too_long" {
+				t.Errorf("error code %q, want target_url_too_long", body.Code)
+			}
+			if got := counter(h.metrics, "url.too_long"); got != 1 {
+				t.Errorf("rejections counted %d, want 1", got)
+			}
+		})
+	}
+}
+
+func TestCheckRewrittenLength(t *testing.T) {
+	// The client resolves URIs against https://example.com/live/
+	const base = len("https://example.com/live/")
+	name := func(n int) string { return strings.Repeat("s", n-base) }
+
+	tests := []struct {
+		name     string
+		limit    int
+		longest  string
+		wantWarn bool
+	}{
+		{"disabled", 0, name(5000), false},
+		{"no URIs", 100, "", false},
+		{"well below limit", 100, name(50), false},
+		{"just below 90 percent", 100, name(89), false},
+		{"at 90 percent", 100, name(90), true},
+		{"beyond limit", 100, name(150), true},
+		{"absolute URI", 100, "https://example.com/" + strings.Repeat("s", 80), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig("http://origin.invalid")
+			cfg.Origin.MaxURLLength = tt.limit
+			h := newTestHandler(t, cfg)
+
+			r := httptest.NewRequest(http.MethodGet, "http://example.com/live/index.m3u8", nil)
+			h.checkRewrittenLength(r, &url.URL{Path: "/live/index.m3u8"}, tt.longest)
+			if warned := counter(h.metrics, "url.rewritten_long") > 0; warned != tt.wantWarn {
+				t.Errorf("warned = %v, want %v", warned, tt.wantWarn)
+			}
+		})
+	}
+}
+
+func TestHandlerReportsLongRewrittenURLs(t *testing.T) {
+	tests := []struct {
+		name     string
+		segment  string
+		wantWarn bool
+	}{
+		{"short segment names", "seg1.ts", false},
+		{"long segment names", strings.Repeat("s", 300) + ".ts", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+				w.Write([]byte("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\n" + tt.segment + "\n"))
+			}))
+			defer origin.Close()
+
+			cfg := testConfig(origin.URL)
+			cfg.Origin.MaxURLLength = 320
+			h := newTestHandler(t, cfg)
+
+			// The playlist is served either way; long URLs are only reported
+			rec := serve(h, "/live/index.m3u8")
+			if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), tt.segment) {
+				t.Fatalf("status %d:\n%s", rec.Code, rec.Body.String())
+			}
+			if warned := counter(h.metrics, "url.rewritten_long") > 0; warned != tt.wantWarn {
+				t.Errorf("warned = %v, want %v", warned, tt.wantWarn)
+			}
+		})
+	}
+}
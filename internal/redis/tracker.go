@@ -3,6 +3,7 @@
 // Redis-based player tracking:
 // - Activity recording
 // - Session tracking
+// - Debounced activity counting
 // - Analytics support
 // - Efficient data structures
 
@@ -14,6 +15,7 @@ import (
 
 	"github.com/ilijajolevski/ilinden/internal/config"
 	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
 )
 
 // Tracker handles player activity tracking
@@ -26,6 +28,12 @@ type Tracker struct {
 	players     map[string]*PlayerInfo
 	mu          sync.RWMutex
 	trackExpiry time.Duration
+	debounce    time.Duration
+	clock       utils.Clock
+	requests    int64
+	activity    int64
+	stop        chan struct{}
+	stopOnce    sync.Once
 }
 
 // PlayerInfo represents player tracking information
@@ -35,7 +43,19 @@ type PlayerInfo struct {
 	Path           string
 	UserAgent      string
 	FirstSeen      time.Time
-	ActivityCount  int
+	ActivityCount  int       // Activity, counted at most once per debounce window
+	RequestCount   int       // Every tracked request
+	SessionCount   int       // Sessions, each starting after the player was idle past the expiry
+	SessionStart   time.Time
+
+	countedAt time.Time // When activity was last counted
+}
+
+// TrackerStats summarizes tracked activity
+type TrackerStats struct {
+	ActiveSessions int   // Players active within the expiry
+	Requests       int64 // Every tracked request
+	Activity       int64 // Activity after debouncing
 }
 
 // NewTracker creates a new player tracker
@@ -45,34 +65,56 @@ func NewTracker(config *config.RedisConfig, logger telemetry.Logger) *Tracker {
 		logger:      logger,
 		players:     make(map[string]*PlayerInfo),
 		trackExpiry: config.TrackingExpiry,
+		debounce:    config.TrackingDebounce,
+		clock:       utils.RealClock{},
+		stop:        make(chan struct{}),
 	}
 }
 
-// TrackPlayer tracks player activity
+// SetClock replaces the clock used for activity times, e.g. with a fake one
+// in tests
+func (t *Tracker) SetClock(clock utils.Clock) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.clock = utils.ClockOrReal(clock)
+}
+
+// TrackPlayer tracks player activity. Every call is counted as a request,
+// but activity within the debounce window of the last counted activity,
+// such as a player polling a live playlist, is counted once.
 func (t *Tracker) TrackPlayer(playerID, path, userAgent string) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	now := time.Now()
+	now := t.clock.Now()
+	t.requests++
 
 	// Check if player exists
 	player, exists := t.players[playerID]
 	if !exists {
 		// Create new player
 		player = &PlayerInfo{
-			PlayerID:      playerID,
-			LastActivity:  now,
-			Path:          path,
-			UserAgent:     userAgent,
-			FirstSeen:     now,
-			ActivityCount: 1,
+			PlayerID:     playerID,
+			UserAgent:    userAgent,
+			FirstSeen:    now,
+			SessionCount: 1,
+			SessionStart: now,
 		}
 		t.players[playerID] = player
-	} else {
-		// Update existing player
-		player.LastActivity = now
-		player.Path = path
+	} else if now.Sub(player.LastActivity) > t.trackExpiry {
+		// Returning after expiring, before cleanup removed the player
+		player.SessionCount++
+		player.SessionStart = now
+	}
+
+	player.RequestCount++
+	player.Path = path
+	player.LastActivity = now
+	if player.ActivityCount == 0 || t.debounce <= 0 || now.Sub(player.countedAt) >= t.debounce {
 		player.ActivityCount++
+		player.countedAt = now
+		t.activity++
 	}
 
 	// In a real implementation, this would be sent to Redis
@@ -87,7 +129,7 @@ func (t *Tracker) GetActivePlayers() int {
 	// In a real implementation, this would query Redis
 	// for the count of active players
 	count := 0
-	now := time.Now()
+	now := t.clock.Now()
 	cutoff := now.Add(-t.trackExpiry)
 
 	for _, player := range t.players {
@@ -99,6 +141,21 @@ func (t *Tracker) GetActivePlayers() int {
 	return count
 }
 
+// Stats returns the number of active sessions and the request and activity
+// totals
+func (t *Tracker) Stats() TrackerStats {
+	active := t.GetActivePlayers()
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return TrackerStats{
+		ActiveSessions: active,
+		Requests:       t.requests,
+		Activity:       t.activity,
+	}
+}
+
 // GetPlayerInfo returns information about a player
 func (t *Tracker) GetPlayerInfo(playerID string) *PlayerInfo {
 	t.mu.RLock()
@@ -119,18 +176,34 @@ func (t *Tracker) StartCleanupWorker() {
 	// This is just a simple in-memory implementation
 	ticker := time.NewTicker(t.trackExpiry / 2)
 	go func() {
-		for range ticker.C {
-			t.cleanup()
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.cleanup()
+			case <-t.stop:
+				return
+			}
 		}
 	}()
 }
 
+// Stop stops the cleanup worker and drops players that have already
+// expired, so nothing stale is left behind at shutdown. It is safe to call
+// more than once.
+func (t *Tracker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stop)
+		t.cleanup()
+	})
+}
+
 // cleanup removes expired players
 func (t *Tracker) cleanup() {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
-	now := time.Now()
+	now := t.clock.Now()
 	cutoff := now.Add(-t.trackExpiry)
 
 	for id, player := range t.players {
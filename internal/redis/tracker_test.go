@@ -0,0 +1,152 @@
+package redis
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+	"github.com/ilijajolevski/ilinden/internal/telemetry"
+	"github.com/ilijajolevski/ilinden/internal/utils"
+)
+
+// newTestTracker creates a tracker on a fake clock
+func newTestTracker(expiry, debounce time.Duration) (*Tracker, *utils.FakeClock) {
+	clock := utils.NewFakeClock(time.Unix(1700000000, 0))
+	tracker := NewTracker(&config.RedisConfig{TrackingExpiry: expiry, TrackingDebounce: debounce}, telemetry.NewLogger("error", "", "stdout"))
+	tracker.SetClock(clock)
+	return tracker, clock
+}
+
+func TestTrackerExpiryFollowsClock(t *testing.T) {
+	tests := []struct {
+		name         string
+		idle         time.Duration
+		wantActive   int
+		wantSessions int
+		wantKept     bool
+	}{
+		{"active", 30 * time.Second, 1, 1, true},
+		{"just inside expiry", 59 * time.Second, 1, 1, true},
+		{"expired", time.Minute + time.Second, 0, 2, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, clock := newTestTracker(time.Minute, 0)
+			tracker.TrackPlayer("p1", "/live/a.m3u8", "ua")
+			clock.Advance(tt.idle)
+
+			if got := tracker.GetActivePlayers(); got != tt.wantActive {
+				t.Errorf("active players %d, want %d", got, tt.wantActive)
+			}
+
+			// Cleanup on the same clock drops only expired players
+			tracker.cleanup()
+			if kept := tracker.GetPlayerInfo("p1") != nil; kept != tt.wantKept {
+				t.Errorf("player kept = %v, want %v", kept, tt.wantKept)
+			}
+
+			// Returning after the expiry starts a new session
+			tracker.TrackPlayer("p1", "/live/a.m3u8", "ua")
+			if tt.wantKept {
+				if got := tracker.GetPlayerInfo("p1").SessionCount; got != tt.wantSessions {
+					t.Errorf("sessions %d, want %d", got, tt.wantSessions)
+				}
+			}
+			if info := tracker.GetPlayerInfo("p1"); !info.LastActivity.Equal(clock.Now()) {
+				t.Errorf("last activity %v, want the fake clock's %v", info.LastActivity, clock.Now())
+			}
+		})
+	}
+}
+
+func TestTrackerStopFlushesOnce(t *testing.T) {
+	tests := []struct {
+		name     string
+		idle     time.Duration
+		wantKept bool
+	}{
+		{"active player kept", 30 * time.Second, true},
+		{"expired player flushed", 2 * time.Minute, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, clock := newTestTracker(time.Minute, 0)
+			tracker.StartCleanupWorker()
+			tracker.TrackPlayer("p1", "/live/a.m3u8", "ua")
+			clock.Advance(tt.idle)
+
+			// A second stop must neither panic on the closed channel nor
+			// run cleanup again
+			tracker.Stop()
+			tracker.Stop()
+
+			if kept := tracker.GetPlayerInfo("p1") != nil; kept != tt.wantKept {
+				t.Errorf("player kept = %v, want %v", kept, tt.wantKept)
+			}
+		})
+	}
+}
+
+func TestTrackerDebouncesActivity(t *testing.T) {
+	tests := []struct {
+		name         string
+		debounce     time.Duration
+		calls        []time.Duration // Offsets of each TrackPlayer call from the first
+		wantActivity int
+		wantSessions int
+	}{
+		{"no debounce counts every request", 0, []time.Duration{0, time.Second, 2 * time.Second}, 3, 1},
+		{"rapid polling counts once", 10 * time.Second, []time.Duration{0, 0, time.Second, 2 * time.Second, 9 * time.Second}, 1, 1},
+		{"counted again after the window", 10 * time.Second, []time.Duration{0, 6 * time.Second, 10 * time.Second, 16 * time.Second, 20 * time.Second}, 3, 1},
+		{"window runs from the last counted activity", 10 * time.Second, []time.Duration{0, 8 * time.Second, 16 * time.Second}, 2, 1},
+		{"new session after expiry", 10 * time.Second, []time.Duration{0, time.Second, 2 * time.Minute, 2*time.Minute + time.Second}, 2, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker, clock := newTestTracker(time.Minute, tt.debounce)
+			start := clock.Now()
+			for _, at := range tt.calls {
+				clock.Set(start.Add(at))
+				tracker.TrackPlayer("p1", "/live/a.m3u8", "ua")
+			}
+
+			info := tracker.GetPlayerInfo("p1")
+			if info.RequestCount != len(tt.calls) {
+				t.Errorf("requests %d, want %d", info.RequestCount, len(tt.calls))
+			}
+			if info.ActivityCount != tt.wantActivity {
+				t.Errorf("activity %d, want %d", info.ActivityCount, tt.wantActivity)
+			}
+			if info.SessionCount != tt.wantSessions {
+				t.Errorf("sessions %d, want %d", info.SessionCount, tt.wantSessions)
+			}
+
+			stats := tracker.Stats()
+			want := TrackerStats{ActiveSessions: 1, Requests: int64(len(tt.calls)), Activity: int64(tt.wantActivity)}
+			if stats != want {
+				t.Errorf("stats %+v, want %+v", stats, want)
+			}
+		})
+	}
+}
+
+func TestTrackerDebouncePerPlayer(t *testing.T) {
+	tracker, clock := newTestTracker(time.Minute, 10*time.Second)
+	for i := 0; i < 5; i++ {
+		tracker.TrackPlayer("p1", "/live/a.m3u8", "ua")
+		tracker.TrackPlayer("p2", "/live/b.m3u8", "ua")
+		clock.Advance(time.Second)
+	}
+
+	// Each player counts once, however their polls interleave
+	want := TrackerStats{ActiveSessions: 2, Requests: 10, Activity: 2}
+	if stats := tracker.Stats(); stats != want {
+		t.Errorf("stats %+v, want %+v", stats, want)
+	}
+	if path := tracker.GetPlayerInfo("p2").Path; path != "/live/b.m3u8" {
+		t.Errorf("p2 path %s, want its own", path)
+	}
+}
@@ -0,0 +1,98 @@
+// Component lifecycle
+//
+// Ordered shutdown of components after the servers drain:
+// - Phases run in a fixed order
+// - Registration order within a phase
+// - Each component closed exactly once
+// - Errors collected rather than aborting shutdown
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Phase orders component shutdown. Servers stop accepting and drain
+// requests before any phase runs.
+type Phase int
+
+const (
+	PhaseFlush   Phase = iota // Flush buffered state, such as player tracking
+	PhasePools                // Close connection pools and caches
+	PhaseWorkers              // Stop background workers and collectors
+)
+
+// String returns the phase name used in shutdown errors
+func (p Phase) String() string {
+	switch p {
+	case PhaseFlush:
+		return "flush"
+	case PhasePools:
+		return "pools"
+	case PhaseWorkers:
+		return "workers"
+	default:
+		return fmt.Sprintf("phase %d", int(p))
+	}
+}
+
+// component is a registered close function
+type component struct {
+	phase Phase
+	name  string
+	close func() error
+}
+
+// Lifecycle closes registered components in phase order on shutdown
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []component
+	closed     bool
+}
+
+// NewLifecycle creates an empty component lifecycle
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Add registers a component closed during phase. Components in the same
+// phase close in the order they were added.
+func (l *Lifecycle) Add(phase Phase, name string, close func() error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.components = append(l.components, component{phase: phase, name: name, close: close})
+}
+
+// Close closes every component in phase order. A failing component does
+// not stop the rest; their errors are joined. Only the first call closes
+// anything.
+func (l *Lifecycle) Close() error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return nil
+	}
+	l.closed = true
+	components := append([]component(nil), l.components...)
+	l.mu.Unlock()
+
+	sort.SliceStable(components, func(i, j int) bool {
+		return components[i].phase < components[j].phase
+	})
+
+	var errs []error
+	for _, c := range components {
+		if err := c.close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: closing %s: %w", c.phase, c.name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
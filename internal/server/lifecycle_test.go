@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closeEntry describes a component registered with a lifecycle
+type closeEntry struct {
+	phase Phase
+	name  string
+	err   error
+}
+
+func TestLifecycleCloseOrder(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []closeEntry
+		wantOrder  []string
+		wantErrs   []string
+	}{
+		{
+			name:       "empty",
+			components: nil,
+			wantOrder:  nil,
+		},
+		{
+			name: "phases out of registration order",
+			components: []closeEntry{
+				{PhaseWorkers, "collector", nil},
+				{PhasePools, "origins", nil},
+				{PhaseFlush, "tracker", nil},
+			},
+			wantOrder: []string{"tracker", "origins", "collector"},
+		},
+		{
+			name: "registration order within a phase",
+			components: []closeEntry{
+				{PhasePools, "origins", nil},
+				{PhaseFlush, "tracker", nil},
+				{PhasePools, "jwks", nil},
+				{PhasePools, "cache", nil},
+			},
+			wantOrder: []string{"tracker", "origins", "jwks", "cache"},
+		},
+		{
+			name: "failures do not stop the rest",
+			components: []closeEntry{
+				{PhaseWorkers, "collector", errors.New("stuck")},
+				{PhaseFlush, "tracker", errors.New("unreachable")},
+				{PhasePools, "origins", nil},
+			},
+			wantOrder: []string{"tracker", "origins", "collector"},
+			wantErrs:  []string{"flush: closing tracker: unreachable", "workers: closing collector: stuck"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order []string
+			calls := map[string]int{}
+			l := NewLifecycle()
+			for _, c := range tt.components {
+				c := c
+				l.Add(c.phase, c.name, func() error {
+					order = append(order, c.name)
+					calls[c.name]++
+					return c.err
+				})
+			}
+
+			err := l.Close()
+			if !reflect.DeepEqual(order, tt.wantOrder) {
+				t.Errorf("close order %v, want %v", order, tt.wantOrder)
+			}
+			if len(tt.wantErrs) == 0 && err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			for _, want := range tt.wantErrs {
+				if err == nil || !strings.Contains(err.Error(), want) {
+					t.Errorf("error %v, want it to contain %q", err, want)
+				}
+			}
+			for _, c := range tt.components {
+				if c.err != nil && !errors.Is(err, c.err) {
+					t.Errorf("error %v does not wrap %v", err, c.err)
+				}
+			}
+
+			// A second close must not close anything again
+			if err := l.Close(); err != nil {
+				t.Errorf("second Close: %v", err)
+			}
+			for name, n := range calls {
+				if n != 1 {
+					t.Errorf("%s closed %d times, want 1", name, n)
+				}
+			}
+		})
+	}
+}
+
+func TestLifecycleNil(t *testing.T) {
+	var l *Lifecycle
+	if err := l.Close(); err != nil {
+		t.Fatalf("nil lifecycle Close: %v", err)
+	}
+}
+
+func TestPhaseString(t *testing.T) {
+	tests := []struct {
+		phase Phase
+		want  string
+	}{
+		{PhaseFlush, "flush"},
+		{PhasePools, "pools"},
+		{PhaseWorkers, "workers"},
+		{Phase(7), "phase 7"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.phase.String(); got != tt.want {
+			t.Errorf("Phase(%d).String() = %q, want %q", int(tt.phase), got, tt.want)
+		}
+	}
+}
+
+func TestGracefulShutdownClosesLifecycleAfterServers(t *testing.T) {
+	tests := []struct {
+		name     string
+		closeErr error
+	}{
+		{"clean", nil},
+		{"component error reported", errors.New("flush failed")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := startServer(t)
+			extra := startServer(t)
+
+			calls := 0
+			var serving []bool
+			l := NewLifecycle()
+			l.Add(PhaseFlush, "tracker", func() error {
+				calls++
+				for _, srv := range []*Server{main, extra} {
+					client := &http.Client{Timeout: time.Second}
+					resp, err := client.Get("http://" + srv.Addr())
+					if err == nil {
+						resp.Body.Close()
+					}
+					serving = append(serving, err == nil)
+				}
+				return tt.closeErr
+			})
+
+			gs := NewGracefulShutdown(main, time.Second).WithServers(extra).WithLifecycle(l)
+			err := gs.stop(context.Background())
+			if !errors.Is(err, tt.closeErr) || (tt.closeErr == nil && err != nil) {
+				t.Fatalf("stop: %v, want %v", err, tt.closeErr)
+			}
+			if calls != 1 {
+				t.Fatalf("lifecycle closed %d times, want 1", calls)
+			}
+			for i, up := range serving {
+				if up {
+					t.Errorf("server %d still serving while components closed", i)
+				}
+			}
+		})
+	}
+}
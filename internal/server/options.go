@@ -42,6 +42,17 @@ func NewOptionsFromConfig(cfg *config.Config) Options {
 	}
 }
 
+// NewInternalOptions creates options for an internal listener, such as the
+// metrics server, sharing the main server's timeouts. There is no write
+// timeout so that CPU profiles and traces can run for their full duration.
+func NewInternalOptions(cfg *config.Config, address string) Options {
+	opts := NewOptionsFromConfig(cfg)
+	opts.Address = address
+	opts.WriteTimeout = 0
+	opts.EnableCompression = false
+	return opts
+}
+
 // WithTLS adds TLS configuration to the server options
 func (o Options) WithTLS(cert, key string) (Options, error) {
 	cert2, err := tls.LoadX509KeyPair(cert, key)
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ilijajolevski/ilinden/internal/config"
+)
+
+func TestNewInternalOptions(t *testing.T) {
+	cfg := &config.Config{}
+	config.SetDefaults(cfg)
+	cfg.Server.EnableCompression = true
+	cfg.Server.ReadTimeout = 7 * time.Second
+	cfg.Server.WriteTimeout = 10 * time.Second
+
+	opts := NewInternalOptions(cfg, "127.0.0.1:9090")
+	if opts.Address != "127.0.0.1:9090" {
+		t.Errorf("address %q, want the internal address", opts.Address)
+	}
+	if opts.WriteTimeout != 0 {
+		t.Errorf("write timeout %v would cut profiles short", opts.WriteTimeout)
+	}
+	if opts.EnableCompression {
+		t.Error("internal listener compresses responses")
+	}
+	if opts.ReadTimeout != cfg.Server.ReadTimeout {
+		t.Errorf("read timeout %v, want the main server's %v", opts.ReadTimeout, cfg.Server.ReadTimeout)
+	}
+}
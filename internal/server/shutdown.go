@@ -4,7 +4,7 @@
 // - Stop accepting new connections
 // - Wait for active requests to complete
 // - Timeout for lingering connections
-// - Resource cleanup
+// - Ordered resource cleanup
 
 package server
 
@@ -20,6 +20,8 @@ import (
 // GracefulShutdown handles graceful shutdown of a server when receiving termination signals
 type GracefulShutdown struct {
 	server          *Server
+	extra           []*Server
+	lifecycle       *Lifecycle
 	shutdownTimeout time.Duration
 	signals         []os.Signal
 }
@@ -39,6 +41,34 @@ func (gs *GracefulShutdown) WithSignals(signals ...os.Signal) *GracefulShutdown
 	return gs
 }
 
+// WithServers adds servers stopped after the main server, such as internal
+// listeners that should stay available while the main server drains
+func (gs *GracefulShutdown) WithServers(servers ...*Server) *GracefulShutdown {
+	gs.extra = append(gs.extra, servers...)
+	return gs
+}
+
+// WithLifecycle sets the components closed once the servers have stopped
+func (gs *GracefulShutdown) WithLifecycle(lifecycle *Lifecycle) *GracefulShutdown {
+	gs.lifecycle = lifecycle
+	return gs
+}
+
+// stop shuts down the main server and then any additional servers, then
+// closes the lifecycle components even if a server failed to drain
+func (gs *GracefulShutdown) stop(ctx context.Context) error {
+	err := gs.server.Stop(ctx)
+	for _, srv := range gs.extra {
+		if stopErr := srv.Stop(ctx); stopErr != nil && err == nil {
+			err = stopErr
+		}
+	}
+	if closeErr := gs.lifecycle.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // HandleShutdown starts listening for signals and performs graceful shutdown when received
 func (gs *GracefulShutdown) HandleShutdown() {
 	sigChan := make(chan os.Signal, 1)
@@ -51,7 +81,7 @@ func (gs *GracefulShutdown) HandleShutdown() {
 		ctx, cancel := context.WithTimeout(context.Background(), gs.shutdownTimeout)
 		defer cancel()
 
-		if err := gs.server.Stop(ctx); err != nil {
+		if err := gs.stop(ctx); err != nil {
 			fmt.Printf("Error during server shutdown: %v\n", err)
 			os.Exit(1)
 		}
@@ -75,7 +105,7 @@ func (gs *GracefulShutdown) WaitForShutdown() {
 	defer cancel()
 
 	// Attempt to shut down gracefully
-	if err := gs.server.Stop(ctx); err != nil {
+	if err := gs.stop(ctx); err != nil {
 		fmt.Printf("Error during server shutdown: %v\n", err)
 		os.Exit(1)
 	}
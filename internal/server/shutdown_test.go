@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// startServer starts a server on a free local port
+func startServer(t *testing.T) *Server {
+	t.Helper()
+	srv := New(Options{Address: "127.0.0.1:0"}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	if err := srv.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	return srv
+}
+
+func TestGracefulShutdownStopsExtraServers(t *testing.T) {
+	tests := []struct {
+		name  string
+		extra int
+	}{
+		{"main only", 0},
+		{"metrics listener", 1},
+		{"several listeners", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := startServer(t)
+			gs := NewGracefulShutdown(main, time.Second)
+			servers := []*Server{main}
+			for i := 0; i < tt.extra; i++ {
+				srv := startServer(t)
+				gs.WithServers(srv)
+				servers = append(servers, srv)
+			}
+
+			for _, srv := range servers {
+				if _, err := http.Get("http://" + srv.Addr()); err != nil {
+					t.Fatalf("%s not serving: %v", srv.Addr(), err)
+				}
+			}
+
+			if err := gs.stop(context.Background()); err != nil {
+				t.Fatalf("stop: %v", err)
+			}
+			for _, srv := range servers {
+				if _, err := http.Get("http://" + srv.Addr()); err == nil {
+					t.Errorf("%s still serving after shutdown", srv.Addr())
+				}
+			}
+		})
+	}
+}
+
+func TestGracefulShutdownReportsExtraServerError(t *testing.T) {
+	main := startServer(t)
+	// Never started, so stopping it fails
+	idle := New(Options{Address: "127.0.0.1:0"}, http.NotFoundHandler())
+
+	gs := NewGracefulShutdown(main, time.Second).WithServers(idle)
+	if err := gs.stop(context.Background()); err == nil {
+		t.Fatal("extra server error not reported")
+	}
+	if _, err := http.Get("http://" + main.Addr()); err == nil {
+		t.Error("main server still serving after shutdown")
+	}
+}
@@ -0,0 +1,29 @@
+// Process statistics on Linux
+//
+// CPU time from getrusage and open descriptors from /proc
+package telemetry
+
+import (
+	"os"
+	"syscall"
+)
+
+// processCPUSeconds returns the user and system CPU time used so far
+func processCPUSeconds() (float64, bool) {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		return 0, false
+	}
+	user := float64(usage.Utime.Sec) + float64(usage.Utime.Usec)/1e6
+	sys := float64(usage.Stime.Sec) + float64(usage.Stime.Usec)/1e6
+	return user + sys, true
+}
+
+// processOpenFDs returns the number of open file descriptors
+func processOpenFDs() (int, bool) {
+	entries, err := os.ReadDir("/proc/self/fd")
+	if err != nil {
+		return 0, false
+	}
+	return len(entries), true
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+// Process statistics elsewhere
+//
+// Not collected outside Linux
+package telemetry
+
+// processCPUSeconds is unavailable on this platform
+func processCPUSeconds() (float64, bool) {
+	return 0, false
+}
+
+// processOpenFDs is unavailable on this platform
+func processOpenFDs() (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,27 @@
+// Runtime gauges
+//
+// Go runtime health published as metrics:
+// - Goroutine count
+// - Heap allocation
+// - Most recent GC pause
+package telemetry
+
+import (
+	"runtime"
+)
+
+// RecordRuntime samples the Go runtime and publishes its state as gauges
+func RecordRuntime(m Metrics) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	m.SetGauge("runtime.goroutines", float64(runtime.NumGoroutine()))
+	m.SetGauge("runtime.heap_alloc_bytes", float64(mem.HeapAlloc))
+
+	// PauseNs is a ring buffer; the latest pause sits at (NumGC+255)%256
+	var lastPause uint64
+	if mem.NumGC > 0 {
+		lastPause = mem.PauseNs[(mem.NumGC+255)%256]
+	}
+	m.SetGauge("runtime.gc_pause_ms", float64(lastPause)/1e6)
+}
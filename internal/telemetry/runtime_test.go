@@ -0,0 +1,51 @@
+package telemetry
+
+import (
+	"runtime"
+	"testing"
+)
+
+// gauge returns a gauge recorded in m and whether it is set
+func gauge(m Metrics, name string) (float64, bool) {
+	value, ok := m.(*SimpleMetrics).DumpMetrics()["gauge_"+name].(float64)
+	return value, ok
+}
+
+func TestRecordRuntime(t *testing.T) {
+	m := NewMetrics()
+	for _, name := range []string{"runtime.goroutines", "runtime.heap_alloc_bytes", "runtime.gc_pause_ms"} {
+		if _, ok := gauge(m, name); ok {
+			t.Fatalf("%s set before recording", name)
+		}
+	}
+
+	runtime.GC()
+	RecordRuntime(m)
+
+	tests := []struct {
+		name     string
+		positive bool
+	}{
+		{"runtime.goroutines", true},
+		{"runtime.heap_alloc_bytes", true},
+		{"runtime.gc_pause_ms", false},
+	}
+	for _, tt := range tests {
+		value, ok := gauge(m, tt.name)
+		if !ok || value < 0 || (tt.positive && value == 0) {
+			t.Errorf("%s = %v, %v", tt.name, value, ok)
+		}
+	}
+
+	// Gauges follow the runtime on every sample
+	before, _ := gauge(m, "runtime.goroutines")
+	stop := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		go func() { <-stop }()
+	}
+	RecordRuntime(m)
+	close(stop)
+	if after, _ := gauge(m, "runtime.goroutines"); after < before+10 {
+		t.Errorf("goroutines gauge %v after starting 10 more than %v", after, before)
+	}
+}
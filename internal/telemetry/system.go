@@ -0,0 +1,78 @@
+// System metrics collection
+//
+// Periodic sampling of process and runtime state:
+// - Memory statistics and GC activity
+// - Goroutine count
+// - Process CPU time and open file descriptors where available
+package telemetry
+
+import (
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SystemCollector periodically publishes system metrics as gauges
+type SystemCollector struct {
+	metrics  Metrics
+	interval time.Duration
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// NewSystemCollector creates a collector sampling every interval
+func NewSystemCollector(metrics Metrics, interval time.Duration) *SystemCollector {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	return &SystemCollector{
+		metrics:  metrics,
+		interval: interval,
+		stop:     make(chan struct{}),
+	}
+}
+
+// Start collects once and then in the background every interval
+func (c *SystemCollector) Start() {
+	c.Collect()
+
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Collect()
+			case <-c.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop ends background collection
+func (c *SystemCollector) Stop() {
+	c.once.Do(func() { close(c.stop) })
+}
+
+// Collect samples the system once and publishes the gauges
+func (c *SystemCollector) Collect() {
+	RecordRuntime(c.metrics)
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	c.metrics.SetGauge("runtime.heap_inuse_bytes", float64(mem.HeapInuse))
+	c.metrics.SetGauge("runtime.heap_objects", float64(mem.HeapObjects))
+	c.metrics.SetGauge("runtime.sys_bytes", float64(mem.Sys))
+	c.metrics.SetGauge("runtime.gc_count", float64(mem.NumGC))
+	c.metrics.SetGauge("runtime.gc_pause_total_ms", float64(mem.PauseTotalNs)/1e6)
+
+	if cpu, ok := processCPUSeconds(); ok {
+		c.metrics.SetGauge("process.cpu_seconds", cpu)
+	}
+	if fds, ok := processOpenFDs(); ok {
+		c.metrics.SetGauge("process.open_fds", float64(fds))
+	}
+}
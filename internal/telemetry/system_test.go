@@ -0,0 +1,76 @@
+package telemetry
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSystemCollector(t *testing.T) {
+	gauges := []string{
+		"runtime.goroutines",
+		"runtime.heap_alloc_bytes",
+		"runtime.heap_inuse_bytes",
+		"runtime.heap_objects",
+		"runtime.sys_bytes",
+		"runtime.gc_count",
+		"runtime.gc_pause_total_ms",
+	}
+	if runtime.GOOS == "linux" {
+		gauges = append(gauges, "process.cpu_seconds", "process.open_fds")
+	}
+
+	tests := []struct {
+		name    string
+		start   bool
+		wantSet bool
+	}{
+		{"disabled", false, false},
+		{"enabled", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMetrics()
+			c := NewSystemCollector(m, 10*time.Millisecond)
+			if tt.start {
+				c.Start()
+				defer c.Stop()
+			}
+
+			for _, name := range gauges {
+				if _, ok := gauge(m, name); ok != tt.wantSet {
+					t.Errorf("%s set = %v, want %v", name, ok, tt.wantSet)
+				}
+			}
+		})
+	}
+}
+
+func TestSystemCollectorSamplesUntilStopped(t *testing.T) {
+	m := NewMetrics()
+	c := NewSystemCollector(m, 5*time.Millisecond)
+	c.Start()
+
+	// A sentinel value is overwritten by the next sample
+	m.SetGauge("runtime.sys_bytes", -1)
+	deadline := time.Now().Add(time.Second)
+	for {
+		if value, _ := gauge(m, "runtime.sys_bytes"); value > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("no sample after the first interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Stop()
+	c.Stop() // Idempotent
+	time.Sleep(10 * time.Millisecond)
+	m.SetGauge("runtime.sys_bytes", -1)
+	time.Sleep(30 * time.Millisecond)
+	if value, _ := gauge(m, "runtime.sys_bytes"); value != -1 {
+		t.Errorf("sampled after Stop: %v", value)
+	}
+}
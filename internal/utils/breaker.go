@@ -0,0 +1,158 @@
+// Circuit breaking
+//
+// Tracks upstream failures to avoid hammering a failing dependency:
+// - Consecutive failure threshold
+// - Cooldown before probing again
+// - One trial request at a time while half-open
+// - Explicit holds requested by the dependency
+// - Nil breaker means breaking is disabled
+// - State reporting for status documents
+
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// Breaker states reported by State
+const (
+	BreakerDisabled = "disabled"
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half-open"
+)
+
+// CircuitBreaker opens after a number of consecutive failures and lets a
+// single trial request through once the cooldown has elapsed. Further
+// requests are refused until the trial reports success or failure.
+type CircuitBreaker struct {
+	mu         sync.Mutex
+	threshold  int
+	cooldown   time.Duration
+	failures   int
+	tripped    bool      // Opened by failures or a hold, until a success
+	openUntil  time.Time
+	holdUntil  time.Time
+	trialUntil time.Time // A trial request is in flight until then
+}
+
+// NewCircuitBreaker creates a circuit breaker, or nil if threshold disables it
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+	}
+}
+
+// Allow reports whether a request may be sent. Once the cooldown of an
+// open circuit has elapsed, exactly one caller is allowed a trial request
+// and must report it with Success, Failure or Cancel. A trial that is
+// never reported is given up after another cooldown.
+func (b *CircuitBreaker) Allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.tripped {
+		return true
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) || now.Before(b.trialUntil) {
+		return false
+	}
+	b.trialUntil = now.Add(b.cooldown)
+	return true
+}
+
+// Cancel gives up a trial request granted by Allow without a verdict, e.g.
+// because it was never sent, so that another caller may probe
+func (b *CircuitBreaker) Cancel() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trialUntil = time.Time{}
+}
+
+// State reports whether the circuit is closed, open, or half-open once the
+// cooldown lets a trial request through
+func (b *CircuitBreaker) State() string {
+	if b == nil {
+		return BreakerDisabled
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.tripped {
+		return BreakerClosed
+	}
+	if time.Now().Before(b.openUntil) {
+		return BreakerOpen
+	}
+	return BreakerHalfOpen
+}
+
+// Success closes the circuit
+func (b *CircuitBreaker) Success() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures = 0
+	b.tripped = false
+	b.openUntil = time.Time{}
+	b.trialUntil = time.Time{}
+}
+
+// Hold opens the circuit for at least d, as requested by the dependency
+// itself (e.g. via Retry-After), regardless of the failure count
+func (b *CircuitBreaker) Hold(d time.Duration) {
+	if b == nil || d <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	until := time.Now().Add(d)
+	if until.After(b.holdUntil) {
+		b.holdUntil = until
+	}
+	if until.After(b.openUntil) {
+		b.openUntil = until
+	}
+	b.tripped = true
+}
+
+// Held returns the remaining time of a hold, or zero if there is none
+func (b *CircuitBreaker) Held() time.Duration {
+	if b == nil {
+		return 0
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if remaining := time.Until(b.holdUntil); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// Failure records a failed request, opening the circuit at the threshold.
+// A failed trial request after the cooldown reopens it immediately.
+func (b *CircuitBreaker) Failure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.failures++
+	if b.failures >= b.threshold || b.tripped {
+		b.tripped = true
+		b.openUntil = time.Now().Add(b.cooldown)
+		b.trialUntil = time.Time{}
+	}
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	tests := []struct {
+		name      string
+		threshold int
+		cooldown  time.Duration
+		events    string // f = failure, s = success
+		wantAllow bool
+		wantState string
+	}{
+		{"disabled", 0, time.Hour, "fff", true, BreakerDisabled},
+		{"below threshold", 3, time.Hour, "ff", true, BreakerClosed},
+		{"opens at threshold", 3, time.Hour, "fff", false, BreakerOpen},
+		{"success resets count", 3, time.Hour, "ffsff", true, BreakerClosed},
+		{"success closes", 1, time.Hour, "fs", true, BreakerClosed},
+		{"half-open after cooldown", 2, 0, "ff", true, BreakerHalfOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(tt.threshold, tt.cooldown)
+			for _, e := range tt.events {
+				if e == 'f' {
+					b.Failure()
+				} else {
+					b.Success()
+				}
+			}
+			time.Sleep(time.Millisecond)
+
+			if got := b.Allow(); got != tt.wantAllow {
+				t.Errorf("Allow() = %v, want %v", got, tt.wantAllow)
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("State() = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerHold(t *testing.T) {
+	tests := []struct {
+		name      string
+		holds     []time.Duration
+		success   bool
+		wantAllow bool
+		wantHeld  bool
+	}{
+		{"no hold", nil, false, true, false},
+		{"hold below threshold", []time.Duration{time.Hour}, false, false, true},
+		{"longest hold wins", []time.Duration{time.Hour, time.Nanosecond}, false, false, true},
+		{"expired hold", []time.Duration{time.Nanosecond}, false, true, false},
+		{"ignored non-positive hold", []time.Duration{0, -time.Second}, false, true, false},
+		{"success closes but keeps the hold", []time.Duration{time.Hour}, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(5, time.Hour)
+			for _, d := range tt.holds {
+				b.Hold(d)
+			}
+			if tt.success {
+				b.Success()
+			}
+			time.Sleep(time.Millisecond)
+
+			if got := b.Allow(); got != tt.wantAllow {
+				t.Errorf("Allow() = %v, want %v", got, tt.wantAllow)
+			}
+			if held := b.Held(); (held > 0) != tt.wantHeld || held > time.Hour {
+				t.Errorf("Held() = %v, want held %v", held, tt.wantHeld)
+			}
+		})
+	}
+
+	var disabled *CircuitBreaker
+	disabled.Hold(time.Hour)
+	if !disabled.Allow() || disabled.Held() != 0 {
+		t.Error("disabled breaker held")
+	}
+}
+
+// concurrentAllows calls Allow from n goroutines at once and returns how
+// many were allowed
+func concurrentAllows(b *CircuitBreaker, n int) int {
+	var allowed atomic.Int32
+	var start, done sync.WaitGroup
+	start.Add(1)
+	for i := 0; i < n; i++ {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			start.Wait()
+			if b.Allow() {
+				allowed.Add(1)
+			}
+		}()
+	}
+	start.Done()
+	done.Wait()
+	return int(allowed.Load())
+}
+
+func TestCircuitBreakerHalfOpenTrial(t *testing.T) {
+	const (
+		callers  = 50
+		cooldown = 20 * time.Millisecond
+	)
+
+	tests := []struct {
+		name        string
+		trip        func(b *CircuitBreaker)
+		verdict     func(b *CircuitBreaker)
+		wait        time.Duration // After the verdict
+		wantAllowed int
+		wantState   string
+	}{
+		{"trial in flight", tripFailures, func(*CircuitBreaker) {}, 0, 0, BreakerHalfOpen},
+		{"trial succeeds", tripFailures, (*CircuitBreaker).Success, 0, callers, BreakerClosed},
+		{"trial fails", tripFailures, (*CircuitBreaker).Failure, 0, 0, BreakerOpen},
+		{"trial fails, next cooldown", tripFailures, (*CircuitBreaker).Failure, 2 * cooldown, 1, BreakerHalfOpen},
+		{"trial canceled", tripFailures, (*CircuitBreaker).Cancel, 0, 1, BreakerHalfOpen},
+		{"trial never reported", tripFailures, func(*CircuitBreaker) {}, 2 * cooldown, 1, BreakerHalfOpen},
+		{"after a hold", func(b *CircuitBreaker) { b.Hold(cooldown) }, func(*CircuitBreaker) {}, 0, 0, BreakerHalfOpen},
+		{"failed trial after a hold", func(b *CircuitBreaker) { b.Hold(cooldown) }, (*CircuitBreaker).Failure, 0, 0, BreakerOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(3, cooldown)
+			tt.trip(b)
+			if got := concurrentAllows(b, callers); got != 0 {
+				t.Fatalf("%d callers allowed through an open circuit", got)
+			}
+
+			time.Sleep(2 * cooldown)
+			if got := concurrentAllows(b, callers); got != 1 {
+				t.Fatalf("%d concurrent trials after the cooldown, want 1", got)
+			}
+
+			tt.verdict(b)
+			time.Sleep(tt.wait)
+			if got := concurrentAllows(b, callers); got != tt.wantAllowed {
+				t.Errorf("%d callers allowed, want %d", got, tt.wantAllowed)
+			}
+			if got := b.State(); got != tt.wantState {
+				t.Errorf("State() = %q, want %q", got, tt.wantState)
+			}
+		})
+	}
+}
+
+// tripFailures opens a breaker with a threshold of 3
+func tripFailures(b *CircuitBreaker) {
+	for i := 0; i < 3; i++ {
+		b.Failure()
+	}
+}
@@ -4,4 +4,90 @@
 // - Header manipulation
 // - Status code handling
 // - Request/response utilities
-// - Content type detection
\ No newline at end of file
+// - Content type detection
+// - Retry-After parsing
+package utils
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AcceptsEncoding reports whether an Accept-Encoding header value allows the
+// given content coding. Codings with q=0 are treated as refused, and a
+// wildcard applies to codings that are not listed explicitly.
+func AcceptsEncoding(header, coding string) bool {
+	wildcard := false
+	for _, part := range strings.Split(header, ",") {
+		name, q := parseCoding(part)
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(name, coding) {
+			return q > 0
+		}
+		if name == "*" {
+			wildcard = q > 0
+		}
+	}
+	return wildcard
+}
+
+// parseCoding splits an Accept-Encoding element into its coding and q-value
+func parseCoding(part string) (string, float64) {
+	name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+	q := 1.0
+	for _, param := range strings.Split(params, ";") {
+		key, val, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if ok && strings.EqualFold(key, "q") {
+			if parsed, err := strconv.ParseFloat(val, 64); err == nil {
+				q = parsed
+			}
+		}
+	}
+	return strings.TrimSpace(name), q
+}
+
+// ParseRetryAfter returns the delay requested by a Retry-After header value,
+// given either as delay-seconds or as an HTTP date relative to now. Missing,
+// malformed or past values yield zero.
+func ParseRetryAfter(value string, now time.Time) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
+
+// MediaType returns the lowercase media type of a Content-Type value without parameters
+func MediaType(contentType string) string {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	return strings.ToLower(strings.TrimSpace(mediaType))
+}
+
+// RedactHeaders returns a copy of the headers with the values of sensitive
+// headers masked, for safe logging. Header names match case-insensitively.
+func RedactHeaders(headers map[string]string, sensitive []string) map[string]string {
+	redacted := make(map[string]string, len(headers))
+	for k, v := range headers {
+		redacted[k] = v
+		for _, s := range sensitive {
+			if strings.EqualFold(k, s) {
+				redacted[k] = "[REDACTED]"
+				break
+			}
+		}
+	}
+	return redacted
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRedactHeaders(t *testing.T) {
+	headers := map[string]string{
+		"Authorization": "Bearer abc",
+		"x-api-key":     "secret",
+		"X-Customer":    "acme",
+	}
+	got := RedactHeaders(headers, []string{"Authorization", "X-Api-Key"})
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Authorization", "[REDACTED]"},
+		{"x-api-key", "[REDACTED]"},
+		{"X-Customer", "acme"},
+	}
+	for _, tt := range tests {
+		if got[tt.name] != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, got[tt.name], tt.want)
+		}
+	}
+	if headers["Authorization"] != "Bearer abc" {
+		t.Error("RedactHeaders modified its input")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "120", 2 * time.Minute},
+		{"padded seconds", " 5 ", 5 * time.Second},
+		{"zero", "0", 0},
+		{"negative", "-3", 0},
+		{"HTTP date", now.Add(90 * time.Second).Format(http.TimeFormat), 90 * time.Second},
+		{"past date", now.Add(-time.Minute).Format(http.TimeFormat), 0},
+		{"malformed", "soon", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseRetryAfter(tt.value, now); got != tt.want {
+				t.Errorf("ParseRetryAfter(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+// IP address helpers
+//
+// Client address handling:
+// - CIDR list parsing
+// - Trusted proxy evaluation
+// - Effective client IP resolution
+package utils
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseCIDRs parses a list of CIDR blocks. Bare IPv4 and IPv6 addresses are
+// accepted and treated as single-host networks.
+func ParseCIDRs(values []string) ([]*net.IPNet, error) {
+	nets := make([]*net.IPNet, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", v)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR: %s", v)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ContainsIP reports whether any of the networks contains the IP
+func ContainsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the effective client IP of a request. Forwarding headers
+// are only honoured when the direct peer is a trusted proxy, in which case
+// X-Forwarded-For is walked right to left skipping trusted hops.
+func ClientIP(r *http.Request, trusted []*net.IPNet) net.IP {
+	remote := parseHostIP(r.RemoteAddr)
+	if remote == nil || !ContainsIP(trusted, remote) {
+		return remote
+	}
+
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		hops := strings.Split(xff, ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			ip := parseHostIP(strings.TrimSpace(hops[i]))
+			if ip == nil {
+				break
+			}
+			if !ContainsIP(trusted, ip) || i == 0 {
+				return ip
+			}
+		}
+	}
+
+	if realIP := parseHostIP(r.Header.Get("X-Real-IP")); realIP != nil {
+		return realIP
+	}
+
+	return remote
+}
+
+// parseHostIP parses an IP from an address that may include a port or brackets
+func parseHostIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.Trim(addr, "[]")
+	return net.ParseIP(addr)
+}
@@ -4,4 +4,5 @@
 // - Buffer pools
 // - Object recycling
 // - Size-based pools
-// - Thread-safe implementation
\ No newline at end of file
+// - Thread-safe implementation
+package utils
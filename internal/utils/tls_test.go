@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClientTLSConfigWithoutCertificates(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	missing := filepath.Join(dir, "missing.pem")
+
+	tests := []struct {
+		name     string
+		certFile string
+		keyFile  string
+		caFile   string
+		wantErr  bool
+	}{
+		{"nothing set", "", "", "", false},
+		{"certificate without key", missing, "", "", true},
+		{"key without certificate", "", missing, "", true},
+		{"missing certificate files", missing, missing, "", true},
+		{"missing CA file", "", "", missing, true},
+		{"CA file without certificates", "", "", notPEM, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := ClientTLSConfig(tt.certFile, tt.keyFile, tt.caFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			// Nothing to configure and a failure both leave the defaults
+			if cfg != nil {
+				t.Errorf("config = %v, want nil", cfg)
+			}
+		})
+	}
+}
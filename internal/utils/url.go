@@ -4,4 +4,188 @@
 // - URL joining
 // - Path normalization
 // - Query parameter handling
-// - URL encoding/decoding
\ No newline at end of file
+// - URL encoding/decoding
+// - Host normalization
+// - Credential redaction
+package utils
+
+import (
+	"net"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces credentials removed from logged values
+const redacted = "[REDACTED]"
+
+// defaultPorts maps URL schemes to the port implied when none is given
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// CanonicalHostname returns a host without its port or IPv6 brackets,
+// lowercased and with IP literals in their canonical form, so that
+// "[2001:DB8::0001]:443", "2001:db8::1" and "[2001:db8::1]" compare equal
+func CanonicalHostname(host string) string {
+	host, _ = splitHostPort(host)
+	return canonicalIP(host)
+}
+
+// CanonicalHost returns host[:port] in a canonical form for use as a map key
+// or metrics label: lowercased, IP literals normalized, IPv6 bracketed when a
+// port is present, and the scheme's default port removed
+func CanonicalHost(scheme, host string) string {
+	name, port := splitHostPort(host)
+	name = canonicalIP(name)
+	if port == "" || port == defaultPorts[strings.ToLower(scheme)] {
+		if strings.Contains(name, ":") {
+			return "[" + name + "]"
+		}
+		return name
+	}
+	return net.JoinHostPort(name, port)
+}
+
+// splitHostPort splits an optional port from a host, accepting bare and
+// bracketed IPv6 literals without a port
+func splitHostPort(host string) (string, string) {
+	host = strings.TrimSpace(host)
+	if name, port, err := net.SplitHostPort(host); err == nil {
+		return name, port
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(host, "["), "]"), ""
+}
+
+// canonicalIP lowercases a host name and rewrites IP literals in their
+// shortest textual form, preserving any IPv6 zone
+func canonicalIP(name string) string {
+	name = strings.ToLower(name)
+	addr, zone, _ := strings.Cut(name, "%")
+	if ip := net.ParseIP(addr); ip != nil {
+		if zone != "" {
+			return ip.String() + "%" + zone
+		}
+		return ip.String()
+	}
+	return name
+}
+
+// CleanPath resolves "." and ".." segments and repeated slashes in a
+// decoded URL path, keeping a trailing slash. A path cannot climb above the
+// root. Access checks and origin fetches use the cleaned path, so both see
+// the resource the origin would serve.
+func CleanPath(p string) string {
+	if p == "" {
+		return p
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// RedactQuery masks the values of the named query parameters in a raw query
+// string, and any value that looks like a JWT. Parameter names match
+// case-insensitively and the rest of the query is left as it was.
+func RedactQuery(rawQuery string, params []string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		name, value, hasValue := strings.Cut(pair, "=")
+		if !hasValue {
+			continue
+		}
+		if redactParam(name, value, params) {
+			pairs[i] = pair[:len(name)+1] + redacted
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// redactParam reports whether an escaped query parameter carries a credential
+func redactParam(name, value string, params []string) bool {
+	if decoded, err := url.QueryUnescape(name); err == nil {
+		name = decoded
+	}
+	for _, param := range params {
+		if param != "" && strings.EqualFold(name, param) {
+			return true
+		}
+	}
+
+	if decoded, err := url.QueryUnescape(value); err == nil {
+		value = decoded
+	}
+	return looksLikeJWT(value)
+}
+
+// RedactPath masks path segments that look like a JWT, for paths used in
+// logs or as metric labels
+func RedactPath(path string) string {
+	if !strings.Contains(path, "eyJ") {
+		return path
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if looksLikeJWT(segment) {
+			segments[i] = redacted
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// jwtPattern matches compact JWS tokens embedded in text
+var jwtPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)
+
+// RedactText masks the values of the named query parameters wherever a URL
+// in free text carries them, e.g. in a playlist body, and any JWT
+func RedactText(text string, params []string) string {
+	for _, param := range params {
+		if param == "" {
+			continue
+		}
+		pattern := regexp.MustCompile(`(?i)([?&]` + regexp.QuoteMeta(param) + `=)[^&"'\s#]*`)
+		text = pattern.ReplaceAllString(text, "${1}"+redacted)
+	}
+	return jwtPattern.ReplaceAllString(text, redacted)
+}
+
+// RedactURL returns a URL as a string with token query parameters and
+// JWT-like path segments masked
+func RedactURL(u *url.URL, params []string) string {
+	if u == nil {
+		return ""
+	}
+
+	c := *u
+	c.User = nil
+	c.Path = RedactPath(u.Path)
+	c.RawPath = RedactPath(u.EscapedPath())
+	c.RawQuery = RedactQuery(u.RawQuery, params)
+	return c.String()
+}
+
+// looksLikeJWT reports whether s has the shape of a compact JWS: three
+// base64url parts, the header starting with an encoded '{"'
+func looksLikeJWT(s string) bool {
+	if !strings.HasPrefix(s, "eyJ") || strings.Count(s, ".") != 2 {
+		return false
+	}
+	for _, c := range s {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
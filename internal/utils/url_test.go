@@ -0,0 +1,80 @@
+package utils
+
+import "testing"
+
+func TestCanonicalHostname(t *testing.T) {
+	tests := []struct {
+		host string
+		want string
+	}{
+		{"CDN.Example.com", "cdn.example.com"},
+		{"cdn.example.com:8443", "cdn.example.com"},
+		{"2001:DB8::0001", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"[2001:db8:0::1]:443", "2001:db8::1"},
+		{"[fe80::1%eth0]:80", "fe80::1%eth0"},
+		{"127.0.0.1:9000", "127.0.0.1"},
+		{" cdn.example.com ", "cdn.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := CanonicalHostname(tt.host); got != tt.want {
+				t.Errorf("CanonicalHostname(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalHost(t *testing.T) {
+	tests := []struct {
+		scheme string
+		host   string
+		want   string
+	}{
+		{"https", "CDN.example.com:443", "cdn.example.com"},
+		{"http", "cdn.example.com:80", "cdn.example.com"},
+		{"http", "cdn.example.com:443", "cdn.example.com:443"},
+		{"HTTPS", "cdn.example.com:443", "cdn.example.com"},
+		{"https", "[2001:DB8::1]:443", "[2001:db8::1]"},
+		{"https", "[2001:db8::0001]:8443", "[2001:db8::1]:8443"},
+		{"", "2001:db8::1", "[2001:db8::1]"},
+		{"", "[2001:db8::1]", "[2001:db8::1]"},
+		{"", "origin:9000", "origin:9000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme+" "+tt.host, func(t *testing.T) {
+			if got := CanonicalHost(tt.scheme, tt.host); got != tt.want {
+				t.Errorf("CanonicalHost(%q, %q) = %q, want %q", tt.scheme, tt.host, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", ""},
+		{"/", "/"},
+		{"/live/seg1.ts", "/live/seg1.ts"},
+		{"/live/", "/live/"},
+		{"/live/../vod/seg1.ts", "/vod/seg1.ts"},
+		{"/live/./seg1.ts", "/live/seg1.ts"},
+		{"/live//seg1.ts", "/live/seg1.ts"},
+		{"/live/../../../etc/passwd", "/etc/passwd"},
+		{"/live/vod/..", "/live"},
+		{"/live/vod/../", "/live/"},
+		{"live/seg1.ts", "/live/seg1.ts"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := CleanPath(tt.path); got != tt.want {
+				t.Errorf("CleanPath(%q) = %q, want %q", tt.path, got, tt.want)
+			}
+		})
+	}
+}
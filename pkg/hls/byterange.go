@@ -0,0 +1,39 @@
+// Byte ranges
+//
+// Sub-ranges of a resource named by EXT-X-BYTERANGE and EXT-X-MAP:
+// - Parsing of the <length>[@<offset>] form
+package hls
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrByteRange is returned when a byte range is malformed
+var ErrByteRange = errors.New("invalid byte range")
+
+// ByteRange is a sub-range of a resource, Length bytes from Offset
+type ByteRange struct {
+	Length int64
+	Offset int64
+}
+
+// ParseByteRange parses a byte range of the form <length>[@<offset>]. A
+// missing offset is reported as false; EXT-X-MAP ranges then start at 0.
+func ParseByteRange(s string) (ByteRange, bool, error) {
+	lengthStr, offsetStr, hasOffset := strings.Cut(strings.Trim(s, `"`), "@")
+
+	length, err := strconv.ParseInt(lengthStr, 10, 64)
+	if err != nil || length <= 0 {
+		return ByteRange{}, false, fmt.Errorf("%w: %q", ErrByteRange, s)
+	}
+	br := ByteRange{Length: length}
+	if hasOffset {
+		if br.Offset, err = strconv.ParseInt(offsetStr, 10, 64); err != nil || br.Offset < 0 {
+			return ByteRange{}, false, fmt.Errorf("%w: %q", ErrByteRange, s)
+		}
+	}
+	return br, hasOffset, nil
+}
@@ -0,0 +1,57 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseByteRange(t *testing.T) {
+	tests := []struct {
+		in         string
+		want       ByteRange
+		wantOffset bool
+		wantErr    bool
+	}{
+		{in: "720@0", want: ByteRange{Length: 720}, wantOffset: true},
+		{in: `"720@100"`, want: ByteRange{Length: 720, Offset: 100}, wantOffset: true},
+		{in: "1500", want: ByteRange{Length: 1500}},
+		{in: "0@0", wantErr: true},
+		{in: "-1", wantErr: true},
+		{in: "10@-5", wantErr: true},
+		{in: "abc", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, hasOffset, err := ParseByteRange(tt.in)
+			if tt.wantErr {
+				if !errors.Is(err, ErrByteRange) {
+					t.Fatalf("got %v, want ErrByteRange", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseByteRange: %v", err)
+			}
+			if got != tt.want || hasOffset != tt.wantOffset {
+				t.Errorf("got %+v (offset %v), want %+v (offset %v)", got, hasOffset, tt.want, tt.wantOffset)
+			}
+		})
+	}
+}
+
+func TestParserWarnsOnBadMapByteRange(t *testing.T) {
+	input := "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-MAP:URI=\"init.mp4\",BYTERANGE=\"x@0\"\n#EXTINF:6,\nseg.mp4\n"
+	playlist, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(playlist.Warnings) == 0 {
+		t.Error("no warning for a malformed init segment range")
+	}
+	if m := playlist.Media.Segments[0].Map; m == nil || m.ByteRange != "x@0" {
+		t.Errorf("map not kept as written: %+v", m)
+	}
+}
@@ -0,0 +1,64 @@
+// Playlist copying
+//
+// Deep copies of parsed playlists:
+// - Independent slices, maps and pointed-to keys and maps
+// - Lets a parsed playlist be rewritten many times
+package hls
+
+// Clone returns a deep copy of the playlist that can be modified without
+// affecting the original
+func (p *Playlist) Clone() *Playlist {
+	c := *p
+
+	c.Tags = make([]Tag, len(p.Tags))
+	for i, tag := range p.Tags {
+		c.Tags[i] = tag
+		if tag.Attributes != nil {
+			c.Tags[i].Attributes = make(map[string]string, len(tag.Attributes))
+			for k, v := range tag.Attributes {
+				c.Tags[i].Attributes[k] = v
+			}
+		}
+	}
+	c.RawLines = append([]string(nil), p.RawLines...)
+	c.Warnings = append([]string(nil), p.Warnings...)
+
+	c.Master.Variants = append([]Variant(nil), p.Master.Variants...)
+	c.Master.IFrameStreams = append([]IFrameStream(nil), p.Master.IFrameStreams...)
+	c.Master.SessionData = append([]SessionData(nil), p.Master.SessionData...)
+	c.Master.SessionKeys = append([]Key(nil), p.Master.SessionKeys...)
+	if p.Master.ContentSteering != nil {
+		steering := *p.Master.ContentSteering
+		c.Master.ContentSteering = &steering
+	}
+	if p.Master.MediaGroups != nil {
+		c.Master.MediaGroups = make(map[string][]MediaGroup, len(p.Master.MediaGroups))
+		for k, groups := range p.Master.MediaGroups {
+			c.Master.MediaGroups[k] = append([]MediaGroup(nil), groups...)
+		}
+	}
+
+	// Segments sharing a key or map keep sharing their copy of it
+	keys := make(map[*Key]*Key)
+	maps := make(map[*Map]*Map)
+	c.Media.Segments = make([]Segment, len(p.Media.Segments))
+	for i, seg := range p.Media.Segments {
+		c.Media.Segments[i] = seg
+		if seg.Key != nil {
+			if _, ok := keys[seg.Key]; !ok {
+				key := *seg.Key
+				keys[seg.Key] = &key
+			}
+			c.Media.Segments[i].Key = keys[seg.Key]
+		}
+		if seg.Map != nil {
+			if _, ok := maps[seg.Map]; !ok {
+				m := *seg.Map
+				maps[seg.Map] = &m
+			}
+			c.Media.Segments[i].Map = maps[seg.Map]
+		}
+	}
+
+	return &c
+}
@@ -13,6 +13,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
@@ -23,17 +24,76 @@ var (
 	ErrPlaylistFormat = errors.New("invalid playlist format")
 	ErrPlaylistHeader = errors.New("missing #EXTM3U header")
 	ErrTagFormat      = errors.New("invalid tag format")
+	ErrSegmentDuration = errors.New("segment duration exceeds target duration")
 )
 
+// Options controls optional compliance checks of the parser
+type Options struct {
+	// ValidateDurations warns when a segment duration, rounded, exceeds
+	// the target duration
+	ValidateDurations bool
+	// ValidateAttributes warns about malformed NAME=value pairs in
+	// attribute lists, which are otherwise skipped
+	ValidateAttributes bool
+	// Strict turns compliance warnings into parse errors; malformed
+	// attributes fail the parse only when the tag requires them
+	Strict bool
+	// LenientBandwidth gives variants and I-frame streams with a missing
+	// or invalid BANDWIDTH the DefaultBandwidth and records a warning,
+	// instead of failing the parse
+	LenientBandwidth bool
+	DefaultBandwidth uint64
+}
+
+// AttributeError describes a malformed pair in a tag's attribute list
+type AttributeError struct {
+	Line      int    // 1-based line number
+	Tag       string // e.g. "#EXT-X-STREAM-INF"
+	Attribute string // Attribute name, if one could be read
+	Text      string // The malformed pair as written
+	Reason    string
+}
+
+// Error implements the error interface
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("line %d: %s: malformed attribute %q: %s", e.Line, e.Tag, e.Text, e.Reason)
+}
+
+// Unwrap classifies attribute errors as tag format errors
+func (e *AttributeError) Unwrap() error {
+	return ErrTagFormat
+}
+
+// requiredAttributes are the attributes a tag cannot do without
+var requiredAttributes = map[string][]string{
+	TagStreamInf:       {AttrBandwidth},
+	TagIFrameStreamInf: {AttrBandwidth, AttrURI},
+	TagMedia:           {AttrType, AttrGroupID, AttrName},
+	TagKey:             {AttrMethod},
+	TagSessionKey:      {AttrMethod},
+	TagMap:             {AttrURI},
+	TagContentSteering: {AttrServerURI},
+}
+
 // Parser represents an HLS playlist parser
 type Parser struct {
 	playlist *Playlist
+	options  Options
+	line     int // Line being parsed, for diagnostics
+	inf      *Tag    // EXTINF of the segment being read
+	next     Segment // Segment tags seen before the segment's URI
 }
 
 // New creates a new HLS parser
 func New() *Parser {
+	return NewWithOptions(Options{})
+}
+
+// NewWithOptions creates a new HLS parser with optional compliance checks
+func NewWithOptions(options Options) *Parser {
 	return &Parser{
 		playlist: NewPlaylist(),
+		options:  options,
 	}
 }
 
@@ -47,6 +107,7 @@ func (p *Parser) Parse(r io.Reader) (*Playlist, error) {
 	for scanner.Scan() {
 		line := scanner.Text()
 		lineNum++
+		p.line = lineNum
 		
 		// Store all raw lines
 		p.playlist.RawLines = append(p.playlist.RawLines, line)
@@ -86,7 +147,7 @@ func (p *Parser) Parse(r io.Reader) (*Playlist, error) {
 				lastTag = nil
 			} else {
 				// This is a segment URI in a media playlist
-				if err := p.processSegmentURI(lastTag, line); err != nil {
+				if err := p.processSegmentURI(line); err != nil {
 					return nil, err
 				}
 				lastTag = nil
@@ -106,9 +167,35 @@ func (p *Parser) Parse(r io.Reader) (*Playlist, error) {
 		p.playlist.Type = PlaylistTypeMedia
 	}
 	
+	if p.options.ValidateDurations {
+		if err := p.validateDurations(); err != nil {
+			return nil, err
+		}
+	}
+	
 	return p.playlist, nil
 }
 
+// validateDurations checks that no segment duration, rounded to the nearest
+// integer, exceeds the target duration
+func (p *Parser) validateDurations() error {
+	if !p.playlist.IsMedia() || p.playlist.Media.TargetDuration <= 0 {
+		return nil
+	}
+
+	target := math.Round(p.playlist.Media.TargetDuration)
+	for i, seg := range p.playlist.Media.Segments {
+		if math.Round(seg.Duration) <= target {
+			continue
+		}
+		if p.options.Strict {
+			return fmt.Errorf("%w: segment %d lasts %gs, target is %gs", ErrSegmentDuration, i, seg.Duration, target)
+		}
+		p.warn("segment %d duration %g exceeds target duration %g", i, seg.Duration, target)
+	}
+	return nil
+}
+
 // parseTag parses an HLS tag into a Tag structure
 func (p *Parser) parseTag(line string) (*Tag, error) {
 	tag := &Tag{
@@ -130,18 +217,102 @@ func (p *Parser) parseTag(line string) (*Tag, error) {
 	// For tags with attributes, parse them
 	if tag.Name == TagStreamInf || tag.Name == TagMedia || 
 	   tag.Name == TagIFrameStreamInf || tag.Name == TagKey ||
-	   tag.Name == TagMap || tag.Name == TagSessionData {
+	   tag.Name == TagMap || tag.Name == TagSessionData ||
+	   tag.Name == TagSessionKey || tag.Name == TagContentSteering {
 		
 		attrs, err := parseAttributes(tag.Value)
 		if err != nil {
 			return nil, err
 		}
 		tag.Attributes = attrs
+		
+		if p.options.ValidateAttributes {
+			if err := p.validateAttributes(tag); err != nil {
+				return nil, err
+			}
+		}
 	}
 	
 	return tag, nil
 }
 
+// validateAttributes reports the malformed pairs of a tag's attribute list
+// as warnings, or as an error in strict mode when the pair names a required
+// attribute
+func (p *Parser) validateAttributes(tag *Tag) error {
+	for _, pair := range splitAttributeList(tag.Value) {
+		reason := malformedAttribute(pair)
+		if reason == "" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(pair, "=")
+		attrErr := &AttributeError{
+			Line:      p.line,
+			Tag:       tag.Name,
+			Attribute: strings.TrimSpace(name),
+			Text:      pair,
+			Reason:    reason,
+		}
+		if p.options.Strict && isRequiredAttribute(tag.Name, attrErr.Attribute) {
+			return attrErr
+		}
+		p.warn("%s", attrErr.Error())
+	}
+	return nil
+}
+
+// splitAttributeList splits an attribute list at commas outside quotes
+func splitAttributeList(s string) []string {
+	var pairs []string
+	inQuotes := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ',':
+			if !inQuotes {
+				pairs = append(pairs, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(pairs, s[start:])
+}
+
+// malformedAttribute returns why a NAME=value pair is malformed, or ""
+func malformedAttribute(pair string) string {
+	name, value, ok := strings.Cut(pair, "=")
+	switch {
+	case strings.TrimSpace(pair) == "":
+		return "empty attribute"
+	case !ok:
+		return "missing '='"
+	case name == "":
+		return "missing name"
+	case !attributeNamePattern.MatchString(name):
+		return "invalid name"
+	case value == "":
+		return "empty value"
+	case strings.HasPrefix(value, "\"") && (len(value) < 2 || !strings.HasSuffix(value, "\"")):
+		return "unterminated quoted string"
+	case !strings.HasPrefix(value, "\"") && strings.Contains(value, "\""):
+		return "stray quote"
+	}
+	return ""
+}
+
+// isRequiredAttribute reports whether a tag cannot do without the attribute
+func isRequiredAttribute(tagName, attr string) bool {
+	for _, required := range requiredAttributes[tagName] {
+		if required == attr {
+			return true
+		}
+	}
+	return false
+}
+
 // processTag processes a tag and updates the playlist
 func (p *Parser) processTag(tag *Tag) error {
 	switch tag.Name {
@@ -159,6 +330,9 @@ func (p *Parser) processTag(tag *Tag) error {
 		if err != nil {
 			return fmt.Errorf("invalid target duration: %w", err)
 		}
+		if dur != math.Trunc(dur) {
+			p.warn("target duration %s is not an integer", tag.Value)
+		}
 		p.playlist.Media.TargetDuration = dur
 		p.playlist.Type = PlaylistTypeMedia
 		
@@ -229,16 +403,34 @@ func (p *Parser) processTag(tag *Tag) error {
 		}
 		p.playlist.Type = PlaylistTypeMaster
 		
+	case TagSessionKey:
+		// Add session key
+		if err := p.processSessionKey(tag); err != nil {
+			return err
+		}
+		p.playlist.Type = PlaylistTypeMaster
+		
+	case TagContentSteering:
+		// Record the steering server
+		if err := p.processContentSteering(tag); err != nil {
+			return err
+		}
+		p.playlist.Type = PlaylistTypeMaster
+		
 	case TagStreamInf:
 		// Tag will be processed with the URI line
 		p.playlist.Type = PlaylistTypeMaster
 		
 	case TagInf:
 		// Will be processed with the URI line
+		p.inf = tag
 		p.playlist.Type = PlaylistTypeMedia
 		
 	case TagDiscontinuity, TagKey, TagByteRange, TagProgramDateTime, TagMap:
-		// These will be processed with the next segment
+		// These belong to the next segment
+		if err := p.processSegmentTag(tag); err != nil {
+			return err
+		}
 		p.playlist.Type = PlaylistTypeMedia
 	}
 	
@@ -248,6 +440,22 @@ func (p *Parser) processTag(tag *Tag) error {
 	return nil
 }
 
+// warn records a tolerated spec violation on the playlist
+func (p *Parser) warn(format string, args ...interface{}) {
+	p.playlist.Warnings = append(p.playlist.Warnings, fmt.Sprintf(format, args...))
+}
+
+// bandwidth returns a stream tag's BANDWIDTH. In lenient mode a missing or
+// invalid value is replaced by the default bandwidth with a warning.
+func (p *Parser) bandwidth(tag *Tag) (uint64, error) {
+	bandwidth, err := parseAttributeUint(tag.Attributes, AttrBandwidth)
+	if err != nil && p.options.LenientBandwidth {
+		p.warn("line %d: %s: %v, using %d", p.line, tag.Name, err, p.options.DefaultBandwidth)
+		return p.options.DefaultBandwidth, nil
+	}
+	return bandwidth, err
+}
+
 // processVariantURI processes a variant URI line in a master playlist
 func (p *Parser) processVariantURI(tag *Tag, uri string) error {
 	if tag.Name != TagStreamInf {
@@ -255,7 +463,7 @@ func (p *Parser) processVariantURI(tag *Tag, uri string) error {
 	}
 	
 	// Get bandwidth
-	bandwidth, err := parseAttributeUint(tag.Attributes, AttrBandwidth)
+	bandwidth, err := p.bandwidth(tag)
 	if err != nil {
 		return err
 	}
@@ -267,20 +475,68 @@ func (p *Parser) processVariantURI(tag *Tag, uri string) error {
 }
 
 // processSegmentURI processes a segment URI line in a media playlist
-func (p *Parser) processSegmentURI(tag *Tag, uri string) error {
+func (p *Parser) processSegmentURI(uri string) error {
 	// If this URI doesn't follow an EXTINF tag, it's invalid
-	if tag == nil || tag.Name != TagInf {
+	if p.inf == nil {
 		return fmt.Errorf("segment URI must follow EXTINF tag")
 	}
 	
 	// Parse duration and title
-	duration, title, err := parseInfValue(tag.Value)
+	duration, title, err := parseInfValue(p.inf.Value)
 	if err != nil {
 		return err
 	}
 	
-	// Add segment
-	p.playlist.AddSegment(uri, duration, title)
+	// Add the segment with the tags that preceded it
+	segment := p.next
+	segment.URI = uri
+	segment.Duration = duration
+	segment.Title = title
+	p.playlist.Media.Segments = append(p.playlist.Media.Segments, segment)
+	p.playlist.Type = PlaylistTypeMedia
+	
+	p.inf = nil
+	p.next = Segment{}
+	
+	return nil
+}
+
+// processSegmentTag records a tag that applies to the next segment. KEY and
+// MAP also apply to the segments after it until they are replaced, but are
+// only attached to the first one so they are written once.
+func (p *Parser) processSegmentTag(tag *Tag) error {
+	switch tag.Name {
+	case TagDiscontinuity:
+		p.next.Discontinuity = true
+		
+	case TagProgramDateTime:
+		p.next.ProgramDateTime = tag.Value
+		
+	case TagByteRange:
+		p.next.ByteRange = tag.Value
+		
+	case TagKey:
+		key, err := parseKey(tag)
+		if err != nil {
+			return err
+		}
+		p.next.Key = key
+		
+	case TagMap:
+		m := &Map{
+			URI:           tag.Attributes[AttrURI],
+			ByteRange:     tag.Attributes[AttrByteRange],
+			RawAttributes: tag.Value,
+		}
+		
+		// A bad init segment range would fetch the wrong bytes
+		if m.ByteRange != "" {
+			if _, _, err := ParseByteRange(m.ByteRange); err != nil {
+				p.warn("line %d: %s: %v", p.line, tag.Name, err)
+			}
+		}
+		p.next.Map = m
+	}
 	
 	return nil
 }
@@ -361,7 +617,7 @@ func (p *Parser) processIFrameStream(tag *Tag) error {
 		return fmt.Errorf("missing URI attribute in EXT-X-I-FRAME-STREAM-INF")
 	}
 	
-	bandwidth, err := parseAttributeUint(tag.Attributes, AttrBandwidth)
+	bandwidth, err := p.bandwidth(tag)
 	if err != nil {
 		return err
 	}
@@ -434,12 +690,78 @@ func (p *Parser) processSessionData(tag *Tag) error {
 	return nil
 }
 
+// processContentSteering processes a content steering tag
+func (p *Parser) processContentSteering(tag *Tag) error {
+	serverURI, ok := tag.Attributes[AttrServerURI]
+	if !ok {
+		return fmt.Errorf("missing SERVER-URI attribute in EXT-X-CONTENT-STEERING")
+	}
+	
+	p.playlist.Master.ContentSteering = &ContentSteering{
+		ServerURI:     serverURI,
+		PathwayID:     tag.Attributes[AttrPathwayID],
+		RawAttributes: tag.Value,
+	}
+	
+	return nil
+}
+
+// processSessionKey processes a session key tag
+func (p *Parser) processSessionKey(tag *Tag) error {
+	key, err := parseKey(tag)
+	if err != nil {
+		return err
+	}
+	
+	// Add to playlist
+	p.playlist.Master.SessionKeys = append(p.playlist.Master.SessionKeys, *key)
+	
+	return nil
+}
+
+// parseKey builds a Key from an EXT-X-KEY or EXT-X-SESSION-KEY tag
+func parseKey(tag *Tag) (*Key, error) {
+	method, ok := tag.Attributes[AttrMethod]
+	if !ok {
+		return nil, fmt.Errorf("missing METHOD attribute in %s", strings.TrimPrefix(tag.Name, "#"))
+	}
+	
+	key := &Key{
+		Method:        KeyMethod(method),
+		RawAttributes: tag.Value,
+	}
+	
+	// Set optional attributes
+	if uri, ok := tag.Attributes[AttrURI]; ok {
+		key.URI = uri
+	}
+	
+	if iv, ok := tag.Attributes[AttrIV]; ok {
+		key.IV = iv
+	}
+	
+	if format, ok := tag.Attributes[AttrKeyFormat]; ok {
+		key.KeyFormat = format
+	}
+	
+	if versions, ok := tag.Attributes[AttrKeyFormatVersions]; ok {
+		key.KeyFormatVersions = versions
+	}
+	
+	return key, nil
+}
+
+// attributePattern matches one NAME=value pair of an attribute list
+var attributePattern = regexp.MustCompile(`([A-Z0-9-]+)=("[^"]*"|[^",]+)`)
+
+// attributeNamePattern matches a valid attribute name
+var attributeNamePattern = regexp.MustCompile(`^[A-Z0-9-]+$`)
+
 // parseAttributes parses a string of comma-separated attributes
 func parseAttributes(s string) (map[string]string, error) {
 	attrs := make(map[string]string)
-	r := regexp.MustCompile(`([A-Z-]+)=("[^"]*"|[^",]+)`)
 	
-	matches := r.FindAllStringSubmatch(s, -1)
+	matches := attributePattern.FindAllStringSubmatch(s, -1)
 	for _, match := range matches {
 		if len(match) != 3 {
 			continue
@@ -474,12 +796,13 @@ func parseAttributeUint(attrs map[string]string, name string) (uint64, error) {
 	return val, nil
 }
 
-// parseInfValue parses the value of an EXTINF tag
+// parseInfValue parses the value of an EXTINF tag. Everything after the
+// first comma is the title, kept verbatim.
 func parseInfValue(s string) (float64, string, error) {
 	parts := strings.SplitN(s, ",", 2)
 	
 	// Parse duration
-	duration, err := strconv.ParseFloat(parts[0], 64)
+	duration, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
 	if err != nil {
 		return 0, "", fmt.Errorf("invalid EXTINF duration: %w", err)
 	}
@@ -0,0 +1,566 @@
+package hls
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParserAttachesSegmentTags(t *testing.T) {
+	input := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXT-X-MEDIA-SEQUENCE:7",
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z",
+		`#EXT-X-KEY:METHOD=AES-128,URI="k1.key",IV=0x01`,
+		`#EXT-X-MAP:URI="init.mp4",BYTERANGE="720@0"`,
+		"#EXTINF:6,",
+		"seg1.mp4",
+		"#EXT-X-DISCONTINUITY",
+		"#EXTINF:6,",
+		"#EXT-X-BYTERANGE:1000@720",
+		"seg2.mp4",
+		"#EXTINF:6,",
+		"seg3.mp4",
+	}, "\n")
+
+	playlist, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	segments := playlist.Media.Segments
+	if len(segments) != 3 {
+		t.Fatalf("got %d segments, want 3", len(segments))
+	}
+
+	tests := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"seg1 date-time", segments[0].ProgramDateTime, "2024-01-01T00:00:00.000Z"},
+		{"seg1 key", segments[0].Key != nil && segments[0].Key.URI == "k1.key", true},
+		{"seg1 map", segments[0].Map != nil && segments[0].Map.ByteRange == "720@0", true},
+		{"seg1 discontinuity", segments[0].Discontinuity, false},
+		{"seg2 discontinuity", segments[1].Discontinuity, true},
+		{"seg2 byte range", segments[1].ByteRange, "1000@720"},
+		{"seg2 key", segments[1].Key == nil, true},
+		{"seg3 byte range", segments[2].ByteRange, ""},
+		{"seg3 discontinuity", segments[2].Discontinuity, false},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestPlaylistStringWritesSegmentTagsInPlace(t *testing.T) {
+	input := strings.Join([]string{
+		"#EXTM3U",
+		"#EXT-X-TARGETDURATION:6",
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z",
+		"#EXTINF:6,",
+		"seg1.ts",
+		"#EXT-X-DISCONTINUITY",
+		`#EXT-X-KEY:METHOD=AES-128,URI="k2.key"`,
+		"#EXTINF:6,",
+		"seg2.ts",
+	}, "\n")
+
+	playlist, err := New().Parse(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	playlist.Media.Segments[1].Key.URI = "https://keys.example.com/k2.key"
+	out := playlist.String()
+
+	// Segment tags directly precede their segment, after the header tags
+	want := strings.Join([]string{
+		"#EXT-X-PROGRAM-DATE-TIME:2024-01-01T00:00:00.000Z",
+		"#EXTINF:6,",
+		"seg1.ts",
+		"#EXT-X-DISCONTINUITY",
+		`#EXT-X-KEY:METHOD=AES-128,URI="https://keys.example.com/k2.key"`,
+		"#EXTINF:6,",
+		"seg2.ts",
+	}, "\n")
+	if !strings.Contains(out, want) {
+		t.Errorf("segment tags out of place:\n%s", out)
+	}
+	if strings.Count(out, "#EXT-X-DISCONTINUITY\n") != 1 || strings.Count(out, "#EXT-X-PROGRAM-DATE-TIME") != 1 {
+		t.Errorf("segment tags repeated:\n%s", out)
+	}
+	if strings.Index(out, "#EXT-X-PROGRAM-DATE-TIME") < strings.Index(out, "#EXT-X-TARGETDURATION") {
+		t.Errorf("date-time hoisted above the header:\n%s", out)
+	}
+}
+
+func TestParserRejectsSegmentWithoutEXTINF(t *testing.T) {
+	input := "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-BYTERANGE:100@0\nseg.ts\n"
+	if _, err := New().Parse(strings.NewReader(input)); err == nil {
+		t.Fatal("segment without EXTINF parsed")
+	}
+}
+
+func TestParserSessionKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		tag     string
+		want    Key
+		wantErr bool
+	}{
+		{
+			name: "full key",
+			tag:  `#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="skd://id",IV=0x01,KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"`,
+			want: Key{Method: "SAMPLE-AES", URI: "skd://id", IV: "0x01", KeyFormat: "com.apple.streamingkeydelivery", KeyFormatVersions: "1"},
+		},
+		{
+			name: "method only",
+			tag:  "#EXT-X-SESSION-KEY:METHOD=NONE",
+			want: Key{Method: "NONE"},
+		},
+		{
+			name:    "missing method",
+			tag:     `#EXT-X-SESSION-KEY:URI="k.key"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n" + tt.tag + "\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nv.m3u8\n"
+			playlist, err := New().Parse(strings.NewReader(input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("parsed a session key without METHOD")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !playlist.IsMaster() || len(playlist.Master.SessionKeys) != 1 {
+				t.Fatalf("got %d session keys in a master: %v", len(playlist.Master.SessionKeys), playlist.IsMaster())
+			}
+
+			got := playlist.Master.SessionKeys[0]
+			got.RawAttributes = ""
+			if got != tt.want {
+				t.Errorf("session key %+v, want %+v", got, tt.want)
+			}
+			if out := playlist.String(); strings.Count(out, "#EXT-X-SESSION-KEY:") != 1 {
+				t.Errorf("session key not written exactly once:\n%s", out)
+			}
+		})
+	}
+}
+
+func TestExtinfRoundTrip(t *testing.T) {
+	tests := []struct {
+		name         string
+		extinf       string
+		wantDuration float64
+		wantTitle    string
+		wantLine     string
+	}{
+		{"no title", "#EXTINF:6,", 6, "", "#EXTINF:6,"},
+		{"missing comma", "#EXTINF:6", 6, "", "#EXTINF:6,"},
+		{"fractional duration", "#EXTINF:5.005,", 5.005, "", "#EXTINF:5.005,"},
+		{"padded duration", "#EXTINF: 4.5 ,", 4.5, "", "#EXTINF:4.5,"},
+		{"plain title", "#EXTINF:6,Intro", 6, "Intro", "#EXTINF:6,Intro"},
+		{"title with commas", "#EXTINF:6,Artist, Song, Live", 6, "Artist, Song, Live", "#EXTINF:6,Artist, Song, Live"},
+		{"title spaces kept", "#EXTINF:6, padded ", 6, " padded ", "#EXTINF:6, padded "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n#EXT-X-TARGETDURATION:6\n" + tt.extinf + "\nseg.ts\n"
+			playlist, err := New().Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			segment := playlist.Media.Segments[0]
+			if segment.Duration != tt.wantDuration || segment.Title != tt.wantTitle {
+				t.Errorf("parsed %v %q, want %v %q", segment.Duration, segment.Title, tt.wantDuration, tt.wantTitle)
+			}
+			if out := playlist.String(); !strings.Contains(out, tt.wantLine+"\nseg.ts\n") {
+				t.Errorf("serialized without %q:\n%s", tt.wantLine, out)
+			}
+		})
+	}
+}
+
+func TestExtinfTitleStaysOnOneLine(t *testing.T) {
+	playlist, err := New().Parse(strings.NewReader("#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg.ts\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	playlist.Media.Segments[0].Title = "line one\r\nline two\nthree"
+	if out := playlist.String(); !strings.Contains(out, "#EXTINF:6,line one line two three\nseg.ts\n") {
+		t.Errorf("title not kept on the EXTINF line:\n%s", out)
+	}
+}
+
+func TestMediaGroupAttributeString(t *testing.T) {
+	tests := []struct {
+		name  string
+		group MediaGroup
+		want  string
+	}{
+		{
+			name:  "with URI",
+			group: MediaGroup{Type: "AUDIO", URI: "a.m3u8", GroupID: "aud", Name: "English", Default: true},
+			want:  `TYPE=AUDIO,URI="a.m3u8",GROUP-ID="aud",NAME="English",DEFAULT=YES`,
+		},
+		{
+			name:  "without URI",
+			group: MediaGroup{Type: "AUDIO", GroupID: "aud", Name: "Main", Autoselect: true},
+			want:  `TYPE=AUDIO,GROUP-ID="aud",NAME="Main",AUTOSELECT=YES`,
+		},
+		{
+			name:  "raw attributes without a type",
+			group: MediaGroup{RawAttributes: `GROUP-ID="x"`},
+			want:  `GROUP-ID="x"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.group.AttributeString(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetDurationRounding(t *testing.T) {
+	tests := []struct {
+		value        string
+		wantLine     string
+		wantWarnings int
+	}{
+		{"6", "#EXT-X-TARGETDURATION:6", 0},
+		{"6.7", "#EXT-X-TARGETDURATION:7", 1},
+		{"6.5", "#EXT-X-TARGETDURATION:7", 1},
+		{"6.4", "#EXT-X-TARGETDURATION:6", 1},
+		{"10.0", "#EXT-X-TARGETDURATION:10", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			input := "#EXTM3U\n#EXT-X-TARGETDURATION:" + tt.value + "\n#EXTINF:6,\nseg.ts\n"
+			playlist, err := New().Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(playlist.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings %v, want %d", playlist.Warnings, tt.wantWarnings)
+			}
+			out := playlist.String()
+			if !strings.Contains(out, tt.wantLine+"\n") {
+				t.Errorf("output without %s:\n%s", tt.wantLine, out)
+			}
+
+			// The rounded value parses cleanly and serializes unchanged
+			again, err := New().Parse(strings.NewReader(out))
+			if err != nil {
+				t.Fatalf("Parse output: %v", err)
+			}
+			if len(again.Warnings) != 0 || again.String() != out {
+				t.Errorf("round trip changed the playlist:\n%s\nwant:\n%s", again.String(), out)
+			}
+		})
+	}
+}
+
+func TestValidateDurations(t *testing.T) {
+	const (
+		compliant    = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6.4,\nseg1.ts\n#EXTINF:5,\nseg2.ts\n"
+		nonCompliant = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXTINF:6,\nseg1.ts\n#EXTINF:6.5,\nseg2.ts\n"
+	)
+
+	tests := []struct {
+		name         string
+		content      string
+		options      Options
+		wantWarnings int
+		wantErr      bool
+	}{
+		{"compliant", compliant, Options{ValidateDurations: true}, 0, false},
+		{"compliant strict", compliant, Options{ValidateDurations: true, Strict: true}, 0, false},
+		{"non-compliant unchecked", nonCompliant, Options{}, 0, false},
+		{"non-compliant warns", nonCompliant, Options{ValidateDurations: true}, 1, false},
+		{"non-compliant strict", nonCompliant, Options{ValidateDurations: true, Strict: true}, 0, true},
+		{"master playlists skipped", "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nv.m3u8\n", Options{ValidateDurations: true, Strict: true}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist, err := NewWithOptions(tt.options).Parse(strings.NewReader(tt.content))
+			if tt.wantErr {
+				if !errors.Is(err, ErrSegmentDuration) {
+					t.Fatalf("err = %v, want %v", err, ErrSegmentDuration)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if len(playlist.Warnings) != tt.wantWarnings {
+				t.Errorf("warnings %q, want %d", playlist.Warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+func TestValidateAttributes(t *testing.T) {
+	tests := []struct {
+		name        string
+		tag         string
+		strict      bool
+		wantWarning string
+		wantErr     *AttributeError
+	}{
+		{
+			name: "well formed",
+			tag:  `#EXT-X-STREAM-INF:BANDWIDTH=1000,CODECS="avc1.4d401f,mp4a.40.2",RESOLUTION=640x360`,
+		},
+		{
+			name:        "empty value",
+			tag:         `#EXT-X-STREAM-INF:BANDWIDTH=1000,RESOLUTION=`,
+			wantWarning: `line 2: #EXT-X-STREAM-INF: malformed attribute "RESOLUTION=": empty value`,
+		},
+		{
+			name:        "missing equals",
+			tag:         `#EXT-X-STREAM-INF:BANDWIDTH=1000,CLOSED-CAPTIONS`,
+			wantWarning: `malformed attribute "CLOSED-CAPTIONS": missing '='`,
+		},
+		{
+			name:        "lower-case name",
+			tag:         `#EXT-X-STREAM-INF:BANDWIDTH=1000,codecs="avc1"`,
+			wantWarning: `malformed attribute "codecs=\"avc1\"": invalid name`,
+		},
+		{
+			name:        "stray quote",
+			tag:         `#EXT-X-STREAM-INF:BANDWIDTH=1000,RESOLUTION=640x"360`,
+			wantWarning: "stray quote",
+		},
+		{
+			name:        "optional attribute in strict mode",
+			tag:         `#EXT-X-STREAM-INF:BANDWIDTH=1000,RESOLUTION=`,
+			strict:      true,
+			wantWarning: "empty value",
+		},
+		{
+			name:    "required attribute in strict mode",
+			tag:     `#EXT-X-STREAM-INF:BANDWIDTH=,RESOLUTION=640x360`,
+			strict:  true,
+			wantErr: &AttributeError{Line: 2, Tag: TagStreamInf, Attribute: "BANDWIDTH", Text: "BANDWIDTH=", Reason: "empty value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n" + tt.tag + "\nv.m3u8\n"
+			playlist, err := NewWithOptions(Options{ValidateAttributes: true, Strict: tt.strict}).Parse(strings.NewReader(input))
+			if tt.wantErr != nil {
+				var attrErr *AttributeError
+				if !errors.As(err, &attrErr) || *attrErr != *tt.wantErr {
+					t.Fatalf("err = %#v, want %#v", err, tt.wantErr)
+				}
+				if !errors.Is(err, ErrTagFormat) {
+					t.Error("attribute error not classified as a tag format error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			warnings := strings.Join(playlist.Warnings, "\n")
+			if tt.wantWarning == "" && warnings != "" {
+				t.Errorf("unexpected warnings: %s", warnings)
+			}
+			if !strings.Contains(warnings, tt.wantWarning) {
+				t.Errorf("warnings %q, want %q", warnings, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestParserContentSteering(t *testing.T) {
+	tests := []struct {
+		name     string
+		tag      string
+		want     ContentSteering
+		wantLine string
+		wantErr  bool
+	}{
+		{
+			name:     "server and pathway",
+			tag:      `#EXT-X-CONTENT-STEERING:SERVER-URI="steer.json",PATHWAY-ID="CDN-A"`,
+			want:     ContentSteering{ServerURI: "steer.json", PathwayID: "CDN-A"},
+			wantLine: `#EXT-X-CONTENT-STEERING:SERVER-URI="steer.json",PATHWAY-ID="CDN-A"`,
+		},
+		{
+			name:     "server only",
+			tag:      `#EXT-X-CONTENT-STEERING:SERVER-URI="https://steer.example.com/s.json"`,
+			want:     ContentSteering{ServerURI: "https://steer.example.com/s.json"},
+			wantLine: `#EXT-X-CONTENT-STEERING:SERVER-URI="https://steer.example.com/s.json"`,
+		},
+		{
+			name:     "unknown attributes kept",
+			tag:      `#EXT-X-CONTENT-STEERING:PATHWAY-ID="CDN-B",SERVER-URI="steer.json",X-TTL=30`,
+			want:     ContentSteering{ServerURI: "steer.json", PathwayID: "CDN-B"},
+			wantLine: `#EXT-X-CONTENT-STEERING:SERVER-URI="steer.json",PATHWAY-ID="CDN-B",X-TTL=30`,
+		},
+		{
+			name:    "missing server",
+			tag:     `#EXT-X-CONTENT-STEERING:PATHWAY-ID="CDN-A"`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n" + tt.tag + "\n#EXT-X-STREAM-INF:BANDWIDTH=1000\nv.m3u8\n"
+			playlist, err := New().Parse(strings.NewReader(input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("parsed content steering without SERVER-URI")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if !playlist.IsMaster() || playlist.Master.ContentSteering == nil {
+				t.Fatal("content steering not parsed into a master")
+			}
+
+			got := *playlist.Master.ContentSteering
+			got.RawAttributes = ""
+			if got != tt.want {
+				t.Errorf("steering %+v, want %+v", got, tt.want)
+			}
+
+			out := playlist.String()
+			if strings.Count(out, "#EXT-X-CONTENT-STEERING:") != 1 || !strings.Contains(out, tt.wantLine+"\n") {
+				t.Errorf("steering not written once as %s:\n%s", tt.wantLine, out)
+			}
+
+			// Clones carry their own copy
+			clone := playlist.Clone()
+			clone.Master.ContentSteering.ServerURI = "changed.json"
+			if playlist.Master.ContentSteering.ServerURI != tt.want.ServerURI {
+				t.Error("clone shares the steering server")
+			}
+		})
+	}
+}
+
+func TestVariantSteeringAttributesRoundTrip(t *testing.T) {
+	tests := []struct {
+		name        string
+		attrs       string
+		wantPathway string
+		wantStable  string
+	}{
+		{"both", `BANDWIDTH=1000,PATHWAY-ID="CDN-A",STABLE-VARIANT-ID="hd"`, "CDN-A", "hd"},
+		{"pathway only", `BANDWIDTH=1000,PATHWAY-ID="CDN-B"`, "CDN-B", ""},
+		{"stable only", `STABLE-VARIANT-ID="sd",BANDWIDTH=1000`, "", "sd"},
+		{"neither", `BANDWIDTH=1000`, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n#EXT-X-STREAM-INF:" + tt.attrs + "\nv.m3u8\n"
+			playlist, err := New().Parse(strings.NewReader(input))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			// Survives parse, serialize and parse again
+			for round := 0; round < 2; round++ {
+				if len(playlist.Master.Variants) != 1 {
+					t.Fatalf("round %d: %d variants", round, len(playlist.Master.Variants))
+				}
+				v := playlist.Master.Variants[0]
+				if v.PathwayID != tt.wantPathway || v.StableVariantID != tt.wantStable {
+					t.Errorf("round %d: PATHWAY-ID %q STABLE-VARIANT-ID %q, want %q %q",
+						round, v.PathwayID, v.StableVariantID, tt.wantPathway, tt.wantStable)
+				}
+				if playlist, err = New().Parse(strings.NewReader(playlist.String())); err != nil {
+					t.Fatalf("reparse: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestAddVariantAttributeOrder(t *testing.T) {
+	attrs := map[string]string{
+		AttrStableVariantID: "hd",
+		AttrPathwayID:       "CDN-A",
+		AttrCodecs:          "avc1.4d401f",
+		AttrBandwidth:       "1000",
+	}
+	want := `BANDWIDTH=1000,CODECS="avc1.4d401f",PATHWAY-ID="CDN-A",STABLE-VARIANT-ID="hd"`
+
+	// Map order varies between runs; the attribute list must not
+	for i := 0; i < 10; i++ {
+		p := &Playlist{}
+		p.AddVariant("v.m3u8", 1000, attrs)
+		if got := p.Master.Variants[0].RawAttributes; got != want {
+			t.Fatalf("attributes %s, want %s", got, want)
+		}
+	}
+}
+
+func TestMissingBandwidth(t *testing.T) {
+	tests := []struct {
+		name          string
+		attrs         string
+		options       Options
+		wantErr       bool
+		wantBandwidth uint64
+		wantWarnings  int
+	}{
+		{"strict rejects missing", `RESOLUTION=640x360`, Options{}, true, 0, 0},
+		{"strict rejects invalid", `BANDWIDTH=fast`, Options{}, true, 0, 0},
+		{"lenient defaults missing", `RESOLUTION=640x360`, Options{LenientBandwidth: true, DefaultBandwidth: 500000}, false, 500000, 1},
+		{"lenient defaults invalid", `BANDWIDTH=fast`, Options{LenientBandwidth: true}, false, 0, 1},
+		{"lenient keeps valid", `BANDWIDTH=800000`, Options{LenientBandwidth: true, DefaultBandwidth: 500000}, false, 800000, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := "#EXTM3U\n#EXT-X-STREAM-INF:" + tt.attrs + "\nv.m3u8\n" +
+				`#EXT-X-I-FRAME-STREAM-INF:` + tt.attrs + `,URI="iframe.m3u8"` + "\n"
+			playlist, err := NewWithOptions(tt.options).Parse(strings.NewReader(input))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("parsed a variant without a valid BANDWIDTH")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+
+			if len(playlist.Master.Variants) != 1 || len(playlist.Master.IFrameStreams) != 1 {
+				t.Fatalf("%d variants, %d I-frame streams", len(playlist.Master.Variants), len(playlist.Master.IFrameStreams))
+			}
+			if got := playlist.Master.Variants[0].Bandwidth; got != tt.wantBandwidth {
+				t.Errorf("variant bandwidth %d, want %d", got, tt.wantBandwidth)
+			}
+			if got := playlist.Master.IFrameStreams[0].Bandwidth; got != tt.wantBandwidth {
+				t.Errorf("I-frame bandwidth %d, want %d", got, tt.wantBandwidth)
+			}
+			if len(playlist.Warnings) != 2*tt.wantWarnings {
+				t.Errorf("warnings %v, want %d", playlist.Warnings, 2*tt.wantWarnings)
+			}
+		})
+	}
+}
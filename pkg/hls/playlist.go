@@ -10,6 +10,8 @@ package hls
 
 import (
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -17,12 +19,13 @@ import (
 // Playlist represents an HLS playlist (either master or media)
 type Playlist struct {
 	Type           PlaylistType
-	Version        int
+	Version        int // Declared EXT-X-VERSION; 0 when the playlist has none
 	Tags           []Tag
 	Master         MasterPlaylist
 	Media          MediaPlaylist
 	OriginalHeader string
 	RawLines       []string
+	Warnings       []string // Spec violations tolerated while parsing
 }
 
 // MasterPlaylist contains data specific to master playlists
@@ -31,6 +34,8 @@ type MasterPlaylist struct {
 	MediaGroups    map[string][]MediaGroup
 	IFrameStreams  []IFrameStream
 	SessionData    []SessionData
+	SessionKeys    []Key
+	ContentSteering *ContentSteering
 	HasIndependentSegments bool
 }
 
@@ -60,6 +65,8 @@ type Variant struct {
 	VideoGroup          string
 	SubtitlesGroup      string
 	ClosedCaptionsGroup string
+	PathwayID           string // Content steering pathway serving the variant
+	StableVariantID     string // Identifies the variant across pathways and reloads
 	RawAttributes       string
 }
 
@@ -92,6 +99,30 @@ type IFrameStream struct {
 	RawAttributes       string
 }
 
+// ContentSteering represents the content steering server of a master
+// playlist, which tells clients which pathway (CDN) to use
+type ContentSteering struct {
+	ServerURI     string
+	PathwayID     string // Pathway used until the first steering manifest arrives
+	RawAttributes string
+}
+
+// AttributeString returns the steering attribute list built from its
+// fields, so that a rewritten server URI is reflected in the output.
+// Attributes without a field are carried over from the original tag.
+func (c *ContentSteering) AttributeString() string {
+	parts := []string{fmt.Sprintf("%s=\"%s\"", AttrServerURI, c.ServerURI)}
+	if c.PathwayID != "" {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", AttrPathwayID, c.PathwayID))
+	}
+	for _, attr := range attributePattern.FindAllStringSubmatch(c.RawAttributes, -1) {
+		if attr[1] != AttrServerURI && attr[1] != AttrPathwayID {
+			parts = append(parts, attr[0])
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
 // SessionData represents session data in a master playlist
 type SessionData struct {
 	DataID          string
@@ -130,6 +161,95 @@ type Map struct {
 	RawAttributes    string
 }
 
+// AttributeString returns the key's attribute list built from its fields,
+// so that a rewritten URI is reflected in the output
+func (k *Key) AttributeString() string {
+	if k.Method == "" {
+		return k.RawAttributes
+	}
+	
+	parts := []string{fmt.Sprintf("%s=%s", AttrMethod, k.Method)}
+	if k.URI != "" {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", AttrURI, k.URI))
+	}
+	if k.IV != "" {
+		parts = append(parts, fmt.Sprintf("%s=%s", AttrIV, k.IV))
+	}
+	if k.KeyFormat != "" {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", AttrKeyFormat, k.KeyFormat))
+	}
+	if k.KeyFormatVersions != "" {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", AttrKeyFormatVersions, k.KeyFormatVersions))
+	}
+	
+	return strings.Join(parts, ",")
+}
+
+// AttributeString returns the map's attribute list built from its fields,
+// so that a rewritten URI is reflected in the output
+func (m *Map) AttributeString() string {
+	if m.URI == "" {
+		return m.RawAttributes
+	}
+	
+	parts := []string{fmt.Sprintf("%s=\"%s\"", AttrURI, m.URI)}
+	if m.ByteRange != "" {
+		parts = append(parts, fmt.Sprintf("%s=\"%s\"", AttrByteRange, m.ByteRange))
+	}
+	
+	return strings.Join(parts, ",")
+}
+
+// mediaGroupAttrs are the EXT-X-MEDIA attributes held in MediaGroup fields
+var mediaGroupAttrs = map[string]bool{
+	AttrType: true, AttrURI: true, AttrGroupID: true, AttrLanguage: true,
+	AttrAssocLanguage: true, AttrName: true, AttrDefault: true,
+	AttrAutoselect: true, AttrForced: true, AttrInstreamID: true,
+	AttrCharacteristics: true, AttrChannels: true,
+}
+
+// AttributeString returns the media group's attribute list built from its
+// fields, so that a rewritten URI is reflected in the output. Renditions
+// without a URI, such as audio muxed into the variant, are emitted without
+// one. Attributes without a field are carried over from the original tag.
+func (m *MediaGroup) AttributeString() string {
+	if m.Type == "" {
+		return m.RawAttributes
+	}
+	
+	parts := []string{fmt.Sprintf("%s=%s", AttrType, m.Type)}
+	quoted := func(name, value string) {
+		if value != "" {
+			parts = append(parts, fmt.Sprintf("%s=\"%s\"", name, value))
+		}
+	}
+	flag := func(name string, set bool) {
+		if set {
+			parts = append(parts, fmt.Sprintf("%s=YES", name))
+		}
+	}
+	
+	quoted(AttrURI, m.URI)
+	quoted(AttrGroupID, m.GroupID)
+	quoted(AttrLanguage, m.Language)
+	quoted(AttrAssocLanguage, m.AssocLanguage)
+	quoted(AttrName, m.Name)
+	flag(AttrDefault, m.Default)
+	flag(AttrAutoselect, m.Autoselect)
+	flag(AttrForced, m.Forced)
+	quoted(AttrInstreamID, m.InstreamID)
+	quoted(AttrCharacteristics, m.Characteristics)
+	quoted(AttrChannels, m.Channels)
+	
+	for _, attr := range attributePattern.FindAllStringSubmatch(m.RawAttributes, -1) {
+		if !mediaGroupAttrs[attr[1]] {
+			parts = append(parts, attr[0])
+		}
+	}
+	
+	return strings.Join(parts, ",")
+}
+
 // Tag represents a parsed HLS tag with its attributes
 type Tag struct {
 	Name         string
@@ -142,13 +262,14 @@ type Tag struct {
 func NewPlaylist() *Playlist {
 	return &Playlist{
 		Type:    PlaylistTypeUnknown,
-		Version: 1, // Default version
+		Version: 0, // Undeclared until an EXT-X-VERSION tag is parsed
 		Tags:    make([]Tag, 0),
 		Master: MasterPlaylist{
 			Variants:    make([]Variant, 0),
 			MediaGroups: make(map[string][]MediaGroup),
 			IFrameStreams: make([]IFrameStream, 0),
 			SessionData: make([]SessionData, 0),
+			SessionKeys: make([]Key, 0),
 		},
 		Media: MediaPlaylist{
 			Segments: make([]Segment, 0),
@@ -161,13 +282,16 @@ func NewPlaylist() *Playlist {
 func (p *Playlist) String() string {
 	var sb strings.Builder
 	
-	// Write header
+	// Write header; the version is written when declared or when the
+	// playlist's features need more than version 1
 	sb.WriteString(TagExtM3U + "\n")
-	sb.WriteString(fmt.Sprintf("%s:%d\n", TagVersion, p.Version))
+	if version := p.OutputVersion(); p.Version > 0 || version > 1 {
+		sb.WriteString(fmt.Sprintf("%s:%d\n", TagVersion, version))
+	}
 	
 	// Write other global tags
 	for _, tag := range p.Tags {
-		if tag.Name != TagExtM3U && tag.Name != TagVersion {
+		if tag.Name != TagExtM3U && tag.Name != TagVersion && !rebuiltTags[tag.Name] {
 			sb.WriteString(tag.String() + "\n")
 		}
 	}
@@ -181,10 +305,15 @@ func (p *Playlist) String() string {
 			sb.WriteString(TagIndependentSegments + "\n")
 		}
 		
+		// Content steering server if present
+		if p.Master.ContentSteering != nil {
+			sb.WriteString(fmt.Sprintf("%s:%s\n", TagContentSteering, p.Master.ContentSteering.AttributeString()))
+		}
+		
 		// Media groups
 		for _, groups := range p.Master.MediaGroups {
 			for _, group := range groups {
-				sb.WriteString(fmt.Sprintf("%s:%s\n", TagMedia, group.RawAttributes))
+				sb.WriteString(fmt.Sprintf("%s:%s\n", TagMedia, group.AttributeString()))
 			}
 		}
 		
@@ -193,6 +322,11 @@ func (p *Playlist) String() string {
 			sb.WriteString(fmt.Sprintf("%s:%s\n", TagSessionData, data.RawAttributes))
 		}
 		
+		// Session keys
+		for _, key := range p.Master.SessionKeys {
+			sb.WriteString(fmt.Sprintf("%s:%s\n", TagSessionKey, key.AttributeString()))
+		}
+		
 		// Variants
 		for _, variant := range p.Master.Variants {
 			sb.WriteString(fmt.Sprintf("%s:%s\n%s\n", TagStreamInf, variant.RawAttributes, variant.URI))
@@ -211,8 +345,9 @@ func (p *Playlist) String() string {
 			sb.WriteString(TagIndependentSegments + "\n")
 		}
 		
-		// Target duration
-		sb.WriteString(fmt.Sprintf("%s:%d\n", TagTargetDuration, int(p.Media.TargetDuration)))
+		// Target duration, an integer per spec; round rather than truncate
+		// so a fractional value is never under-reported
+		sb.WriteString(fmt.Sprintf("%s:%d\n", TagTargetDuration, int64(math.Round(p.Media.TargetDuration))))
 		
 		// Media sequence
 		sb.WriteString(fmt.Sprintf("%s:%d\n", TagMediaSequence, p.Media.MediaSequence))
@@ -239,14 +374,19 @@ func (p *Playlist) String() string {
 		
 		// Segments
 		for _, segment := range p.Media.Segments {
+			// Discontinuity if present
+			if segment.Discontinuity {
+				sb.WriteString(fmt.Sprintf("%s\n", TagDiscontinuity))
+			}
+			
 			// Key information if present
 			if segment.Key != nil {
-				sb.WriteString(fmt.Sprintf("%s:%s\n", TagKey, segment.Key.RawAttributes))
+				sb.WriteString(fmt.Sprintf("%s:%s\n", TagKey, segment.Key.AttributeString()))
 			}
 			
 			// Map information if present
 			if segment.Map != nil {
-				sb.WriteString(fmt.Sprintf("%s:%s\n", TagMap, segment.Map.RawAttributes))
+				sb.WriteString(fmt.Sprintf("%s:%s\n", TagMap, segment.Map.AttributeString()))
 			}
 			
 			// Program date time if present
@@ -254,22 +394,13 @@ func (p *Playlist) String() string {
 				sb.WriteString(fmt.Sprintf("%s:%s\n", TagProgramDateTime, segment.ProgramDateTime))
 			}
 			
-			// Discontinuity if present
-			if segment.Discontinuity {
-				sb.WriteString(fmt.Sprintf("%s\n", TagDiscontinuity))
-			}
-			
 			// Byte range if present
 			if segment.ByteRange != "" {
 				sb.WriteString(fmt.Sprintf("%s:%s\n", TagByteRange, segment.ByteRange))
 			}
 			
-			// Segment information
-			if segment.Title != "" {
-				sb.WriteString(fmt.Sprintf("%s:%.3f,%s\n", TagInf, segment.Duration, segment.Title))
-			} else {
-				sb.WriteString(fmt.Sprintf("%s:%.3f\n", TagInf, segment.Duration))
-			}
+			// Segment information; the comma is required even without a title
+			sb.WriteString(fmt.Sprintf("%s:%s,%s\n", TagInf, formatDuration(segment.Duration), sanitizeTitle(segment.Title)))
 			
 			// URI
 			sb.WriteString(segment.URI + "\n")
@@ -284,6 +415,21 @@ func (p *Playlist) String() string {
 	return sb.String()
 }
 
+// formatDuration formats a segment duration with the precision it was
+// parsed with, so durations round-trip unchanged
+func formatDuration(d float64) string {
+	return strconv.FormatFloat(d, 'f', -1, 64)
+}
+
+// sanitizeTitle keeps a segment title on the EXTINF line. Titles are
+// otherwise written verbatim, including commas and surrounding spaces.
+func sanitizeTitle(title string) string {
+	if !strings.ContainsAny(title, "\r\n") {
+		return title
+	}
+	return strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ").Replace(title)
+}
+
 // String returns a tag as a string
 func (t *Tag) String() string {
 	if t.Value != "" {
@@ -350,17 +496,33 @@ func (p *Playlist) AddVariant(uri string, bandwidth uint64, attrs map[string]str
 		v.ClosedCaptionsGroup = cc
 	}
 	
-	// Build raw attributes string
+	if pathway, ok := attrs[AttrPathwayID]; ok {
+		v.PathwayID = pathway
+	}
+	
+	if stable, ok := attrs[AttrStableVariantID]; ok {
+		v.StableVariantID = stable
+	}
+	
+	// Build raw attributes string, in name order so output is stable
 	var parts []string
 	parts = append(parts, fmt.Sprintf("%s=%d", AttrBandwidth, bandwidth))
 	
-	for k, v := range attrs {
+	names := make([]string, 0, len(attrs))
+	for k := range attrs {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	
+	for _, k := range names {
+		v := attrs[k]
 		if k != AttrBandwidth {
 			// Quote string values
 			if k == AttrCodecs || k == AttrResolution || 
 			   k == AttrAudio || k == AttrVideo || 
 			   k == AttrSubtitles || k == AttrClosedCaptions ||
-			   k == AttrHDCPLevel {
+			   k == AttrHDCPLevel || k == AttrPathwayID ||
+			   k == AttrStableVariantID {
 				parts = append(parts, fmt.Sprintf("%s=\"%s\"", k, v))
 			} else {
 				parts = append(parts, fmt.Sprintf("%s=%s", k, v))
@@ -13,7 +13,9 @@ const (
 	TagMedia            = "#EXT-X-MEDIA"
 	TagIFrameStreamInf  = "#EXT-X-I-FRAME-STREAM-INF"
 	TagSessionData      = "#EXT-X-SESSION-DATA"
+	TagSessionKey       = "#EXT-X-SESSION-KEY"
 	TagIndependentSegments = "#EXT-X-INDEPENDENT-SEGMENTS"
+	TagContentSteering  = "#EXT-X-CONTENT-STEERING"
 	
 	// Media playlist tags
 	TagTargetDuration   = "#EXT-X-TARGETDURATION"
@@ -48,6 +50,9 @@ const (
 	AttrKeyFormatVersions = "KEYFORMATVERSIONS"
 	AttrIV              = "IV"
 	
+	// Map attributes
+	AttrByteRange       = "BYTERANGE"
+	
 	// Media attributes
 	AttrType            = "TYPE"
 	AttrGroupID         = "GROUP-ID"
@@ -64,9 +69,38 @@ const (
 	// Session data attributes
 	AttrDataID          = "DATA-ID"
 	AttrValue           = "VALUE"
-	AttrLanguage        = "LANGUAGE"
+	
+	// Content steering attributes
+	AttrServerURI       = "SERVER-URI"
+	AttrPathwayID       = "PATHWAY-ID"
+	AttrStableVariantID = "STABLE-VARIANT-ID"
 )
 
+// rebuiltTags are tags that Playlist.String writes from the parsed
+// structures rather than copying the original tag line
+var rebuiltTags = map[string]bool{
+	TagStreamInf:             true,
+	TagMedia:                 true,
+	TagIFrameStreamInf:       true,
+	TagSessionData:           true,
+	TagSessionKey:            true,
+	TagContentSteering:       true,
+	TagIndependentSegments:   true,
+	TagTargetDuration:        true,
+	TagMediaSequence:         true,
+	TagDiscontinuitySequence: true,
+	TagAllowCache:            true,
+	TagPlaylistType:          true,
+	TagIFramesOnly:           true,
+	TagInf:                   true,
+	TagEndList:               true,
+	TagDiscontinuity:         true,
+	TagProgramDateTime:       true,
+	TagByteRange:             true,
+	TagKey:                   true,
+	TagMap:                   true,
+}
+
 // PlaylistType represents the type of playlist (master or media)
 type PlaylistType int
 
@@ -0,0 +1,100 @@
+// Protocol version requirements
+//
+// Minimum EXT-X-VERSION for the features a playlist uses:
+// - Encryption key attributes
+// - Fractional durations, byte ranges and I-frame playlists
+// - Media initialization sections
+// - Closed caption services and variable substitution
+package hls
+
+import (
+	"math"
+	"strings"
+)
+
+// tagDefine declares playlist variables, the only version 8 feature
+// recognised here
+const tagDefine = "#EXT-X-DEFINE"
+
+// MinVersion returns the lowest protocol version that supports every
+// feature the playlist uses, following RFC 8216 section 7
+func (p *Playlist) MinVersion() int {
+	version := 1
+	need := func(v int) {
+		if v > version {
+			version = v
+		}
+	}
+
+	for _, tag := range p.Tags {
+		if tag.Name == tagDefine {
+			need(8)
+		}
+	}
+
+	if p.Type == PlaylistTypeMedia {
+		hasMap := false
+		if p.Media.IFramesOnly {
+			need(4)
+		}
+		for _, s := range p.Media.Segments {
+			if s.Duration != math.Trunc(s.Duration) {
+				need(3)
+			}
+			if s.ByteRange != "" {
+				need(4)
+			}
+			if s.Key != nil {
+				need(keyVersion(map[string]string{
+					AttrIV:                s.Key.IV,
+					AttrKeyFormat:         s.Key.KeyFormat,
+					AttrKeyFormatVersions: s.Key.KeyFormatVersions,
+				}))
+			}
+			if s.Map != nil {
+				hasMap = true
+			}
+		}
+
+		// EXT-X-MAP needs version 5 in I-frame playlists, 6 otherwise
+		if hasMap {
+			if p.Media.IFramesOnly {
+				need(5)
+			} else {
+				need(6)
+			}
+		}
+	}
+
+	if p.Type == PlaylistTypeMaster {
+		for _, groups := range p.Master.MediaGroups {
+			for _, g := range groups {
+				if strings.HasPrefix(g.InstreamID, "SERVICE") {
+					need(7)
+				}
+			}
+		}
+	}
+
+	return version
+}
+
+// OutputVersion returns the version written for the playlist: the declared
+// version, raised to the minimum its features require
+func (p *Playlist) OutputVersion() int {
+	if required := p.MinVersion(); required > p.Version {
+		return required
+	}
+	return p.Version
+}
+
+// keyVersion returns the version required by EXT-X-KEY attributes
+func keyVersion(attrs map[string]string) int {
+	switch {
+	case attrs[AttrKeyFormat] != "" || attrs[AttrKeyFormatVersions] != "":
+		return 5
+	case attrs[AttrIV] != "":
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,112 @@
+package hls
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlaylistVersion(t *testing.T) {
+	media := func(lines ...string) []string {
+		return append([]string{"#EXTM3U", "#EXT-X-TARGETDURATION:6"}, lines...)
+	}
+
+	tests := []struct {
+		name     string
+		lines    []string
+		wantMin  int
+		wantLine string // "" when no EXT-X-VERSION is written
+	}{
+		{"undeclared version 1", media("#EXTINF:6,", "seg.ts"), 1, ""},
+		{"declared version kept", media("#EXT-X-VERSION:3", "#EXTINF:6,", "seg.ts"), 1, "#EXT-X-VERSION:3"},
+		{"fractional durations", media("#EXTINF:5.005,", "seg.ts"), 3, "#EXT-X-VERSION:3"},
+		{"key IV", media(`#EXT-X-KEY:METHOD=AES-128,URI="k.key",IV=0x01`, "#EXTINF:6,", "seg.ts"), 2, "#EXT-X-VERSION:2"},
+		{"key format", media(`#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://k",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"`, "#EXTINF:6,", "seg.ts"), 5, "#EXT-X-VERSION:5"},
+		{"byte range", media("#EXTINF:6,", "#EXT-X-BYTERANGE:1000@0", "media.ts"), 4, "#EXT-X-VERSION:4"},
+		{"I-frames only", media("#EXT-X-I-FRAMES-ONLY", "#EXTINF:6,", "#EXT-X-BYTERANGE:1000@0", "media.ts"), 4, "#EXT-X-VERSION:4"},
+		{"map", media(`#EXT-X-MAP:URI="init.mp4"`, "#EXTINF:6,", "seg.mp4"), 6, "#EXT-X-VERSION:6"},
+		{"map in I-frame playlist", media("#EXT-X-I-FRAMES-ONLY", `#EXT-X-MAP:URI="init.mp4"`, "#EXTINF:6,", "#EXT-X-BYTERANGE:1000@0", "seg.mp4"), 5, "#EXT-X-VERSION:5"},
+		{"declared too low", media("#EXT-X-VERSION:3", `#EXT-X-MAP:URI="init.mp4"`, "#EXTINF:6,", "seg.mp4"), 6, "#EXT-X-VERSION:6"},
+		{"variable definitions", media(`#EXT-X-DEFINE:NAME="host",VALUE="cdn.example.com"`, "#EXTINF:6,", "seg.ts"), 8, "#EXT-X-VERSION:8"},
+		{"declared above minimum", media("#EXT-X-VERSION:9", "#EXTINF:6,", "seg.ts"), 1, "#EXT-X-VERSION:9"},
+		{
+			name: "closed caption services",
+			lines: []string{
+				"#EXTM3U",
+				`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="SERVICE1"`,
+				`#EXT-X-STREAM-INF:BANDWIDTH=1500000,CLOSED-CAPTIONS="cc"`,
+				"720p.m3u8",
+			},
+			wantMin:  7,
+			wantLine: "#EXT-X-VERSION:7",
+		},
+		{
+			name: "CEA-608 channels",
+			lines: []string{
+				"#EXTM3U",
+				`#EXT-X-MEDIA:TYPE=CLOSED-CAPTIONS,GROUP-ID="cc",NAME="English",INSTREAM-ID="CC1"`,
+				`#EXT-X-STREAM-INF:BANDWIDTH=1500000,CLOSED-CAPTIONS="cc"`,
+				"720p.m3u8",
+			},
+			wantMin: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			playlist, err := New().Parse(strings.NewReader(strings.Join(tt.lines, "\n")))
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := playlist.MinVersion(); got != tt.wantMin {
+				t.Errorf("MinVersion() = %d, want %d", got, tt.wantMin)
+			}
+
+			out := playlist.String()
+			if n := strings.Count(out, "#EXT-X-VERSION"); (tt.wantLine == "" && n != 0) || (tt.wantLine != "" && n != 1) {
+				t.Fatalf("%d EXT-X-VERSION tags written:\n%s", n, out)
+			}
+			if tt.wantLine != "" && !strings.HasPrefix(out, "#EXTM3U\n"+tt.wantLine+"\n") {
+				t.Errorf("output does not start with %s:\n%s", tt.wantLine, out)
+			}
+
+			// The written version survives another round trip
+			again, err := New().Parse(strings.NewReader(out))
+			if err != nil {
+				t.Fatalf("Parse output: %v", err)
+			}
+			if again.OutputVersion() != playlist.OutputVersion() {
+				t.Errorf("version %d after a round trip, want %d", again.OutputVersion(), playlist.OutputVersion())
+			}
+		})
+	}
+}
+
+func TestNewPlaylistVersionUndeclared(t *testing.T) {
+	playlist := NewPlaylist()
+	if playlist.Version != 0 {
+		t.Errorf("new playlist declares version %d", playlist.Version)
+	}
+	if out := playlist.String(); strings.Contains(out, "#EXT-X-VERSION") {
+		t.Errorf("empty playlist written with a version:\n%s", out)
+	}
+}
+
+func TestKeyVersion(t *testing.T) {
+	tests := []struct {
+		name  string
+		attrs map[string]string
+		want  int
+	}{
+		{"method and URI only", map[string]string{AttrMethod: "AES-128", AttrURI: "k.key"}, 1},
+		{"IV", map[string]string{AttrIV: "0x01"}, 2},
+		{"key format", map[string]string{AttrKeyFormat: "identity"}, 5},
+		{"key format versions", map[string]string{AttrKeyFormatVersions: "1/2"}, 5},
+		{"key format and IV", map[string]string{AttrIV: "0x01", AttrKeyFormat: "identity"}, 5},
+	}
+
+	for _, tt := range tests {
+		if got := keyVersion(tt.attrs); got != tt.want {
+			t.Errorf("%s: keyVersion() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -5,13 +5,16 @@
 // - Query parameter extraction
 // - Format detection
 // - Bearer token handling
+// - Precedence between header and query tokens
 
 package jwtheader
 
 import (
+	"encoding/base64"
 	"errors"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
@@ -26,10 +29,22 @@ var (
 	ErrInvalidTokenType = errors.New("invalid token type")
 )
 
+// Precedence decides which token is used when a request carries one in
+// both the header and the query
+type Precedence string
+
+const (
+	PreferHeader     Precedence = "header"     // The header token wins (default)
+	PreferQuery      Precedence = "query"      // The query token wins
+	PreferNonExpired Precedence = "nonExpired" // The header token unless it has expired and the query token has not
+)
+
 // Options for token extraction
 type ExtractOptions struct {
 	HeaderName string
 	ParamName  string
+	Precedence Precedence
+	Now        func() time.Time // Clock for PreferNonExpired; nil uses time.Now
 }
 
 // DefaultOptions creates default extraction options
@@ -70,21 +85,57 @@ func FromQuery(r *http.Request, paramName string) (string, error) {
 	return token, nil
 }
 
-// FromRequest extracts a JWT token from a request using the provided options
-// It tries the header first, then falls back to query parameters
+// FromRequest extracts a JWT token from a request using the provided options.
+// A token in only one place is used as is; when both the header and the
+// query carry one, opts.Precedence picks the token, the header by default.
 func FromRequest(r *http.Request, opts ExtractOptions) (string, error) {
-	// Try header first
-	token, err := FromHeader(r, opts.HeaderName)
-	if err == nil {
-		return token, nil
+	headerToken, err := FromHeader(r, opts.HeaderName)
+	if err != nil && err != ErrNoToken {
+		return "", err
 	}
+	queryToken, _ := FromQuery(r, opts.ParamName)
 	
-	if err != ErrNoToken {
-		return "", err
+	switch {
+	case headerToken == "" && queryToken == "":
+		return "", ErrNoToken
+	case headerToken == "":
+		return queryToken, nil
+	case queryToken == "" || headerToken == queryToken:
+		return headerToken, nil
 	}
 	
-	// Try query parameter
-	return FromQuery(r, opts.ParamName)
+	// Both are present and differ
+	switch opts.Precedence {
+	case PreferQuery:
+		return queryToken, nil
+	case PreferNonExpired:
+		now := time.Now()
+		if opts.Now != nil {
+			now = opts.Now()
+		}
+		if isExpired(headerToken, now) && !isExpired(queryToken, now) {
+			return queryToken, nil
+		}
+	}
+	return headerToken, nil
+}
+
+// isExpired reports whether a token's exp claim has passed, without
+// verifying it; the chosen token is verified later. Tokens whose claims
+// cannot be read count as expired.
+func isExpired(token string, now time.Time) bool {
+	if !IsValidJWT(token) {
+		return true
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.Split(token, ".")[1])
+	if err != nil {
+		return true
+	}
+	claims, err := parseClaims(payload)
+	if err != nil {
+		return true
+	}
+	return claims.ExpirationTime > 0 && now.Unix() >= claims.ExpirationTime
 }
 
 // IsValidJWT performs basic validation on a JWT token string
@@ -0,0 +1,68 @@
+package jwtheader
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// unsignedToken builds a token expiring at exp; signatures are not checked
+// during extraction
+func unsignedToken(exp int64) string {
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		enc.EncodeToString([]byte(fmt.Sprintf(`{"sub":"p","exp":%d}`, exp))) + ".c2ln"
+}
+
+func TestFromRequestPrecedence(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	fresh := unsignedToken(now.Add(time.Hour).Unix())
+	stale := unsignedToken(now.Add(-time.Hour).Unix())
+	staleToo := unsignedToken(now.Add(-time.Minute).Unix())
+
+	tests := []struct {
+		name       string
+		precedence Precedence
+		header     string
+		query      string
+		want       string
+		wantErr    error
+	}{
+		{"header only", PreferQuery, fresh, "", fresh, nil},
+		{"query only", PreferHeader, "", fresh, fresh, nil},
+		{"neither", PreferHeader, "", "", "", ErrNoToken},
+		{"default prefers header", "", stale, fresh, stale, nil},
+		{"header", PreferHeader, stale, fresh, stale, nil},
+		{"query", PreferQuery, fresh, stale, stale, nil},
+		{"non-expired skips a stale header", PreferNonExpired, stale, fresh, fresh, nil},
+		{"non-expired keeps a fresh header", PreferNonExpired, fresh, unsignedToken(now.Add(2 * time.Hour).Unix()), fresh, nil},
+		{"non-expired keeps the header when both expired", PreferNonExpired, stale, staleToo, stale, nil},
+		{"non-expired skips an unreadable header", PreferNonExpired, "garbage", fresh, fresh, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/live/index.m3u8", nil)
+			if tt.query != "" {
+				r.URL.RawQuery = "token=" + tt.query
+			}
+			if tt.header != "" {
+				r.Header.Set("Authorization", BearerPrefix+tt.header)
+			}
+			opts := DefaultOptions()
+			opts.Precedence = tt.precedence
+			opts.Now = func() time.Time { return now }
+
+			got, err := FromRequest(r, opts)
+			if err != tt.wantErr {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
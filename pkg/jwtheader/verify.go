@@ -2,11 +2,15 @@
 package jwtheader
 
 import (
+	"crypto"
 	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net/http"
 	"strings"
@@ -22,8 +26,13 @@ var (
 	ErrInvalidAlgorithm  = errors.New("unsupported signing algorithm")
 	ErrInvalidIssuer     = errors.New("invalid token issuer")
 	ErrInvalidAudience   = errors.New("invalid token audience")
+	ErrKeyUnavailable    = errors.New("signing key unavailable")
+	ErrJWKSTooLarge      = errors.New("JWKS response too large")
 )
 
+// DefaultMaxJWKSBytes is the JWKS response size accepted by FetchJWKS
+const DefaultMaxJWKSBytes = 1 << 20
+
 // JWTHeader represents the header of a JWT token
 type JWTHeader struct {
 	Algorithm string `json:"alg"`
@@ -69,8 +78,13 @@ type ValidationOptions struct {
 	RequiredClaims []string  // Claims that must be present
 	Issuer         string    // Expected issuer
 	Audience        string   // Expected audience
+	Issuers         []string // Accepted issuers, in addition to Issuer
+	Audiences       []string // Accepted audiences, in addition to Audience
 	ClaimsNamespace string   // Namespace for custom claims
 	AllowedAlgs     []string // Allowed signing algorithms
+	RequiredType    string   // Required typ header, e.g. "JWT" or "at+jwt"; empty accepts any
+	KeyFunc         func(kid string) (*rsa.PublicKey, error) // RSA key lookup for RS* tokens
+	Now             func() time.Time // Current time for expiry checks (default time.Now)
 }
 
 // ParseAndVerify parses a JWT token string and verifies its signature
@@ -103,6 +117,11 @@ func ParseAndVerify(tokenString string, opts ValidationOptions) (*JWTClaims, err
 		return nil, ErrInvalidAlgorithm
 	}
 	
+	// Verify the token type when one is required
+	if opts.RequiredType != "" && !typeMatches(header.Type, opts.RequiredType) {
+		return nil, ErrInvalidTokenType
+	}
+	
 	// Parse claims
 	claims, err := parseClaims(payloadBytes)
 	if err != nil {
@@ -117,27 +136,67 @@ func ParseAndVerify(tokenString string, opts ValidationOptions) (*JWTClaims, err
 	// Validate expiration
 	if claims.ExpirationTime > 0 {
 		now := time.Now().Unix()
+		if opts.Now != nil {
+			now = opts.Now().Unix()
+		}
 		if now > claims.ExpirationTime {
 			return nil, ErrTokenExpired
 		}
 	}
 	
 	// Validate issuer if specified
-	if opts.Issuer != "" && claims.Issuer != "" && claims.Issuer != opts.Issuer {
+	issuers := acceptedValues(opts.Issuer, opts.Issuers)
+	if len(issuers) > 0 && claims.Issuer != "" && !containsString(issuers, claims.Issuer) {
 		return nil, ErrInvalidIssuer
 	}
 	
 	// Validate audience if specified
-	if opts.Audience != "" && !hasAudience(claims, opts.Audience) {
+	audiences := acceptedValues(opts.Audience, opts.Audiences)
+	if len(audiences) > 0 && !hasAnyAudience(claims, audiences) {
 		return nil, ErrInvalidAudience
 	}
 	
-	// For this implementation, we'll skip actual signature verification
+	// Verify RSA signatures when a key source is configured
+	if opts.KeyFunc != nil && strings.HasPrefix(header.Algorithm, "RS") {
+		key, err := opts.KeyFunc(header.KeyID)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyRSA(header.Algorithm, parts[0]+"."+parts[1], parts[2], key); err != nil {
+			return nil, ErrInvalidSignature
+		}
+	}
+	
+	// For this implementation, we'll skip HMAC signature verification
 	// In a real implementation, this would verify the signature using the appropriate algorithm
 	
 	return claims, nil
 }
 
+// verifyRSA verifies a PKCS#1 v1.5 signature over the signing input
+func verifyRSA(alg, signingInput, signature string, key *rsa.PublicKey) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256":
+		hash = crypto.SHA256
+	case "RS384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return ErrInvalidAlgorithm
+	}
+	
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return err
+	}
+	
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	return rsa.VerifyPKCS1v15(key, hash, h.Sum(nil), sig)
+}
+
 // isAllowedAlgorithm checks if the algorithm is in the allowed list
 func isAllowedAlgorithm(alg string, allowed []string) bool {
 	if len(allowed) == 0 {
@@ -153,6 +212,18 @@ func isAllowedAlgorithm(alg string, allowed []string) bool {
 	return false
 }
 
+// typeMatches compares typ header values as media types: case-insensitively
+// and with the "application/" prefix optional (RFC 7515, section 4.1.9)
+func typeMatches(got, want string) bool {
+	trim := func(t string) string {
+		if len(t) > len("application/") && strings.EqualFold(t[:len("application/")], "application/") {
+			return t[len("application/"):]
+		}
+		return t
+	}
+	return got != "" && strings.EqualFold(trim(got), trim(want))
+}
+
 // parseClaims parses the JWT claims from the payload
 func parseClaims(payloadBytes []byte) (*JWTClaims, error) {
 	var claims JWTClaims
@@ -261,9 +332,44 @@ func hasAudience(claims *JWTClaims, expected string) bool {
 	return false
 }
 
-// fetchJWKS fetches a JWKS from the given URL
-func fetchJWKS(url string) (*JWKSet, error) {
-	resp, err := http.Get(url)
+// hasAnyAudience checks if the claims have one of the accepted audiences
+func hasAnyAudience(claims *JWTClaims, accepted []string) bool {
+	for _, expected := range accepted {
+		if hasAudience(claims, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptedValues merges a single configured value with a list of them
+func acceptedValues(single string, list []string) []string {
+	if single == "" {
+		return list
+	}
+	return append([]string{single}, list...)
+}
+
+// containsString reports whether values contains s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// FetchJWKS fetches a JWKS from the given URL, accepting responses of up
+// to DefaultMaxJWKSBytes. The client's timeout bounds the whole fetch.
+func FetchJWKS(client *http.Client, url string) (*JWKSet, error) {
+	return FetchJWKSLimit(client, url, DefaultMaxJWKSBytes)
+}
+
+// FetchJWKSLimit fetches a JWKS from the given URL, rejecting responses
+// larger than maxBytes with ErrJWKSTooLarge
+func FetchJWKSLimit(client *http.Client, url string, maxBytes int64) (*JWKSet, error) {
+	resp, err := client.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
 	}
@@ -272,15 +378,32 @@ func fetchJWKS(url string) (*JWKSet, error) {
 	if resp.StatusCode != http.StatusOK {
 		return nil, fmt.Errorf("failed to fetch JWKS: HTTP %d", resp.StatusCode)
 	}
+	if resp.ContentLength > maxBytes {
+		return nil, ErrJWKSTooLarge
+	}
+	
+	// Read one byte past the limit to tell a full body from a cut one
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	if int64(len(body)) > maxBytes {
+		return nil, ErrJWKSTooLarge
+	}
 	
 	var jwks JWKSet
-	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+	if err := json.Unmarshal(body, &jwks); err != nil {
 		return nil, fmt.Errorf("invalid JWKS format: %w", err)
 	}
 	
 	return &jwks, nil
 }
 
+// PublicKey returns the RSA public key described by the JWK
+func (jwk JWK) PublicKey() (*rsa.PublicKey, error) {
+	return jwkToRSA(jwk)
+}
+
 // jwkToRSA converts a JWK to an RSA public key
 func jwkToRSA(jwk JWK) (*rsa.PublicKey, error) {
 	if jwk.KeyType != "RSA" {
@@ -0,0 +1,71 @@
+package jwtheader
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchJWKSLimit(t *testing.T) {
+	const set = `{"keys":[]}`
+	padded := `{"keys":[],"pad":"` + strings.Repeat("x", 100) + `"}`
+
+	tests := []struct {
+		name     string
+		body     string
+		chunked  bool
+		maxBytes int64
+		wantErr  error
+	}{
+		{"within limit", set, false, 64, nil},
+		{"exactly the limit", set, false, int64(len(set)), nil},
+		{"declared length over limit", padded, false, 64, ErrJWKSTooLarge},
+		{"streamed body over limit", padded, true, 64, ErrJWKSTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.chunked {
+					// Flushing before the body is complete drops Content-Length
+					w.Write([]byte(tt.body[:1]))
+					w.(http.Flusher).Flush()
+					w.Write([]byte(tt.body[1:]))
+					return
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			_, err := FetchJWKSLimit(server.Client(), server.URL, tt.maxBytes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTypeMatches(t *testing.T) {
+	tests := []struct {
+		got, want string
+		match     bool
+	}{
+		{"JWT", "JWT", true},
+		{"jwt", "JWT", true},
+		{"application/at+jwt", "at+jwt", true},
+		{"at+jwt", "Application/AT+JWT", true},
+		{"JWT", "at+jwt", false},
+		{"", "JWT", false},
+		{"application/", "application/", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.got+"~"+tt.want, func(t *testing.T) {
+			if got := typeMatches(tt.got, tt.want); got != tt.match {
+				t.Errorf("typeMatches(%q, %q) = %v, want %v", tt.got, tt.want, got, tt.match)
+			}
+		})
+	}
+}